@@ -0,0 +1,550 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/config"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/control"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/gateway"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/events"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/node"
+)
+
+const (
+	peerWaitTimeout = 10 * time.Second
+	shutdownTimeout = 10 * time.Second
+)
+
+func runInit(opts *options, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	if _, err := os.Stat(opts.configPath); err == nil {
+		return fmt.Errorf("%s already exists", opts.configPath)
+	}
+	opts.creating = true
+
+	cfg, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+	for _, dir := range []string{cfg.Storage.DatabasePath, cfg.Storage.DownloadsDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	// the config is written last, a failed init can be run again and reuses
+	// the identity and token it already created
+	n, err := unlockNode(opts, cfg)
+	if err != nil {
+		return err
+	}
+	defer stopNode(n)
+	if cfg.API.TokenFile != "" {
+		if _, err := gateway.GenerateToken(cfg.API.TokenFile); err != nil {
+			return fmt.Errorf("generate gateway token: %w", err)
+		}
+	}
+	if err := cfg.Save(opts.configPath); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+
+	fmt.Printf("Config written to %s\n", opts.configPath)
+	if cfg.API.TokenFile != "" {
+		fmt.Printf("API token:  %s\n", cfg.API.TokenFile)
+	}
+	fmt.Printf("Peer ID:    %s\n", hex.EncodeToString(n.ID[:]))
+	fmt.Printf("Public key: %s\n", hex.EncodeToString(n.PubKey[:]))
+	return nil
+}
+
+func runNode(opts *options, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	opts.verbose = true
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	cfg, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	n, err := startNode(ctx, opts, cfg)
+	if err != nil {
+		return err
+	}
+
+	go printEvents(n.Subscribe(0, events.DropNewest, nil))
+
+	var ctrl *control.Server
+	if cfg.API.ControlSocket != "" {
+		lis, err := control.ListenUnix(cfg.API.ControlSocket)
+		if err != nil {
+			stopNode(n)
+			return fmt.Errorf("control socket: %w", err)
+		}
+		ctrl = control.NewServer(n)
+		go ctrl.Serve(lis)
+		n.Logger.Info("Control service listening", "socket", cfg.API.ControlSocket)
+	}
+
+	var gw *gateway.Server
+	if cfg.API.HTTPAddr != "" {
+		gw, err = startGateway(n, cfg)
+		if err != nil {
+			if ctrl != nil {
+				ctrl.Stop(context.Background())
+			}
+			stopNode(n)
+			return err
+		}
+	}
+
+	var metricsSrv *http.Server
+	if cfg.Metrics.ListenAddr != "" {
+		metricsSrv, err = startMetrics(n, cfg)
+		if err != nil {
+			if gw != nil {
+				gw.Stop(context.Background())
+			}
+			if ctrl != nil {
+				ctrl.Stop(context.Background())
+			}
+			stopNode(n)
+			return err
+		}
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for ctx.Err() == nil {
+		select {
+		case <-hup:
+			reloadConfig(opts, n)
+		case <-ctx.Done():
+		}
+	}
+
+	fmt.Println("\nShutting down EchoFog...")
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	if metricsSrv != nil {
+		metricsSrv.Shutdown(stopCtx)
+	}
+	if gw != nil {
+		gw.Stop(stopCtx)
+	}
+	if ctrl != nil {
+		ctrl.Stop(stopCtx)
+	}
+	stopCancel()
+	return stopNode(n)
+}
+
+func runID(opts *options, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	cfg, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if c := dialDaemon(cfg); c != nil {
+		defer c.Close()
+		return remoteID(c)
+	}
+
+	n, err := unlockNode(opts, cfg)
+	if err != nil {
+		return err
+	}
+	defer stopNode(n)
+
+	fmt.Printf("Peer ID:    %s\n", hex.EncodeToString(n.ID[:]))
+	fmt.Printf("Public key: %s\n", hex.EncodeToString(n.PubKey[:]))
+	if cfg.Identity.Callsign != "" {
+		fmt.Printf("Callsign:   %s\n", cfg.Identity.Callsign)
+	}
+	return nil
+}
+
+func runPeers(opts *options, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	cfg, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if c := dialDaemon(cfg); c != nil {
+		defer c.Close()
+		return remotePeers(c)
+	}
+
+	n, err := unlockNode(opts, cfg)
+	if err != nil {
+		return err
+	}
+	defer stopNode(n)
+
+	peers, err := n.KnownPeers()
+	if err != nil {
+		return err
+	}
+	printPeers(peers)
+	return nil
+}
+
+func runConnect(opts *options, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	cfg, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	if c := dialDaemon(cfg); c != nil {
+		defer c.Close()
+		return remoteConnect(ctx, c, args[0])
+	}
+
+	n, err := startNode(ctx, opts, cfg)
+	if err != nil {
+		return err
+	}
+	defer stopNode(n)
+
+	dialCtx, dialCancel := context.WithTimeout(ctx, peerWaitTimeout)
+	defer dialCancel()
+
+	peerID, err := n.Connect(dialCtx, args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Connected to %s (%s)\n", hex.EncodeToString(peerID[:]), args[0])
+	return nil
+}
+
+func runSend(opts *options, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	to, err := types.ParsePeerPublicKey(args[0])
+	if err != nil {
+		return err
+	}
+	cfg, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	if c := dialDaemon(cfg); c != nil {
+		defer c.Close()
+		return remoteSend(ctx, c, to, args[1])
+	}
+
+	n, err := startNode(ctx, opts, cfg)
+	if err != nil {
+		return err
+	}
+	defer stopNode(n)
+
+	if err := waitForPeers(ctx, n); err != nil {
+		return err
+	}
+
+	if err := n.SendMessage(ctx, to, []byte(args[1])); err != nil {
+		return err
+	}
+	fmt.Println("Message sent")
+	return nil
+}
+
+func runShare(opts *options, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	cfg, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if c := dialDaemon(cfg); c != nil {
+		defer c.Close()
+		return remoteShare(c, args[0])
+	}
+
+	n, err := unlockNode(opts, cfg)
+	if err != nil {
+		return err
+	}
+	defer stopNode(n)
+
+	hash, err := n.Share(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(hex.EncodeToString(hash[:]))
+	return nil
+}
+
+func runGet(opts *options, args []string) error {
+	if len(args) != 1 && len(args) != 2 {
+		return errUsage
+	}
+	hash, err := types.ParseContentHash(args[0])
+	if err != nil {
+		return err
+	}
+
+	cfg, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	out := filepath.Join(cfg.Storage.DownloadsDir, args[0])
+	if len(args) == 2 {
+		out = args[1]
+	}
+
+	if c := dialDaemon(cfg); c != nil {
+		defer c.Close()
+		return remoteGet(c, hash, out)
+	}
+
+	n, err := unlockNode(opts, cfg)
+	if err != nil {
+		return err
+	}
+	defer stopNode(n)
+
+	data, err := n.Get(hash)
+	if err != nil {
+		return fmt.Errorf("content %s not found: %w", args[0], err)
+	}
+
+	if err := os.WriteFile(out, data, 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("Saved %d bytes to %s\n", len(data), out)
+	return nil
+}
+
+func runConfig(opts *options, args []string) error {
+	if len(args) != 1 || args[0] != "show" {
+		return errUsage
+	}
+	cfg, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func newNode(opts *options, cfg *config.AppConfig) (*node.Node, error) {
+	n, err := node.NewNode(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	n.PasswordPrompt = readPassword
+
+	go func() {
+		for logEntry := range n.GetLogChannel() {
+			if opts.verbose {
+				fmt.Fprintf(os.Stderr, "[%s] %s: %s%s\n", logEntry.Time, logEntry.Level, logEntry.Message, formatAttrs(logEntry.Attrs))
+			}
+		}
+	}()
+
+	return n, nil
+}
+
+func formatAttrs(attrs map[string]string) string {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%s", k, attrs[k])
+	}
+	return b.String()
+}
+
+func unlockNode(opts *options, cfg *config.AppConfig) (*node.Node, error) {
+	n, err := newNode(opts, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := n.LoadIdentity(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func startNode(ctx context.Context, opts *options, cfg *config.AppConfig) (*node.Node, error) {
+	n, err := newNode(opts, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := n.Start(ctx); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// reloadConfig re-reads all config layers on SIGHUP, a broken config is
+// reported and the node keeps running with the current one
+func reloadConfig(opts *options, n *node.Node) {
+	cfg, err := opts.loadConfig()
+	if err == nil {
+		err = n.Reload(cfg)
+	}
+	if err != nil {
+		n.Logger.Error("Config reload failed", "err", err)
+	}
+}
+
+func startGateway(n *node.Node, cfg *config.AppConfig) (*gateway.Server, error) {
+	token, err := gateway.LoadToken(cfg.API.TokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("http gateway: %w", err)
+	}
+	lis, err := gateway.Listen(cfg.API.HTTPAddr)
+	if err != nil {
+		return nil, fmt.Errorf("http gateway: %w", err)
+	}
+
+	gw := gateway.NewServer(n, token)
+	go gw.Serve(lis)
+	n.Logger.Info("HTTP gateway listening", "addr", lis.Addr().String())
+	return gw, nil
+}
+
+func startMetrics(n *node.Node, cfg *config.AppConfig) (*http.Server, error) {
+	lis, err := net.Listen("tcp", cfg.Metrics.ListenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("metrics: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(cfg.Metrics.Path, n.Metrics)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go srv.Serve(lis)
+	n.Logger.Info("Metrics listening", "addr", lis.Addr().String(), "path", cfg.Metrics.Path)
+	return srv, nil
+}
+
+func stopNode(n *node.Node) error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := n.Stop(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "shutdown: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+func readPassword() ([]byte, error) {
+	fmt.Fprintln(os.Stderr, "Enter password to unlock identity:")
+	var password string
+	if _, err := fmt.Scanln(&password); err != nil {
+		return nil, fmt.Errorf("read password: %w", err)
+	}
+	return []byte(password), nil
+}
+
+func waitForPeers(ctx context.Context, n *node.Node) error {
+	ctx, cancel := context.WithTimeout(ctx, peerWaitTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for len(n.Peers()) == 0 {
+		select {
+		case <-ctx.Done():
+			return errors.New("no peers connected, check network.bootstrap_nodes")
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+func printPeers(peers []node.PeerInfo) {
+	if len(peers) == 0 {
+		fmt.Println("No peers")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PEER ID\tPUBLIC KEY\tADDRESS\tCALLSIGN\tLAST SEEN")
+	for _, p := range peers {
+		lastSeen := "-"
+		if !p.LastSeen.IsZero() {
+			lastSeen = p.LastSeen.Format(time.DateTime)
+		}
+		callsign := "-"
+		if p.Callsign != "" {
+			callsign = p.Callsign
+		}
+		fmt.Fprintf(w, "%x\t%s\t%s\t%s\t%s\n", p.ID[:8], hex.EncodeToString(p.PubKey[:]), p.Addr, callsign, lastSeen)
+	}
+	w.Flush()
+}
+
+func printEvents(sub *events.Subscription) {
+	for e := range sub.C {
+		switch ev := e.(type) {
+		case events.PeerConnected:
+			fmt.Printf("peer connected: %x %s\n", ev.PeerID[:8], ev.Addr)
+		case events.PeerDisconnected:
+			fmt.Printf("peer disconnected: %x (%s)\n", ev.PeerID[:8], ev.Reason)
+		case events.MessageReceived:
+			fmt.Printf("message from %s: %s\n", hex.EncodeToString(ev.From[:]), ev.Text)
+		case events.ContentAnnounced:
+			fmt.Printf("content announced: %s (%d bytes)\n", hex.EncodeToString(ev.Hash[:]), ev.Size)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/config"
+)
+
+func initOptions(t *testing.T, args ...string) *options {
+	t.Helper()
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	o := registerFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+// TestInitWithoutIdentityWritesNoConfig fails to unlock the identity, init
+// has to leave no config or token behind so it can be run again
+func TestInitWithoutIdentityWritesNoConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(config.DefaultPasswordEnv, "")
+	os.Unsetenv(config.DefaultPasswordEnv)
+	path := filepath.Join(t.TempDir(), "echofog.json")
+	tokenFile := filepath.Join(home, config.DefaultDataDirName, "api.token")
+	args := []string{"-config", path, "-password-source", "env"}
+
+	if err := runInit(initOptions(t, args...), nil); err == nil {
+		t.Fatal("init succeeded without a password")
+	}
+	for _, f := range []string{path, tokenFile} {
+		if _, err := os.Stat(f); !os.IsNotExist(err) {
+			t.Fatalf("%s exists after a failed init", f)
+		}
+	}
+
+	t.Setenv(config.DefaultPasswordEnv, "hunter2")
+	if err := runInit(initOptions(t, args...), nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{path, tokenFile} {
+		if _, err := os.Stat(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/config"
+)
+
+type options struct {
+	fs *flag.FlagSet
+
+	configPath   string
+	listenAddr   string
+	bootstrap    string
+	maxConns     int
+	enableMDNS   bool
+	enableTCP    bool
+	wsAddr       string
+	stunServers  string
+	dbPath       string
+	downloadsDir string
+	callsign     string
+	passwordSrc  string
+	passwordFile string
+	passwordFD   int
+	socket       string
+	httpAddr     string
+	logLevel     string
+	metricsAddr  string
+	role         string
+	verbose      bool
+
+	// creating is set by init, the config file does not exist yet
+	creating bool
+}
+
+func registerFlags(fs *flag.FlagSet) *options {
+	o := &options{fs: fs}
+
+	fs.StringVar(&o.configPath, "config", config.DefaultConfigName, "path to the config file")
+	fs.StringVar(&o.role, "role", "", "node role: full, relay, seed or light (node.role)")
+	fs.StringVar(&o.listenAddr, "listen", "", "UDP listen address (network.listen_addr)")
+	fs.StringVar(&o.bootstrap, "bootstrap", "", "comma separated bootstrap addresses (network.bootstrap_nodes)")
+	fs.IntVar(&o.maxConns, "max-conns", 0, "maximum number of connections (network.max_connections)")
+	fs.BoolVar(&o.enableMDNS, "mdns", false, "enable local discovery via mDNS (network.enable_mdns)")
+	fs.BoolVar(&o.enableTCP, "tcp", false, "listen on TCP and fall back to it when QUIC fails (network.enable_tcp)")
+	fs.StringVar(&o.wsAddr, "ws", "", "address to accept WebSocket peers on (network.websocket_addr)")
+	fs.StringVar(&o.stunServers, "stun", "", "comma separated STUN servers, empty disables NAT detection (network.stun_servers)")
+	fs.StringVar(&o.dbPath, "db", "", "database directory (storage.database_path)")
+	fs.StringVar(&o.downloadsDir, "downloads", "", "downloads directory (storage.downloads_dir)")
+	fs.StringVar(&o.callsign, "callsign", "", "human readable node name (identity.callsign)")
+	fs.StringVar(&o.passwordSrc, "password-source", "", "identity password source: prompt, env, file, fd or dev (identity.password_source)")
+	fs.StringVar(&o.passwordFile, "password-file", "", "file holding the identity password (identity.password_file)")
+	fs.IntVar(&o.passwordFD, "password-fd", 0, "file descriptor to read the identity password from (identity.password_fd)")
+	fs.StringVar(&o.socket, "socket", "", "control socket of a running node (api.control_socket)")
+	fs.StringVar(&o.httpAddr, "http", "", "loopback address of the HTTP gateway (api.http_addr)")
+	fs.StringVar(&o.metricsAddr, "metrics", "", "address to serve Prometheus metrics on (metrics.listen_addr)")
+	fs.StringVar(&o.logLevel, "log-level", "", "debug, info, warn or error (log.level)")
+	fs.BoolVar(&o.verbose, "v", false, "print node logs to stderr")
+
+	return o
+}
+
+// loadConfig layers defaults, the config file, ECHOFOG_* variables and the
+// flags that were set explicitly, then validates the result. Without -config
+// a missing echofog.json just means defaults.
+func (o *options) loadConfig() (*config.AppConfig, error) {
+	path := o.configPath
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) && (o.creating || !o.isSet("config")) {
+		path = ""
+	}
+
+	dataDir, err := config.DefaultDataDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.LoadLayers(path, dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	o.fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "role":
+			cfg.Node.Role = o.role
+		case "listen":
+			cfg.Network.ListenAddr = o.listenAddr
+		case "bootstrap":
+			cfg.Network.BootstrapNodes = splitList(o.bootstrap)
+		case "max-conns":
+			cfg.Network.MaxConnections = o.maxConns
+		case "mdns":
+			cfg.Network.EnableMDNS = o.enableMDNS
+		case "tcp":
+			cfg.Network.EnableTCP = o.enableTCP
+		case "ws":
+			cfg.Network.WebSocketAddr = o.wsAddr
+		case "stun":
+			cfg.Network.StunServers = splitList(o.stunServers)
+		case "db":
+			cfg.Storage.DatabasePath = o.dbPath
+		case "downloads":
+			cfg.Storage.DownloadsDir = o.downloadsDir
+		case "callsign":
+			cfg.Identity.Callsign = o.callsign
+		case "password-source":
+			cfg.Identity.PasswordSource = o.passwordSrc
+		case "password-file":
+			cfg.Identity.PasswordFile = o.passwordFile
+			cfg.Identity.PasswordSource = config.PasswordSourceFile
+		case "socket":
+			cfg.API.ControlSocket = o.socket
+		case "http":
+			cfg.API.HTTPAddr = o.httpAddr
+		case "password-fd":
+			cfg.Identity.PasswordFD = o.passwordFD
+			cfg.Identity.PasswordSource = config.PasswordSourceFD
+		case "metrics":
+			cfg.Metrics.ListenAddr = o.metricsAddr
+		case "log-level":
+			cfg.Log.Level = o.logLevel
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
+	return cfg, nil
+}
+
+func (o *options) isSet(name string) bool {
+	set := false
+	o.fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func splitList(s string) []string {
+	var res []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/config"
+)
+
+// TestFlagsOverrideEnvAndFile sets listen_addr in every layer, the flag
+// wins, keys without a flag keep the value of the layers below
+func TestFlagsOverrideEnvAndFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "echofog.json")
+	data := `{"network": {"listen_addr": ":4000", "max_connections": 60}, "conn_manager": {"low_water": 10, "high_water": 20}}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ECHOFOG_NETWORK_LISTEN_ADDR", ":5000")
+	t.Setenv("ECHOFOG_NETWORK_MAX_CONNECTIONS", "50")
+	t.Setenv("ECHOFOG_LOG_LEVEL", "debug")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	o := registerFlags(fs)
+	if err := fs.Parse([]string{"-config", path, "-listen", ":6000", "-password-fd", "3"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := o.loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Network.ListenAddr != ":6000" {
+		t.Errorf("listen_addr = %q, want the flag", cfg.Network.ListenAddr)
+	}
+	if cfg.Network.MaxConnections != 50 || cfg.Log.Level != "debug" {
+		t.Errorf("max_connections = %d and log.level = %q, want the environment", cfg.Network.MaxConnections, cfg.Log.Level)
+	}
+	if cfg.ConnManager.HighWater != 20 {
+		t.Errorf("high_water = %d, want the file", cfg.ConnManager.HighWater)
+	}
+	if cfg.Identity.PasswordSource != config.PasswordSourceFD || cfg.Identity.PasswordFD != 3 {
+		t.Errorf("password source %q fd %d, want fd 3", cfg.Identity.PasswordSource, cfg.Identity.PasswordFD)
+	}
+	if cfg.Metrics.Path != "/metrics" {
+		t.Errorf("metrics.path = %q, want the default", cfg.Metrics.Path)
+	}
+}
+
+func TestFlagsAreValidated(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "echofog.json")
+	if err := os.WriteFile(path, []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	o := registerFlags(fs)
+	if err := fs.Parse([]string{"-config", path, "-role", "router"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.loadConfig(); err == nil || !strings.Contains(err.Error(), `node.role: unknown role "router"`) {
+		t.Fatalf("loadConfig returned %v, want the role rejected", err)
+	}
+}
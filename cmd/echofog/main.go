@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/crypto"
+)
+
+type command struct {
+	usage string
+	help  string
+	run   func(opts *options, args []string) error
+}
+
+var commands = map[string]command{
+	"init":    {usage: "init", help: "create the config file, data directories and identity", run: runInit},
+	"run":     {usage: "run", help: "start the node and serve the control socket until interrupted", run: runNode},
+	"id":      {usage: "id", help: "print the identity of this node", run: runID},
+	"peers":   {usage: "peers", help: "list peers known from previous sessions", run: runPeers},
+	"connect": {usage: "connect <addr>", help: "dial a peer and report its ID", run: runConnect},
+	"send":    {usage: "send <pubkey> <text>", help: "send an encrypted chat message", run: runSend},
+	"share":   {usage: "share <file>", help: "store a file in the content DAG and print its hash", run: runShare},
+	"get":     {usage: "get <hash> [output]", help: "read content by hash from the local content DAG, it is not fetched from peers", run: runGet},
+	"config":  {usage: "config show", help: "print the effective configuration", run: runConfig},
+}
+
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage()
+		return
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		printUsage()
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	opts := registerFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: echofog %s [flags]\n\n%s\n\nFlags:\n", cmd.usage, cmd.help)
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(os.Args[2:])
+
+	if err := cmd.run(opts, fs.Args()); err != nil {
+		if errors.Is(err, errUsage) {
+			fs.Usage()
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "echofog %s: %v\n", name, err)
+		if errors.Is(err, crypto.ErrWrongPassword) {
+			os.Exit(3)
+		}
+		os.Exit(1)
+	}
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: echofog <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-22s %s\n", commands[name].usage, commands[name].help)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run 'echofog <command> -h' for the flags of a command.")
+}
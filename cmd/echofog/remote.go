@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/config"
+	"github.com/DmytroBuzhylov/echofog-core/internal/network"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/control"
+	api_pb "github.com/DmytroBuzhylov/echofog-core/pkg/api/proto"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/node"
+)
+
+// dialDaemon returns a client when a node started with `echofog run` is
+// listening on the control socket, nil otherwise
+func dialDaemon(cfg *config.AppConfig) *control.Client {
+	if cfg.API.ControlSocket == "" {
+		return nil
+	}
+	if _, err := os.Stat(cfg.API.ControlSocket); err != nil {
+		return nil
+	}
+
+	c, err := control.Dial(context.Background(), cfg.API.ControlSocket)
+	if err != nil {
+		return nil
+	}
+	return c
+}
+
+func remoteID(c *control.Client) error {
+	res, err := c.GetIdentity(context.Background(), &api_pb.GetIdentityRequest{})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Peer ID:    %s\n", hex.EncodeToString(res.GetPeerId()))
+	fmt.Printf("Public key: %s\n", hex.EncodeToString(res.GetPubKey()))
+	if res.GetCallsign() != "" {
+		fmt.Printf("Callsign:   %s\n", res.GetCallsign())
+	}
+	for _, addr := range res.GetListenAddrs() {
+		fmt.Printf("Listening:  %s\n", addr)
+	}
+	for _, addr := range res.GetPublicAddrs() {
+		fmt.Printf("Public:     %s\n", addr)
+	}
+	fmt.Printf("NAT:        %s\n", res.GetNatMapping())
+	return nil
+}
+
+func remotePeers(c *control.Client) error {
+	res, err := c.ListPeers(context.Background(), &api_pb.ListPeersRequest{})
+	if err != nil {
+		return err
+	}
+
+	peers := make([]node.PeerInfo, 0, len(res.GetPeers()))
+	for _, p := range res.GetPeers() {
+		id, _ := types.ToPeerID(p.GetPeerId())
+		pubKey, _ := types.ToPeerPublicKey(p.GetPubKey())
+		info := node.PeerInfo{
+			ID:       id,
+			PubKey:   pubKey,
+			Addr:     p.GetAddress(),
+			Outbound: p.GetOutbound(),
+			Version:  p.GetProtocolVersion(),
+			Caps:     network.Capabilities(p.GetCapabilities()),
+			Agent:    p.GetAgent(),
+			Role:     p.GetRole(),
+			Callsign: p.GetCallsign(),
+		}
+		if p.GetLastSeen() != 0 {
+			info.LastSeen = time.Unix(0, int64(p.GetLastSeen()))
+		}
+		peers = append(peers, info)
+	}
+	printPeers(peers)
+	return nil
+}
+
+func remoteConnect(ctx context.Context, c *control.Client, addr string) error {
+	ctx, cancel := context.WithTimeout(ctx, peerWaitTimeout)
+	defer cancel()
+
+	res, err := c.ConnectPeer(ctx, &api_pb.ConnectPeerRequest{Address: addr})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Connected to %s (%s)\n", hex.EncodeToString(res.GetPeerId()), addr)
+	return nil
+}
+
+func remoteSend(ctx context.Context, c *control.Client, to types.PeerPublicKey, text string) error {
+	_, err := c.SendMessage(ctx, &api_pb.SendMessageRequest{
+		ToPubKey: to[:],
+		Text:     []byte(text),
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Message sent")
+	return nil
+}
+
+func remoteShare(c *control.Client, path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.ShareContent(context.Background(), &api_pb.ShareContentRequest{Path: abs})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(hex.EncodeToString(res.GetRootHash()))
+	return nil
+}
+
+func remoteGet(c *control.Client, hash types.ContentHash, out string) error {
+	stream, err := c.FetchContent(context.Background(), &api_pb.FetchContentRequest{RootHash: hash[:]})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var written int64
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			os.Remove(out)
+			return err
+		}
+		n, err := f.Write(chunk.GetData())
+		if err != nil {
+			return err
+		}
+		written += int64(n)
+	}
+
+	fmt.Printf("Saved %d bytes to %s\n", written, out)
+	return nil
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix starts the environment variables that override config keys:
+// network.listen_addr is set by ECHOFOG_NETWORK_LISTEN_ADDR, lists are comma separated
+const EnvPrefix = "ECHOFOG_"
+
+// LoadConfig returns the validated config built from the defaults for
+// dataDir, the file at path and the ECHOFOG_* environment variables, in that
+// order. An empty path skips the file, a missing file is an error.
+func LoadConfig(path, dataDir string) (*AppConfig, error) {
+	cfg, err := LoadLayers(path, dataDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadLayers is LoadConfig without validation, for callers that apply more
+// overrides, such as command line flags, and call Validate themselves.
+func LoadLayers(path, dataDir string) (*AppConfig, error) {
+	cfg := DefaultConfigIn(dataDir)
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// loadFile decodes the file on top of the current values, so keys missing
+// from the file keep their defaults. Unknown keys are rejected.
+func (c *AppConfig) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("%s: %w", path, describeJSONError(data, err))
+	}
+	if dec.More() {
+		return fmt.Errorf("%s: unexpected data after the config object", path)
+	}
+	return nil
+}
+
+func describeJSONError(data []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("line %d: %s", lineOf(data, syntaxErr.Offset), syntaxErr.Error())
+	case errors.As(err, &typeErr):
+		return fmt.Errorf("line %d: %s must be %s, got %s",
+			lineOf(data, typeErr.Offset), typeErr.Field, typeErr.Type, typeErr.Value)
+	default:
+		return err
+	}
+}
+
+func lineOf(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// applyEnv walks the two level section/key structure of AppConfig and
+// overrides every field that has a matching ECHOFOG_<SECTION>_<KEY> variable
+func (c *AppConfig) applyEnv(lookup func(string) (string, bool)) error {
+	root := reflect.ValueOf(c).Elem()
+
+	for i := 0; i < root.NumField(); i++ {
+		section := root.Field(i)
+		sectionKey := jsonKey(root.Type().Field(i))
+
+		for j := 0; j < section.NumField(); j++ {
+			key := jsonKey(section.Type().Field(j))
+			name := EnvPrefix + strings.ToUpper(sectionKey+"_"+key)
+
+			value, ok := lookup(name)
+			if !ok {
+				continue
+			}
+			if err := setField(section.Field(j), value); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		field.SetInt(n)
+	case reflect.Uint32:
+		n, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", value)
+		}
+		field.SetUint(n)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+func jsonKey(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// Changed returns the keys, such as "network.listen_addr", whose values
+// differ between a and b
+func Changed(a, b *AppConfig) []string {
+	var keys []string
+
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	for i := 0; i < va.NumField(); i++ {
+		sectionKey := jsonKey(va.Type().Field(i))
+		sa, sb := va.Field(i), vb.Field(i)
+
+		for j := 0; j < sa.NumField(); j++ {
+			if !reflect.DeepEqual(sa.Field(j).Interface(), sb.Field(j).Interface()) {
+				keys = append(keys, sectionKey+"."+jsonKey(sa.Type().Field(j)))
+			}
+		}
+	}
+	return keys
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "echofog.json")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestLoadLayers sets keys in the file and the environment, the environment
+// wins over the file and the file over the defaults
+func TestLoadLayers(t *testing.T) {
+	path := writeConfig(t, `{
+		"node": {"role": "relay"},
+		"network": {"listen_addr": ":4000", "max_connections": 50},
+		"conn_manager": {"low_water": 10, "high_water": 20}
+	}`)
+	t.Setenv("ECHOFOG_NETWORK_LISTEN_ADDR", ":5000")
+	t.Setenv("ECHOFOG_NETWORK_ENABLE_MDNS", "false")
+	t.Setenv("ECHOFOG_NETWORK_BOOTSTRAP_NODES", "a.example:1, b.example:2,")
+	t.Setenv("ECHOFOG_CONN_MANAGER_HIGH_WATER", "30")
+
+	cfg, err := LoadConfig(path, "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := DefaultConfigIn("data")
+	want.Node.Role = RoleRelay
+	want.Network.ListenAddr = ":5000"
+	want.Network.MaxConnections = 50
+	want.Network.EnableMDNS = false
+	want.Network.BootstrapNodes = []string{"a.example:1", "b.example:2"}
+	want.ConnManager.LowWater = 10
+	want.ConnManager.HighWater = 30
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("config differs from the layers in %v", Changed(cfg, want))
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		err  string
+	}{
+		{"unknown key", `{"network": {"listen": ":4000"}}`, nil, `unknown field "listen"`},
+		{"wrong type", "{\n\"network\": {\"max_connections\": \"many\"}}", nil, "line 2: network.max_connections must be int"},
+		{"syntax", "{\n\n\"network\": }", nil, "line 3"},
+		{"trailing data", `{} {}`, nil, "unexpected data after the config object"},
+		{"invalid env boolean", `{}`, map[string]string{"ECHOFOG_NETWORK_ENABLE_TCP": "maybe"}, `ECHOFOG_NETWORK_ENABLE_TCP: invalid boolean "maybe"`},
+		{"invalid env integer", `{}`, map[string]string{"ECHOFOG_NETWORK_MAX_CONNECTIONS": "lots"}, `ECHOFOG_NETWORK_MAX_CONNECTIONS: invalid integer "lots"`},
+		{"invalid after the layers", `{"node": {"role": "relay"}}`, map[string]string{"ECHOFOG_NODE_ROLE": "router"}, `node.role: unknown role "router"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, err := LoadConfig(writeConfig(t, tt.file), "data")
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("LoadConfig returned %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json"), "data"); err == nil {
+		t.Fatal("missing config file accepted")
+	}
+	cfg, err := LoadConfig("", "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, DefaultConfigIn("data")) {
+		t.Fatal("no config file did not give the defaults")
+	}
+}
@@ -2,30 +2,102 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 )
 
 const (
-	CurrentProtocolVersion uint32 = 100
+	// CurrentProtocolVersion is the newest protocol version this build
+	// speaks, the Version constants of internal/network list the changes
+	CurrentProtocolVersion uint32 = 106
 	DefaultConfigName             = "echofog.json"
+	DefaultDataDirName            = ".echofog"
 	AppName                       = "EchoFog"
+	DefaultPasswordEnv            = "ECHOFOG_PASSWORD"
+)
+
+// MinProtocolVersion is the oldest protocol version this build still speaks,
+// the first one that signs the announced versions in the handshake
+const MinProtocolVersion uint32 = 101
+
+// Password sources for identity.password_source
+const (
+	PasswordSourcePrompt = "prompt"
+	PasswordSourceEnv    = "env"
+	PasswordSourceFile   = "file"
+	PasswordSourceFD     = "fd"
+	// PasswordSourceDev stores the identity key unencrypted, for local development only
+	PasswordSourceDev = "dev"
+)
+
+// Node roles for node.role, see node.Profile
+const (
+	RoleFull  = "full"
+	RoleRelay = "relay"
+	RoleSeed  = "seed"
+	RoleLight = "light"
 )
 
 type AppConfig struct {
 	Identity struct {
-		Callsign string `json:"callsign"`
-		KeyPath  string `json:"key_path"`
+		Callsign       string `json:"callsign"`
+		KeyPath        string `json:"key_path"`
+		PasswordSource string `json:"password_source"`
+		PasswordEnv    string `json:"password_env,omitempty"`
+		PasswordFile   string `json:"password_file,omitempty"`
+		PasswordFD     int    `json:"password_fd,omitempty"`
 	} `json:"identity"`
 
+	Node struct {
+		Role string `json:"role"`
+	} `json:"node"`
+
 	Network struct {
-		ListenAddr      string   `json:"listen_addr"`
-		BootstrapNodes  []string `json:"bootstrap_nodes"`
-		MaxConnections  int      `json:"max_connections"`
-		ProtocolVersion uint32   `json:"protocol_version"`
-		EnableMDNS      bool     `json:"enable_mdns"`
+		ListenAddr     string   `json:"listen_addr"`
+		BootstrapNodes []string `json:"bootstrap_nodes"`
+		MaxConnections int      `json:"max_connections"`
+		// ProtocolVersion caps the versions the node announces, 0 is
+		// CurrentProtocolVersion, see AppConfig.ProtocolVersion
+		ProtocolVersion uint32 `json:"protocol_version,omitempty"`
+		EnableMDNS      bool   `json:"enable_mdns"`
+		// EnableTCP listens on TCP at the port of listen_addr too and falls back to it when QUIC dials fail
+		EnableTCP bool `json:"enable_tcp"`
+		// WebSocketAddr accepts WebSocket peers such as browsers, empty disables it
+		WebSocketAddr string `json:"websocket_addr"`
+		// StunServers tell us our public address, two of them also classify
+		// the NAT mapping, empty disables it
+		StunServers []string `json:"stun_servers"`
 	} `json:"network"`
 
+	// ConnManager trims the connections of least value down to low_water
+	// once there are more than high_water, which must not exceed
+	// network.max_connections
+	ConnManager struct {
+		LowWater  int `json:"low_water"`
+		HighWater int `json:"high_water"`
+		// GracePeriodSeconds keeps new connections from being trimmed
+		GracePeriodSeconds int `json:"grace_period_seconds"`
+	} `json:"conn_manager"`
+
+	// Relay limits the circuits this node relays for peers that cannot be
+	// dialed. The relay role always serves them, the full role only with
+	// Enabled set
+	Relay struct {
+		Enabled            bool `json:"enabled"`
+		MaxReservations    int  `json:"max_reservations"`
+		MaxCircuits        int  `json:"max_circuits"`
+		MaxCircuitsPerPeer int  `json:"max_circuits_per_peer"`
+		MaxCircuitBytes    int  `json:"max_circuit_bytes"`
+		MaxCircuitSeconds  int  `json:"max_circuit_seconds"`
+		// MaxPeerBytes bounds what is relayed from and to one peer per hour
+		// over all its circuits
+		MaxPeerBytes int `json:"max_peer_bytes"`
+		// Reservations is how many relays this node keeps a slot on to be
+		// reachable through them, 0 disables it
+		Reservations int `json:"reservations"`
+	} `json:"relay"`
+
 	Storage struct {
 		DatabasePath string `json:"database_path"`
 		DownloadsDir string `json:"downloads_dir"`
@@ -35,24 +107,34 @@ type AppConfig struct {
 		AnonymousMode bool `json:"anonymous_mode"`
 		HideTraffic   bool `json:"hide_traffic"`
 	} `json:"security"`
-}
 
-func LoadConfig(path string) (*AppConfig, error) {
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return DefaultConfig(), nil
-	}
-
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
+	API struct {
+		// ControlSocket is the Unix socket of the gRPC control service, empty disables it
+		ControlSocket string `json:"control_socket"`
+		// HTTPAddr is the loopback address of the HTTP/JSON gateway, empty disables it
+		HTTPAddr string `json:"http_addr"`
+		// TokenFile holds the gateway access token generated by `echofog init`
+		TokenFile string `json:"token_file"`
+	} `json:"api"`
+
+	Metrics struct {
+		// ListenAddr serves Prometheus metrics over HTTP, empty disables it
+		ListenAddr string `json:"listen_addr"`
+		Path       string `json:"path"`
+	} `json:"metrics"`
+
+	Log struct {
+		// Level is one of debug, info, warn or error
+		Level string `json:"level"`
+	} `json:"log"`
+}
 
-	cfg := &AppConfig{}
-	if err := json.Unmarshal(data, cfg); err != nil {
-		return nil, err
+// ProtocolVersion is the newest protocol version the node announces
+func (c *AppConfig) ProtocolVersion() uint32 {
+	if c.Network.ProtocolVersion == 0 {
+		return CurrentProtocolVersion
 	}
-
-	return cfg, nil
+	return c.Network.ProtocolVersion
 }
 
 func (c *AppConfig) Save(path string) error {
@@ -63,23 +145,59 @@ func (c *AppConfig) Save(path string) error {
 	return os.WriteFile(path, data, 0644)
 }
 
+// DefaultConfig returns the built-in defaults with every path inside
+// DefaultDataDirName, relative to the working directory. It has no side
+// effects, directories are created by `echofog init` and the storage.
 func DefaultConfig() *AppConfig {
+	return DefaultConfigIn(DefaultDataDirName)
+}
+
+// DefaultConfigIn returns the built-in defaults with every path inside
+// dataDir, nodes running in one process need a dataDir each
+func DefaultConfigIn(dataDir string) *AppConfig {
 	cfg := &AppConfig{}
 
+	cfg.Identity.PasswordSource = PasswordSourcePrompt
+
+	cfg.Node.Role = RoleFull
+
 	cfg.Network.ListenAddr = ":0"
 	cfg.Network.MaxConnections = 100
-	cfg.Network.ProtocolVersion = CurrentProtocolVersion
 	cfg.Network.EnableMDNS = true
+	cfg.Network.EnableTCP = true
+	cfg.Network.StunServers = []string{"stun.l.google.com:19302", "stun1.l.google.com:19302"}
 
-	home, _ := os.UserHomeDir()
-	appDir := filepath.Join(home, ".echofog")
+	cfg.ConnManager.LowWater = 64
+	cfg.ConnManager.HighWater = 96
+	cfg.ConnManager.GracePeriodSeconds = 30
 
-	cfg.Storage.DatabasePath = filepath.Join(appDir, "db")
-	cfg.Storage.DownloadsDir = filepath.Join(home, "Downloads", "EchoFog")
-	cfg.Identity.KeyPath = filepath.Join(appDir, "identity.key")
+	cfg.Relay.MaxReservations = 128
+	cfg.Relay.MaxCircuits = 256
+	cfg.Relay.MaxCircuitsPerPeer = 8
+	cfg.Relay.MaxCircuitBytes = 128 << 20
+	cfg.Relay.MaxCircuitSeconds = 30 * 60
+	cfg.Relay.MaxPeerBytes = 512 << 20
 
-	_ = os.MkdirAll(cfg.Storage.DatabasePath, 0755)
-	_ = os.MkdirAll(cfg.Storage.DownloadsDir, 0755)
+	cfg.Storage.DatabasePath = filepath.Join(dataDir, "db")
+	cfg.Storage.DownloadsDir = filepath.Join(dataDir, "downloads")
+	cfg.Identity.KeyPath = filepath.Join(dataDir, "identity.key")
+	cfg.API.ControlSocket = filepath.Join(dataDir, "control.sock")
+	cfg.API.HTTPAddr = "127.0.0.1:7380"
+	cfg.API.TokenFile = filepath.Join(dataDir, "api.token")
+
+	cfg.Metrics.Path = "/metrics"
+
+	cfg.Log.Level = "info"
 
 	return cfg
 }
+
+// DefaultDataDir is DefaultDataDirName in the home directory of the user,
+// the data directory of the echofog command
+func DefaultDataDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locate data directory: %w", err)
+	}
+	return filepath.Join(home, DefaultDataDirName), nil
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Validate reports every invalid value at once, each prefixed with its key
+func (c *AppConfig) Validate() error {
+	var errs []error
+	check := func(key string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+
+	switch c.Identity.PasswordSource {
+	case PasswordSourcePrompt, PasswordSourceEnv, PasswordSourceDev:
+	case PasswordSourceFile:
+		if c.Identity.PasswordFile == "" {
+			check("identity.password_file", errors.New("required when password_source is file"))
+		}
+	case PasswordSourceFD:
+		if c.Identity.PasswordFD < 3 {
+			check("identity.password_fd", errors.New("must be 3 or greater when password_source is fd"))
+		}
+	default:
+		check("identity.password_source", fmt.Errorf("unknown source %q, expected prompt, env, file, fd or dev", c.Identity.PasswordSource))
+	}
+
+	switch c.Node.Role {
+	case RoleFull, RoleRelay, RoleSeed, RoleLight:
+	default:
+		check("node.role", fmt.Errorf("unknown role %q, expected full, relay, seed or light", c.Node.Role))
+	}
+
+	check("network.listen_addr", checkAddr(c.Network.ListenAddr, false))
+	for i, addr := range c.Network.BootstrapNodes {
+		check(fmt.Sprintf("network.bootstrap_nodes[%d]", i), checkDialAddr(addr))
+	}
+	if c.Network.WebSocketAddr != "" {
+		check("network.websocket_addr", checkAddr(c.Network.WebSocketAddr, false))
+	}
+	for i, addr := range c.Network.StunServers {
+		check(fmt.Sprintf("network.stun_servers[%d]", i), checkAddr(addr, true))
+	}
+	if c.Network.MaxConnections <= 0 {
+		check("network.max_connections", fmt.Errorf("must be positive, got %d", c.Network.MaxConnections))
+	}
+	if v := c.Network.ProtocolVersion; v != 0 && (v < MinProtocolVersion || v > CurrentProtocolVersion) {
+		check("network.protocol_version", fmt.Errorf("must be between %d and %d or unset for the current version, got %d", MinProtocolVersion, CurrentProtocolVersion, v))
+	}
+
+	if c.ConnManager.LowWater <= 0 {
+		check("conn_manager.low_water", fmt.Errorf("must be positive, got %d", c.ConnManager.LowWater))
+	}
+	if c.ConnManager.HighWater < c.ConnManager.LowWater {
+		check("conn_manager.high_water", fmt.Errorf("must be at least low_water %d, got %d", c.ConnManager.LowWater, c.ConnManager.HighWater))
+	}
+	if c.Network.MaxConnections > 0 && c.ConnManager.HighWater > c.Network.MaxConnections {
+		check("conn_manager.high_water", fmt.Errorf("must not exceed network.max_connections %d, got %d", c.Network.MaxConnections, c.ConnManager.HighWater))
+	}
+	if c.ConnManager.GracePeriodSeconds < 0 {
+		check("conn_manager.grace_period_seconds", fmt.Errorf("must not be negative, got %d", c.ConnManager.GracePeriodSeconds))
+	}
+
+	for _, limit := range []struct {
+		key   string
+		value int
+	}{
+		{"relay.max_reservations", c.Relay.MaxReservations},
+		{"relay.max_circuits", c.Relay.MaxCircuits},
+		{"relay.max_circuits_per_peer", c.Relay.MaxCircuitsPerPeer},
+		{"relay.max_circuit_bytes", c.Relay.MaxCircuitBytes},
+		{"relay.max_circuit_seconds", c.Relay.MaxCircuitSeconds},
+		{"relay.max_peer_bytes", c.Relay.MaxPeerBytes},
+	} {
+		if limit.value <= 0 {
+			check(limit.key, fmt.Errorf("must be positive, got %d", limit.value))
+		}
+	}
+	if c.Relay.Reservations < 0 {
+		check("relay.reservations", fmt.Errorf("must not be negative, got %d", c.Relay.Reservations))
+	}
+
+	if c.Storage.DatabasePath == "" {
+		check("storage.database_path", errors.New("required"))
+	}
+
+	if c.API.HTTPAddr != "" {
+		check("api.http_addr", checkAddr(c.API.HTTPAddr, false))
+		if c.API.TokenFile == "" {
+			check("api.token_file", errors.New("required when http_addr is set"))
+		}
+	}
+
+	if c.Metrics.ListenAddr != "" {
+		check("metrics.listen_addr", checkAddr(c.Metrics.ListenAddr, false))
+		if !strings.HasPrefix(c.Metrics.Path, "/") {
+			check("metrics.path", fmt.Errorf("must start with /, got %q", c.Metrics.Path))
+		}
+	}
+
+	if _, err := ParseLogLevel(c.Log.Level); err != nil {
+		check("log.level", err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// ParseLogLevel accepts the slog level names, an empty string means info
+func ParseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return slog.LevelInfo, fmt.Errorf("unknown level %q, expected debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// checkDialAddr accepts host:port, the ws:// or wss:// URLs of WebSocket
+// peers and the relay:// addresses of relayed peers
+func checkDialAddr(addr string) error {
+	if !strings.Contains(addr, "://") {
+		return checkAddr(addr, true)
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+	if u.Scheme == "relay" {
+		return checkAddr(u.Host, true)
+	}
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return fmt.Errorf("unsupported scheme %q in %q", u.Scheme, addr)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("missing host in %q", addr)
+	}
+	return nil
+}
+
+// checkAddr validates host:port. Dial addresses need a host and a non zero port.
+func checkAddr(addr string, dial bool) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port %q in %q", port, addr)
+	}
+	if dial {
+		if host == "" {
+			return fmt.Errorf("missing host in %q", addr)
+		}
+		if p == 0 {
+			return fmt.Errorf("port 0 in %q", addr)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*AppConfig)
+		err    string
+	}{
+		{"defaults", func(*AppConfig) {}, ""},
+		{"listen without host", func(c *AppConfig) { c.Network.ListenAddr = ":4000" }, ""},
+		{"websocket and relay peers", func(c *AppConfig) {
+			c.Network.BootstrapNodes = []string{"peer.example:4000", "wss://peer.example/ws", "relay://relay.example:4000/abcd"}
+		}, ""},
+		{"listen without port", func(c *AppConfig) { c.Network.ListenAddr = "localhost" }, "network.listen_addr: invalid address"},
+		{"listen port out of range", func(c *AppConfig) { c.Network.ListenAddr = ":70000" }, "network.listen_addr: invalid port"},
+		{"bootstrap without host", func(c *AppConfig) { c.Network.BootstrapNodes = []string{":4000"} }, "network.bootstrap_nodes[0]: missing host"},
+		{"bootstrap port 0", func(c *AppConfig) { c.Network.BootstrapNodes = []string{"ok.example:1", "peer.example:0"} }, "network.bootstrap_nodes[1]: port 0"},
+		{"bootstrap unknown scheme", func(c *AppConfig) { c.Network.BootstrapNodes = []string{"http://peer.example"} }, `unsupported scheme "http"`},
+		{"relay without port", func(c *AppConfig) { c.Network.BootstrapNodes = []string{"relay://relay.example/abcd"} }, "network.bootstrap_nodes[0]: invalid address"},
+		{"stun without host", func(c *AppConfig) { c.Network.StunServers = []string{":3478"} }, "network.stun_servers[0]: missing host"},
+		{"http address", func(c *AppConfig) { c.API.HTTPAddr = "localhost" }, "api.http_addr: invalid address"},
+		{"no max connections", func(c *AppConfig) { c.Network.MaxConnections = 0 }, "network.max_connections: must be positive"},
+		{"high water above max connections", func(c *AppConfig) {
+			c.Network.MaxConnections = 50
+		}, "conn_manager.high_water: must not exceed network.max_connections 50, got 96"},
+		{"high water below low water", func(c *AppConfig) { c.ConnManager.HighWater = 10 }, "conn_manager.high_water: must be at least low_water 64"},
+		{"no low water", func(c *AppConfig) { c.ConnManager.LowWater = 0 }, "conn_manager.low_water: must be positive"},
+		{"unknown role", func(c *AppConfig) { c.Node.Role = "router" }, `node.role: unknown role "router"`},
+		{"unknown password source", func(c *AppConfig) { c.Identity.PasswordSource = "keychain" }, `identity.password_source: unknown source "keychain"`},
+		{"password file missing", func(c *AppConfig) { c.Identity.PasswordSource = PasswordSourceFile }, "identity.password_file: required"},
+		{"password fd of stdin", func(c *AppConfig) { c.Identity.PasswordSource = PasswordSourceFD }, "identity.password_fd: must be 3 or greater"},
+		{"relay limit", func(c *AppConfig) { c.Relay.MaxCircuits = 0 }, "relay.max_circuits: must be positive"},
+		{"log level", func(c *AppConfig) { c.Log.Level = "loud" }, `log.level: unknown level "loud"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfigIn("data")
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Validate returned %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// TestValidateReportsEveryError expects one line per invalid key
+func TestValidateReportsEveryError(t *testing.T) {
+	cfg := DefaultConfigIn("data")
+	cfg.Node.Role = "router"
+	cfg.Network.ListenAddr = "nowhere"
+	cfg.Identity.PasswordSource = "keychain"
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("invalid config accepted")
+	}
+	if lines := strings.Split(err.Error(), "\n"); len(lines) != 3 {
+		t.Fatalf("got %d errors, want 3:\n%v", len(lines), err)
+	}
+}
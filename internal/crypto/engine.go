@@ -46,13 +46,21 @@ type Engine struct {
 	myDiffieHellmanKey *ecdh.PrivateKey
 }
 
+// IdentityX25519Key converts the Ed25519 identity key to the X25519 key
+// whose public half is EdPubKeyToX25519 of the identity public key
+func IdentityX25519Key(identityKey ed25519.PrivateKey) (*ecdh.PrivateKey, error) {
+	priv, err := ecdh.X25519().NewPrivateKey(edPrivToX25519(identityKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create X25519 private key: %w", err)
+	}
+	return priv, nil
+}
+
 // NewEngine takes your identity key (Ed25519) and creates an engine
 func NewEngine(identityKey ed25519.PrivateKey) (*Engine, error) {
-	x25519Bytes := edPrivToX25519(identityKey)
-
-	priv, err := ecdh.X25519().NewPrivateKey(x25519Bytes)
+	priv, err := IdentityX25519Key(identityKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create X25519 private key: %w", err)
+		return nil, err
 	}
 
 	return &Engine{
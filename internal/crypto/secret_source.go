@@ -0,0 +1,118 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+)
+
+const maxSecretSize = 4096
+
+var (
+	ErrWrongPassword    = errors.New("wrong password")
+	ErrEmptyPassword    = errors.New("empty password")
+	ErrNoPasswordPrompt = errors.New("no interactive password prompt available, set identity.password_source")
+)
+
+// SecretSource provides the password that protects the identity key
+type SecretSource interface {
+	Secret() ([]byte, error)
+}
+
+// PasswordSecret is a password that is already known, e.g. read from a terminal
+type PasswordSecret []byte
+
+func (p PasswordSecret) Secret() ([]byte, error) {
+	if len(p) == 0 {
+		return nil, ErrEmptyPassword
+	}
+	return p, nil
+}
+
+// PromptSecret asks for the password only when the key store needs it
+type PromptSecret func() ([]byte, error)
+
+func (p PromptSecret) Secret() ([]byte, error) {
+	if p == nil {
+		return nil, ErrNoPasswordPrompt
+	}
+	secret, err := p()
+	if err != nil {
+		return nil, err
+	}
+	return PasswordSecret(secret).Secret()
+}
+
+// EnvSecret reads the password from the named environment variable
+type EnvSecret string
+
+func (e EnvSecret) Secret() ([]byte, error) {
+	value, ok := os.LookupEnv(string(e))
+	if !ok {
+		return nil, fmt.Errorf("environment variable %s is not set", string(e))
+	}
+	return PasswordSecret(value).Secret()
+}
+
+// FileSecret reads the password from a file that must not be accessible by group or others
+type FileSecret string
+
+func (f FileSecret) Secret() ([]byte, error) {
+	file, err := os.Open(string(f))
+	if err != nil {
+		return nil, fmt.Errorf("open password file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("stat password file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("password file %s is not a regular file", string(f))
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		return nil, fmt.Errorf("password file %s has mode %04o, it must not be accessible by group or others (chmod 600)", string(f), info.Mode().Perm())
+	}
+
+	return readSecret(file)
+}
+
+// FDSecret reads the password from an inherited file descriptor, e.g. a pipe set up by a supervisor
+type FDSecret int
+
+func (fd FDSecret) Secret() ([]byte, error) {
+	if fd < 3 {
+		return nil, fmt.Errorf("invalid password file descriptor %d", int(fd))
+	}
+
+	file := os.NewFile(uintptr(fd), fmt.Sprintf("password-fd-%d", int(fd)))
+	if file == nil {
+		return nil, fmt.Errorf("invalid password file descriptor %d", int(fd))
+	}
+	defer file.Close()
+
+	return readSecret(file)
+}
+
+// DevSecret keeps the identity key unencrypted. It must only be used for local development.
+type DevSecret struct{}
+
+func (DevSecret) Secret() ([]byte, error) {
+	return nil, nil
+}
+
+func readSecret(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxSecretSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read password: %w", err)
+	}
+	if len(data) > maxSecretSize {
+		return nil, errors.New("password is too long")
+	}
+
+	return PasswordSecret(bytes.TrimRight(data, "\r\n")).Secret()
+}
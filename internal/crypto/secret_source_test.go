@@ -0,0 +1,144 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// passwordFile writes data to a file with the given mode
+func passwordFile(t *testing.T, data string, mode os.FileMode) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// chmod, the umask may have dropped bits of the mode
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSecretSources(t *testing.T) {
+	const env = "ECHOFOG_TEST_PASSWORD"
+
+	tests := []struct {
+		name   string
+		unix   bool
+		source func(t *testing.T) SecretSource
+		want   string
+		err    string
+	}{
+		{"file", false, func(t *testing.T) SecretSource {
+			return FileSecret(passwordFile(t, "hunter2\n", 0o600))
+		}, "hunter2", ""},
+		{"file with crlf", false, func(t *testing.T) SecretSource {
+			return FileSecret(passwordFile(t, "hunter2\r\n", 0o400))
+		}, "hunter2", ""},
+		{"group-readable file", true, func(t *testing.T) SecretSource {
+			return FileSecret(passwordFile(t, "hunter2", 0o640))
+		}, "", "must not be accessible by group or others"},
+		{"world-readable file", true, func(t *testing.T) SecretSource {
+			return FileSecret(passwordFile(t, "hunter2", 0o604))
+		}, "", "must not be accessible by group or others"},
+		{"empty file", false, func(t *testing.T) SecretSource {
+			return FileSecret(passwordFile(t, "\n", 0o600))
+		}, "", ErrEmptyPassword.Error()},
+		{"file too long", false, func(t *testing.T) SecretSource {
+			return FileSecret(passwordFile(t, strings.Repeat("x", maxSecretSize+1), 0o600))
+		}, "", "password is too long"},
+		{"missing file", false, func(t *testing.T) SecretSource {
+			return FileSecret(filepath.Join(t.TempDir(), "missing"))
+		}, "", "open password file"},
+		{"directory", false, func(t *testing.T) SecretSource {
+			return FileSecret(t.TempDir())
+		}, "", "is not a regular file"},
+		{"env", false, func(t *testing.T) SecretSource {
+			t.Setenv(env, "hunter2")
+			return EnvSecret(env)
+		}, "hunter2", ""},
+		{"empty env", false, func(t *testing.T) SecretSource {
+			t.Setenv(env, "")
+			return EnvSecret(env)
+		}, "", ErrEmptyPassword.Error()},
+		{"unset env", false, func(t *testing.T) SecretSource {
+			t.Setenv(env, "")
+			os.Unsetenv(env)
+			return EnvSecret(env)
+		}, "", "is not set"},
+		{"stdin fd", false, func(t *testing.T) SecretSource {
+			return FDSecret(0)
+		}, "", "invalid password file descriptor"},
+		{"negative fd", false, func(t *testing.T) SecretSource {
+			return FDSecret(-1)
+		}, "", "invalid password file descriptor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.unix && runtime.GOOS == "windows" {
+				t.Skip("file modes are not checked on windows")
+			}
+			secret, err := tt.source(t).Secret()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Secret returned %v, want an error containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(secret) != tt.want {
+				t.Fatalf("Secret = %q, want %q", secret, tt.want)
+			}
+		})
+	}
+}
+
+// TestFDSecretInherited passes a pipe to a child process the way a
+// supervisor would, the child reads the password from fd 3
+func TestFDSecretInherited(t *testing.T) {
+	if os.Getenv("ECHOFOG_TEST_FD_SECRET") == "1" {
+		secret, err := FDSecret(3).Secret()
+		if err != nil {
+			fmt.Fprint(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Print(string(secret))
+		os.Exit(0)
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("no inherited file descriptors on windows")
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := w.WriteString("hunter2\n"); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFDSecretInherited$")
+	cmd.Env = append(os.Environ(), "ECHOFOG_TEST_FD_SECRET=1")
+	cmd.ExtraFiles = []*os.File{r}
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		t.Fatalf("child failed: %s", exitErr.Stderr)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "hunter2" {
+		t.Fatalf("child read %q, want %q", out, "hunter2")
+	}
+}
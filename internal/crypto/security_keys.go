@@ -9,8 +9,8 @@ import (
 	"encoding/hex"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"io"
-	"log"
 	"math/big"
 	"time"
 
@@ -27,25 +27,52 @@ import (
 //	PublicKey  string `json:"public_key"`
 //}
 
+const (
+	algorithmArgon2Chacha = "argon2id-chacha20"
+	algorithmPlain        = "none"
+)
+
+var (
+	ErrKeyEncrypted   = errors.New("identity key is encrypted, the dev password source cannot unlock it")
+	ErrKeyUnencrypted = errors.New("identity key is stored unencrypted, set identity.password_source to \"dev\" to use it")
+)
+
 type SecureKeyStore struct {
-	Password []byte
-	storage  storage.Storage
+	secret  SecretSource
+	storage storage.Storage
 }
 
-func NewSecureKeyStore(pass []byte, s storage.Storage) *SecureKeyStore {
+func NewSecureKeyStore(secret SecretSource, s storage.Storage) *SecureKeyStore {
 	return &SecureKeyStore{
-		Password: pass,
-		storage:  s,
+		secret:  secret,
+		storage: s,
 	}
 }
 
+func (s *SecureKeyStore) isDevMode() bool {
+	_, ok := s.secret.(DevSecret)
+	return ok
+}
+
 func (s *SecureKeyStore) encryptPrivateKey(privKey ed25519.PrivateKey) ([]byte, error) {
+	if s.isDevMode() {
+		return proto.Marshal(&EncryptedPrivateKey{
+			EncryptedKey: privKey,
+			Algorithm:    algorithmPlain,
+		})
+	}
+
+	password, err := s.secret.Secret()
+	if err != nil {
+		return nil, err
+	}
+
 	salt := make([]byte, 16)
 	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
 		return nil, err
 	}
 
-	key := argon2.IDKey(s.Password, salt, 1, 64*1024, 4, 32)
+	key := argon2.IDKey(password, salt, 1, 64*1024, 4, 32)
 
 	aead, _ := chacha20poly1305.NewX(key)
 	nonce := make([]byte, aead.NonceSize())
@@ -56,7 +83,7 @@ func (s *SecureKeyStore) encryptPrivateKey(privKey ed25519.PrivateKey) ([]byte,
 		Salt:         salt,
 		Nonce:        nonce,
 		EncryptedKey: cipherText,
-		Algorithm:    "argon2id-chacha20",
+		Algorithm:    algorithmArgon2Chacha,
 	}
 	return proto.Marshal(protoKeys)
 }
@@ -68,17 +95,35 @@ func (s *SecureKeyStore) decryptPrivateKey(encryptedData []byte) (ed25519.Privat
 		return nil, err
 	}
 
+	if protoData.GetAlgorithm() == algorithmPlain {
+		if !s.isDevMode() {
+			return nil, ErrKeyUnencrypted
+		}
+		if len(protoData.GetEncryptedKey()) != ed25519.PrivateKeySize {
+			return nil, errors.New("invalid private key size")
+		}
+		return ed25519.PrivateKey(protoData.GetEncryptedKey()), nil
+	}
+	if s.isDevMode() {
+		return nil, ErrKeyEncrypted
+	}
+
+	password, err := s.secret.Secret()
+	if err != nil {
+		return nil, err
+	}
+
 	salt := protoData.GetSalt()
 	nonce := protoData.GetNonce()
 	actualCipher := protoData.GetEncryptedKey()
 
-	key := argon2.IDKey(s.Password, salt, 1, 64*1024, 4, 32)
+	key := argon2.IDKey(password, salt, 1, 64*1024, 4, 32)
 
 	aead, _ := chacha20poly1305.NewX(key)
 
 	decrypted, err := aead.Open(nil, nonce, actualCipher, nil)
 	if err != nil {
-		return nil, errors.New("decryption error (wrong password?)")
+		return nil, ErrWrongPassword
 	}
 
 	return ed25519.PrivateKey(decrypted), nil
@@ -107,7 +152,6 @@ func (s *SecureKeyStore) GetOrGenerateKeys() (ed25519.PrivateKey, error) {
 
 			return priv, nil
 		} else {
-			log.Println(err)
 			return nil, err
 		}
 	}
@@ -150,15 +194,42 @@ func GenerateTLSConfig(priv ed25519.PrivateKey) (*tls.Config, error) {
 	return &tls.Config{
 		Certificates:       []tls.Certificate{tlsCert},
 		NextProtos:         []string{"my-gossip-protocol"},
-		ClientAuth:         tls.RequestClientCert,
+		ClientAuth:         tls.RequireAnyClientCert,
 		InsecureSkipVerify: true,
+		// there is no CA, the certificate only has to be a valid identity
+		// certificate, the transport binds its key to the handshake key
 		VerifyPeerCertificate: func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
-			return nil
+			if len(rawCerts) != 1 {
+				return fmt.Errorf("expected one peer certificate, got %d", len(rawCerts))
+			}
+			cert, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			_, err = PeerKeyFromCertificate(cert)
+			return err
 		},
 		ClientSessionCache: tls.NewLRUClientSessionCache(100),
 	}, nil
 }
 
+// PeerKeyFromCertificate returns the Ed25519 identity key of a certificate
+// made by GenerateTLSConfig. The certificate must be signed by that key and
+// name it in its CommonName.
+func PeerKeyFromCertificate(cert *x509.Certificate) (ed25519.PublicKey, error) {
+	pub, ok := cert.PublicKey.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("peer certificate key is not ed25519")
+	}
+	if cert.Subject.CommonName != hex.EncodeToString(pub) {
+		return nil, errors.New("peer certificate CommonName does not match its key")
+	}
+	if err := cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+		return nil, fmt.Errorf("peer certificate is not self-signed: %w", err)
+	}
+	return pub, nil
+}
+
 func decodeKey(hexStr string) (ed25519.PrivateKey, error) {
 	data, err := hex.DecodeString(hexStr)
 	if err != nil {
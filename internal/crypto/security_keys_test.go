@@ -0,0 +1,86 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func newEdKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv
+}
+
+// newCertificate makes a certificate for pub named commonName and signed by signer
+func newCertificate(t *testing.T, pub ed25519.PublicKey, commonName string, signer ed25519.PrivateKey) *x509.Certificate {
+	t.Helper()
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, pub, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestPeerKeyFromCertificate(t *testing.T) {
+	priv, other := newEdKey(t), newEdKey(t)
+	pub := priv.Public().(ed25519.PublicKey)
+
+	tlsCfg, err := GenerateTLSConfig(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated := tlsCfg.Certificates[0].Leaf
+	if generated == nil {
+		if generated, err = x509.ParseCertificate(tlsCfg.Certificates[0].Certificate[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		cert *x509.Certificate
+		ok   bool
+	}{
+		{"generated", generated, true},
+		{"self-signed", newCertificate(t, pub, hex.EncodeToString(pub), priv), true},
+		{"named after another key", newCertificate(t, pub, hex.EncodeToString(other.Public().(ed25519.PublicKey)), priv), false},
+		{"signed by another key", newCertificate(t, pub, hex.EncodeToString(pub), other), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := PeerKeyFromCertificate(tt.cert)
+			if !tt.ok {
+				if err == nil {
+					t.Fatal("certificate accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(key, pub) {
+				t.Fatal("returned another key")
+			}
+		})
+	}
+}
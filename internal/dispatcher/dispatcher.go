@@ -2,11 +2,13 @@ package dispatcher
 
 import (
 	"context"
-
-	"log"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
 	"reflect"
 	"runtime"
 	"sync"
+	"sync/atomic"
 
 	"github.com/DmytroBuzhylov/echofog-core/internal/crypto"
 	internal_pb "github.com/DmytroBuzhylov/echofog-core/internal/proto"
@@ -19,16 +21,28 @@ type Handler interface {
 	Handle(msg *internal_pb.MessageData, peerID types.PeerID)
 }
 
+// Filter runs for every verified message before it is routed to its
+// handler, returning false drops the message
+type Filter func(msg *internal_pb.MessageData, peerID types.PeerID) bool
+
 type Dispatcher struct {
 	// The channel on which ALL peers send incoming packets
 	ingressChan chan IngressPacket
 
 	handlersMu sync.RWMutex
 	handlers   map[reflect.Type]Handler // string = payload type
+	filter     Filter
 
 	workersNum int
+	log        *slog.Logger
 	ctx        context.Context
 	cancel     context.CancelFunc
+
+	stopping atomic.Bool
+	dropped  atomic.Uint64
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
 }
 
 type IngressPacket struct {
@@ -36,18 +50,20 @@ type IngressPacket struct {
 	PeerID   types.PeerID
 }
 
-func NewDispatcher(ctx context.Context) *Dispatcher {
+func NewDispatcher(ctx context.Context, log *slog.Logger) *Dispatcher {
 	ctx, cancel := context.WithCancel(ctx)
 	workerNum := runtime.NumCPU() - 1
-	if workerNum < 0 {
+	if workerNum <= 0 {
 		workerNum = 1
 	}
 	return &Dispatcher{
 		ingressChan: make(chan IngressPacket, 2000),
 		handlers:    make(map[reflect.Type]Handler),
 		workersNum:  workerNum,
+		log:         log,
 		ctx:         ctx,
 		cancel:      cancel,
+		stopCh:      make(chan struct{}),
 	}
 }
 
@@ -58,34 +74,96 @@ func (d *Dispatcher) Registry(payloadProto interface{}, handler Handler) {
 	d.handlers[t] = handler
 }
 
+// SetFilter installs f, it must be called before Start
+func (d *Dispatcher) SetFilter(f Filter) {
+	d.handlersMu.Lock()
+	defer d.handlersMu.Unlock()
+	d.filter = f
+}
+
 // PushMessage calls Peer when it has read something from the network
 func (d *Dispatcher) PushMessage(env *internal_pb.Envelope, peerID types.PeerID) {
+	if d.stopping.Load() {
+		return
+	}
 	select {
 	case d.ingressChan <- IngressPacket{
 		Envelope: env,
 		PeerID:   peerID,
 	}:
 	default:
-		log.Println("Dispatcher queue full, dropping message from", peerID)
+		d.dropped.Add(1)
 	}
 }
 
+// Start runs the workers, it has to return before Stop is called or Stop
+// may not wait for them
 func (d *Dispatcher) Start() {
+	d.wg.Add(d.workersNum)
 	for i := 0; i < d.workersNum; i++ {
 		go d.workerLoop()
 	}
 }
 
-func (d *Dispatcher) Stop() {
-	d.cancel()
+// Stop rejects new packets, lets the workers drain the queue and waits for them.
+// When ctx expires first the remaining packets are discarded.
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	d.stopOnce.Do(func() {
+		d.stopping.Store(true)
+		close(d.stopCh)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		return fmt.Errorf("draining dispatcher queue (%d left): %w", len(d.ingressChan), ctx.Err())
+	}
+}
+
+// QueueLen returns the number of packets waiting for a worker
+func (d *Dispatcher) QueueLen() int {
+	return len(d.ingressChan)
+}
+
+// Dropped returns the number of packets discarded because the queue was full
+func (d *Dispatcher) Dropped() uint64 {
+	return d.dropped.Load()
 }
 
 func (d *Dispatcher) workerLoop() {
-	select {
-	case packet := <-d.ingressChan:
-		d.processPacket(packet)
-	case <-d.ctx.Done():
-		return
+	defer d.wg.Done()
+	for {
+		select {
+		case packet := <-d.ingressChan:
+			d.processPacket(packet)
+		case <-d.stopCh:
+			d.drain()
+			return
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) drain() {
+	for {
+		select {
+		case packet := <-d.ingressChan:
+			d.processPacket(packet)
+		case <-d.ctx.Done():
+			return
+		default:
+			return
+		}
 	}
 }
 
@@ -93,13 +171,13 @@ func (d *Dispatcher) processPacket(packet IngressPacket) {
 	env := packet.Envelope
 	pubKey := types.PeerPublicKey(env.PubKey)
 	if len(pubKey) != 32 || !crypto.VerifySignature(pubKey, env.Data, env.Signature) {
-		log.Printf("Security Alert: Invalid signature from peer!")
+		d.log.Warn("Dropped message with an invalid signature", "peer_id", hex.EncodeToString(packet.PeerID[:]))
 		return
 	}
 
 	var msgData internal_pb.MessageData
 	if err := proto.Unmarshal(env.Data, &msgData); err != nil {
-		log.Printf("Failed to unmarshal MessageData: %v", err)
+		d.log.Warn("Dropped malformed message", "peer_id", hex.EncodeToString(packet.PeerID[:]), "err", err)
 		return
 	}
 
@@ -111,11 +189,15 @@ func (d *Dispatcher) route(msg *internal_pb.MessageData, fromPeer types.PeerID)
 
 	d.handlersMu.RLock()
 	handler, ok := d.handlers[payloadType]
+	filter := d.filter
 	d.handlersMu.RUnlock()
 
+	if filter != nil && !filter(msg, fromPeer) {
+		return
+	}
+
+	// not every node role handles every payload, see node.Profile
 	if ok {
 		handler.Handle(msg, fromPeer)
-	} else {
-		log.Printf("No handler registered for type: %v", payloadType)
 	}
 }
@@ -14,17 +14,20 @@ type LogEntry struct {
 
 type ChannelHandler struct {
 	out         chan<- LogEntry
+	hub         *Hub
 	opts        slog.HandlerOptions
 	attrs       []slog.Attr
 	groupPrefix string
 }
 
-func NewChannelHandler(out chan<- LogEntry, opts *slog.HandlerOptions) *ChannelHandler {
+// NewChannelHandler sends entries to out and, when hub is not nil, to the hub subscribers
+func NewChannelHandler(out chan<- LogEntry, hub *Hub, opts *slog.HandlerOptions) *ChannelHandler {
 	if opts == nil {
 		opts = &slog.HandlerOptions{}
 	}
 	return &ChannelHandler{
 		out:  out,
+		hub:  hub,
 		opts: *opts,
 	}
 }
@@ -54,6 +57,9 @@ func (h *ChannelHandler) Handle(ctx context.Context, r slog.Record) error {
 	case h.out <- entry:
 	default:
 	}
+	if h.hub != nil {
+		h.hub.publish(entry)
+	}
 
 	return nil
 }
@@ -65,6 +71,7 @@ func (h *ChannelHandler) WithGroup(name string) slog.Handler {
 func (h *ChannelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	return &ChannelHandler{
 		out:   h.out,
+		hub:   h.hub,
 		opts:  h.opts,
 		attrs: append(h.attrs, attrs...),
 	}
@@ -0,0 +1,53 @@
+package logger
+
+import "sync"
+
+const DefaultHubBufferSize = 256
+
+// Hub fans log entries out to any number of subscribers. Like ChannelHandler
+// it never blocks: entries are dropped for a subscriber whose buffer is full.
+type Hub struct {
+	mu   sync.RWMutex
+	subs map[chan LogEntry]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subs: make(map[chan LogEntry]struct{}),
+	}
+}
+
+// Subscribe returns a channel of log entries and a func to stop receiving them.
+// bufSize <= 0 uses DefaultHubBufferSize.
+func (h *Hub) Subscribe(bufSize int) (<-chan LogEntry, func()) {
+	if bufSize <= 0 {
+		bufSize = DefaultHubBufferSize
+	}
+	ch := make(chan LogEntry, bufSize)
+
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs, ch)
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (h *Hub) publish(entry LogEntry) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subs {
+		select {
+		case ch <- entry:
+		default:
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Package nat finds the address this node is reachable at from the
+// internet and how the NAT in front of it maps that address.
+package nat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Mapping is how a NAT picks the public address of our socket
+type Mapping int
+
+const (
+	MappingUnknown Mapping = iota
+	// MappingNone means the socket is not behind a NAT
+	MappingNone
+	// MappingEndpointIndependent keeps the public address whatever the
+	// destination, peers can dial the address STUN reported
+	MappingEndpointIndependent
+	// MappingEndpointDependent gives every destination another public
+	// address, only hole punching and relays reach us
+	MappingEndpointDependent
+)
+
+func (m Mapping) String() string {
+	switch m {
+	case MappingNone:
+		return "none"
+	case MappingEndpointIndependent:
+		return "endpoint-independent"
+	case MappingEndpointDependent:
+		return "endpoint-dependent"
+	default:
+		return "unknown"
+	}
+}
+
+// Reachable reports whether peers can dial the mapped address
+func (m Mapping) Reachable() bool {
+	return m == MappingNone || m == MappingEndpointIndependent
+}
+
+// Result is what Detect learned
+type Result struct {
+	Mapping Mapping
+	// Addr is the mapped address the first server reported
+	Addr *net.UDPAddr
+	// Servers is how many servers answered
+	Servers int
+}
+
+// Detect sends binding requests to servers in order until two of them
+// answered and compares the addresses they saw. The mapping stays unknown
+// when only one server answered and the address is not local.
+func Detect(ctx context.Context, conn PacketConn, servers []string) (Result, error) {
+	var (
+		mapped []*net.UDPAddr
+		errs   []error
+	)
+	for _, server := range servers {
+		if len(mapped) == 2 {
+			break
+		}
+		addr, err := Binding(ctx, conn, server)
+		if err != nil {
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		mapped = append(mapped, addr)
+	}
+	if len(mapped) == 0 {
+		if len(errs) == 0 {
+			return Result{}, errors.New("no STUN server configured")
+		}
+		return Result{}, fmt.Errorf("no STUN server answered: %w", errors.Join(errs...))
+	}
+
+	res := Result{Addr: mapped[0], Servers: len(mapped)}
+	switch {
+	case len(mapped) == 2 && !sameUDPAddr(mapped[1], mapped[0]):
+		res.Mapping = MappingEndpointDependent
+	case isLocal(mapped[0], conn.LocalAddr()):
+		res.Mapping = MappingNone
+	case len(mapped) == 2:
+		res.Mapping = MappingEndpointIndependent
+	}
+	return res, nil
+}
+
+// isLocal reports whether mapped is the address of the socket itself
+func isLocal(mapped *net.UDPAddr, local net.Addr) bool {
+	udp, ok := local.(*net.UDPAddr)
+	if !ok || udp.Port != mapped.Port {
+		return false
+	}
+	if udp.IP != nil && !udp.IP.IsUnspecified() {
+		return udp.IP.Equal(mapped.IP)
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.Equal(mapped.IP) {
+			return true
+		}
+	}
+	return false
+}
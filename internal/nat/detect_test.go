@@ -0,0 +1,153 @@
+package nat
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// startServers runs a local stand-in STUN server per shift
+func startServers(t *testing.T, shifts ...int) []string {
+	t.Helper()
+	var addrs []string
+	for _, shift := range shifts {
+		s, err := NewServer("127.0.0.1:0", shift)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		addrs = append(addrs, s.Addr().String())
+	}
+	return addrs
+}
+
+func listenUDP(t *testing.T, addr string) UDPConn {
+	t.Helper()
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return UDPConn{conn}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		shifts  []int
+		mapping Mapping
+		servers int
+	}{
+		{"no nat", []int{0, 0}, MappingNone, 2},
+		{"endpoint independent", []int{1000, 1000}, MappingEndpointIndependent, 2},
+		{"endpoint dependent", []int{0, 7}, MappingEndpointDependent, 2},
+		{"endpoint dependent behind shifted first", []int{1000, 1001}, MappingEndpointDependent, 2},
+		{"one server, local address", []int{0}, MappingNone, 1},
+		{"one server, mapped address", []int{1000}, MappingUnknown, 1},
+		{"stops after two answers", []int{1000, 1000, 7}, MappingEndpointIndependent, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			servers := startServers(t, tt.shifts...)
+			conn := listenUDP(t, "127.0.0.1:0")
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			res, err := Detect(ctx, conn, servers)
+			if err != nil {
+				t.Fatalf("Detect: %v", err)
+			}
+			if res.Mapping != tt.mapping {
+				t.Errorf("mapping = %v, want %v", res.Mapping, tt.mapping)
+			}
+			if res.Servers != tt.servers {
+				t.Errorf("servers = %d, want %d", res.Servers, tt.servers)
+			}
+			local := conn.LocalAddr().(*net.UDPAddr)
+			if want := (local.Port + tt.shifts[0]) & 0xffff; res.Addr.Port != want {
+				t.Errorf("addr = %v, want port %d", res.Addr, want)
+			}
+			if res.Mapping.Reachable() != (tt.mapping == MappingNone || tt.mapping == MappingEndpointIndependent) {
+				t.Errorf("Reachable = %v for %v", res.Mapping.Reachable(), res.Mapping)
+			}
+		})
+	}
+}
+
+// TestDetectWildcardSocket checks that a socket bound to all interfaces
+// finds its own address among the interface addresses
+func TestDetectWildcardSocket(t *testing.T) {
+	servers := startServers(t, 0, 0)
+	conn := listenUDP(t, "0.0.0.0:0")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := Detect(ctx, conn, servers)
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if res.Mapping != MappingNone {
+		t.Errorf("mapping = %v, want %v", res.Mapping, MappingNone)
+	}
+}
+
+// TestDetectSkipsSilentServer checks that a server that does not answer is
+// skipped for the next one
+func TestDetectSkipsSilentServer(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for the binding retransmissions")
+	}
+	silent := listenUDP(t, "127.0.0.1:0")
+	servers := append([]string{silent.LocalAddr().String()}, startServers(t, 0, 7)...)
+	conn := listenUDP(t, "127.0.0.1:0")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := Detect(ctx, conn, servers)
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if res.Mapping != MappingEndpointDependent || res.Servers != 2 {
+		t.Errorf("got %v from %d servers, want %v from 2", res.Mapping, res.Servers, MappingEndpointDependent)
+	}
+}
+
+func TestDetectFails(t *testing.T) {
+	conn := listenUDP(t, "127.0.0.1:0")
+	if _, err := Detect(context.Background(), conn, nil); err == nil {
+		t.Error("Detect without servers succeeded")
+	}
+
+	silent := listenUDP(t, "127.0.0.1:0")
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := Detect(ctx, conn, []string{silent.LocalAddr().String()}); err == nil {
+		t.Error("Detect against a silent server succeeded")
+	}
+}
+
+func TestBindingIgnoresOtherPackets(t *testing.T) {
+	servers := startServers(t, 0)
+	conn := listenUDP(t, "127.0.0.1:0")
+
+	// a packet from another sender arrives before the answer
+	other := listenUDP(t, "127.0.0.1:0")
+	if _, err := other.WriteTo([]byte("not stun"), conn.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	mapped, err := Binding(ctx, conn, servers[0])
+	if err != nil {
+		t.Fatalf("Binding: %v", err)
+	}
+	if !sameUDPAddr(conn.LocalAddr(), mapped) {
+		t.Errorf("mapped = %v, want %v", mapped, conn.LocalAddr())
+	}
+}
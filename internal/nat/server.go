@@ -0,0 +1,71 @@
+package nat
+
+import (
+	"net"
+	"sync"
+
+	"github.com/pion/stun"
+)
+
+// Server is a minimal STUN server that answers binding requests, enough to
+// stand in for public servers on a local network or in tests
+type Server struct {
+	conn *net.UDPConn
+	// shift is added to the ports it reports
+	shift int
+	wg    sync.WaitGroup
+}
+
+// NewServer listens on addr. A non-zero shift is added to the ports it
+// reports, two servers with different shifts look like a NAT with
+// endpoint-dependent mapping to the client, equal ones like an
+// endpoint-independent one.
+func NewServer(addr string, shift int) (*Server, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{conn: conn, shift: shift}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+func (s *Server) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+func (s *Server) Close() error {
+	err := s.conn.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, from, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		req := &stun.Message{Raw: append([]byte(nil), buf[:n]...)}
+		if req.Decode() != nil || req.Type != stun.BindingRequest {
+			continue
+		}
+		res, err := stun.Build(
+			stun.NewTransactionIDSetter(req.TransactionID),
+			stun.BindingSuccess,
+			&stun.XORMappedAddress{IP: from.IP, Port: (from.Port + s.shift) & 0xffff},
+			stun.Fingerprint,
+		)
+		if err != nil {
+			continue
+		}
+		s.conn.WriteToUDP(res.Raw, from)
+	}
+}
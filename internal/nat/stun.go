@@ -0,0 +1,121 @@
+package nat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/pion/stun"
+)
+
+const (
+	// bindingRTO is the first retransmission timeout of a binding request,
+	// it doubles after each attempt as in RFC 5389
+	bindingRTO      = 500 * time.Millisecond
+	bindingAttempts = 3
+
+	maxPacketSize = 1500
+)
+
+var ErrNoMappedAddress = errors.New("no mapped address in STUN response")
+
+// PacketConn is the socket binding requests travel on. To learn the
+// address peers see, it must be the socket connections are accepted on.
+type PacketConn interface {
+	WriteTo(b []byte, addr net.Addr) (int, error)
+	// ReadFrom returns the next packet or fails once ctx is done
+	ReadFrom(ctx context.Context, b []byte) (int, net.Addr, error)
+	LocalAddr() net.Addr
+}
+
+// Binding asks the STUN server at addr for the address it sees conn at.
+// Packets that do not answer the request are skipped, so conn must not be
+// read by anyone else meanwhile.
+func Binding(ctx context.Context, conn PacketConn, addr string) (*net.UDPAddr, error) {
+	server, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("resolve STUN server %q: %w", addr, err)
+	}
+	req, err := stun.Build(stun.TransactionID, stun.BindingRequest, stun.Fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, maxPacketSize)
+	rto := bindingRTO
+	for range bindingAttempts {
+		if _, err := conn.WriteTo(req.Raw, server); err != nil {
+			return nil, fmt.Errorf("send binding request: %w", err)
+		}
+		attemptCtx, cancel := context.WithTimeout(ctx, rto)
+		mapped, err := readBinding(attemptCtx, conn, buf, server, req.TransactionID)
+		cancel()
+		if err == nil || !errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+			return mapped, err
+		}
+		rto *= 2
+	}
+	return nil, fmt.Errorf("no answer from STUN server %s", addr)
+}
+
+func readBinding(ctx context.Context, conn PacketConn, buf []byte, server *net.UDPAddr, id [stun.TransactionIDSize]byte) (*net.UDPAddr, error) {
+	for {
+		n, from, err := conn.ReadFrom(ctx, buf)
+		if err != nil {
+			return nil, err
+		}
+		if !sameUDPAddr(from, server) || !stun.IsMessage(buf[:n]) {
+			continue
+		}
+		res := &stun.Message{Raw: append([]byte(nil), buf[:n]...)}
+		if res.Decode() != nil || res.TransactionID != id {
+			continue
+		}
+		if res.Type != stun.BindingSuccess {
+			return nil, fmt.Errorf("STUN server answered %s", res.Type)
+		}
+		return mappedAddr(res)
+	}
+}
+
+// mappedAddr prefers XOR-MAPPED-ADDRESS, old servers only send MAPPED-ADDRESS
+func mappedAddr(res *stun.Message) (*net.UDPAddr, error) {
+	var xorAddr stun.XORMappedAddress
+	if err := xorAddr.GetFrom(res); err == nil {
+		return &net.UDPAddr{IP: xorAddr.IP, Port: xorAddr.Port}, nil
+	}
+	var addr stun.MappedAddress
+	if err := addr.GetFrom(res); err == nil {
+		return &net.UDPAddr{IP: addr.IP, Port: addr.Port}, nil
+	}
+	return nil, ErrNoMappedAddress
+}
+
+func sameUDPAddr(a net.Addr, b *net.UDPAddr) bool {
+	udp, ok := a.(*net.UDPAddr)
+	return ok && udp.Port == b.Port && udp.IP.Equal(b.IP)
+}
+
+// UDPConn is a PacketConn over a plain UDP socket
+type UDPConn struct {
+	*net.UDPConn
+}
+
+func (c UDPConn) ReadFrom(ctx context.Context, b []byte) (int, net.Addr, error) {
+	deadline, _ := ctx.Deadline()
+	c.SetReadDeadline(deadline)
+	// a cancel without a deadline interrupts the read through an expired one
+	stop := context.AfterFunc(ctx, func() { c.SetReadDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	n, addr, err := c.UDPConn.ReadFrom(b)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		// only ctx sets deadlines, it is done or about to be
+		<-ctx.Done()
+		return 0, nil, ctx.Err()
+	}
+	return n, addr, err
+}
@@ -0,0 +1,231 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// maxControlMessage is the largest gossip message sent over the control
+	// stream, larger ones would hold up the messages queued behind them
+	maxControlMessage = 16 << 10
+	// maxControlBatch is how many bytes of queued frames are coalesced into one write
+	maxControlBatch = 64 << 10
+
+	controlQueueSize      = 256
+	controlEnqueueTimeout = 2 * time.Second
+	controlWriteTimeout   = 5 * time.Second
+)
+
+var ErrControlQueueFull = errors.New("control stream queue full")
+
+// controlMessage is a frame to write, or a flush marker when flushed is set
+type controlMessage struct {
+	msgType MessageType
+	data    []byte
+	flushed chan struct{}
+}
+
+// controlStream is the long-lived uni stream that carries the gossip
+// messages of one direction of a connection in order. It starts with a
+// TypeControlStream frame, so the receiver can tell it from the streams
+// SendGossipStream opens per message. Once it fails, messages fall back to
+// their own streams.
+type controlStream struct {
+	queue    chan controlMessage
+	down     chan struct{}
+	downOnce sync.Once
+}
+
+func newControlStream() *controlStream {
+	return &controlStream{
+		queue: make(chan controlMessage, controlQueueSize),
+		down:  make(chan struct{}),
+	}
+}
+
+func (c *controlStream) markDown() {
+	c.downOnce.Do(func() { close(c.down) })
+}
+
+func (c *controlStream) isDown() bool {
+	select {
+	case <-c.down:
+		return true
+	default:
+		return false
+	}
+}
+
+// SendGossipMessage queues a message on the control stream, messages above
+// maxControlMessage and messages to peers older than VersionControlStream
+// get a stream of their own. data must not be modified after the call.
+func (p *PeerWrapper) SendGossipMessage(msgType MessageType, data []byte) error {
+	if len(data) > maxControlMessage || p.version < VersionControlStream || p.control.isDown() {
+		return p.SendGossipStream(msgType, data)
+	}
+
+	timer := time.NewTimer(controlEnqueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case p.control.queue <- controlMessage{msgType: msgType, data: data}:
+		return nil
+	case <-p.control.down:
+		return p.SendGossipStream(msgType, data)
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	case <-timer.C:
+		return ErrControlQueueFull
+	}
+}
+
+// Flush waits until the gossip messages queued before the call were written
+// to the connection. It does not wait for the remote side to read them.
+func (p *PeerWrapper) Flush(ctx context.Context) error {
+	if p.version < VersionControlStream || p.control.isDown() {
+		// messages go out on streams of their own, written before Send returns
+		return nil
+	}
+	flushed := make(chan struct{})
+	select {
+	case p.control.queue <- controlMessage{flushed: flushed}:
+	case <-p.control.down:
+		return nil
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-flushed:
+		return nil
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	case <-p.conn.Context().Done():
+		return context.Cause(p.conn.Context())
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// controlWriteLoop opens the control stream with the first message and
+// writes everything queued since the last write as one batch
+func (p *PeerWrapper) controlWriteLoop() {
+	var (
+		stream SendStream
+		batch  = make([]byte, 0, maxControlBatch+frameOverhead+maxControlMessage)
+		sent   []controlMessage
+	)
+	defer func() {
+		if stream != nil {
+			stream.Close()
+		}
+	}()
+
+	for {
+		var msg controlMessage
+		select {
+		case msg = <-p.control.queue:
+		case <-p.ctx.Done():
+			p.control.markDown()
+			return
+		case <-p.conn.Context().Done():
+			p.control.markDown()
+			return
+		}
+
+		batch, sent = batch[:0], append(sent[:0], msg)
+		if msg.flushed != nil && stream == nil {
+			// nothing was queued before the marker
+			close(msg.flushed)
+			continue
+		}
+		if stream == nil {
+			var err error
+			if stream, err = p.openControlStream(); err != nil {
+				p.controlFailed(sent, err)
+				return
+			}
+			batch = appendFrame(batch, TypeControlStream, nil)
+		}
+		if msg.flushed == nil {
+			batch = appendFrame(batch, msg.msgType, msg.data)
+		}
+
+	coalesce:
+		for len(batch) < maxControlBatch {
+			select {
+			case msg := <-p.control.queue:
+				if msg.flushed == nil {
+					batch = appendFrame(batch, msg.msgType, msg.data)
+				}
+				sent = append(sent, msg)
+			default:
+				break coalesce
+			}
+		}
+
+		if len(batch) > 0 {
+			stream.SetWriteDeadline(time.Now().Add(controlWriteTimeout))
+			if _, err := stream.Write(batch); err != nil {
+				p.controlFailed(sent, err)
+				return
+			}
+		}
+		for _, msg := range sent {
+			if msg.flushed != nil {
+				close(msg.flushed)
+				continue
+			}
+			p.traffic.countOut(msg.msgType, len(msg.data))
+		}
+	}
+}
+
+func (p *PeerWrapper) openControlStream() (SendStream, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, controlEnqueueTimeout)
+	defer cancel()
+	return p.conn.OpenUniStream(ctx)
+}
+
+// controlFailed sends the messages of the failed batch and those still
+// queued over streams of their own, order is lost from here on
+func (p *PeerWrapper) controlFailed(pending []controlMessage, err error) {
+	p.log.Debug("Control stream failed", "addr", p.RemoteAddr().String(), "err", err)
+	p.control.markDown()
+
+	for _, msg := range pending {
+		p.sendPending(msg)
+	}
+	for {
+		select {
+		case msg := <-p.control.queue:
+			p.sendPending(msg)
+		default:
+			return
+		}
+	}
+}
+
+func (p *PeerWrapper) sendPending(msg controlMessage) {
+	if msg.flushed != nil {
+		close(msg.flushed)
+		return
+	}
+	p.SendGossipStream(msg.msgType, msg.data)
+}
+
+// readControlStream delivers the frames of a control stream until it ends,
+// it owns the read deadline of the stream from here on
+func (p *PeerWrapper) readControlStream(stream ReceiveStream) error {
+	stream.SetReadDeadline(time.Time{})
+	for {
+		if err := readFrameTimed(stream, StreamKindControl, p.handleGossipFrame); err != nil {
+			return err
+		}
+	}
+}
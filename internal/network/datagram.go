@@ -0,0 +1,136 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// Every datagram starts with a header of the message type, the message id
+// and the index and count of its fragments. Messages that fit a single
+// datagram have a count of 1, larger ones are split into up to
+// maxDatagramFragments datagrams and lost fragments lose the whole message.
+const (
+	datagramHeaderSize   = 1 + 2 + 1 + 1
+	maxDatagramFragments = 4
+	datagramFragmentSize = maxDatagramSize - datagramHeaderSize
+
+	// MaxDatagramPayload is the largest message SendDatagram accepts
+	MaxDatagramPayload = maxDatagramFragments * datagramFragmentSize
+
+	// datagramReassemblyTimeout drops messages whose fragments stop arriving
+	datagramReassemblyTimeout = 3 * time.Second
+	// maxPartialDatagrams bounds the messages reassembled at once per connection
+	maxPartialDatagrams = 32
+)
+
+var errMalformedDatagram = errors.New("malformed datagram")
+
+type datagramHeader struct {
+	msgType MessageType
+	id      uint16
+	index   uint8
+	count   uint8
+}
+
+func (h datagramHeader) put(buf []byte) {
+	buf[0] = byte(h.msgType)
+	binary.BigEndian.PutUint16(buf[1:3], h.id)
+	buf[3] = h.index
+	buf[4] = h.count
+}
+
+func parseDatagram(data []byte) (datagramHeader, []byte, error) {
+	if len(data) <= datagramHeaderSize {
+		return datagramHeader{}, nil, errMalformedDatagram
+	}
+	h := datagramHeader{
+		msgType: MessageType(data[0]),
+		id:      binary.BigEndian.Uint16(data[1:3]),
+		index:   data[3],
+		count:   data[4],
+	}
+	if h.count == 0 || h.count > maxDatagramFragments || h.index >= h.count {
+		return datagramHeader{}, nil, errMalformedDatagram
+	}
+	return h, data[datagramHeaderSize:], nil
+}
+
+// fragmentDatagram splits data into datagrams carrying the header
+func fragmentDatagram(msgType MessageType, id uint16, data []byte) ([][]byte, error) {
+	if len(data) == 0 || len(data) > MaxDatagramPayload {
+		return nil, ErrDatagramLarge
+	}
+
+	count := (len(data) + datagramFragmentSize - 1) / datagramFragmentSize
+	datagrams := make([][]byte, 0, count)
+	for i := range count {
+		part := data[i*datagramFragmentSize : min((i+1)*datagramFragmentSize, len(data))]
+		buf := make([]byte, datagramHeaderSize+len(part))
+		datagramHeader{msgType: msgType, id: id, index: uint8(i), count: uint8(count)}.put(buf)
+		copy(buf[datagramHeaderSize:], part)
+		datagrams = append(datagrams, buf)
+	}
+	return datagrams, nil
+}
+
+type partialDatagram struct {
+	msgType  MessageType
+	parts    [][]byte
+	received int
+	size     int
+	deadline time.Time
+}
+
+// datagramReassembler joins fragmented messages, it is used by the single
+// datagram loop of a connection and is not safe for concurrent use
+type datagramReassembler struct {
+	partial map[uint16]*partialDatagram
+}
+
+func newDatagramReassembler() *datagramReassembler {
+	return &datagramReassembler{partial: make(map[uint16]*partialDatagram)}
+}
+
+// add returns the message once all of its fragments arrived
+func (r *datagramReassembler) add(h datagramHeader, part []byte, now time.Time) ([]byte, bool) {
+	if h.count == 1 {
+		return part, true
+	}
+
+	for id, p := range r.partial {
+		if now.After(p.deadline) {
+			delete(r.partial, id)
+		}
+	}
+
+	p, ok := r.partial[h.id]
+	if !ok {
+		if len(r.partial) >= maxPartialDatagrams {
+			return nil, false
+		}
+		p = &partialDatagram{
+			msgType:  h.msgType,
+			parts:    make([][]byte, h.count),
+			deadline: now.Add(datagramReassemblyTimeout),
+		}
+		r.partial[h.id] = p
+	}
+	if p.msgType != h.msgType || len(p.parts) != int(h.count) || p.parts[h.index] != nil {
+		return nil, false
+	}
+
+	p.parts[h.index] = part
+	p.received++
+	p.size += len(part)
+	if p.received < len(p.parts) {
+		return nil, false
+	}
+
+	delete(r.partial, h.id)
+	msg := make([]byte, 0, p.size)
+	for _, part := range p.parts {
+		msg = append(msg, part...)
+	}
+	return msg, true
+}
@@ -0,0 +1,203 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+)
+
+// fallbackDelay is how long a dial over one transport may take before the
+// next transport is tried
+const fallbackDelay = 3 * time.Second
+
+// FallbackTransport dials over its transports in order until one succeeds,
+// e.g. QUIC, then TCP for networks that block UDP, then WebSocket for ws://
+// addresses. All of them accept connections, the swarm sees a single channel.
+type FallbackTransport struct {
+	transports []Transport
+	log        *slog.Logger
+
+	connChan chan NewConnEvent
+	traffic  *Traffic
+	closing  chan struct{}
+	forwards sync.WaitGroup
+	closed   sync.Once
+
+	dialsOK     atomic.Uint64
+	dialsFailed atomic.Uint64
+}
+
+var _ Transport = (*FallbackTransport)(nil)
+
+// NewFallbackTransport combines transports, the first one is the primary
+func NewFallbackTransport(transports []Transport, log *slog.Logger) *FallbackTransport {
+	if log == nil {
+		log = slog.Default()
+	}
+	t := &FallbackTransport{
+		transports: transports,
+		log:        log,
+		connChan:   make(chan NewConnEvent),
+		traffic:    NewTraffic(),
+		closing:    make(chan struct{}),
+	}
+
+	for _, tr := range transports {
+		t.forwards.Add(1)
+		go t.forward(tr.ConnChan())
+	}
+	go func() {
+		t.forwards.Wait()
+		close(t.connChan)
+	}()
+	return t
+}
+
+// forward hands the connections of one transport to the shared channel,
+// they are counted in the traffic of the FallbackTransport
+func (t *FallbackTransport) forward(events <-chan NewConnEvent) {
+	defer t.forwards.Done()
+	for ev := range events {
+		ev.Traffic = t.traffic
+		select {
+		case t.connChan <- ev:
+		case <-t.closing:
+			ev.Conn.CloseWithError(ErrCodeNormalClose, "shutting down")
+		}
+	}
+}
+
+func (t *FallbackTransport) Listen(ctx context.Context) error {
+	for i, tr := range t.transports {
+		if err := tr.Listen(ctx); err != nil {
+			for _, started := range t.transports[:i] {
+				started.StopAccepting()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *FallbackTransport) Dial(ctx context.Context, addr string) (types.PeerID, error) {
+	peerID, err := t.dial(ctx, addr)
+	if err != nil {
+		t.dialsFailed.Add(1)
+	} else {
+		t.dialsOK.Add(1)
+	}
+	return peerID, err
+}
+
+// dial gives every transport but the last fallbackDelay, transports that
+// cannot dial addr at all are left out of the error
+func (t *FallbackTransport) dial(ctx context.Context, addr string) (types.PeerID, error) {
+	var errs []error
+	for i, tr := range t.transports {
+		dialCtx, cancel := ctx, context.CancelFunc(func() {})
+		if i < len(t.transports)-1 {
+			dialCtx, cancel = context.WithTimeout(ctx, fallbackDelay)
+		}
+		peerID, err := tr.Dial(dialCtx, addr)
+		cancel()
+		if err == nil {
+			return peerID, nil
+		}
+		if !errors.Is(err, ErrUnsupportedAddr) {
+			errs = append(errs, err)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		t.log.Debug("Dial failed, trying next transport", "addr", addr, "err", err)
+	}
+	if len(errs) == 0 {
+		return types.PeerID{}, ErrUnsupportedAddr
+	}
+	return types.PeerID{}, errors.Join(errs...)
+}
+
+// findTransport returns t, or the first transport of a FallbackTransport,
+// that is a T
+func findTransport[T any](t Transport) (T, bool) {
+	if fallback, ok := t.(*FallbackTransport); ok {
+		for _, tr := range fallback.transports {
+			if found, ok := findTransport[T](tr); ok {
+				return found, true
+			}
+		}
+		var zero T
+		return zero, false
+	}
+	found, ok := t.(T)
+	return found, ok
+}
+
+func (t *FallbackTransport) ConnChan() <-chan NewConnEvent {
+	return t.connChan
+}
+
+// Addr is the address of the primary transport
+func (t *FallbackTransport) Addr() net.Addr {
+	return t.transports[0].Addr()
+}
+
+// Addrs returns the addresses of all listening transports, the primary first
+func (t *FallbackTransport) Addrs() []net.Addr {
+	addrs := make([]net.Addr, 0, len(t.transports))
+	for _, tr := range t.transports {
+		if addr := tr.Addr(); addr != nil {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// ListenAddrs returns the addresses t, or the transports of a
+// FallbackTransport, accept connections on. QUIC and TCP share host and port,
+// so it appears once.
+func ListenAddrs(t Transport) []string {
+	all := []net.Addr{t.Addr()}
+	if fallback, ok := t.(*FallbackTransport); ok {
+		all = fallback.Addrs()
+	}
+	var addrs []string
+	for _, addr := range all {
+		if addr != nil && !slices.Contains(addrs, addr.String()) {
+			addrs = append(addrs, addr.String())
+		}
+	}
+	return addrs
+}
+
+func (t *FallbackTransport) Traffic() *Traffic {
+	return t.traffic
+}
+
+func (t *FallbackTransport) DialStats() (succeeded, failed uint64) {
+	return t.dialsOK.Load(), t.dialsFailed.Load()
+}
+
+func (t *FallbackTransport) StopAccepting() {
+	for _, tr := range t.transports {
+		tr.StopAccepting()
+	}
+}
+
+func (t *FallbackTransport) Close() error {
+	t.closed.Do(func() {
+		close(t.closing)
+	})
+	var errs []error
+	for _, tr := range t.transports {
+		errs = append(errs, tr.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,122 @@
+package network
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+)
+
+// benchTimeout fails a run whose messages were lost
+const benchTimeout = 30 * time.Second
+
+// BenchmarkGossipControlStream sends gossip over the persistent control stream
+func BenchmarkGossipControlStream(b *testing.B) {
+	for _, size := range []int{64, 512, 4096} {
+		b.Run(sizeName(size), func(b *testing.B) {
+			benchGossip(b, size, func(p *PeerWrapper) func(MessageType, []byte) error {
+				return p.SendGossipMessage
+			})
+		})
+	}
+}
+
+// BenchmarkGossipUniStreams sends gossip with one uni stream per message, the
+// path for peers below VersionControlStream
+func BenchmarkGossipUniStreams(b *testing.B) {
+	for _, size := range []int{64, 512, 4096} {
+		b.Run(sizeName(size), func(b *testing.B) {
+			benchGossip(b, size, func(p *PeerWrapper) func(MessageType, []byte) error {
+				return p.SendGossipStream
+			})
+		})
+	}
+}
+
+func sizeName(size int) string {
+	if size >= 1024 {
+		return strconv.Itoa(size/1024) + "KiB"
+	}
+	return strconv.Itoa(size) + "B"
+}
+
+// benchGossip connects two wrappers over a MemoryNetwork and sends b.N
+// messages of size bytes from parallel senders until all are delivered
+func benchGossip(b *testing.B, size int, sendFunc func(*PeerWrapper) func(MessageType, []byte) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	sender, receiver := newWrapperPair(b, ctx, log)
+	defer sender.Close()
+	defer receiver.Close()
+
+	var delivered atomic.Int64
+	done := make(chan struct{})
+	target := int64(b.N)
+	receiver.OnData(func(_ MessageType, _ []byte, _ types.PeerID) {
+		if delivered.Add(1) == target {
+			close(done)
+		}
+	})
+	receiver.StartLoops()
+	sender.StartLoops()
+
+	send := sendFunc(sender)
+	payload := make([]byte, size)
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := send(TypeGossip, payload); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+
+	select {
+	case <-done:
+	case <-time.After(benchTimeout):
+		b.Fatalf("delivered %d of %d messages", delivered.Load(), target)
+	}
+}
+
+// newWrapperPair returns both ends of a fresh in-memory connection
+func newWrapperPair(tb testing.TB, ctx context.Context, log *slog.Logger) (*PeerWrapper, *PeerWrapper) {
+	tb.Helper()
+	memNet := NewMemoryNetwork()
+	protocol := Protocol{MinVersion: VersionSignedHello, MaxVersion: VersionIdentify}
+
+	newTransport := func() *MemoryTransport {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		t, err := memNet.NewTransport("", types.PeerPrivateKey(priv), protocol, log)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		tb.Cleanup(func() { t.Close() })
+		return t
+	}
+	a, c := newTransport(), newTransport()
+	if err := c.Listen(ctx); err != nil {
+		tb.Fatal(err)
+	}
+	if _, err := a.Dial(ctx, c.Addr().String()); err != nil {
+		tb.Fatal(err)
+	}
+	evA, evC := <-a.ConnChan(), <-c.ConnChan()
+	return NewPeerWrapper(ctx, evA.Conn, evA.PeerID, evA.Version, evA.Traffic, log),
+		NewPeerWrapper(ctx, evC.Conn, evC.PeerID, evC.Version, evC.Traffic, log)
+}
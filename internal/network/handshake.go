@@ -1,16 +1,20 @@
 package network
 
 import (
+	"encoding/binary"
 	"errors"
+	"io"
 
 	"github.com/DmytroBuzhylov/echofog-core/internal/crypto"
 	internal_pb "github.com/DmytroBuzhylov/echofog-core/internal/proto"
 	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
-	"github.com/quic-go/quic-go"
 	"google.golang.org/protobuf/proto"
 )
 
-func sendHandshake(stream *quic.Stream, nonce []byte) error {
+// errUnsignedHello is returned for peers older than VersionSignedHello
+var errUnsignedHello = errors.New("handshake response does not sign the protocol")
+
+func sendHandshake(stream io.Writer, nonce []byte) error {
 	//nonce := make([]byte, 32)
 	//rand.Read(nonce)
 
@@ -24,54 +28,64 @@ func sendHandshake(stream *quic.Stream, nonce []byte) error {
 		return err
 	}
 
-	return writeFrame(stream, TypeHandshake, data)
+	return writeFrame(stream, StreamKindHandshake, TypeHandshake, data)
 }
 
-func checkHandshakeResponse(stream *quic.Stream, nonce []byte) (types.PeerPublicKey, error) {
+// helloSignedData is what a handshake response signs: the nonce of the other
+// side, the key and the announced protocol, so none of them can be replaced
+// on the way
+func helloSignedData(nonce, pubKey []byte, protocol Protocol) []byte {
+	data := make([]byte, 0, len(nonce)+len(pubKey)+16)
+	data = append(data, nonce...)
+	data = append(data, pubKey...)
+	data = binary.BigEndian.AppendUint32(data, protocol.MinVersion)
+	data = binary.BigEndian.AppendUint32(data, protocol.MaxVersion)
+	return binary.BigEndian.AppendUint64(data, uint64(protocol.Capabilities))
+}
 
-	msgType, protoData, err := readFrame(stream)
-	if err != nil {
-		return types.PeerPublicKey{}, err
-	}
-	if msgType != TypeHandshake {
-		return types.PeerPublicKey{}, errors.New("message type is not handshake")
-	}
+// checkHandshakeResponse verifies the signed nonce and protocol and returns
+// the key and the protocol the peer announced
+func checkHandshakeResponse(stream io.Reader, nonce []byte) (types.PeerPublicKey, Protocol, error) {
 
 	var data internal_pb.MessageData
-	err = proto.Unmarshal(protoData, &data)
-	if err != nil {
-		return types.PeerPublicKey{}, err
+	if err := readHandshakeFrame(stream, &data); err != nil {
+		return types.PeerPublicKey{}, Protocol{}, err
 	}
 
 	switch msg := data.Payload.(type) {
 	case *internal_pb.MessageData_HandshakeResponse:
-		dataForVerify := append(nonce, msg.HandshakeResponse.GetPubKey()...)
-		pubKey := types.PeerPublicKey(msg.HandshakeResponse.GetPubKey())
+		resp := msg.HandshakeResponse
+		if len(resp.GetPubKey()) != len(types.PeerPublicKey{}) {
+			return types.PeerPublicKey{}, Protocol{}, errors.New("invalid public key size")
+		}
+		pubKey := types.PeerPublicKey(resp.GetPubKey())
+		remote := Protocol{
+			MinVersion:   resp.GetMinVersion(),
+			MaxVersion:   resp.GetVersion(),
+			Capabilities: Capabilities(resp.GetCapabilities()),
+		}
+		// peers from before signed hellos cannot be verified
+		if remote.MaxVersion < VersionSignedHello {
+			if remote.MinVersion == 0 {
+				remote.MinVersion = remote.MaxVersion
+			}
+			return types.PeerPublicKey{}, remote, errUnsignedHello
+		}
 
-		ok := crypto.VerifySignature(pubKey, dataForVerify, msg.HandshakeResponse.GetSignature())
+		ok := crypto.VerifySignature(pubKey, helloSignedData(nonce, pubKey[:], remote), resp.GetSignature())
 		if !ok {
-			return types.PeerPublicKey{}, errors.New("invalid signature")
+			return types.PeerPublicKey{}, Protocol{}, errors.New("invalid signature")
 		}
-
-		return types.PeerPublicKey(msg.HandshakeResponse.GetPubKey()), nil
+		return pubKey, remote, nil
 	default:
-		return types.PeerPublicKey{}, errors.New("invalid proto type")
+		return types.PeerPublicKey{}, Protocol{}, errors.New("invalid proto type")
 	}
 
 }
 
-func acceptHandshake(stream *quic.Stream, privKey types.PeerPrivateKey, version uint32) error {
-	msgType, protoData, err := readFrame(stream)
-	if err != nil {
-		return err
-	}
-	if msgType != TypeHandshake {
-		return errors.New("message type is not handshake")
-	}
-
+func acceptHandshake(stream io.ReadWriter, privKey types.PeerPrivateKey, protocol Protocol) error {
 	var data internal_pb.MessageData
-	err = proto.Unmarshal(protoData, &data)
-	if err != nil {
+	if err := readHandshakeFrame(stream, &data); err != nil {
 		return err
 	}
 	hs, ok := data.Payload.(*internal_pb.MessageData_HandshakeInit)
@@ -81,16 +95,16 @@ func acceptHandshake(stream *quic.Stream, privKey types.PeerPrivateKey, version
 
 	myPubKey := types.PeerPrivateKeyToPublic(privKey)
 
-	dataForSiganature := append(hs.HandshakeInit.GetNonce(), myPubKey[:]...)
-
-	signature := crypto.CreateSignature(dataForSiganature, privKey[:])
+	signature := crypto.CreateSignature(helloSignedData(hs.HandshakeInit.GetNonce(), myPubKey[:], protocol), privKey[:])
 
 	hsResp := &internal_pb.MessageData{
 		Payload: &internal_pb.MessageData_HandshakeResponse{
 			HandshakeResponse: &internal_pb.HandshakeResponse{
-				PubKey:    myPubKey[:],
-				Signature: signature,
-				Version:   version,
+				PubKey:       myPubKey[:],
+				Signature:    signature,
+				Version:      protocol.MaxVersion,
+				MinVersion:   protocol.MinVersion,
+				Capabilities: uint64(protocol.Capabilities),
 			},
 		},
 	}
@@ -99,5 +113,14 @@ func acceptHandshake(stream *quic.Stream, privKey types.PeerPrivateKey, version
 	if err != nil {
 		return err
 	}
-	return writeFrame(stream, TypeHandshake, bytes)
+	return writeFrame(stream, StreamKindHandshake, TypeHandshake, bytes)
+}
+
+func readHandshakeFrame(stream io.Reader, data *internal_pb.MessageData) error {
+	return readFrameFunc(stream, StreamKindHandshake, func(msgType MessageType, payload []byte) error {
+		if msgType != TypeHandshake {
+			return errors.New("message type is not handshake")
+		}
+		return proto.Unmarshal(payload, data)
+	})
 }
@@ -2,36 +2,30 @@ package network
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
 )
 
-func writeLengthPrefix(w io.Writer, data []byte) error {
-	buf := make([]byte, 4+len(data))
+const lengthPrefixSize = 4
 
-	binary.BigEndian.PutUint32(buf[:4], uint32(len(data)))
-
-	copy(buf[4:], data)
-
-	_, err := w.Write(buf)
-	return err
+func putLengthPrefix(buf []byte, length int) {
+	binary.BigEndian.PutUint32(buf[:lengthPrefixSize], uint32(length))
 }
 
-func readLengthPrefix(r io.Reader) ([]byte, error) {
-	header := make([]byte, 4)
-
-	_, err := io.ReadFull(r, header)
-	if err != nil {
-		return nil, err
+// readLengthPrefix reads the length of the next frame, a length of zero or
+// above maxSize is a protocol violation
+func readLengthPrefix(r io.Reader, maxSize int) (int, error) {
+	var header [lengthPrefixSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, err
 	}
 
-	length := binary.BigEndian.Uint32(header)
-
-	payload := make([]byte, length)
-
-	_, err = io.ReadFull(r, payload)
-	if err != nil {
-		return nil, err
+	length := binary.BigEndian.Uint32(header[:])
+	switch {
+	case length == 0:
+		return 0, ErrEmptyFrame
+	case uint64(length) > uint64(maxSize):
+		return 0, fmt.Errorf("%w: %d > %d bytes", ErrFrameTooLarge, length, maxSize)
 	}
-
-	return payload, nil
+	return int(length), nil
 }
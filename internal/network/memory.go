@@ -0,0 +1,362 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+)
+
+const (
+	// MemoryNetworkName is what net.Addr.Network returns for in-memory addresses
+	MemoryNetworkName = "memory"
+	// memoryHost is the host part of every in-memory address, "mem:1" is a valid dial address
+	memoryHost = "mem"
+
+	memoryAcceptBacklog  = 16
+	memoryStreamBacklog  = 100
+	memoryDatagramBuffer = 100
+)
+
+var (
+	ErrAddrInUse    = errors.New("address already in use")
+	ErrNotListening = errors.New("connection refused")
+)
+
+// MemoryNetwork connects MemoryTransports of the same process without any
+// sockets, so whole swarms can run inside a unit test
+type MemoryNetwork struct {
+	mu         sync.Mutex
+	transports map[string]*MemoryTransport
+	nextPort   int
+}
+
+func NewMemoryNetwork() *MemoryNetwork {
+	return &MemoryNetwork{
+		transports: make(map[string]*MemoryTransport),
+	}
+}
+
+// NewTransport binds addr, "mem:0" or an empty addr picks a free port
+func (n *MemoryNetwork) NewTransport(addr string, privKey types.PeerPrivateKey, protocol Protocol, log *slog.Logger) (*MemoryTransport, error) {
+	port := 0
+	if addr != "" {
+		host, portStr, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if host != memoryHost {
+			return nil, fmt.Errorf("invalid memory address %q, expected %s:<port>", addr, memoryHost)
+		}
+		if port, err = strconv.Atoi(portStr); err != nil || port < 0 {
+			return nil, fmt.Errorf("invalid port in %q", addr)
+		}
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if port == 0 {
+		for {
+			n.nextPort++
+			if _, used := n.transports[memoryAddr(n.nextPort).String()]; !used {
+				break
+			}
+		}
+		port = n.nextPort
+	}
+	local := memoryAddr(port)
+	if _, used := n.transports[local.String()]; used {
+		return nil, fmt.Errorf("listen %s: %w", local, ErrAddrInUse)
+	}
+
+	t := &MemoryTransport{
+		baseTransport: newBaseTransport(privKey, protocol, log),
+		network:       n,
+		addr:          local,
+		incoming:      make(chan *memConn, memoryAcceptBacklog),
+	}
+	n.transports[local.String()] = t
+	return t, nil
+}
+
+func (n *MemoryNetwork) lookup(addr string) *MemoryTransport {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.transports[addr]
+}
+
+func (n *MemoryNetwork) remove(t *MemoryTransport) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.transports[t.addr.String()] == t {
+		delete(n.transports, t.addr.String())
+	}
+}
+
+type memoryAddr int
+
+func (a memoryAddr) Network() string { return MemoryNetworkName }
+func (a memoryAddr) String() string  { return memoryHost + ":" + strconv.Itoa(int(a)) }
+
+// MemoryTransport is a Transport on a MemoryNetwork, it runs the same
+// handshake as QuicTransport
+type MemoryTransport struct {
+	*baseTransport
+
+	network *MemoryNetwork
+	addr    memoryAddr
+
+	mu           sync.Mutex
+	listening    bool
+	incoming     chan *memConn
+	acceptCancel context.CancelFunc
+}
+
+var _ Transport = (*MemoryTransport)(nil)
+
+func (t *MemoryTransport) Addr() net.Addr {
+	return t.addr
+}
+
+func (t *MemoryTransport) Listen(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.listening {
+		return errors.New("MemoryTransport is already listening")
+	}
+
+	acceptCtx, cancel := context.WithCancel(ctx)
+	t.listening = true
+	t.acceptCancel = cancel
+
+	t.acceptWg.Add(1)
+	go func() {
+		defer t.acceptWg.Done()
+		for {
+			select {
+			case conn := <-t.incoming:
+				t.acceptInbound(acceptCtx, conn, nil)
+			case <-acceptCtx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (t *MemoryTransport) StopAccepting() {
+	t.mu.Lock()
+	t.listening = false
+	if t.acceptCancel != nil {
+		t.acceptCancel()
+	}
+	t.mu.Unlock()
+
+	t.acceptWg.Wait()
+	for {
+		select {
+		case conn := <-t.incoming:
+			conn.CloseWithError(ErrCodeNormalClose, "shutting down")
+		default:
+			return
+		}
+	}
+}
+
+// Close frees the address for other transports
+func (t *MemoryTransport) Close() error {
+	t.StopAccepting()
+	if t.closeConnChan() {
+		t.network.remove(t)
+	}
+	return nil
+}
+
+func (t *MemoryTransport) Dial(ctx context.Context, addr string) (types.PeerID, error) {
+	peerID, err := t.dial(ctx, addr)
+	t.countDial(err)
+	return peerID, err
+}
+
+func (t *MemoryTransport) dial(ctx context.Context, addr string) (types.PeerID, error) {
+	remote := t.network.lookup(addr)
+	if remote == nil {
+		return types.PeerID{}, fmt.Errorf("dial %s: %w", addr, ErrNotListening)
+	}
+
+	local, accepted := newMemConnPair(t.addr, remote.addr)
+	if err := remote.enqueue(accepted); err != nil {
+		local.CloseWithError(ErrCodeNormalClose, "dial failed")
+		return types.PeerID{}, fmt.Errorf("dial %s: %w", addr, err)
+	}
+
+	return t.handshakeOutbound(ctx, local, nil)
+}
+
+// enqueue refuses the connection when the transport is not listening or its
+// backlog is full
+func (t *MemoryTransport) enqueue(conn *memConn) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.listening {
+		return ErrNotListening
+	}
+
+	select {
+	case t.incoming <- conn:
+		return nil
+	default:
+		return ErrNotListening
+	}
+}
+
+// memConn is one end of an in-memory connection
+type memConn struct {
+	local, remote memoryAddr
+	peer          *memConn
+
+	streams    chan *memStream
+	uniStreams chan *memStream
+	datagrams  chan []byte
+	nextStream atomic.Int64
+	// streamBit keeps the stream IDs of both ends apart, like the initiator bit of QUIC
+	streamBit int64
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	// done is shared by both ends and closed by the first CloseWithError
+	done      chan struct{}
+	closeOnce *sync.Once
+}
+
+var _ Conn = (*memConn)(nil)
+
+func newMemConnPair(dialer, listener memoryAddr) (*memConn, *memConn) {
+	done := make(chan struct{})
+	once := new(sync.Once)
+
+	newEnd := func(local, remote memoryAddr, streamBit int64) *memConn {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		return &memConn{
+			local:      local,
+			remote:     remote,
+			streams:    make(chan *memStream, memoryStreamBacklog),
+			uniStreams: make(chan *memStream, memoryStreamBacklog),
+			datagrams:  make(chan []byte, memoryDatagramBuffer),
+			streamBit:  streamBit,
+			ctx:        ctx,
+			cancel:     cancel,
+			done:       done,
+			closeOnce:  once,
+		}
+	}
+
+	a := newEnd(dialer, listener, 0)
+	b := newEnd(listener, dialer, 1)
+	a.peer, b.peer = b, a
+	return a, b
+}
+
+// openPair creates both ends of a stream and queues the remote end on ch of the peer
+func (c *memConn) openPair(ctx context.Context, uni bool) (*memStream, error) {
+	id := StreamID(c.nextStream.Add(1)<<1 | c.streamBit)
+	toRemote := newPipe(c.done)
+	local := &memStream{id: id, out: toRemote}
+	remote := &memStream{id: id, in: toRemote}
+	queue := c.peer.uniStreams
+	if !uni {
+		toLocal := newPipe(c.done)
+		local.in = toLocal
+		remote.out = toLocal
+		queue = c.peer.streams
+	}
+
+	select {
+	case queue <- remote:
+		return local, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, context.Cause(c.ctx)
+	}
+}
+
+func (c *memConn) OpenStream(ctx context.Context) (RawStream, error) {
+	return c.openPair(ctx, false)
+}
+
+func (c *memConn) OpenUniStream(ctx context.Context) (SendStream, error) {
+	return c.openPair(ctx, true)
+}
+
+func (c *memConn) accept(ctx context.Context, ch chan *memStream) (*memStream, error) {
+	select {
+	case stream := <-ch:
+		return stream, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, context.Cause(c.ctx)
+	}
+}
+
+func (c *memConn) AcceptStream(ctx context.Context) (RawStream, error) {
+	return c.accept(ctx, c.streams)
+}
+
+func (c *memConn) AcceptUniStream(ctx context.Context) (ReceiveStream, error) {
+	return c.accept(ctx, c.uniStreams)
+}
+
+// SendDatagram drops the datagram when the receiver is not keeping up, like UDP
+func (c *memConn) SendDatagram(data []byte) error {
+	if len(data) > maxDatagramSize {
+		return ErrDatagramLarge
+	}
+	select {
+	case <-c.done:
+		return context.Cause(c.ctx)
+	default:
+	}
+
+	select {
+	case c.peer.datagrams <- append([]byte(nil), data...):
+	default:
+	}
+	return nil
+}
+
+func (c *memConn) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	select {
+	case data := <-c.datagrams:
+		return data, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, context.Cause(c.ctx)
+	}
+}
+
+func (c *memConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *memConn) Context() context.Context {
+	return c.ctx
+}
+
+func (c *memConn) CloseWithError(code ErrorCode, reason string) error {
+	c.closeOnce.Do(func() {
+		c.cancel(&ConnError{Code: code, Message: reason})
+		c.peer.cancel(&ConnError{Code: code, Message: reason, Remote: true})
+		close(c.done)
+	})
+	return nil
+}
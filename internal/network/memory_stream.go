@@ -0,0 +1,196 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+var errWriteClosed = errors.New("write on closed stream")
+
+// memStream is one end of an in-memory stream, uni streams only have in or out
+type memStream struct {
+	id  StreamID
+	in  *pipe
+	out *pipe
+}
+
+var _ RawStream = (*memStream)(nil)
+
+func (s *memStream) StreamID() StreamID {
+	return s.id
+}
+
+func (s *memStream) Read(b []byte) (int, error) {
+	return s.in.read(b)
+}
+
+func (s *memStream) Write(b []byte) (int, error) {
+	return s.out.write(b)
+}
+
+// Close finishes the sending direction, reading is still possible
+func (s *memStream) Close() error {
+	s.out.closeWrite()
+	return nil
+}
+
+func (s *memStream) CancelRead(code ErrorCode) {
+	s.in.cancelRead(code)
+}
+
+func (s *memStream) SetReadDeadline(t time.Time) error {
+	s.in.setReadDeadline(t)
+	return nil
+}
+
+func (s *memStream) SetWriteDeadline(t time.Time) error {
+	s.out.setWriteDeadline(t)
+	return nil
+}
+
+func (s *memStream) SetDeadline(t time.Time) error {
+	s.SetReadDeadline(t)
+	return s.SetWriteDeadline(t)
+}
+
+// pipe is one direction of an in-memory stream. Writes never block, the
+// buffer grows until the reader catches up.
+type pipe struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	// eof is set when the writer closed its side
+	eof bool
+	// canceled is set when the reader gave up, writes fail with it
+	canceled error
+
+	readDeadline  time.Time
+	writeDeadline time.Time
+
+	// changed is closed and replaced whenever a blocked reader should look again
+	changed  chan struct{}
+	connDone <-chan struct{}
+}
+
+func newPipe(connDone <-chan struct{}) *pipe {
+	return &pipe{
+		changed:  make(chan struct{}),
+		connDone: connDone,
+	}
+}
+
+func (p *pipe) notify() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+func (p *pipe) connClosed() bool {
+	select {
+	case <-p.connDone:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *pipe) read(b []byte) (int, error) {
+	for {
+		if p.connClosed() {
+			return 0, net.ErrClosed
+		}
+
+		p.mu.Lock()
+		switch {
+		case p.canceled != nil:
+			p.mu.Unlock()
+			return 0, p.canceled
+		case p.buf.Len() > 0:
+			n, _ := p.buf.Read(b)
+			p.mu.Unlock()
+			return n, nil
+		case p.eof:
+			p.mu.Unlock()
+			return 0, io.EOF
+		}
+		deadline := p.readDeadline
+		changed := p.changed
+		p.mu.Unlock()
+
+		if deadline.IsZero() {
+			select {
+			case <-changed:
+			case <-p.connDone:
+			}
+			continue
+		}
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return 0, os.ErrDeadlineExceeded
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-changed:
+		case <-p.connDone:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+func (p *pipe) write(b []byte) (int, error) {
+	if p.connClosed() {
+		return 0, net.ErrClosed
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case p.canceled != nil:
+		return 0, p.canceled
+	case p.eof:
+		return 0, errWriteClosed
+	case !p.writeDeadline.IsZero() && !time.Now().Before(p.writeDeadline):
+		return 0, os.ErrDeadlineExceeded
+	}
+
+	p.buf.Write(b)
+	p.notify()
+	return len(b), nil
+}
+
+func (p *pipe) closeWrite() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.eof {
+		p.eof = true
+		p.notify()
+	}
+}
+
+func (p *pipe) cancelRead(code ErrorCode) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.canceled == nil {
+		p.canceled = &StreamError{Code: code}
+		p.buf.Reset()
+		p.notify()
+	}
+}
+
+func (p *pipe) setReadDeadline(t time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.readDeadline = t
+	p.notify()
+}
+
+func (p *pipe) setWriteDeadline(t time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.writeDeadline = t
+}
@@ -0,0 +1,664 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// muxSession multiplexes streams and datagrams over one reliable connection,
+// it gives the TCP and WebSocket transports the shape of a QUIC connection. Every frame
+// starts with a header of type, flags, stream ID and length. Data frames are
+// followed by length bytes, window updates, stop sending and go away frames
+// carry their value in the length field. A go away frame carries the error
+// code and, in place of the stream ID, the length of the reason that follows.
+const (
+	muxHeaderLen     = 1 + 1 + 4 + 4
+	muxMaxFrame      = 16 << 10
+	muxInitialWindow = 256 << 10
+	muxMaxReason     = 1024
+	muxAcceptBacklog = 256
+	muxDatagrams     = 100
+	muxGoAwayTimeout = time.Second
+
+	// muxMaxIncomingStreams caps the open streams of each kind the remote
+	// side may have opened, the same as the QUIC transport allows
+	muxMaxIncomingStreams = 1000
+)
+
+const (
+	muxData byte = iota
+	muxWindowUpdate
+	muxStopSending
+	muxDatagram
+	muxGoAway
+)
+
+const (
+	// muxFlagSYN opens a stream, muxFlagUni makes it unidirectional
+	muxFlagSYN byte = 1 << iota
+	muxFlagUni
+	// muxFlagFIN ends the sending direction
+	muxFlagFIN
+)
+
+type muxSession struct {
+	conn       net.Conn
+	remoteAddr net.Addr
+	client     bool
+
+	mu        sync.Mutex
+	streams   map[uint32]*muxStream
+	nextID    uint32
+	accept    chan *muxStream
+	acceptUni chan *muxStream
+	datagrams chan []byte
+	// incoming and incomingUni count the open streams the remote side opened
+	incoming    int
+	incomingUni int
+
+	writeMu sync.Mutex
+
+	ctx       context.Context
+	cancel    context.CancelCauseFunc
+	closeOnce sync.Once
+}
+
+var _ Conn = (*muxSession)(nil)
+
+// newMuxSession starts reading frames from conn. The dialing side opens odd
+// stream IDs and the accepting side even ones.
+func newMuxSession(conn net.Conn, remoteAddr net.Addr, client bool) *muxSession {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	s := &muxSession{
+		conn:       conn,
+		remoteAddr: remoteAddr,
+		client:     client,
+		streams:    make(map[uint32]*muxStream),
+		nextID:     2,
+		accept:     make(chan *muxStream, muxAcceptBacklog),
+		acceptUni:  make(chan *muxStream, muxAcceptBacklog),
+		datagrams:  make(chan []byte, muxDatagrams),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	if client {
+		s.nextID = 1
+	}
+
+	go s.readLoop()
+	return s
+}
+
+func (s *muxSession) open(ctx context.Context, uni bool) (*muxStream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if s.ctx.Err() != nil {
+		s.mu.Unlock()
+		return nil, context.Cause(s.ctx)
+	}
+	id := s.nextID
+	s.nextID += 2
+	stream := newMuxStream(s, id, !uni, true)
+	s.streams[id] = stream
+	s.mu.Unlock()
+
+	flags := muxFlagSYN
+	if uni {
+		flags |= muxFlagUni
+	}
+	if err := s.writeFrame(muxData, flags, id, 0, nil); err != nil {
+		s.remove(id)
+		return nil, err
+	}
+	return stream, nil
+}
+
+func (s *muxSession) OpenStream(ctx context.Context) (RawStream, error) {
+	return s.open(ctx, false)
+}
+
+func (s *muxSession) OpenUniStream(ctx context.Context) (SendStream, error) {
+	return s.open(ctx, true)
+}
+
+func (s *muxSession) acceptFrom(ctx context.Context, ch chan *muxStream) (*muxStream, error) {
+	select {
+	case stream := <-ch:
+		return stream, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-s.ctx.Done():
+		return nil, context.Cause(s.ctx)
+	}
+}
+
+func (s *muxSession) AcceptStream(ctx context.Context) (RawStream, error) {
+	return s.acceptFrom(ctx, s.accept)
+}
+
+func (s *muxSession) AcceptUniStream(ctx context.Context) (ReceiveStream, error) {
+	return s.acceptFrom(ctx, s.acceptUni)
+}
+
+func (s *muxSession) SendDatagram(data []byte) error {
+	if len(data) > maxDatagramSize {
+		return ErrDatagramLarge
+	}
+	return s.writeFrame(muxDatagram, 0, 0, uint32(len(data)), data)
+}
+
+func (s *muxSession) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	select {
+	case data := <-s.datagrams:
+		return data, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-s.ctx.Done():
+		return nil, context.Cause(s.ctx)
+	}
+}
+
+func (s *muxSession) RemoteAddr() net.Addr {
+	return s.remoteAddr
+}
+
+func (s *muxSession) Context() context.Context {
+	return s.ctx
+}
+
+// CloseWithError tells the remote side why with a go away frame and closes the connection
+func (s *muxSession) CloseWithError(code ErrorCode, reason string) error {
+	if s.ctx.Err() != nil {
+		return nil
+	}
+	if len(reason) > muxMaxReason {
+		reason = reason[:muxMaxReason]
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(muxGoAwayTimeout))
+	s.writeFrame(muxGoAway, 0, uint32(len(reason)), uint32(code), []byte(reason))
+	s.shutdown(&ConnError{Code: code, Message: reason})
+	return nil
+}
+
+func (s *muxSession) shutdown(cause error) {
+	s.closeOnce.Do(func() {
+		s.cancel(cause)
+		s.conn.Close()
+	})
+}
+
+func (s *muxSession) remove(id uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stream, ok := s.streams[id]
+	if !ok {
+		return
+	}
+	delete(s.streams, id)
+	if s.remoteOpened(id) {
+		if stream.canWrite {
+			s.incoming--
+		} else {
+			s.incomingUni--
+		}
+	}
+}
+
+// remoteOpened reports whether the remote side picked the stream ID
+func (s *muxSession) remoteOpened(id uint32) bool {
+	return (id%2 == 1) != s.client
+}
+
+func (s *muxSession) stream(id uint32) *muxStream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.streams[id]
+}
+
+// writeFrame sends one frame, value goes into the length field
+func (s *muxSession) writeFrame(frameType, flags byte, id uint32, value uint32, payload []byte) error {
+	buf := make([]byte, muxHeaderLen+len(payload))
+	buf[0] = frameType
+	buf[1] = flags
+	binary.BigEndian.PutUint32(buf[2:6], id)
+	binary.BigEndian.PutUint32(buf[6:10], value)
+	copy(buf[muxHeaderLen:], payload)
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if s.ctx.Err() != nil {
+		return context.Cause(s.ctx)
+	}
+	if _, err := s.conn.Write(buf); err != nil {
+		s.shutdown(err)
+		return err
+	}
+	return nil
+}
+
+func (s *muxSession) readLoop() {
+	header := make([]byte, muxHeaderLen)
+	for {
+		if _, err := io.ReadFull(s.conn, header); err != nil {
+			s.shutdown(err)
+			s.closeStreams()
+			return
+		}
+		if err := s.handleFrame(header); err != nil {
+			if readErr, ok := err.(muxReadError); ok {
+				s.shutdown(readErr.error)
+			} else {
+				s.CloseWithError(ErrCodeProtocolViolation, err.Error())
+			}
+			s.closeStreams()
+			return
+		}
+		if s.ctx.Err() != nil {
+			s.closeStreams()
+			return
+		}
+	}
+}
+
+// muxReadError is a failed read of the connection, not a misbehaving peer
+type muxReadError struct {
+	error
+}
+
+func (s *muxSession) handleFrame(header []byte) error {
+	frameType, flags := header[0], header[1]
+	id := binary.BigEndian.Uint32(header[2:6])
+	value := binary.BigEndian.Uint32(header[6:10])
+
+	switch frameType {
+	case muxData:
+		if value > muxMaxFrame {
+			return fmt.Errorf("data frame of %d bytes", value)
+		}
+		payload := make([]byte, value)
+		if _, err := io.ReadFull(s.conn, payload); err != nil {
+			return muxReadError{err}
+		}
+		return s.handleData(id, flags, payload)
+
+	case muxWindowUpdate:
+		if stream := s.stream(id); stream != nil {
+			stream.addSendWindow(value)
+		}
+
+	case muxStopSending:
+		if stream := s.stream(id); stream != nil {
+			stream.stopSending(ErrorCode(value))
+		}
+
+	case muxDatagram:
+		if value > maxDatagramSize {
+			return fmt.Errorf("datagram of %d bytes", value)
+		}
+		data := make([]byte, value)
+		if _, err := io.ReadFull(s.conn, data); err != nil {
+			return muxReadError{err}
+		}
+		// dropped when nobody keeps up, like any datagram
+		select {
+		case s.datagrams <- data:
+		default:
+		}
+
+	case muxGoAway:
+		if id > muxMaxReason {
+			return fmt.Errorf("go away reason of %d bytes", id)
+		}
+		reason := make([]byte, id)
+		if _, err := io.ReadFull(s.conn, reason); err != nil {
+			return muxReadError{err}
+		}
+		s.shutdown(&ConnError{Code: ErrorCode(value), Message: string(reason), Remote: true})
+
+	default:
+		return fmt.Errorf("unknown frame type %d", frameType)
+	}
+	return nil
+}
+
+func (s *muxSession) handleData(id uint32, flags byte, payload []byte) error {
+	if flags&muxFlagSYN != 0 {
+		if !s.remoteOpened(id) {
+			return fmt.Errorf("stream %d opened with a local ID", id)
+		}
+		uni := flags&muxFlagUni != 0
+		stream := newMuxStream(s, id, true, !uni)
+
+		s.mu.Lock()
+		if _, exists := s.streams[id]; exists {
+			s.mu.Unlock()
+			return fmt.Errorf("stream %d opened twice", id)
+		}
+		count := &s.incoming
+		if uni {
+			count = &s.incomingUni
+		}
+		if *count >= muxMaxIncomingStreams {
+			s.mu.Unlock()
+			s.reject(id, uni)
+			return nil
+		}
+		*count++
+		s.streams[id] = stream
+		s.mu.Unlock()
+
+		queue := s.accept
+		if uni {
+			queue = s.acceptUni
+		}
+		select {
+		case queue <- stream:
+		default:
+			s.remove(id)
+			s.reject(id, uni)
+			return nil
+		}
+	}
+
+	stream := s.stream(id)
+	if stream == nil {
+		// closed on our side, the remote has not noticed yet
+		return nil
+	}
+	if err := stream.pushData(payload); err != nil {
+		return err
+	}
+	if flags&muxFlagFIN != 0 {
+		stream.remoteFinished()
+	}
+	return nil
+}
+
+// reject refuses a stream the remote side opened, nothing is kept of it and
+// its later frames are dropped
+func (s *muxSession) reject(id uint32, uni bool) {
+	s.writeFrame(muxStopSending, 0, id, uint32(ErrCodeStreamError), nil)
+	if !uni {
+		s.writeFrame(muxData, muxFlagFIN, id, 0, nil)
+	}
+}
+
+// closeStreams wakes up every stream after the connection is gone
+func (s *muxSession) closeStreams() {
+	s.mu.Lock()
+	streams := make([]*muxStream, 0, len(s.streams))
+	for _, stream := range s.streams {
+		streams = append(streams, stream)
+	}
+	s.mu.Unlock()
+
+	for _, stream := range streams {
+		stream.mu.Lock()
+		stream.notify()
+		stream.mu.Unlock()
+	}
+}
+
+// muxStream is a stream of a muxSession. Writers may send at most the
+// window the reader granted, readers grant more as they consume data.
+type muxStream struct {
+	id      uint32
+	session *muxSession
+
+	mu      sync.Mutex
+	changed chan struct{}
+
+	canRead      bool
+	recvBuf      bytes.Buffer
+	recvWindow   uint32
+	consumed     uint32
+	remoteFin    bool
+	readCanceled error
+	readDeadline time.Time
+
+	canWrite      bool
+	sendWindow    uint32
+	localFin      bool
+	writeCanceled error
+	writeDeadline time.Time
+}
+
+var _ RawStream = (*muxStream)(nil)
+
+func newMuxStream(session *muxSession, id uint32, canRead, canWrite bool) *muxStream {
+	return &muxStream{
+		id:         id,
+		session:    session,
+		changed:    make(chan struct{}),
+		canRead:    canRead,
+		recvWindow: muxInitialWindow,
+		canWrite:   canWrite,
+		sendWindow: muxInitialWindow,
+	}
+}
+
+func (s *muxStream) StreamID() StreamID {
+	return StreamID(s.id)
+}
+
+// notify wakes up blocked readers and writers, s.mu must be held
+func (s *muxStream) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// wait blocks until the stream changes, the deadline passes or the session
+// is gone. It is called with s.mu held and returns with it held.
+func (s *muxStream) wait(deadline time.Time) error {
+	changed := s.changed
+	s.mu.Unlock()
+	defer s.mu.Lock()
+
+	if deadline.IsZero() {
+		select {
+		case <-changed:
+			return nil
+		case <-s.session.ctx.Done():
+			return context.Cause(s.session.ctx)
+		}
+	}
+
+	wait := time.Until(deadline)
+	if wait <= 0 {
+		return os.ErrDeadlineExceeded
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-changed:
+		return nil
+	case <-s.session.ctx.Done():
+		return context.Cause(s.session.ctx)
+	case <-timer.C:
+		return os.ErrDeadlineExceeded
+	}
+}
+
+func (s *muxStream) Read(b []byte) (int, error) {
+	s.mu.Lock()
+	for {
+		switch {
+		case s.readCanceled != nil:
+			s.mu.Unlock()
+			return 0, s.readCanceled
+		case s.recvBuf.Len() > 0:
+			n, _ := s.recvBuf.Read(b)
+			s.consumed += uint32(n)
+			var update uint32
+			if s.consumed >= muxInitialWindow/2 && !s.remoteFin {
+				update = s.consumed
+				s.recvWindow += update
+				s.consumed = 0
+			}
+			s.mu.Unlock()
+			if update > 0 {
+				s.session.writeFrame(muxWindowUpdate, 0, s.id, update, nil)
+			}
+			return n, nil
+		case s.remoteFin:
+			s.mu.Unlock()
+			return 0, io.EOF
+		}
+		if err := s.wait(s.readDeadline); err != nil {
+			s.mu.Unlock()
+			return 0, err
+		}
+	}
+}
+
+func (s *muxStream) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		s.mu.Lock()
+		for s.sendWindow == 0 || s.writeCanceled != nil || s.localFin {
+			if s.writeCanceled != nil {
+				s.mu.Unlock()
+				return written, s.writeCanceled
+			}
+			if s.localFin {
+				s.mu.Unlock()
+				return written, errWriteClosed
+			}
+			if err := s.wait(s.writeDeadline); err != nil {
+				s.mu.Unlock()
+				return written, err
+			}
+		}
+		if !s.writeDeadline.IsZero() && !time.Now().Before(s.writeDeadline) {
+			s.mu.Unlock()
+			return written, os.ErrDeadlineExceeded
+		}
+		n := min(len(b), int(s.sendWindow), muxMaxFrame)
+		s.sendWindow -= uint32(n)
+		s.mu.Unlock()
+
+		if err := s.session.writeFrame(muxData, 0, s.id, uint32(n), b[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// Close finishes the sending direction, reading is still possible
+func (s *muxStream) Close() error {
+	s.mu.Lock()
+	if s.localFin || !s.canWrite {
+		s.mu.Unlock()
+		return nil
+	}
+	s.localFin = true
+	s.notify()
+	s.mu.Unlock()
+
+	err := s.session.writeFrame(muxData, muxFlagFIN, s.id, 0, nil)
+	s.removeIfDone()
+	return err
+}
+
+// CancelRead discards unread data and asks the remote side to stop sending
+func (s *muxStream) CancelRead(code ErrorCode) {
+	s.mu.Lock()
+	if s.readCanceled != nil || !s.canRead {
+		s.mu.Unlock()
+		return
+	}
+	s.readCanceled = &StreamError{Code: code}
+	s.recvBuf.Reset()
+	s.notify()
+	s.mu.Unlock()
+
+	s.session.writeFrame(muxStopSending, 0, s.id, uint32(code), nil)
+	s.removeIfDone()
+}
+
+func (s *muxStream) SetReadDeadline(t time.Time) error {
+	s.mu.Lock()
+	s.readDeadline = t
+	s.notify()
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *muxStream) SetWriteDeadline(t time.Time) error {
+	s.mu.Lock()
+	s.writeDeadline = t
+	s.notify()
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *muxStream) SetDeadline(t time.Time) error {
+	s.SetReadDeadline(t)
+	return s.SetWriteDeadline(t)
+}
+
+func (s *muxStream) pushData(payload []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.canRead {
+		return fmt.Errorf("data on send-only stream %d", s.id)
+	}
+	if uint32(len(payload)) > s.recvWindow {
+		return fmt.Errorf("stream %d exceeded its window", s.id)
+	}
+	s.recvWindow -= uint32(len(payload))
+	if s.readCanceled == nil && len(payload) > 0 {
+		s.recvBuf.Write(payload)
+		s.notify()
+	}
+	return nil
+}
+
+func (s *muxStream) remoteFinished() {
+	s.mu.Lock()
+	s.remoteFin = true
+	s.notify()
+	s.mu.Unlock()
+	s.removeIfDone()
+}
+
+func (s *muxStream) addSendWindow(delta uint32) {
+	s.mu.Lock()
+	s.sendWindow += delta
+	s.notify()
+	s.mu.Unlock()
+}
+
+func (s *muxStream) stopSending(code ErrorCode) {
+	s.mu.Lock()
+	if s.writeCanceled == nil {
+		s.writeCanceled = &StreamError{Code: code}
+		s.notify()
+	}
+	s.mu.Unlock()
+	s.removeIfDone()
+}
+
+// removeIfDone forgets the stream once both directions are finished
+func (s *muxStream) removeIfDone() {
+	s.mu.Lock()
+	readDone := !s.canRead || s.remoteFin || s.readCanceled != nil
+	writeDone := !s.canWrite || s.localFin || s.writeCanceled != nil
+	s.mu.Unlock()
+
+	if readDone && writeDone {
+		s.session.remove(s.id)
+	}
+}
@@ -0,0 +1,388 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tcpPipe returns both ends of a loopback TCP connection, unlike net.Pipe
+// its writes do not wait for the reader
+func tcpPipe(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := ln.Accept()
+		accepted <- conn
+	}()
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := <-accepted
+	if server == nil {
+		t.Fatal("accept failed")
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client, server
+}
+
+func newMuxPair(t *testing.T) (client, server *muxSession) {
+	t.Helper()
+	a, b := tcpPipe(t)
+	client = newMuxSession(a, a.RemoteAddr(), true)
+	server = newMuxSession(b, b.RemoteAddr(), false)
+	t.Cleanup(func() {
+		client.CloseWithError(ErrCodeNormalClose, "")
+		server.CloseWithError(ErrCodeNormalClose, "")
+	})
+	return client, server
+}
+
+func openMuxStream(t *testing.T, client, server *muxSession) (local, remote RawStream) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	local, err := client.OpenStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err = server.AcceptStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return local, remote
+}
+
+func (s *muxSession) streamCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.streams)
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMuxFlowControl(t *testing.T) {
+	client, server := newMuxPair(t)
+	local, remote := openMuxStream(t, client, server)
+
+	data := make([]byte, muxInitialWindow+1000)
+	rand.Read(data)
+
+	// nothing is read, the writer stops at the window
+	local.SetWriteDeadline(time.Now().Add(200 * time.Millisecond))
+	n, err := local.Write(data)
+	if !errors.Is(err, os.ErrDeadlineExceeded) || n != muxInitialWindow {
+		t.Fatalf("write past the window wrote %d bytes and returned %v", n, err)
+	}
+
+	// reading half the window grants more
+	got := make([]byte, len(data))
+	if _, err := io.ReadFull(remote, got[:muxInitialWindow/2]); err != nil {
+		t.Fatal(err)
+	}
+	local.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if _, err := local.Write(data[n:]); err != nil {
+		t.Fatalf("write after a window update: %v", err)
+	}
+	remote.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadFull(remote, got[muxInitialWindow/2:]); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("data corrupted")
+	}
+}
+
+func TestMuxHalfClose(t *testing.T) {
+	client, server := newMuxPair(t)
+	local, remote := openMuxStream(t, client, server)
+	local.SetDeadline(time.Now().Add(5 * time.Second))
+	remote.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := local.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	local.Close()
+	if _, err := local.Write([]byte("more")); !errors.Is(err, errWriteClosed) {
+		t.Fatalf("write after close returned %v", err)
+	}
+	if got, err := io.ReadAll(remote); err != nil || string(got) != "ping" {
+		t.Fatalf("read %q, %v before the FIN", got, err)
+	}
+
+	// the other direction stays open until it is finished too
+	if _, err := remote.Write([]byte("pong")); err != nil {
+		t.Fatalf("write back after the remote FIN: %v", err)
+	}
+	remote.Close()
+	if got, err := io.ReadAll(local); err != nil || string(got) != "pong" {
+		t.Fatalf("read %q, %v back", got, err)
+	}
+
+	waitFor(t, "both sides to forget the stream", func() bool {
+		return client.streamCount() == 0 && server.streamCount() == 0
+	})
+}
+
+func TestMuxCancelRead(t *testing.T) {
+	client, server := newMuxPair(t)
+	local, remote := openMuxStream(t, client, server)
+
+	const code ErrorCode = 42
+	remote.CancelRead(code)
+	var streamErr *StreamError
+	if _, err := remote.Read(make([]byte, 1)); !errors.As(err, &streamErr) || streamErr.Code != code {
+		t.Fatalf("read after CancelRead returned %v", err)
+	}
+
+	// the writer learns about it once the stop sending frame arrived
+	local.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	waitFor(t, "the writer to be stopped", func() bool {
+		_, err := local.Write([]byte("data"))
+		return errors.As(err, &streamErr)
+	})
+	if streamErr.Code != code {
+		t.Fatalf("writer stopped with code %d, want %d", streamErr.Code, code)
+	}
+
+	// the reverse direction still works
+	remote.SetDeadline(time.Now().Add(5 * time.Second))
+	local.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := remote.Write([]byte("still here")); err != nil {
+		t.Fatal(err)
+	}
+	remote.Close()
+	if got, err := io.ReadAll(local); err != nil || string(got) != "still here" {
+		t.Fatalf("read %q, %v", got, err)
+	}
+	waitFor(t, "both sides to forget the stream", func() bool {
+		return client.streamCount() == 0 && server.streamCount() == 0
+	})
+}
+
+func TestMuxGoAway(t *testing.T) {
+	tests := []struct {
+		reason string
+		want   string
+	}{
+		{"shutting down", "shutting down"},
+		{strings.Repeat("x", 2*muxMaxReason), strings.Repeat("x", muxMaxReason)},
+	}
+	for _, tt := range tests {
+		client, server := newMuxPair(t)
+		client.CloseWithError(ErrCodeSpamDetected, tt.reason)
+
+		var local, remote *ConnError
+		if err := context.Cause(client.Context()); !errors.As(err, &local) || local.Remote {
+			t.Fatalf("closing side ended with %v", err)
+		}
+		select {
+		case <-server.Context().Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("go away did not close the remote side")
+		}
+		err := context.Cause(server.Context())
+		if !errors.As(err, &remote) || !remote.Remote || remote.Code != ErrCodeSpamDetected || remote.Message != tt.want {
+			t.Fatalf("remote side ended with %v", err)
+		}
+		if _, err := server.AcceptStream(context.Background()); !errors.As(err, &remote) {
+			t.Fatalf("accept after go away returned %v", err)
+		}
+	}
+}
+
+// rawMux is the far end of a muxSession that writes frames by hand
+type rawMux struct {
+	conn   net.Conn
+	frames chan rawFrame
+}
+
+type rawFrame struct {
+	frameType, flags byte
+	id, value        uint32
+}
+
+func newRawMux(t *testing.T) (*rawMux, *muxSession) {
+	t.Helper()
+	a, b := tcpPipe(t)
+	server := newMuxSession(b, b.RemoteAddr(), false)
+	t.Cleanup(func() { server.CloseWithError(ErrCodeNormalClose, "") })
+
+	r := &rawMux{conn: a, frames: make(chan rawFrame, 4*muxMaxIncomingStreams)}
+	go func() {
+		header := make([]byte, muxHeaderLen)
+		for {
+			if _, err := io.ReadFull(a, header); err != nil {
+				close(r.frames)
+				return
+			}
+			f := rawFrame{
+				frameType: header[0],
+				flags:     header[1],
+				id:        binary.BigEndian.Uint32(header[2:6]),
+				value:     binary.BigEndian.Uint32(header[6:10]),
+			}
+			skip := 0
+			switch f.frameType {
+			case muxData, muxDatagram:
+				skip = int(f.value)
+			case muxGoAway:
+				skip = int(f.id)
+			}
+			if _, err := io.CopyN(io.Discard, a, int64(skip)); err != nil {
+				close(r.frames)
+				return
+			}
+			r.frames <- f
+		}
+	}()
+	return r, server
+}
+
+func (r *rawMux) write(t *testing.T, frameType, flags byte, id, value uint32, payload []byte) {
+	t.Helper()
+	buf := make([]byte, muxHeaderLen, muxHeaderLen+len(payload))
+	buf[0], buf[1] = frameType, flags
+	binary.BigEndian.PutUint32(buf[2:6], id)
+	binary.BigEndian.PutUint32(buf[6:10], value)
+	if _, err := r.conn.Write(append(buf, payload...)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// refused waits for the stop sending frame of a refused stream
+func (r *rawMux) refused(t *testing.T, id uint32) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case f, ok := <-r.frames:
+			if !ok {
+				t.Fatal("connection closed")
+			}
+			if f.frameType == muxStopSending && f.id == id {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("stream %d was not refused", id)
+		}
+	}
+}
+
+// TestMuxFullBacklogForgetsStream checks that streams refused because
+// nobody accepts them leave nothing behind
+func TestMuxFullBacklogForgetsStream(t *testing.T) {
+	raw, server := newRawMux(t)
+
+	const extra = 10
+	var last uint32
+	for i := range muxAcceptBacklog + extra {
+		last = uint32(2*i + 1)
+		raw.write(t, muxData, muxFlagSYN, last, 0, nil)
+	}
+	raw.refused(t, last)
+	if n := server.streamCount(); n != muxAcceptBacklog {
+		t.Fatalf("%d streams kept, want the %d waiting to be accepted", n, muxAcceptBacklog)
+	}
+
+	// data for a refused stream is dropped, not an error
+	raw.write(t, muxData, 0, last, 4, []byte("late"))
+	raw.write(t, muxData, muxFlagFIN, last, 0, nil)
+	time.Sleep(50 * time.Millisecond)
+	if err := server.Context().Err(); err != nil {
+		t.Fatalf("data for a refused stream closed the session: %v", context.Cause(server.Context()))
+	}
+}
+
+func TestMuxIncomingStreamLimit(t *testing.T) {
+	raw, server := newRawMux(t)
+	accepted := make(chan RawStream, muxMaxIncomingStreams+1)
+	go func() {
+		for {
+			stream, err := server.AcceptStream(context.Background())
+			if err != nil {
+				return
+			}
+			accepted <- stream
+		}
+	}()
+
+	id := func(i int) uint32 { return uint32(2*i + 1) }
+	// in batches the accept loop keeps up with, so the backlog never fills
+	const batch = muxAcceptBacklog / 2
+	for i := range muxMaxIncomingStreams {
+		raw.write(t, muxData, muxFlagSYN, id(i), 0, nil)
+		if (i+1)%batch == 0 || i+1 == muxMaxIncomingStreams {
+			waitFor(t, "the streams to be accepted", func() bool { return len(accepted) == i+1 })
+		}
+	}
+	raw.write(t, muxData, muxFlagSYN, id(muxMaxIncomingStreams), 0, nil)
+	raw.refused(t, id(muxMaxIncomingStreams))
+
+	// once a stream is done in both directions another one may be opened
+	first := <-accepted
+	for range muxMaxIncomingStreams - 1 {
+		<-accepted
+	}
+	raw.write(t, muxData, muxFlagFIN, id(0), 0, nil)
+	first.Close()
+	waitFor(t, "the finished stream to be forgotten", func() bool {
+		return server.streamCount() == muxMaxIncomingStreams-1
+	})
+	next := id(muxMaxIncomingStreams + 1)
+	raw.write(t, muxData, muxFlagSYN, next, 0, nil)
+	select {
+	case stream := <-accepted:
+		if stream.StreamID() != StreamID(next) {
+			t.Fatalf("accepted stream %d, want %d", stream.StreamID(), next)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream below the limit was not accepted")
+	}
+}
+
+func TestMuxWindowViolation(t *testing.T) {
+	raw, server := newRawMux(t)
+	raw.write(t, muxData, muxFlagSYN, 1, 0, nil)
+	chunk := make([]byte, muxMaxFrame)
+	for range muxInitialWindow/muxMaxFrame + 1 {
+		raw.write(t, muxData, 0, 1, uint32(len(chunk)), chunk)
+	}
+
+	select {
+	case <-server.Context().Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("sending past the window did not close the session")
+	}
+	var connErr *ConnError
+	if err := context.Cause(server.Context()); !errors.As(err, &connErr) || connErr.Code != ErrCodeProtocolViolation {
+		t.Fatalf("session closed with %v, want a protocol violation", err)
+	}
+}
@@ -0,0 +1,191 @@
+package network
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/crypto"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+
+	"github.com/flynn/noise"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Noise_XX_25519_ChaChaPoly_SHA256 from the Noise Protocol Framework, revision 34.
+// The static key is the X25519 form of the Ed25519 identity and the handshake
+// payload carries the Ed25519 public key, so the key proven by Noise is bound
+// to the peer identity.
+const (
+	noisePrologue = "echofog"
+
+	noiseTagLen     = chacha20poly1305.Overhead
+	noiseMaxMessage = noise.MaxMsgLen
+	noiseMaxPayload = noiseMaxMessage - noiseTagLen
+)
+
+var noiseSuite = noise.NewCipherSuite(noise.DH25519, noise.CipherChaChaPoly, noise.HashSHA256)
+
+var errNoiseIdentity = errors.New("noise static key does not match the peer identity")
+
+// noiseHandshake runs XX over conn and returns the encrypted connection and
+// the Ed25519 key of the remote side. The caller sets a deadline on conn.
+func noiseHandshake(conn net.Conn, identity types.PeerPrivateKey, initiator bool) (*noiseConn, types.PeerPublicKey, error) {
+	static, err := crypto.IdentityX25519Key(ed25519.PrivateKey(identity[:]))
+	if err != nil {
+		return nil, types.PeerPublicKey{}, err
+	}
+	hs, err := noise.NewHandshakeState(noise.Config{
+		CipherSuite:   noiseSuite,
+		Random:        rand.Reader,
+		Pattern:       noise.HandshakeXX,
+		Initiator:     initiator,
+		Prologue:      []byte(noisePrologue),
+		StaticKeypair: noise.DHKey{Private: static.Bytes(), Public: static.PublicKey().Bytes()},
+	})
+	if err != nil {
+		return nil, types.PeerPublicKey{}, fmt.Errorf("noise: %w", err)
+	}
+	myPubKey := types.PeerPrivateKeyToPublic(identity)
+
+	// -> e, <- e, ee, s, es, -> s, se: the initiator writes the first and
+	// the last message, both static keys travel with the identity payload
+	var (
+		remotePubKey types.PeerPublicKey
+		cs1, cs2     *noise.CipherState
+	)
+	for i := range 3 {
+		if (i%2 == 0) == initiator {
+			var payload []byte
+			if i > 0 {
+				payload = myPubKey[:]
+			}
+			var msg []byte
+			if msg, cs1, cs2, err = hs.WriteMessage(nil, payload); err != nil {
+				return nil, types.PeerPublicKey{}, fmt.Errorf("noise: %w", err)
+			}
+			if err := writeNoiseMessage(conn, msg); err != nil {
+				return nil, types.PeerPublicKey{}, err
+			}
+			continue
+		}
+
+		msg, err := readNoiseMessage(conn)
+		if err != nil {
+			return nil, types.PeerPublicKey{}, err
+		}
+		var payload []byte
+		if payload, cs1, cs2, err = hs.ReadMessage(nil, msg); err != nil {
+			return nil, types.PeerPublicKey{}, fmt.Errorf("noise: %w", err)
+		}
+		// checked right away, the initiator does not reveal its identity to
+		// a responder that is not who it claims to be
+		if i > 0 {
+			if remotePubKey, err = noiseIdentity(hs.PeerStatic(), payload); err != nil {
+				return nil, types.PeerPublicKey{}, err
+			}
+		}
+	}
+
+	// cs1 encrypts from the initiator to the responder, cs2 the other way
+	nc := &noiseConn{Conn: conn, send: cs1, recv: cs2}
+	if !initiator {
+		nc.send, nc.recv = cs2, cs1
+	}
+	return nc, remotePubKey, nil
+}
+
+// noiseIdentity checks that the static key is the X25519 form of the Ed25519 key in the payload
+func noiseIdentity(static, payload []byte) (types.PeerPublicKey, error) {
+	pubKey, err := types.ToPeerPublicKey(payload)
+	if err != nil {
+		return types.PeerPublicKey{}, fmt.Errorf("noise: %w", err)
+	}
+	expected, err := crypto.EdPubKeyToX25519(pubKey)
+	if err != nil {
+		return types.PeerPublicKey{}, fmt.Errorf("noise: %w", err)
+	}
+	if !hmac.Equal(expected, static) {
+		return types.PeerPublicKey{}, errNoiseIdentity
+	}
+	return pubKey, nil
+}
+
+func writeNoiseMessage(w io.Writer, msg []byte) error {
+	if len(msg) > noiseMaxMessage {
+		return errors.New("noise: message too long")
+	}
+	buf := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+	copy(buf[2:], msg)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readNoiseMessage(r io.Reader) ([]byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(header[:]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// noiseConn encrypts everything written to the underlying connection with
+// the transport keys of the handshake. Reads must come from one goroutine.
+type noiseConn struct {
+	net.Conn
+
+	writeMu sync.Mutex
+	send    *noise.CipherState
+	recv    *noise.CipherState
+	pending []byte
+}
+
+func (c *noiseConn) Write(b []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	written := 0
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > noiseMaxPayload {
+			chunk = chunk[:noiseMaxPayload]
+		}
+		buf, err := c.send.Encrypt(make([]byte, 2, 2+len(chunk)+noiseTagLen), nil, chunk)
+		if err != nil {
+			return written, fmt.Errorf("noise: %w", err)
+		}
+		binary.BigEndian.PutUint16(buf, uint16(len(buf)-2))
+		if _, err := c.Conn.Write(buf); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+		b = b[len(chunk):]
+	}
+	return written, nil
+}
+
+func (c *noiseConn) Read(b []byte) (int, error) {
+	if len(c.pending) == 0 {
+		msg, err := readNoiseMessage(c.Conn)
+		if err != nil {
+			return 0, err
+		}
+		if c.pending, err = c.recv.Decrypt(msg[:0], nil, msg); err != nil {
+			return 0, fmt.Errorf("noise: %w", err)
+		}
+	}
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
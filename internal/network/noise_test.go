@@ -0,0 +1,191 @@
+package network
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/crypto"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+
+	"github.com/flynn/noise"
+)
+
+func newNoiseKey(t *testing.T) types.PeerPrivateKey {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return types.PeerPrivateKey(priv)
+}
+
+// netPipe returns both ends of a synchronous connection that gives up after 5 seconds
+func netPipe(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+	a, b := net.Pipe()
+	deadline := time.Now().Add(5 * time.Second)
+	a.SetDeadline(deadline)
+	b.SetDeadline(deadline)
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	return a, b
+}
+
+type noiseResult struct {
+	conn   *noiseConn
+	remote types.PeerPublicKey
+	err    error
+}
+
+// noisePair runs the initiator on a and the responder on b
+func noisePair(a, b net.Conn, initiator, responder types.PeerPrivateKey) (noiseResult, noiseResult) {
+	done := make(chan noiseResult, 1)
+	go func() {
+		conn, remote, err := noiseHandshake(b, responder, false)
+		if err != nil {
+			// let the initiator see the failure instead of waiting for a message
+			b.Close()
+		}
+		done <- noiseResult{conn, remote, err}
+	}()
+	conn, remote, err := noiseHandshake(a, initiator, true)
+	if err != nil {
+		a.Close()
+	}
+	return noiseResult{conn, remote, err}, <-done
+}
+
+// tamperConn flips the last byte of the nth write
+type tamperConn struct {
+	net.Conn
+	mu     sync.Mutex
+	writes int
+	nth    int
+}
+
+func (c *tamperConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	c.writes++
+	if c.writes == c.nth {
+		b = bytes.Clone(b)
+		b[len(b)-1] ^= 1
+	}
+	c.mu.Unlock()
+	return c.Conn.Write(b)
+}
+
+func TestNoiseHandshake(t *testing.T) {
+	a, b := netPipe(t)
+	keyA, keyB := newNoiseKey(t), newNoiseKey(t)
+	initiator, responder := noisePair(a, b, keyA, keyB)
+	if initiator.err != nil || responder.err != nil {
+		t.Fatalf("handshake: initiator %v, responder %v", initiator.err, responder.err)
+	}
+	if initiator.remote != types.PeerPrivateKeyToPublic(keyB) {
+		t.Error("initiator got the wrong remote key")
+	}
+	if responder.remote != types.PeerPrivateKeyToPublic(keyA) {
+		t.Error("responder got the wrong remote key")
+	}
+
+	// both directions, one write larger than a noise message
+	for _, dir := range []struct {
+		name     string
+		from, to *noiseConn
+		size     int
+	}{
+		{"initiator to responder", initiator.conn, responder.conn, 100},
+		{"responder to initiator", responder.conn, initiator.conn, 3 * noiseMaxPayload},
+	} {
+		data := make([]byte, dir.size)
+		rand.Read(data)
+		errc := make(chan error, 1)
+		go func() {
+			_, err := dir.from.Write(data)
+			errc <- err
+		}()
+		got := make([]byte, len(data))
+		if _, err := io.ReadFull(dir.to, got); err != nil {
+			t.Fatalf("%s: %v", dir.name, err)
+		}
+		if err := <-errc; err != nil {
+			t.Fatalf("%s: %v", dir.name, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("%s: data corrupted", dir.name)
+		}
+	}
+}
+
+// TestNoiseIdentityMismatch runs a responder whose static key belongs to
+// another identity than the Ed25519 key it claims in the payload
+func TestNoiseIdentityMismatch(t *testing.T) {
+	a, b := netPipe(t)
+	claimed, actual := newNoiseKey(t), newNoiseKey(t)
+
+	go func() {
+		static, err := crypto.IdentityX25519Key(ed25519.PrivateKey(actual[:]))
+		if err != nil {
+			b.Close()
+			return
+		}
+		hs, err := noise.NewHandshakeState(noise.Config{
+			CipherSuite:   noiseSuite,
+			Random:        rand.Reader,
+			Pattern:       noise.HandshakeXX,
+			Prologue:      []byte(noisePrologue),
+			StaticKeypair: noise.DHKey{Private: static.Bytes(), Public: static.PublicKey().Bytes()},
+		})
+		if err != nil {
+			b.Close()
+			return
+		}
+		msg, err := readNoiseMessage(b)
+		if err != nil {
+			return
+		}
+		if _, _, _, err := hs.ReadMessage(nil, msg); err != nil {
+			b.Close()
+			return
+		}
+		pubKey := types.PeerPrivateKeyToPublic(claimed)
+		msg, _, _, _ = hs.WriteMessage(nil, pubKey[:])
+		writeNoiseMessage(b, msg)
+	}()
+
+	_, _, err := noiseHandshake(a, newNoiseKey(t), true)
+	if !errors.Is(err, errNoiseIdentity) {
+		t.Fatalf("handshake with a mismatched identity returned %v, want errNoiseIdentity", err)
+	}
+}
+
+func TestNoiseTamperedHandshake(t *testing.T) {
+	a, b := netPipe(t)
+	// the responder writes a single handshake message
+	initiator, _ := noisePair(a, &tamperConn{Conn: b, nth: 1}, newNoiseKey(t), newNoiseKey(t))
+	if initiator.err == nil {
+		t.Fatal("handshake with a tampered message succeeded")
+	}
+}
+
+func TestNoiseTamperedCiphertext(t *testing.T) {
+	a, b := netPipe(t)
+	initiator, responder := noisePair(a, &tamperConn{Conn: b, nth: 2}, newNoiseKey(t), newNoiseKey(t))
+	if initiator.err != nil || responder.err != nil {
+		t.Fatalf("handshake: initiator %v, responder %v", initiator.err, responder.err)
+	}
+
+	go responder.conn.Write([]byte("transfer 100 to alice"))
+	if _, err := initiator.conn.Read(make([]byte, 64)); err == nil {
+		t.Fatal("read a tampered ciphertext")
+	}
+}
@@ -0,0 +1,98 @@
+package network
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Protocol versions, each one changed the wire format. What a version
+// introduced is only used with peers that negotiated it or a later one.
+const (
+	// VersionSignedHello signs the version range and the capabilities in the
+	// handshake. Older peers sign only the nonce and their key, a downgrade of
+	// their announcement could not be detected, so it is the oldest version
+	// spoken.
+	VersionSignedHello uint32 = 101
+	// VersionDatagrams adds the fragmenting datagram header
+	VersionDatagrams uint32 = 102
+	// VersionControlStream carries gossip over one control stream per
+	// direction, older peers get a stream per message
+	VersionControlStream uint32 = 103
+	// VersionRPC adds request/response streams, hole punching runs over them
+	VersionRPC uint32 = 104
+	// VersionRelay adds relay reservations and circuits
+	VersionRelay uint32 = 105
+	// VersionIdentify adds the identify exchange
+	VersionIdentify uint32 = 106
+)
+
+// Capabilities is the set of optional features a peer announces in the
+// handshake, services check them before sending a peer something it may
+// not understand
+type Capabilities uint64
+
+// Bits 0, 1 and 4 were announced for content transfer, a DHT server and
+// compression, none of which was implemented. They stay reserved so peers
+// that still announce them are not misread.
+const (
+	// CapRelay relays circuits for other peers
+	CapRelay Capabilities = 1 << 2
+	// CapDatagrams accepts unreliable datagrams
+	CapDatagrams Capabilities = 1 << 3
+)
+
+var capabilityNames = map[Capabilities]string{
+	CapRelay:     "relay",
+	CapDatagrams: "datagrams",
+}
+
+// Has reports whether every capability of caps is set
+func (c Capabilities) Has(caps Capabilities) bool {
+	return c&caps == caps
+}
+
+func (c Capabilities) String() string {
+	var names []string
+	unknown := c
+	for bit := Capabilities(1); bit != 0; bit <<= 1 {
+		if name, ok := capabilityNames[bit]; ok && c&bit != 0 {
+			names = append(names, name)
+			unknown &^= bit
+		}
+	}
+	if unknown != 0 {
+		names = append(names, fmt.Sprintf("0x%x", uint64(unknown)))
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+// Protocol is what a node announces in the handshake
+type Protocol struct {
+	// MinVersion and MaxVersion are the range of protocol versions the node speaks
+	MinVersion   uint32
+	MaxVersion   uint32
+	Capabilities Capabilities
+}
+
+// VersionError is returned by the handshake when the version ranges of both
+// sides do not overlap, the connection is closed with ErrCodeIncompatibleVersion
+type VersionError struct {
+	Local, Remote Protocol
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("incompatible protocol version: we speak %d-%d, peer speaks %d-%d",
+		e.Local.MinVersion, e.Local.MaxVersion, e.Remote.MinVersion, e.Remote.MaxVersion)
+}
+
+// negotiate picks the highest version both sides speak
+func (p Protocol) negotiate(remote Protocol) (uint32, error) {
+	version := min(p.MaxVersion, remote.MaxVersion)
+	if version < max(p.MinVersion, remote.MinVersion) {
+		return 0, &VersionError{Local: p, Remote: remote}
+	}
+	return version, nil
+}
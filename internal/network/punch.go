@@ -0,0 +1,87 @@
+package network
+
+import (
+	"context"
+	"crypto/rand"
+	"net"
+	"time"
+)
+
+const (
+	punchPackets  = 5
+	punchInterval = 200 * time.Millisecond
+	punchSize     = 64
+)
+
+// HolePuncher is a transport that can open the NAT in front of it for a
+// peer, so the peer's dial to our observed address gets through
+type HolePuncher interface {
+	Transport
+	// Punch sends a few packets to addr from the listening socket
+	Punch(ctx context.Context, addr string) error
+}
+
+var _ HolePuncher = (*QuicTransport)(nil)
+
+// AsHolePuncher returns t, or the first transport of a FallbackTransport,
+// that can punch holes
+func AsHolePuncher(t Transport) (HolePuncher, bool) {
+	return findTransport[HolePuncher](t)
+}
+
+// Punch writes packets that QUIC ignores, their first byte has the fixed
+// bit cleared, to addr through the socket connections are accepted on
+func (q *QuicTransport) Punch(ctx context.Context, addr string) error {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+
+	packet := make([]byte, punchSize)
+	ticker := time.NewTicker(punchInterval)
+	defer ticker.Stop()
+	for i := range punchPackets {
+		if i > 0 {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		rand.Read(packet[1:])
+		packet[0] = 0
+		if _, err := q.tr.WriteTo(packet, udpAddr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AsQuicTransport returns t, or the first transport of a FallbackTransport,
+// that is a QuicTransport
+func AsQuicTransport(t Transport) (*QuicTransport, bool) {
+	return findTransport[*QuicTransport](t)
+}
+
+// PacketConn shares the socket connections are accepted on with protocols
+// such as STUN, whose packets QUIC does not take for its own
+func (q *QuicTransport) PacketConn() *NonQUICConn {
+	return &NonQUICConn{q: q}
+}
+
+// NonQUICConn reads the packets QUIC ignored, the first reader takes each
+type NonQUICConn struct {
+	q *QuicTransport
+}
+
+func (c *NonQUICConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return c.q.tr.WriteTo(b, addr)
+}
+
+func (c *NonQUICConn) ReadFrom(ctx context.Context, b []byte) (int, net.Addr, error) {
+	return c.q.tr.ReadNonQUICPacket(ctx, b)
+}
+
+func (c *NonQUICConn) LocalAddr() net.Addr {
+	return c.q.addr
+}
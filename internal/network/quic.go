@@ -2,14 +2,13 @@ package network
 
 import (
 	"context"
-	"crypto/rand"
 	"crypto/tls"
 	"errors"
 	"fmt"
-
-	"log"
+	"log/slog"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
@@ -37,17 +36,16 @@ const (
 	TypeChunkRequest
 	TypeChunkResponse
 	TypeStreamCancel
-)
-
-type QuicErrorCode = quic.ApplicationErrorCode
-
-const (
-	ErrCodeNoError QuicErrorCode = iota
-	ErrCodeProtocolViolation
-	ErrCodeSpamDetected
-	ErrCodeStreamError
-	ErrCodeAuthFailed
-	ErrCodeNormalClose
+	// TypeControlStream is the first frame of a control stream
+	TypeControlStream
+	TypeRPCRequest
+	TypeRPCResponse
+	// TypeRelayHop and TypeRelayStop open the two halves of a relay circuit,
+	// TypeRelayData frames carry its bytes
+	TypeRelayHop
+	TypeRelayStop
+	TypeRelayStatus
+	TypeRelayData
 )
 
 func GetQuicConfig() *quic.Config {
@@ -61,195 +59,239 @@ func GetQuicConfig() *quic.Config {
 	}
 }
 
+// QuicTransport is the default Transport, TLS 1.3 over QUIC on one UDP socket
 type QuicTransport struct {
-	tr              *quic.Transport
-	tlsCfg          *tls.Config
-	quicCgf         *quic.Config
-	privKey         types.PeerPrivateKey
-	protocolVersion uint32
+	*baseTransport
+
+	tr      *quic.Transport
+	udpConn *net.UDPConn
+	tlsCfg  *tls.Config
+	quicCgf *quic.Config
 
 	addr net.Addr
 
-	connChan chan NewConnEvent
+	ln           *quic.EarlyListener
+	acceptCancel context.CancelFunc
 }
 
-func NewQUICTransport(addr string, tlsCfg *tls.Config, quicCgf *quic.Config, privKey types.PeerPrivateKey, protocolVersion uint32) *QuicTransport {
-	udpAddr, _ := net.ResolveUDPAddr("udp", addr)
+var _ Transport = (*QuicTransport)(nil)
+
+// NewQUICTransport binds the UDP socket at addr, errors such as a busy port
+// are returned to the caller
+func NewQUICTransport(addr string, tlsCfg *tls.Config, quicCgf *quic.Config, privKey types.PeerPrivateKey, protocol Protocol, log *slog.Logger) (*QuicTransport, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("resolve listen address %q: %w", addr, err)
+	}
 	udpConn, err := net.ListenUDP("udp", udpAddr)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	tr := &quic.Transport{
 		Conn: udpConn,
 	}
 
 	return &QuicTransport{
-		tr:              tr,
-		tlsCfg:          tlsCfg,
-		quicCgf:         quicCgf,
-		privKey:         privKey,
-		connChan:        make(chan NewConnEvent, 10),
-		protocolVersion: protocolVersion,
-		addr:            udpConn.LocalAddr(),
-	}
+		baseTransport: newBaseTransport(privKey, protocol, log),
+		tr:            tr,
+		udpConn:       udpConn,
+		tlsCfg:        tlsCfg,
+		quicCgf:       quicCgf,
+		addr:          udpConn.LocalAddr(),
+	}, nil
 }
 
 func (q *QuicTransport) Addr() net.Addr {
 	return q.addr
 }
 
-func (q *QuicTransport) ListenEarly(ctx context.Context) error {
+func (q *QuicTransport) Listen(ctx context.Context) error {
 	ln, err := q.tr.ListenEarly(q.tlsCfg, q.quicCgf)
 	if err != nil {
 		return fmt.Errorf("QuicTransport Listen error: %v", err)
 	}
 
-	go q.AcceptConn(ctx, ln, q.protocolVersion)
+	acceptCtx, cancel := context.WithCancel(ctx)
+	q.ln = ln
+	q.acceptCancel = cancel
+
+	q.acceptWg.Add(1)
+	go func() {
+		defer q.acceptWg.Done()
+		q.acceptLoop(acceptCtx, ln)
+	}()
 
 	return nil
 }
 
-func (q *QuicTransport) DialEarly(ctx context.Context, addr string) error {
-	targetAddres, err := net.ResolveUDPAddr("udp", addr)
-	if err != nil {
-		return err
+// StopAccepting closes the listener and waits for in-flight inbound handshakes to finish
+func (q *QuicTransport) StopAccepting() {
+	if q.acceptCancel != nil {
+		q.acceptCancel()
 	}
+	if q.ln != nil {
+		q.ln.Close()
+	}
+	q.acceptWg.Wait()
+}
 
-	conn, err := q.tr.DialEarly(ctx, targetAddres, q.tlsCfg, q.quicCgf)
-	if err != nil {
-		return err
+// Close stops accepting, closes the UDP socket and the connection channel.
+// Connections that were already handed to the swarm must be closed by it.
+func (q *QuicTransport) Close() error {
+	q.StopAccepting()
+
+	if !q.closeConnChan() {
+		return nil
 	}
+	return errors.Join(q.tr.Close(), q.udpConn.Close())
+}
+
+func (q *QuicTransport) Dial(ctx context.Context, addr string) (types.PeerID, error) {
+	peerID, err := q.dial(ctx, addr)
+	q.countDial(err)
+	return peerID, err
+}
 
-	stream, err := conn.OpenStream()
+func (q *QuicTransport) dial(ctx context.Context, addr string) (types.PeerID, error) {
+	if isURLAddr(addr) {
+		return types.PeerID{}, ErrUnsupportedAddr
+	}
+	targetAddres, err := net.ResolveUDPAddr("udp", addr)
 	if err != nil {
-		conn.CloseWithError(ErrCodeStreamError, "stream error")
-		return err
+		return types.PeerID{}, err
 	}
-	defer stream.Close()
 
-	peerPubKey, err := q.authenticatePeer(stream, q.protocolVersion)
+	// quic-go names the server by its IP, peers behind one address would
+	// share session tickets and fail the dial on the 0-RTT rejection
+	tlsCfg := q.tlsCfg.Clone()
+	tlsCfg.ServerName = targetAddres.String()
+
+	conn, err := q.tr.DialEarly(ctx, targetAddres, tlsCfg, q.quicCgf)
 	if err != nil {
-		conn.CloseWithError(ErrCodeAuthFailed, "auth failed")
-		return err
-	}
-	peerID := types.PeerPubKeyToID(peerPubKey)
-	if !sendReadyFrame(stream) {
-		return errors.New("error to send ready frame to peer")
+		return types.PeerID{}, err
 	}
 
-	q.newConn(conn, true, conn.RemoteAddr().String(), peerID, peerPubKey)
-	fmt.Printf("Peer %s authenticated and TLS confirmed\n", peerID)
-
-	return nil
+	qc := &quicConn{conn: conn}
+	return q.handshakeOutbound(ctx, qc, qc.verifyTLSIdentity)
 }
 
-func (q *QuicTransport) AcceptConn(ctx context.Context, ln *quic.EarlyListener, protocolVersion uint32) {
+func (q *QuicTransport) acceptLoop(ctx context.Context, ln *quic.EarlyListener) {
 	defer ln.Close()
 	for {
 		conn, err := ln.Accept(ctx)
 		if err != nil {
-			fmt.Println(err)
-			continue
-		}
-
-		go func(c *quic.Conn) {
-			stream, err := c.AcceptStream(ctx)
-			if err != nil {
-				c.CloseWithError(ErrCodeStreamError, "stream error")
+			if ctx.Err() != nil || errors.Is(err, quic.ErrServerClosed) {
 				return
 			}
-			defer stream.Close()
-
-			peerPubKey, err := q.authenticatePeer(stream, protocolVersion)
-			if err != nil {
-				c.CloseWithError(ErrCodeAuthFailed, "auth failed")
-				return
-			}
-			peerID := types.PeerPubKeyToID(peerPubKey)
-			if !acceptReadyFrame(stream) {
-				return
-			}
-
-			q.newConn(conn, false, c.RemoteAddr().String(), peerID, peerPubKey)
-
-			fmt.Printf("Peer %s authenticated and TLS confirmed\n", peerID)
-
-		}(conn)
-	}
-}
-
-func (q *QuicTransport) ConnChan() <-chan NewConnEvent {
-	return q.connChan
-}
-
-func (q *QuicTransport) newConn(conn *quic.Conn, isOut bool, addr string, PeerID types.PeerID, PeerPubKey types.PeerPublicKey) {
-	q.connChan <- NewConnEvent{
-		Conn:       conn,
-		IsOut:      isOut,
-		PeerID:     PeerID,
-		Addr:       addr,
-		PeerPubKey: PeerPubKey,
-	}
-}
-
-func (q *QuicTransport) authenticatePeer(stream *quic.Stream, protocolVersion uint32) (types.PeerPublicKey, error) {
-	myNonce := make([]byte, 32)
-	rand.Read(myNonce)
-
-	if err := sendHandshake(stream, myNonce); err != nil {
-		return types.PeerPublicKey{}, err
-	}
-
-	if err := acceptHandshake(stream, q.privKey, protocolVersion); err != nil {
-		return types.PeerPublicKey{}, err
-	}
+			q.log.Warn("Accept failed", "err", err)
+			continue
+		}
 
-	peerPubKey, err := checkHandshakeResponse(stream, myNonce)
-	if err != nil {
-		return types.PeerPublicKey{}, err
+		qc := &quicConn{conn: conn}
+		q.acceptInbound(ctx, qc, qc.verifyTLSIdentity)
 	}
-
-	return peerPubKey, nil
 }
 
 type PeerWrapper struct {
-	conn   *quic.Conn
+	conn   Conn
 	peerID types.PeerID
+	// version was negotiated in the handshake
+	version uint32
 
 	ctx    context.Context
 	cancel context.CancelFunc
 
 	streamsMu sync.RWMutex
-	streams   map[quic.StreamID]*Stream
+	streams   map[StreamID]*Stream
 
 	onData      func(msgType MessageType, payload []byte, peerID types.PeerID)
+	onDatagram  func(msgType MessageType, payload []byte, peerID types.PeerID)
 	onNewStream func(stream *Stream)
+	onViolation func(err error)
+
+	// datagramID numbers outgoing datagram messages for reassembly
+	datagramID atomic.Uint32
+
+	control *controlStream
+
+	traffic *Traffic
+	log     *slog.Logger
+
+	// wg tracks every goroutine started for this connection, including stream loops
+	wg sync.WaitGroup
 }
 
-func NewPeerWrapper(parentCtx context.Context, conn *quic.Conn) *PeerWrapper {
+// NewPeerWrapper wraps the authenticated connection to peerID that
+// negotiated version, its frames are counted in traffic
+func NewPeerWrapper(parentCtx context.Context, conn Conn, peerID types.PeerID, version uint32, traffic *Traffic, log *slog.Logger) *PeerWrapper {
 	ctx, cancel := context.WithCancel(parentCtx)
 	return &PeerWrapper{
 		conn:    conn,
+		peerID:  peerID,
+		version: version,
 		ctx:     ctx,
 		cancel:  cancel,
-		streams: make(map[quic.StreamID]*Stream),
+		streams: make(map[StreamID]*Stream),
+		control: newControlStream(),
+		traffic: traffic,
+		log:     log,
 	}
 }
 
+// Close says goodbye to the remote side with ErrCodeNormalClose and closes all streams
 func (p *PeerWrapper) Close() error {
 	p.cancel()
+
+	p.streamsMu.RLock()
+	streams := make([]*Stream, 0, len(p.streams))
+	for _, stream := range p.streams {
+		streams = append(streams, stream)
+	}
+	p.streamsMu.RUnlock()
+
+	for _, stream := range streams {
+		stream.Close()
+	}
+
 	return p.conn.CloseWithError(ErrCodeNormalClose, "normal close")
 }
 
+// Wait blocks until all goroutines of this connection have returned
+func (p *PeerWrapper) Wait() {
+	p.wg.Wait()
+}
+
+// OnData handles gossip frames, payload is a pooled buffer that must not be
+// kept after onData returns
 func (p *PeerWrapper) OnData(onData func(msgType MessageType, payload []byte, peerID types.PeerID)) {
 	p.onData = onData
 }
 
+// OnDatagram handles messages received as datagrams, they may be lost,
+// duplicated or reordered
+func (p *PeerWrapper) OnDatagram(handler func(msgType MessageType, payload []byte, peerID types.PeerID)) {
+	p.onDatagram = handler
+}
+
 func (p *PeerWrapper) OnNewStream(handler func(stream *Stream)) {
 	p.onNewStream = handler
 }
 
-func (p *PeerWrapper) GetConn() *quic.Conn {
+// OnViolation is called after the connection was closed because the peer
+// broke the framing
+func (p *PeerWrapper) OnViolation(handler func(err error)) {
+	p.onViolation = handler
+}
+
+func (p *PeerWrapper) violation(err error) {
+	p.log.Warn("Protocol violation", "addr", p.RemoteAddr().String(), "err", err)
+	p.conn.CloseWithError(ErrCodeProtocolViolation, err.Error())
+	if p.onViolation != nil {
+		p.onViolation(err)
+	}
+}
+
+func (p *PeerWrapper) GetConn() Conn {
 	return p.conn
 }
 
@@ -257,35 +299,66 @@ func (p *PeerWrapper) RemoteAddr() net.Addr {
 	return p.conn.RemoteAddr()
 }
 
+// Done is closed when the underlying connection is gone
+func (p *PeerWrapper) Done() <-chan struct{} {
+	return p.conn.Context().Done()
+}
+
+// CloseReason returns why the connection was closed, nil while it is alive
+func (p *PeerWrapper) CloseReason() error {
+	return context.Cause(p.conn.Context())
+}
+
 func (p *PeerWrapper) StartLoops() {
-	go p.AcceptUniLoop(p.ctx)
-	go p.AcceptDatagramLoop(p.ctx)
-	go p.AcceptStreamLoop(p.ctx)
+	p.goTracked(func() { p.AcceptUniLoop(p.ctx) })
+	p.goTracked(func() { p.AcceptDatagramLoop(p.ctx) })
+	p.goTracked(func() { p.AcceptStreamLoop(p.ctx) })
+	p.goTracked(p.controlWriteLoop)
+}
+
+func (p *PeerWrapper) goTracked(fn func()) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		fn()
+	}()
 }
 
-func (p *PeerWrapper) SendGossipMessage(msgType MessageType, data []byte) error {
+// SendGossipStream sends a message over a uni stream of its own, it costs a
+// stream per message but does not wait behind the control stream
+func (p *PeerWrapper) SendGossipStream(msgType MessageType, data []byte) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	stream, err := p.conn.OpenUniStreamSync(ctx)
+	stream, err := p.conn.OpenUniStream(ctx)
 	if err != nil {
 		return err
 	}
 
 	stream.SetWriteDeadline(time.Now().Add(1 * time.Second))
 
-	if err := writeFrame(stream, msgType, data); err != nil {
+	if err := writeFrame(stream, StreamKindGossip, msgType, data); err != nil {
 		return err
 	}
+	p.traffic.countOut(msgType, len(data))
 
 	return stream.Close()
 }
 
-func (p *PeerWrapper) SendGossipDatagram(data []byte) error {
-	if len(data) > 1200 {
-		return fmt.Errorf("message too big for datagram")
+// SendDatagram sends data unreliably, messages up to MaxDatagramPayload are
+// fragmented and lost as a whole if any fragment is lost
+func (p *PeerWrapper) SendDatagram(msgType MessageType, data []byte) error {
+	datagrams, err := fragmentDatagram(msgType, uint16(p.datagramID.Add(1)), data)
+	if err != nil {
+		return err
+	}
+	for _, datagram := range datagrams {
+		if err := p.conn.SendDatagram(datagram); err != nil {
+			return err
+		}
 	}
-	return p.conn.SendDatagram(data)
+	p.traffic.countOut(msgType, len(data))
+	return nil
 }
 
 func (p *PeerWrapper) AcceptUniLoop(ctx context.Context) {
@@ -300,11 +373,11 @@ func (p *PeerWrapper) AcceptUniLoop(ctx context.Context) {
 			if errors.Is(err, context.Canceled) {
 				return
 			}
-			log.Println("Peer disconnected: ", err)
+			p.log.Debug("Peer disconnected", "addr", p.RemoteAddr().String(), "err", err)
 			return
 		}
 
-		go p.handleGossipStream(stream)
+		p.goTracked(func() { p.handleGossipStream(stream) })
 	}
 }
 
@@ -317,13 +390,14 @@ func (p *PeerWrapper) AcceptStream(ctx context.Context) (*Stream, error) {
 	streamWrapper := p.wrapAndRegister(stream)
 
 	if p.onNewStream != nil {
-		go p.onNewStream(streamWrapper)
+		p.goTracked(func() { p.onNewStream(streamWrapper) })
 	}
 
 	return streamWrapper, nil
 }
 
 func (p *PeerWrapper) AcceptDatagramLoop(ctx context.Context) {
+	reassembler := newDatagramReassembler()
 	for {
 		select {
 		case <-p.ctx.Done():
@@ -335,36 +409,67 @@ func (p *PeerWrapper) AcceptDatagramLoop(ctx context.Context) {
 			return
 		}
 
-		fmt.Printf("Gossip (Unreliable): %s\n", string(msg))
+		h, part, err := parseDatagram(msg)
+		if err != nil {
+			p.log.Debug("Dropped datagram", "addr", p.RemoteAddr().String(), "size", len(msg), "err", err)
+			continue
+		}
+		data, ok := reassembler.add(h, part, time.Now())
+		if !ok {
+			continue
+		}
+		p.traffic.countIn(h.msgType, len(data))
+		if p.onDatagram != nil {
+			p.onDatagram(h.msgType, data, p.peerID)
+		}
 	}
 }
 
 func (p *PeerWrapper) AcceptStreamLoop(ctx context.Context) {
-	go func() {
-		for {
-			stream, err := p.conn.AcceptStream(ctx)
-			if err != nil {
-				return
-			}
+	for {
+		stream, err := p.conn.AcceptStream(ctx)
+		if err != nil {
+			return
+		}
 
-			streamWrapper := p.wrapAndRegister(stream)
+		streamWrapper := p.wrapAndRegister(stream)
 
-			if p.onNewStream != nil {
-				go p.onNewStream(streamWrapper)
-			}
+		if p.onNewStream != nil {
+			p.goTracked(func() { p.onNewStream(streamWrapper) })
 		}
-	}()
+	}
 }
 
-func (p *PeerWrapper) handleGossipStream(stream *quic.ReceiveStream) {
-	msgType, msg, err := readFrame(stream)
-	if err == nil && p.onData != nil {
+// handleGossipStream reads the single frame of a gossip stream, or all
+// frames if it is a control stream
+func (p *PeerWrapper) handleGossipStream(stream ReceiveStream) {
+	stream.SetReadDeadline(time.Now().Add(gossipReadTimeout))
+	control := false
+	err := readFrameFunc(stream, StreamKindGossip, func(msgType MessageType, msg []byte) error {
+		if msgType == TypeControlStream {
+			control = true
+			return nil
+		}
+		return p.handleGossipFrame(msgType, msg)
+	})
+	if err == nil && control {
+		err = p.readControlStream(stream)
+	}
+	if isProtocolViolation(err) {
+		p.violation(err)
+	}
+}
+
+func (p *PeerWrapper) handleGossipFrame(msgType MessageType, msg []byte) error {
+	p.traffic.countIn(msgType, len(msg))
+	if p.onData != nil {
 		p.onData(msgType, msg, p.peerID)
 	}
+	return nil
 }
 
 func (p *PeerWrapper) OpenBidirectionalStream(ctx context.Context) (*Stream, error) {
-	stream, err := p.conn.OpenStreamSync(ctx)
+	stream, err := p.conn.OpenStream(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -372,21 +477,23 @@ func (p *PeerWrapper) OpenBidirectionalStream(ctx context.Context) (*Stream, err
 	return p.wrapAndRegister(stream), nil
 }
 
-func (p *PeerWrapper) wrapAndRegister(qStream *quic.Stream) *Stream {
+func (p *PeerWrapper) wrapAndRegister(rawStream RawStream) *Stream {
 	cleanup := func() {
-		p.removeStream(qStream.StreamID())
+		p.removeStream(rawStream.StreamID())
 	}
 
-	stream := NewStream(p.ctx, qStream, qStream.StreamID(), p.peerID, cleanup)
+	stream := NewStream(p.ctx, rawStream, rawStream.StreamID(), p.peerID, p.traffic, cleanup, p.violation)
 
 	p.streamsMu.Lock()
 	p.streams[stream.StreamID] = stream
 	p.streamsMu.Unlock()
 
+	p.goTracked(stream.Wait)
+
 	return stream
 }
 
-func (p *PeerWrapper) removeStream(id quic.StreamID) {
+func (p *PeerWrapper) removeStream(id StreamID) {
 	p.streamsMu.Lock()
 	delete(p.streams, id)
 	p.streamsMu.Unlock()
@@ -0,0 +1,105 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/crypto"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+	"github.com/quic-go/quic-go"
+)
+
+// quicConn adapts *quic.Conn to Conn
+type quicConn struct {
+	conn *quic.Conn
+}
+
+// verifyTLSIdentity is the verifyPeer of QUIC, the handshake key must be the
+// key of the TLS certificate. The listener hands out connections before the
+// TLS handshake is done, so it waits for the client certificate first.
+func (c *quicConn) verifyTLSIdentity(peerPubKey types.PeerPublicKey) error {
+	select {
+	case <-c.conn.HandshakeComplete():
+	case <-c.conn.Context().Done():
+		return context.Cause(c.conn.Context())
+	}
+
+	certs := c.conn.ConnectionState().TLS.PeerCertificates
+	if len(certs) == 0 {
+		return errors.New("peer sent no TLS certificate")
+	}
+	certKey, err := crypto.PeerKeyFromCertificate(certs[0])
+	if err != nil {
+		return err
+	}
+	return sameIdentity(types.PeerPublicKey(certKey))(peerPubKey)
+}
+
+func (c *quicConn) OpenStream(ctx context.Context) (RawStream, error) {
+	stream, err := c.conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &quicStream{stream}, nil
+}
+
+func (c *quicConn) AcceptStream(ctx context.Context) (RawStream, error) {
+	stream, err := c.conn.AcceptStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &quicStream{stream}, nil
+}
+
+func (c *quicConn) OpenUniStream(ctx context.Context) (SendStream, error) {
+	return c.conn.OpenUniStreamSync(ctx)
+}
+
+func (c *quicConn) AcceptUniStream(ctx context.Context) (ReceiveStream, error) {
+	stream, err := c.conn.AcceptUniStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return quicReceiveStream{stream}, nil
+}
+
+func (c *quicConn) SendDatagram(data []byte) error {
+	return c.conn.SendDatagram(data)
+}
+
+func (c *quicConn) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	return c.conn.ReceiveDatagram(ctx)
+}
+
+func (c *quicConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *quicConn) Context() context.Context {
+	return c.conn.Context()
+}
+
+func (c *quicConn) CloseWithError(code ErrorCode, reason string) error {
+	return c.conn.CloseWithError(quic.ApplicationErrorCode(code), reason)
+}
+
+type quicStream struct {
+	*quic.Stream
+}
+
+func (s *quicStream) StreamID() StreamID {
+	return StreamID(s.Stream.StreamID())
+}
+
+func (s *quicStream) CancelRead(code ErrorCode) {
+	s.Stream.CancelRead(quic.StreamErrorCode(code))
+}
+
+type quicReceiveStream struct {
+	*quic.ReceiveStream
+}
+
+func (s quicReceiveStream) CancelRead(code ErrorCode) {
+	s.ReceiveStream.CancelRead(quic.StreamErrorCode(code))
+}
@@ -1,26 +1,147 @@
 package network
 
-import "io"
+import (
+	"errors"
+	"io"
+	"time"
 
-func writeFrame(w io.Writer, msgType MessageType, data []byte) error {
+	"github.com/DmytroBuzhylov/echofog-core/pkg/utils"
+)
 
-	payload := make([]byte, 1+len(data))
+var (
+	ErrFrameTooLarge = errors.New("frame too large")
+	ErrEmptyFrame    = errors.New("empty frame")
+)
 
-	copy(payload[1:], data)
+// isProtocolViolation reports whether err means the peer broke the framing,
+// such connections are closed with ErrCodeProtocolViolation
+func isProtocolViolation(err error) bool {
+	return errors.Is(err, ErrFrameTooLarge) || errors.Is(err, ErrEmptyFrame)
+}
+
+// StreamKind selects the frame limits of a stream
+type StreamKind int
+
+const (
+	StreamKindHandshake StreamKind = iota
+	StreamKindGossip
+	StreamKindContent
+	StreamKindControl
+)
+
+type frameLimits struct {
+	// maxSize is the largest frame including the type byte
+	maxSize int
+	// bodyTimeout bounds reading a frame once its length arrived, it is
+	// only applied by readFrameTimed
+	bodyTimeout time.Duration
+}
+
+var streamLimits = [...]frameLimits{
+	StreamKindHandshake: {maxSize: 4 << 10},
+	StreamKindGossip:    {maxSize: 256 << 10},
+	// content frames carry a DAG chunk of 256 KiB plus its envelope
+	StreamKindContent: {maxSize: 1 << 20, bodyTimeout: 30 * time.Second},
+	// larger gossip messages get a stream of their own, see SendGossipMessage
+	StreamKindControl: {maxSize: maxControlMessage + 1, bodyTimeout: 10 * time.Second},
+}
 
-	payload[0] = byte(msgType)
+// gossipReadTimeout bounds reading the single frame of a gossip stream
+const gossipReadTimeout = 10 * time.Second
 
-	return writeLengthPrefix(w, payload)
+// MaxFrameSize is the largest payload writeFrame accepts for kind
+func MaxFrameSize(kind StreamKind) int {
+	return streamLimits[kind].maxSize - 1
 }
 
-func readFrame(r io.Reader) (MessageType, []byte, error) {
-	data, err := readLengthPrefix(r)
+func writeFrame(w io.Writer, kind StreamKind, msgType MessageType, data []byte) error {
+	if len(data) > MaxFrameSize(kind) {
+		return ErrFrameTooLarge
+	}
+
+	n := frameOverhead + len(data)
+	bufp := utils.GetBuffer()
+	defer utils.PutBuffer(bufp)
+	buf := *bufp
+	if n > len(buf) {
+		buf = make([]byte, n)
+	}
+
+	putLengthPrefix(buf, 1+len(data))
+	buf[lengthPrefixSize] = byte(msgType)
+	copy(buf[frameOverhead:], data)
+
+	_, err := w.Write(buf[:n])
+	return err
+}
+
+// appendFrame appends the frame writeFrame would write to buf
+func appendFrame(buf []byte, msgType MessageType, data []byte) []byte {
+	var prefix [frameOverhead]byte
+	putLengthPrefix(prefix[:], 1+len(data))
+	prefix[lengthPrefixSize] = byte(msgType)
+	buf = append(buf, prefix[:]...)
+	return append(buf, data...)
+}
+
+// readFrame returns a frame the caller may keep, see readFrameTimed
+func readFrame(r ReceiveStream, kind StreamKind) (MessageType, []byte, error) {
+	var (
+		msgType MessageType
+		payload []byte
+	)
+	err := readFrameTimed(r, kind, func(t MessageType, data []byte) error {
+		msgType = t
+		payload = append([]byte(nil), data...)
+		return nil
+	})
 	if err != nil {
 		return TypeUnknown, nil, err
 	}
-	msgType := MessageType(data[0])
+	return msgType, payload, nil
+}
+
+// readFrameFunc reads one frame into a pooled buffer and passes it to fn,
+// the payload must not be used after fn returns. Read deadlines are left to
+// the caller.
+func readFrameFunc(r io.Reader, kind StreamKind, fn func(msgType MessageType, payload []byte) error) error {
+	length, err := readLengthPrefix(r, streamLimits[kind].maxSize)
+	if err != nil {
+		return err
+	}
+	return readFrameBody(r, length, fn)
+}
+
+// readFrameTimed is readFrameFunc for the read loops that own the read side
+// of a stream and set no deadline on it: once the length of a frame arrived,
+// its body must follow within the body timeout of kind. The read deadline
+// is cleared again before it returns.
+func readFrameTimed(r ReceiveStream, kind StreamKind, fn func(msgType MessageType, payload []byte) error) error {
+	limits := streamLimits[kind]
+	length, err := readLengthPrefix(r, limits.maxSize)
+	if err != nil {
+		return err
+	}
+	if limits.bodyTimeout > 0 {
+		r.SetReadDeadline(time.Now().Add(limits.bodyTimeout))
+		defer r.SetReadDeadline(time.Time{})
+	}
+	return readFrameBody(r, length, fn)
+}
+
+func readFrameBody(r io.Reader, length int, fn func(msgType MessageType, payload []byte) error) error {
+	bufp := utils.GetBuffer()
+	defer utils.PutBuffer(bufp)
+	buf := *bufp
+	if length > len(buf) {
+		buf = make([]byte, length)
+	}
+	data := buf[:length]
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
 
-	return msgType, data[1:], err
+	return fn(MessageType(data[0]), data[1:])
 }
 
 func sendReadyFrame(w io.Writer) bool {
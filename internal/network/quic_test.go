@@ -0,0 +1,113 @@
+package network
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/crypto"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+
+	"github.com/quic-go/quic-go"
+)
+
+// newQUICTransport listens on loopback with the TLS certificate of certKey
+// and the handshake key priv
+func newQUICTransport(t *testing.T, certKey, priv types.PeerPrivateKey) *QuicTransport {
+	t.Helper()
+	tlsCfg, err := crypto.GenerateTLSConfig(ed25519.PrivateKey(certKey[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	protocol := Protocol{MinVersion: VersionSignedHello, MaxVersion: VersionIdentify}
+	tr, err := NewQUICTransport("127.0.0.1:0", tlsCfg, GetQuicConfig(), priv, protocol, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := tr.Listen(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		tr.Close()
+	})
+	return tr
+}
+
+func TestQUICHandshake(t *testing.T) {
+	keyA, keyB := newNoiseKey(t), newNoiseKey(t)
+	a, b := newQUICTransport(t, keyA, keyA), newQUICTransport(t, keyB, keyB)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	id, err := a.Dial(ctx, b.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != types.PeerPubKeyToID(types.PeerPrivateKeyToPublic(keyB)) {
+		t.Fatal("dial reached another peer")
+	}
+	select {
+	case ev := <-b.ConnChan():
+		if ev.PeerID != types.PeerPubKeyToID(types.PeerPrivateKeyToPublic(keyA)) {
+			t.Fatal("listener authenticated another peer")
+		}
+	case <-ctx.Done():
+		t.Fatal("listener handed out no connection")
+	}
+}
+
+// TestQUICCertificateOfAnotherKey runs a side whose TLS certificate is not
+// made by its handshake key, the other side has to close the connection
+func TestQUICCertificateOfAnotherKey(t *testing.T) {
+	t.Run("dialer", func(t *testing.T) {
+		keyA, keyB := newNoiseKey(t), newNoiseKey(t)
+		a, b := newQUICTransport(t, newNoiseKey(t), keyA), newQUICTransport(t, keyB, keyB)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		// the dial may return before the listener checked the certificate,
+		// the connection is closed right after
+		_, err := a.Dial(ctx, b.Addr().String())
+		if err == nil {
+			conn := (<-a.ConnChan()).Conn
+			select {
+			case <-conn.Context().Done():
+				err = context.Cause(conn.Context())
+			case <-ctx.Done():
+				t.Fatal("connection stayed open")
+			}
+		}
+		var appErr *quic.ApplicationError
+		if !errors.As(err, &appErr) || !appErr.Remote || appErr.ErrorCode != quic.ApplicationErrorCode(ErrCodeAuthFailed) {
+			t.Fatalf("connection closed with %v, want the listener to close with ErrCodeAuthFailed", err)
+		}
+		select {
+		case ev := <-b.ConnChan():
+			t.Fatalf("listener handed out the connection of %x", ev.PeerID[:4])
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("listener", func(t *testing.T) {
+		keyA, keyB := newNoiseKey(t), newNoiseKey(t)
+		a, b := newQUICTransport(t, keyA, keyA), newQUICTransport(t, newNoiseKey(t), keyB)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := a.Dial(ctx, b.Addr().String())
+		if err == nil || !strings.Contains(err.Error(), "handshake key does not match the transport identity") {
+			t.Fatalf("dial returned %v, want an identity mismatch", err)
+		}
+		select {
+		case ev := <-b.ConnChan():
+			t.Fatalf("listener handed out the connection of %x", ev.PeerID[:4])
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+}
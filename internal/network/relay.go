@@ -0,0 +1,364 @@
+package network
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+)
+
+// RelayScheme starts the address of a peer reachable through a relay
+const RelayScheme = "relay"
+
+// relayChunk is the largest TypeRelayData frame a circuit writes
+const relayChunk = 16 << 10
+
+var errCircuitFrame = errors.New("unexpected frame on relay circuit")
+
+// RelayAddr is relay://<relay addr>/<relay id>/<peer id>, the peer is
+// reached through the relay dialed at Relay
+type RelayAddr struct {
+	Relay   string
+	RelayID types.PeerID
+	PeerID  types.PeerID
+}
+
+func (a RelayAddr) Network() string { return RelayScheme }
+func (a RelayAddr) String() string {
+	return RelayScheme + "://" + a.Relay + "/" + hex.EncodeToString(a.RelayID[:]) + "/" + hex.EncodeToString(a.PeerID[:])
+}
+
+// IsRelayAddr reports whether addr is a relay:// address
+func IsRelayAddr(addr string) bool {
+	return strings.HasPrefix(addr, RelayScheme+"://")
+}
+
+// ParseRelayAddr parses a relay:// address, the relay itself must have a
+// direct host:port address
+func ParseRelayAddr(addr string) (RelayAddr, error) {
+	u, err := url.Parse(addr)
+	if err != nil || u.Scheme != RelayScheme {
+		return RelayAddr{}, fmt.Errorf("invalid relay address %q", addr)
+	}
+	if _, _, err := net.SplitHostPort(u.Host); err != nil {
+		return RelayAddr{}, fmt.Errorf("invalid relay host in %q: %w", addr, err)
+	}
+	relayHex, peerHex, ok := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if !ok {
+		return RelayAddr{}, fmt.Errorf("missing peer id in %q", addr)
+	}
+
+	res := RelayAddr{Relay: u.Host}
+	for _, id := range []struct {
+		dst *types.PeerID
+		hex string
+	}{{&res.RelayID, relayHex}, {&res.PeerID, peerHex}} {
+		raw, err := hex.DecodeString(id.hex)
+		if err != nil || len(raw) != len(id.dst) {
+			return RelayAddr{}, fmt.Errorf("invalid peer id %q in %q", id.hex, addr)
+		}
+		copy(id.dst[:], raw)
+	}
+	return res, nil
+}
+
+// CircuitOpener asks the relay of addr for a circuit to its peer and
+// returns the stream once the relay and the peer accepted it
+type CircuitOpener func(ctx context.Context, addr RelayAddr) (*Stream, error)
+
+// RelayTransport runs Noise and the peer handshake end to end over relay
+// circuits, like TCPTransport over a socket, so the relay only sees
+// ciphertext and can act as neither side. The circuits are streams of the
+// connections to relays, the swarm opens them and hands over the ones
+// relays open to us.
+type RelayTransport struct {
+	*baseTransport
+
+	mu           sync.Mutex
+	open         CircuitOpener
+	acceptCtx    context.Context
+	acceptCancel context.CancelFunc
+}
+
+var _ Transport = (*RelayTransport)(nil)
+
+func NewRelayTransport(privKey types.PeerPrivateKey, protocol Protocol, log *slog.Logger) *RelayTransport {
+	return &RelayTransport{
+		baseTransport: newBaseTransport(privKey, protocol, log),
+	}
+}
+
+// AsRelayTransport returns t, or the first transport of a FallbackTransport,
+// that is a RelayTransport
+func AsRelayTransport(t Transport) (*RelayTransport, bool) {
+	return findTransport[*RelayTransport](t)
+}
+
+// SetOpener sets how circuits are opened, dials fail until it is set
+func (t *RelayTransport) SetOpener(open CircuitOpener) {
+	t.mu.Lock()
+	t.open = open
+	t.mu.Unlock()
+}
+
+// Addr is nil, the relayed addresses depend on the reservations of the swarm
+func (t *RelayTransport) Addr() net.Addr {
+	return nil
+}
+
+func (t *RelayTransport) Listen(ctx context.Context) error {
+	t.mu.Lock()
+	t.acceptCtx, t.acceptCancel = context.WithCancel(ctx)
+	t.mu.Unlock()
+	return nil
+}
+
+// StopAccepting refuses new circuits and waits for in-flight inbound handshakes
+func (t *RelayTransport) StopAccepting() {
+	t.mu.Lock()
+	if t.acceptCancel != nil {
+		t.acceptCancel()
+	}
+	t.mu.Unlock()
+	t.acceptWg.Wait()
+}
+
+func (t *RelayTransport) Close() error {
+	t.StopAccepting()
+	t.closeConnChan()
+	return nil
+}
+
+func (t *RelayTransport) Dial(ctx context.Context, addr string) (types.PeerID, error) {
+	peerID, err := t.dial(ctx, addr)
+	t.countDial(err)
+	return peerID, err
+}
+
+func (t *RelayTransport) dial(ctx context.Context, addr string) (types.PeerID, error) {
+	if !IsRelayAddr(addr) {
+		return types.PeerID{}, ErrUnsupportedAddr
+	}
+	ra, err := ParseRelayAddr(addr)
+	if err != nil {
+		return types.PeerID{}, err
+	}
+
+	t.mu.Lock()
+	open := t.open
+	t.mu.Unlock()
+	if open == nil {
+		return types.PeerID{}, errors.New("relay transport is not attached to a swarm")
+	}
+
+	stream, err := open(ctx, ra)
+	if err != nil {
+		return types.PeerID{}, err
+	}
+	conn := newCircuitConn(stream, t.localAddr(ra), ra)
+
+	session, remoteKey, err := t.secure(ctx, conn, true)
+	if err != nil {
+		conn.Close()
+		return types.PeerID{}, fmt.Errorf("noise handshake with %s: %w", addr, err)
+	}
+	if types.PeerPubKeyToID(remoteKey) != ra.PeerID {
+		session.CloseWithError(ErrCodeAuthFailed, "unexpected peer")
+		return types.PeerID{}, errors.New("relay circuit reached another peer")
+	}
+
+	return t.handshakeOutbound(ctx, session, sameIdentity(remoteKey))
+}
+
+// Accept takes over a circuit a relay opened to us from remote.PeerID, the
+// relay only claims that identity, the handshake proves it
+func (t *RelayTransport) Accept(stream *Stream, remote RelayAddr) {
+	t.mu.Lock()
+	ctx := t.acceptCtx
+	if ctx == nil || ctx.Err() != nil {
+		t.mu.Unlock()
+		stream.Close()
+		return
+	}
+	t.acceptWg.Add(1)
+	t.mu.Unlock()
+
+	go func() {
+		defer t.acceptWg.Done()
+
+		conn := newCircuitConn(stream, t.localAddr(remote), remote)
+		session, remoteKey, err := t.secure(ctx, conn, false)
+		if err != nil {
+			t.log.Debug("Noise handshake failed", "addr", remote.String(), "err", err)
+			conn.Close()
+			return
+		}
+		if types.PeerPubKeyToID(remoteKey) != remote.PeerID {
+			t.log.Debug("Relayed peer is not who the relay announced", "addr", remote.String())
+			session.CloseWithError(ErrCodeAuthFailed, "unexpected peer")
+			return
+		}
+		t.acceptInbound(ctx, session, sameIdentity(remoteKey))
+	}()
+}
+
+// localAddr is our own address behind the relay of remote
+func (t *RelayTransport) localAddr(remote RelayAddr) RelayAddr {
+	remote.PeerID = types.PeerPubKeyToID(types.PeerPrivateKeyToPublic(t.privKey))
+	return remote
+}
+
+// circuitConn is a relay circuit as a net.Conn, its bytes travel in
+// TypeRelayData frames of a Stream
+type circuitConn struct {
+	stream        *Stream
+	local, remote net.Addr
+
+	// pending is what is left of the frame read last
+	pending []byte
+
+	readDeadline  connDeadline
+	writeDeadline connDeadline
+}
+
+var _ net.Conn = (*circuitConn)(nil)
+
+func newCircuitConn(stream *Stream, local, remote net.Addr) *circuitConn {
+	return &circuitConn{stream: stream, local: local, remote: remote}
+}
+
+func (c *circuitConn) Read(b []byte) (int, error) {
+	for len(c.pending) == 0 {
+		deadline, changed := c.readDeadline.get()
+		var (
+			err     error
+			timer   *time.Timer
+			timeout <-chan time.Time
+		)
+		if !deadline.IsZero() {
+			wait := time.Until(deadline)
+			if wait <= 0 {
+				return 0, os.ErrDeadlineExceeded
+			}
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+
+		select {
+		case msg := <-c.stream.Incoming:
+			err = c.take(msg)
+		case <-c.stream.Done():
+			// frames read before the stream closed are still delivered
+			select {
+			case msg := <-c.stream.Incoming:
+				err = c.take(msg)
+			default:
+				err = io.EOF
+			}
+		case <-timeout:
+			err = os.ErrDeadlineExceeded
+		case <-changed:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *circuitConn) take(msg *StreamMessage) error {
+	if msg.Type != TypeRelayData {
+		c.stream.Close()
+		return errCircuitFrame
+	}
+	c.pending = msg.Payload
+	return nil
+}
+
+// Write copies b, the frames are queued on the stream
+func (c *circuitConn) Write(b []byte) (int, error) {
+	ctx := context.Background()
+	if deadline, _ := c.writeDeadline.get(); !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	written := 0
+	for written < len(b) {
+		chunk := b[written:min(len(b), written+relayChunk)]
+		if err := c.stream.Send(ctx, TypeRelayData, append([]byte(nil), chunk...)); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				err = os.ErrDeadlineExceeded
+			}
+			return written, err
+		}
+		written += len(chunk)
+	}
+	return written, nil
+}
+
+func (c *circuitConn) Close() error {
+	return c.stream.Close()
+}
+
+func (c *circuitConn) LocalAddr() net.Addr  { return c.local }
+func (c *circuitConn) RemoteAddr() net.Addr { return c.remote }
+
+func (c *circuitConn) SetDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	c.writeDeadline.set(t)
+	return nil
+}
+
+func (c *circuitConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+func (c *circuitConn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.set(t)
+	return nil
+}
+
+// connDeadline wakes a blocked reader whenever the deadline changes
+type connDeadline struct {
+	mu      sync.Mutex
+	t       time.Time
+	changed chan struct{}
+}
+
+func (d *connDeadline) get() (time.Time, <-chan struct{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.changed == nil {
+		d.changed = make(chan struct{})
+	}
+	return d.t, d.changed
+}
+
+func (d *connDeadline) set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.t = t
+	if d.changed != nil {
+		close(d.changed)
+	}
+	d.changed = make(chan struct{})
+}
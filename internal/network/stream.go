@@ -2,60 +2,108 @@ package network
 
 import (
 	"context"
-
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+)
+
+const (
+	// streamQueueSize is how many frames Send queues before it blocks
+	streamQueueSize = 100
+	// streamWriteTimeout closes the stream of a peer that stopped reading
+	streamWriteTimeout = 30 * time.Second
+)
 
-	"github.com/quic-go/quic-go"
+var (
+	ErrStreamClosed = errors.New("stream closed")
+	// ErrFramesDropped is the Err of a stream that closed before its queued
+	// frames were written
+	ErrFramesDropped = errors.New("stream closed with unsent frames")
 )
 
 type StreamMessage struct {
 	Type    MessageType
 	Payload []byte
+
+	// flushed marks a Flush, it is closed once the frames before it were written
+	flushed chan struct{}
 }
 
 type Stream struct {
-	StreamID quic.StreamID
+	StreamID StreamID
 	RemoteID types.PeerID
 
 	Incoming chan *StreamMessage
-	Outgoing chan *StreamMessage
+	outgoing chan *StreamMessage
 
-	stream *quic.Stream
+	stream RawStream
 
 	ctx    context.Context
 	cancel context.CancelFunc
 
-	onClose func()
-	once    sync.Once
+	traffic  *Traffic
+	bytesIn  atomic.Uint64
+	bytesOut atomic.Uint64
+
+	// err is the write error that closed the stream, or ErrFramesDropped
+	err     error
+	errOnce sync.Once
+
+	onClose     func()
+	onViolation func(err error)
+	once        sync.Once
+	wg          sync.WaitGroup
 }
 
-func NewStream(ctx context.Context, stream *quic.Stream, StreamID quic.StreamID, remoteID types.PeerID, onClose func()) *Stream {
+// NewStream starts the loops of a content stream, onViolation is called when
+// the peer breaks the framing
+func NewStream(ctx context.Context, stream RawStream, StreamID StreamID, remoteID types.PeerID, traffic *Traffic, onClose func(), onViolation func(err error)) *Stream {
 	childCtx, cancel := context.WithCancel(ctx)
 	s := &Stream{
-		StreamID: StreamID,
-		RemoteID: remoteID,
-		Incoming: make(chan *StreamMessage, 100),
-		Outgoing: make(chan *StreamMessage, 100),
-		stream:   stream,
-		ctx:      childCtx,
-		cancel:   cancel,
-		onClose:  onClose,
+		StreamID:    StreamID,
+		RemoteID:    remoteID,
+		Incoming:    make(chan *StreamMessage, streamQueueSize),
+		outgoing:    make(chan *StreamMessage, streamQueueSize),
+		stream:      stream,
+		traffic:     traffic,
+		ctx:         childCtx,
+		cancel:      cancel,
+		onClose:     onClose,
+		onViolation: onViolation,
 	}
 
+	s.wg.Add(2)
 	go s.readLoop()
 	go s.writeLoop()
 
 	return s
 }
 
+// Wait blocks until the read and write loops have returned
+func (s *Stream) Wait() {
+	s.wg.Wait()
+}
+
+// Close drops the frames still queued, it returns ErrFramesDropped if there
+// were any, Flush first to have them written
 func (s *Stream) Close() error {
 	var err error
 	s.once.Do(func() {
+		var dropped error
+		if n := len(s.outgoing); n > 0 {
+			dropped = fmt.Errorf("%w: %d queued", ErrFramesDropped, n)
+			s.errOnce.Do(func() { s.err = dropped })
+		}
 		s.cancel()
-		s.stream.CancelRead(quic.StreamErrorCode(ErrCodeNormalClose))
+		s.stream.CancelRead(ErrCodeNormalClose)
 		err = s.stream.Close()
+		if err == nil {
+			err = dropped
+		}
 
 		if s.onClose != nil {
 			s.onClose()
@@ -66,12 +114,18 @@ func (s *Stream) Close() error {
 }
 
 func (s *Stream) readLoop() {
+	defer s.wg.Done()
 	defer s.Close()
 	for {
-		msgType, payload, err := readFrame(s.stream)
+		msgType, payload, err := readFrame(s.stream, StreamKindContent)
 		if err != nil {
+			if isProtocolViolation(err) && s.onViolation != nil {
+				s.onViolation(err)
+			}
 			return
 		}
+		s.traffic.countIn(msgType, len(payload))
+		s.bytesIn.Add(uint64(len(payload) + frameOverhead))
 
 		select {
 		case s.Incoming <- &StreamMessage{
@@ -84,12 +138,25 @@ func (s *Stream) readLoop() {
 	}
 }
 
+// writeLoop closes the stream on the first failed write, frames still
+// queued are dropped
 func (s *Stream) writeLoop() {
+	defer s.wg.Done()
 	defer s.Close()
 	for {
 		select {
-		case msg := <-s.Outgoing:
-			writeFrame(s.stream, msg.Type, msg.Payload)
+		case msg := <-s.outgoing:
+			if msg.flushed != nil {
+				close(msg.flushed)
+				continue
+			}
+			s.stream.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if err := writeFrame(s.stream, StreamKindContent, msg.Type, msg.Payload); err != nil {
+				s.errOnce.Do(func() { s.err = err })
+				return
+			}
+			s.traffic.countOut(msg.Type, len(msg.Payload))
+			s.bytesOut.Add(uint64(len(msg.Payload) + frameOverhead))
 
 		case <-s.ctx.Done():
 			return
@@ -97,11 +164,86 @@ func (s *Stream) writeLoop() {
 	}
 }
 
-func (s *Stream) Send(Type MessageType, Payload []byte) {
-	s.Outgoing <- &StreamMessage{
-		Type:    Type,
-		Payload: Payload,
+// Send queues a frame and blocks while the queue is full until ctx ends or
+// the stream closes. A nil error means the frame was queued, not written:
+// frames are written in order, a failed write closes the stream and is
+// returned by every later Send and by Err, frames still queued when the
+// stream closes are lost and Err reports ErrFramesDropped. Use Flush to wait
+// for the write.
+func (s *Stream) Send(ctx context.Context, msgType MessageType, payload []byte) error {
+	if len(payload) > MaxFrameSize(StreamKindContent) {
+		return ErrFrameTooLarge
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+
+	select {
+	case s.outgoing <- &StreamMessage{Type: msgType, Payload: payload}:
+		return nil
+	case <-s.ctx.Done():
+		return s.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush waits until the frames queued before the call were written to the
+// stream, it returns Err if the stream closed first
+func (s *Stream) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case s.outgoing <- &StreamMessage{flushed: flushed}:
+	case <-s.ctx.Done():
+		return s.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-flushed:
+		return nil
+	case <-s.ctx.Done():
+		select {
+		case <-flushed:
+			return nil
+		default:
+			return s.Err()
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Err returns why the stream closed, nil while it is open
+func (s *Stream) Err() error {
+	select {
+	case <-s.ctx.Done():
+	default:
+		return nil
 	}
+	s.errOnce.Do(func() { s.err = ErrStreamClosed })
+	return s.err
+}
+
+// QueueLen is the number of frames waiting to be written, a queue that
+// stays full means the peer reads slower than we send
+func (s *Stream) QueueLen() int {
+	return len(s.outgoing)
+}
+
+// Done is closed once the stream is closed
+func (s *Stream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// BytesIn and BytesOut count the frames received and sent on this stream
+func (s *Stream) BytesIn() uint64 {
+	return s.bytesIn.Load()
+}
+
+func (s *Stream) BytesOut() uint64 {
+	return s.bytesOut.Load()
 }
 
 func (s *Stream) ReadCh() <-chan *StreamMessage {
@@ -0,0 +1,116 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+)
+
+// blockedStream holds every write until release is closed
+type blockedStream struct {
+	RawStream
+	writing chan struct{}
+	release chan struct{}
+}
+
+func (s *blockedStream) Write(b []byte) (int, error) {
+	select {
+	case s.writing <- struct{}{}:
+	default:
+	}
+	<-s.release
+	return s.RawStream.Write(b)
+}
+
+func newStreamPair(t *testing.T) (RawStream, RawStream) {
+	t.Helper()
+	a, b := newMemConnPair(1, 2)
+	t.Cleanup(func() { a.CloseWithError(ErrCodeNormalClose, "") })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	local, err := a.OpenStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := b.AcceptStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return local, remote
+}
+
+func TestStreamFlush(t *testing.T) {
+	local, remote := newStreamPair(t)
+	sender := NewStream(context.Background(), local, local.StreamID(), types.PeerID{}, nil, nil, nil)
+	receiver := NewStream(context.Background(), remote, remote.StreamID(), types.PeerID{}, nil, nil, nil)
+	defer sender.Close()
+	defer receiver.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	const frames = 10
+	for i := range frames {
+		if err := sender.Send(ctx, TypeRelayData, []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sender.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got := sender.QueueLen(); got != 0 {
+		t.Fatalf("QueueLen after Flush = %d, want 0", got)
+	}
+	if err := sender.Close(); err != nil {
+		t.Fatalf("Close after Flush: %v", err)
+	}
+
+	for i := range frames {
+		select {
+		case msg := <-receiver.ReadCh():
+			if msg.Payload[0] != byte(i) {
+				t.Fatalf("frame %d carries %d", i, msg.Payload[0])
+			}
+		case <-ctx.Done():
+			t.Fatalf("received %d of %d frames", i, frames)
+		}
+	}
+}
+
+func TestStreamCloseReportsDroppedFrames(t *testing.T) {
+	local, _ := newStreamPair(t)
+	blocked := &blockedStream{RawStream: local, writing: make(chan struct{}, 1), release: make(chan struct{})}
+	s := NewStream(context.Background(), blocked, local.StreamID(), types.PeerID{}, nil, nil, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for range 3 {
+		if err := s.Send(ctx, TypeRelayData, []byte("frame")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the first frame is in Write, the other two stay queued
+	<-blocked.writing
+
+	flushed := make(chan error, 1)
+	go func() { flushed <- s.Flush(ctx) }()
+
+	if err := s.Close(); !errors.Is(err, ErrFramesDropped) {
+		t.Fatalf("Close = %v, want ErrFramesDropped", err)
+	}
+	if err := s.Err(); !errors.Is(err, ErrFramesDropped) {
+		t.Fatalf("Err = %v, want ErrFramesDropped", err)
+	}
+	if err := <-flushed; !errors.Is(err, ErrFramesDropped) {
+		t.Fatalf("Flush = %v, want ErrFramesDropped", err)
+	}
+	if err := s.Send(ctx, TypeRelayData, []byte("late")); !errors.Is(err, ErrFramesDropped) {
+		t.Fatalf("Send after Close = %v, want ErrFramesDropped", err)
+	}
+
+	close(blocked.release)
+	s.Wait()
+}
@@ -0,0 +1,125 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+)
+
+const tcpAcceptRetryDelay = 100 * time.Millisecond
+
+// TCPTransport is the fallback for networks that block UDP. Connections are
+// secured with Noise XX bound to the node identity and carry streams and
+// datagrams through muxSession, after that the handshake is the same as on QUIC.
+type TCPTransport struct {
+	*baseTransport
+
+	ln           net.Listener
+	acceptCancel context.CancelFunc
+}
+
+var _ Transport = (*TCPTransport)(nil)
+
+// NewTCPTransport binds the TCP listener at addr, errors such as a busy port
+// are returned to the caller
+func NewTCPTransport(addr string, privKey types.PeerPrivateKey, protocol Protocol, log *slog.Logger) (*TCPTransport, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TCPTransport{
+		baseTransport: newBaseTransport(privKey, protocol, log),
+		ln:            ln,
+	}, nil
+}
+
+func (t *TCPTransport) Addr() net.Addr {
+	return t.ln.Addr()
+}
+
+func (t *TCPTransport) Listen(ctx context.Context) error {
+	acceptCtx, cancel := context.WithCancel(ctx)
+	t.acceptCancel = cancel
+
+	t.acceptWg.Add(1)
+	go func() {
+		defer t.acceptWg.Done()
+		t.acceptLoop(acceptCtx)
+	}()
+	return nil
+}
+
+// StopAccepting closes the listener and waits for in-flight inbound handshakes to finish
+func (t *TCPTransport) StopAccepting() {
+	if t.acceptCancel != nil {
+		t.acceptCancel()
+	}
+	t.ln.Close()
+	t.acceptWg.Wait()
+}
+
+// Close stops accepting and closes the connection channel. Connections that
+// were already handed to the swarm must be closed by it.
+func (t *TCPTransport) Close() error {
+	t.StopAccepting()
+	t.closeConnChan()
+	return nil
+}
+
+func (t *TCPTransport) Dial(ctx context.Context, addr string) (types.PeerID, error) {
+	peerID, err := t.dial(ctx, addr)
+	t.countDial(err)
+	return peerID, err
+}
+
+func (t *TCPTransport) dial(ctx context.Context, addr string) (types.PeerID, error) {
+	if isURLAddr(addr) {
+		return types.PeerID{}, ErrUnsupportedAddr
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return types.PeerID{}, err
+	}
+
+	session, remoteKey, err := t.secure(ctx, conn, true)
+	if err != nil {
+		conn.Close()
+		return types.PeerID{}, fmt.Errorf("noise handshake with %s: %w", addr, err)
+	}
+
+	return t.handshakeOutbound(ctx, session, sameIdentity(remoteKey))
+}
+
+func (t *TCPTransport) acceptLoop(ctx context.Context) {
+	for {
+		conn, err := t.ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			t.log.Warn("Accept failed", "err", err)
+			time.Sleep(tcpAcceptRetryDelay)
+			continue
+		}
+
+		t.acceptWg.Add(1)
+		go func() {
+			defer t.acceptWg.Done()
+
+			session, remoteKey, err := t.secure(ctx, conn, false)
+			if err != nil {
+				t.log.Debug("Noise handshake failed", "addr", conn.RemoteAddr().String(), "err", err)
+				conn.Close()
+				return
+			}
+			t.acceptInbound(ctx, session, sameIdentity(remoteKey))
+		}()
+	}
+}
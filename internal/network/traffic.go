@@ -0,0 +1,85 @@
+package network
+
+import "sync/atomic"
+
+var messageTypeNames = [...]string{
+	TypeUnknown:             "unknown",
+	TypeHandshake:           "handshake",
+	TypeReady:               "ready",
+	TypeGossip:              "gossip",
+	TypePing:                "ping",
+	TypeChatMessage:         "chat_message",
+	TypeDatagram:            "datagram",
+	TypeGetPeerRequest:      "get_peer_request",
+	TypeGetPeerResponse:     "get_peer_response",
+	TypeBlockRequest:        "block_request",
+	TypeBlockResponse:       "block_response",
+	TypeSessionInitRequest:  "session_init_request",
+	TypeSessionInitResponse: "session_init_response",
+	TypeStreamInitRequest:   "stream_init_request",
+	TypeChunkRequest:        "chunk_request",
+	TypeChunkResponse:       "chunk_response",
+	TypeStreamCancel:        "stream_cancel",
+	TypeControlStream:       "control_stream",
+	TypeRPCRequest:          "rpc_request",
+	TypeRPCResponse:         "rpc_response",
+	TypeRelayHop:            "relay_hop",
+	TypeRelayStop:           "relay_stop",
+	TypeRelayStatus:         "relay_status",
+	TypeRelayData:           "relay_data",
+}
+
+func (t MessageType) String() string {
+	if t < 0 || int(t) >= len(messageTypeNames) {
+		return "unknown"
+	}
+	return messageTypeNames[t]
+}
+
+// frameOverhead is the length prefix plus the type byte written by writeFrame
+const frameOverhead = 4 + 1
+
+// Traffic counts the bytes of frames sent and received per MessageType,
+// including the frame header. A nil *Traffic counts nothing.
+type Traffic struct {
+	in  [len(messageTypeNames)]atomic.Uint64
+	out [len(messageTypeNames)]atomic.Uint64
+}
+
+func NewTraffic() *Traffic {
+	return &Traffic{}
+}
+
+func (t *Traffic) countIn(msgType MessageType, payloadLen int) {
+	if t != nil {
+		t.in[trafficIndex(msgType)].Add(uint64(payloadLen + frameOverhead))
+	}
+}
+
+func (t *Traffic) countOut(msgType MessageType, payloadLen int) {
+	if t != nil {
+		t.out[trafficIndex(msgType)].Add(uint64(payloadLen + frameOverhead))
+	}
+}
+
+// Snapshot returns the byte counters of the message types seen so far
+func (t *Traffic) Snapshot() (in, out map[MessageType]uint64) {
+	in = make(map[MessageType]uint64)
+	out = make(map[MessageType]uint64)
+	for i := range messageTypeNames {
+		if n := t.in[i].Load(); n > 0 {
+			in[MessageType(i)] = n
+		}
+		if n := t.out[i].Load(); n > 0 {
+			out[MessageType(i)] = n
+		}
+	}
+	return in, out
+}
+
+func trafficIndex(t MessageType) int {
+	if t < 0 || int(t) >= len(messageTypeNames) {
+		return int(TypeUnknown)
+	}
+	return int(t)
+}
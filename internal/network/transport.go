@@ -0,0 +1,398 @@
+package network
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+)
+
+// ErrorCode tells the remote side why a connection or stream was closed
+type ErrorCode uint64
+
+const (
+	ErrCodeNoError ErrorCode = iota
+	ErrCodeProtocolViolation
+	ErrCodeSpamDetected
+	ErrCodeStreamError
+	ErrCodeAuthFailed
+	ErrCodeNormalClose
+	ErrCodeIncompatibleVersion
+)
+
+type StreamID int64
+
+// maxDatagramSize keeps datagrams below the usual path MTU
+const maxDatagramSize = 1200
+
+var ErrDatagramLarge = errors.New("message too big for datagram")
+
+// ErrUnsupportedAddr is returned by Dial for addresses of another transport,
+// such as a ws:// URL given to QUIC
+var ErrUnsupportedAddr = errors.New("address not supported by transport")
+
+// isURLAddr reports whether addr is a URL rather than host:port
+func isURLAddr(addr string) bool {
+	return strings.Contains(addr, "://")
+}
+
+// ConnError is the close reason of a connection of the in-memory and TCP transports
+type ConnError struct {
+	Code    ErrorCode
+	Message string
+	// Remote is set when the other side closed the connection
+	Remote bool
+}
+
+func (e *ConnError) Error() string {
+	side := "local"
+	if e.Remote {
+		side = "remote"
+	}
+	return fmt.Sprintf("connection closed by %s (code %d): %s", side, e.Code, e.Message)
+}
+
+// StreamError is returned by the writer of a stream whose reader canceled it
+type StreamError struct {
+	Code ErrorCode
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("stream canceled (code %d)", e.Code)
+}
+
+// Transport establishes authenticated connections to peers. Connections in
+// both directions are handed over on ConnChan once the handshake is done.
+type Transport interface {
+	// Listen starts accepting inbound connections until ctx is canceled
+	Listen(ctx context.Context) error
+	Dial(ctx context.Context, addr string) (types.PeerID, error)
+	ConnChan() <-chan NewConnEvent
+	Addr() net.Addr
+
+	Traffic() *Traffic
+	DialStats() (succeeded, failed uint64)
+
+	// StopAccepting closes the listener and waits for in-flight inbound handshakes
+	StopAccepting()
+	// Close also closes ConnChan, connections already handed over stay open
+	Close() error
+}
+
+// Conn is an authenticated connection to one peer that carries any number
+// of streams and unreliable datagrams
+type Conn interface {
+	OpenStream(ctx context.Context) (RawStream, error)
+	AcceptStream(ctx context.Context) (RawStream, error)
+	OpenUniStream(ctx context.Context) (SendStream, error)
+	AcceptUniStream(ctx context.Context) (ReceiveStream, error)
+
+	SendDatagram(data []byte) error
+	ReceiveDatagram(ctx context.Context) ([]byte, error)
+
+	RemoteAddr() net.Addr
+	// Context is canceled when the connection is gone, its cause is the close reason
+	Context() context.Context
+	CloseWithError(code ErrorCode, reason string) error
+}
+
+type SendStream interface {
+	io.Writer
+	// Close finishes the sending direction, the remote reader gets io.EOF
+	Close() error
+	SetWriteDeadline(t time.Time) error
+}
+
+type ReceiveStream interface {
+	io.Reader
+	CancelRead(code ErrorCode)
+	SetReadDeadline(t time.Time) error
+}
+
+// RawStream is a bidirectional byte stream of a Conn, Stream adds framing on top
+type RawStream interface {
+	SendStream
+	ReceiveStream
+	StreamID() StreamID
+	SetDeadline(t time.Time) error
+}
+
+// baseTransport is the part every transport shares: the peer handshake,
+// dial statistics and handing authenticated connections to the swarm
+type baseTransport struct {
+	privKey  types.PeerPrivateKey
+	protocol Protocol
+	log      *slog.Logger
+
+	connChan chan NewConnEvent
+
+	traffic     *Traffic
+	dialsOK     atomic.Uint64
+	dialsFailed atomic.Uint64
+
+	// acceptWg tracks inbound handshakes
+	acceptWg sync.WaitGroup
+
+	// closeMu guards connChan against sends after it has been closed
+	closeMu   sync.RWMutex
+	closed    bool
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+func newBaseTransport(privKey types.PeerPrivateKey, protocol Protocol, log *slog.Logger) *baseTransport {
+	return &baseTransport{
+		privKey:  privKey,
+		protocol: protocol,
+		log:      log,
+		connChan: make(chan NewConnEvent, 10),
+		traffic:  NewTraffic(),
+		closing:  make(chan struct{}),
+	}
+}
+
+// Traffic counts the frames of every connection made by this transport
+func (b *baseTransport) Traffic() *Traffic {
+	return b.traffic
+}
+
+// DialStats returns how many outbound dials completed the handshake and how many failed
+func (b *baseTransport) DialStats() (succeeded, failed uint64) {
+	return b.dialsOK.Load(), b.dialsFailed.Load()
+}
+
+func (b *baseTransport) ConnChan() <-chan NewConnEvent {
+	return b.connChan
+}
+
+func (b *baseTransport) countDial(err error) {
+	if err != nil {
+		b.dialsFailed.Add(1)
+	} else {
+		b.dialsOK.Add(1)
+	}
+}
+
+// closeConnChan stops handing out connections, later ones are refused.
+// It reports whether this call closed it.
+func (b *baseTransport) closeConnChan() bool {
+	first := false
+	b.closeOnce.Do(func() {
+		first = true
+		close(b.closing)
+
+		b.closeMu.Lock()
+		b.closed = true
+		close(b.connChan)
+		b.closeMu.Unlock()
+	})
+	return first
+}
+
+// verifyPeer lets a transport check the key proven in the handshake against
+// the identity its own security layer authenticated, nil accepts any key
+type verifyPeer func(peerPubKey types.PeerPublicKey) error
+
+// sameIdentity rejects a handshake key that differs from the key the
+// security layer authenticated, so the handshake can't be relayed
+func sameIdentity(transportKey types.PeerPublicKey) verifyPeer {
+	return func(peerPubKey types.PeerPublicKey) error {
+		if peerPubKey != transportKey {
+			return errors.New("handshake key does not match the transport identity")
+		}
+		return nil
+	}
+}
+
+// peerHello is what the handshake learned about the peer
+type peerHello struct {
+	pubKey       types.PeerPublicKey
+	version      uint32
+	capabilities Capabilities
+}
+
+const (
+	// peerHandshakeTimeout bounds the peer handshake on the first stream
+	peerHandshakeTimeout = 10 * time.Second
+	// noiseHandshakeTimeout bounds the Noise handshake of byte stream transports
+	noiseHandshakeTimeout = 10 * time.Second
+)
+
+// secure runs the Noise handshake over conn and starts the stream
+// multiplexer, for transports without a security layer of their own
+func (b *baseTransport) secure(ctx context.Context, conn net.Conn, initiator bool) (*muxSession, types.PeerPublicKey, error) {
+	conn.SetDeadline(time.Now().Add(noiseHandshakeTimeout))
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	nc, remoteKey, err := noiseHandshake(conn, b.privKey, initiator)
+	if err != nil {
+		return nil, types.PeerPublicKey{}, err
+	}
+	if !stop() {
+		return nil, types.PeerPublicKey{}, ctx.Err()
+	}
+	conn.SetDeadline(time.Time{})
+
+	return newMuxSession(nc, conn.RemoteAddr(), initiator), remoteKey, nil
+}
+
+// closeHandshake closes conn after a failed handshake, the peer learns why
+// when the versions do not match or it broke the framing
+func closeHandshake(conn Conn, err error) {
+	var versionErr *VersionError
+	switch {
+	case errors.As(err, &versionErr):
+		conn.CloseWithError(ErrCodeIncompatibleVersion, versionErr.Error())
+	case isProtocolViolation(err):
+		conn.CloseWithError(ErrCodeProtocolViolation, err.Error())
+	default:
+		conn.CloseWithError(ErrCodeAuthFailed, "auth failed")
+	}
+}
+
+// handshakeOutbound authenticates the peer on the first stream of a dialed
+// connection and hands the connection over
+func (b *baseTransport) handshakeOutbound(ctx context.Context, conn Conn, verify verifyPeer) (types.PeerID, error) {
+	stream, err := conn.OpenStream(ctx)
+	if err != nil {
+		conn.CloseWithError(ErrCodeStreamError, "stream error")
+		return types.PeerID{}, err
+	}
+	defer stream.Close()
+	stream.SetDeadline(time.Now().Add(peerHandshakeTimeout))
+
+	hello, err := b.authenticatePeer(stream, verify)
+	if err != nil {
+		closeHandshake(conn, err)
+		return types.PeerID{}, err
+	}
+	peerID := types.PeerPubKeyToID(hello.pubKey)
+	if !sendReadyFrame(stream) {
+		conn.CloseWithError(ErrCodeStreamError, "stream error")
+		return types.PeerID{}, errors.New("error to send ready frame to peer")
+	}
+
+	addr := conn.RemoteAddr().String()
+	b.newConn(conn, true, addr, peerID, hello)
+	b.log.Debug("Peer authenticated", "peer_id", hex.EncodeToString(peerID[:]), "addr", addr, "version", hello.version)
+
+	return peerID, nil
+}
+
+// acceptInbound runs the handshake of an accepted connection in the
+// background, the connection is closed if ctx ends before it completes
+func (b *baseTransport) acceptInbound(ctx context.Context, conn Conn, verify verifyPeer) {
+	b.acceptWg.Add(1)
+	go func() {
+		defer b.acceptWg.Done()
+
+		stop := context.AfterFunc(ctx, func() {
+			conn.CloseWithError(ErrCodeNormalClose, "shutting down")
+		})
+		defer stop()
+
+		stream, err := conn.AcceptStream(ctx)
+		if err != nil {
+			conn.CloseWithError(ErrCodeStreamError, "stream error")
+			return
+		}
+		defer stream.Close()
+		stream.SetDeadline(time.Now().Add(peerHandshakeTimeout))
+
+		hello, err := b.authenticatePeer(stream, verify)
+		if err != nil {
+			b.log.Debug("Inbound handshake failed", "addr", conn.RemoteAddr().String(), "err", err)
+			closeHandshake(conn, err)
+			return
+		}
+		peerID := types.PeerPubKeyToID(hello.pubKey)
+		if !acceptReadyFrame(stream) {
+			conn.CloseWithError(ErrCodeProtocolViolation, "missing ready frame")
+			return
+		}
+
+		if !stop() {
+			return
+		}
+		addr := conn.RemoteAddr().String()
+		b.newConn(conn, false, addr, peerID, hello)
+		b.log.Debug("Peer authenticated", "peer_id", hex.EncodeToString(peerID[:]), "addr", addr, "version", hello.version)
+	}()
+}
+
+// authenticatePeer runs the nonce-signature handshake and negotiates the
+// protocol version, both sides check the version ranges on their own
+func (b *baseTransport) authenticatePeer(stream io.ReadWriter, verify verifyPeer) (peerHello, error) {
+	myNonce := make([]byte, 32)
+	rand.Read(myNonce)
+
+	if err := sendHandshake(stream, myNonce); err != nil {
+		return peerHello{}, err
+	}
+
+	if err := acceptHandshake(stream, b.privKey, b.protocol); err != nil {
+		return peerHello{}, err
+	}
+
+	peerPubKey, remote, err := checkHandshakeResponse(stream, myNonce)
+	if errors.Is(err, errUnsignedHello) {
+		return peerHello{}, &VersionError{Local: b.protocol, Remote: remote}
+	}
+	if err != nil {
+		return peerHello{}, err
+	}
+	if verify != nil {
+		if err := verify(peerPubKey); err != nil {
+			return peerHello{}, err
+		}
+	}
+
+	version, err := b.protocol.negotiate(remote)
+	if err != nil {
+		return peerHello{}, err
+	}
+
+	return peerHello{
+		pubKey:       peerPubKey,
+		version:      version,
+		capabilities: remote.Capabilities,
+	}, nil
+}
+
+func (b *baseTransport) newConn(conn Conn, isOut bool, addr string, peerID types.PeerID, hello peerHello) {
+	b.closeMu.RLock()
+	defer b.closeMu.RUnlock()
+
+	if b.closed {
+		conn.CloseWithError(ErrCodeNormalClose, "shutting down")
+		return
+	}
+
+	select {
+	case b.connChan <- NewConnEvent{
+		Conn:         conn,
+		IsOut:        isOut,
+		PeerID:       peerID,
+		Addr:         addr,
+		PeerPubKey:   hello.pubKey,
+		Version:      hello.version,
+		Capabilities: hello.capabilities,
+		Traffic:      b.traffic,
+	}:
+	case <-b.closing:
+		conn.CloseWithError(ErrCodeNormalClose, "shutting down")
+	}
+}
@@ -2,13 +2,18 @@ package network
 
 import (
 	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
-	"github.com/quic-go/quic-go"
 )
 
+// NewConnEvent hands an authenticated connection from a Transport to the swarm
 type NewConnEvent struct {
-	Conn       *quic.Conn
+	Conn       Conn
 	IsOut      bool
 	PeerID     types.PeerID
 	PeerPubKey types.PeerPublicKey
 	Addr       string
+	// Version is the negotiated protocol version, Capabilities what the peer announced
+	Version      uint32
+	Capabilities Capabilities
+	// Traffic is where the connection frames are counted
+	Traffic *Traffic
 }
@@ -0,0 +1,167 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+	"golang.org/x/net/websocket"
+)
+
+const wsHandshakeTimeout = 10 * time.Second
+
+// WebSocketTransport lets peers that cannot speak QUIC, such as browsers,
+// join the network. Every binary message carries frames of muxSession, the
+// first stream runs the same nonce-signature handshake as QUIC and the
+// streams after it carry the frames of writeFrame. There is no Noise layer,
+// so public listeners belong behind a TLS proxy and peers dial wss:// URLs.
+type WebSocketTransport struct {
+	*baseTransport
+
+	ln     net.Listener
+	server *http.Server
+
+	// mu orders new handshakes against StopAccepting
+	mu           sync.Mutex
+	acceptCtx    context.Context
+	acceptCancel context.CancelFunc
+}
+
+var _ Transport = (*WebSocketTransport)(nil)
+
+// NewWebSocketTransport binds the HTTP listener at addr, WebSocket upgrades
+// are accepted on every path. An empty addr creates a transport that only dials.
+func NewWebSocketTransport(addr string, privKey types.PeerPrivateKey, protocol Protocol, log *slog.Logger) (*WebSocketTransport, error) {
+	t := &WebSocketTransport{
+		baseTransport: newBaseTransport(privKey, protocol, log),
+	}
+	if addr == "" {
+		return t, nil
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	t.ln = ln
+	t.server = &http.Server{
+		// any origin is fine, peers are authenticated by their key
+		Handler:           websocket.Server{Handler: t.serve},
+		ReadHeaderTimeout: wsHandshakeTimeout,
+	}
+	return t, nil
+}
+
+// Addr is the ws:// URL other peers dial, nil if the transport only dials
+func (t *WebSocketTransport) Addr() net.Addr {
+	if t.ln == nil {
+		return nil
+	}
+	return wsAddr{t.ln.Addr()}
+}
+
+type wsAddr struct {
+	tcp net.Addr
+}
+
+func (a wsAddr) Network() string { return "ws" }
+func (a wsAddr) String() string  { return "ws://" + a.tcp.String() }
+
+func (t *WebSocketTransport) Listen(ctx context.Context) error {
+	if t.ln == nil {
+		return nil
+	}
+	t.mu.Lock()
+	t.acceptCtx, t.acceptCancel = context.WithCancel(ctx)
+	t.mu.Unlock()
+
+	t.acceptWg.Add(1)
+	go func() {
+		defer t.acceptWg.Done()
+		if err := t.server.Serve(t.ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			t.log.Error("WebSocket server failed", "err", err)
+		}
+	}()
+	return nil
+}
+
+// StopAccepting closes the listener and waits for in-flight inbound handshakes
+// to finish, upgraded connections are owned by the swarm
+func (t *WebSocketTransport) StopAccepting() {
+	t.mu.Lock()
+	if t.acceptCancel != nil {
+		t.acceptCancel()
+	}
+	t.mu.Unlock()
+	if t.server != nil {
+		t.server.Close()
+	}
+	t.acceptWg.Wait()
+}
+
+func (t *WebSocketTransport) Close() error {
+	t.StopAccepting()
+	t.closeConnChan()
+	return nil
+}
+
+// serve runs for the lifetime of an upgraded connection
+func (t *WebSocketTransport) serve(ws *websocket.Conn) {
+	ws.PayloadType = websocket.BinaryFrame
+
+	// RemoteAddr of a server side websocket.Conn is the Origin header
+	var remote net.Addr = ws.RemoteAddr()
+	if addr, err := net.ResolveTCPAddr("tcp", ws.Request().RemoteAddr); err == nil {
+		remote = addr
+	}
+
+	t.mu.Lock()
+	if t.acceptCtx.Err() != nil {
+		t.mu.Unlock()
+		ws.Close()
+		return
+	}
+	t.acceptWg.Add(1)
+	ctx, cancel := context.WithTimeout(t.acceptCtx, wsHandshakeTimeout)
+	t.mu.Unlock()
+	defer cancel()
+
+	session := newMuxSession(ws, remote, false)
+	t.acceptInbound(ctx, session, nil)
+	t.acceptWg.Done()
+	<-session.Context().Done()
+}
+
+func (t *WebSocketTransport) Dial(ctx context.Context, addr string) (types.PeerID, error) {
+	peerID, err := t.dial(ctx, addr)
+	t.countDial(err)
+	return peerID, err
+}
+
+// dial accepts ws:// and wss:// URLs only
+func (t *WebSocketTransport) dial(ctx context.Context, addr string) (types.PeerID, error) {
+	u, err := url.Parse(addr)
+	if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") {
+		return types.PeerID{}, ErrUnsupportedAddr
+	}
+
+	origin := "http" + strings.TrimPrefix(u.Scheme, "ws") + "://" + u.Host
+	cfg, err := websocket.NewConfig(addr, origin)
+	if err != nil {
+		return types.PeerID{}, err
+	}
+	ws, err := cfg.DialContext(ctx)
+	if err != nil {
+		return types.PeerID{}, err
+	}
+	ws.PayloadType = websocket.BinaryFrame
+
+	return t.handshakeOutbound(ctx, newMuxSession(ws, ws.RemoteAddr(), true), nil)
+}
@@ -0,0 +1,248 @@
+package p2p
+
+import (
+	"encoding/hex"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+)
+
+const (
+	// connMgrInterval is how often the connections are checked against the
+	// high water mark, besides right after a new one is registered
+	connMgrInterval = 10 * time.Second
+	// minTrimInterval keeps a burst of new connections from trimming over
+	// and over, new peers are likely still in their grace period anyway
+	minTrimInterval = 5 * time.Second
+
+	// relayTag marks peers that relay circuits, they are worth more than
+	// other peers to a node that may need a reservation
+	relayTag       = "relay"
+	relayTagWeight = 10
+)
+
+// ConnManager keeps the number of connections between two watermarks. Once
+// there are more than high it closes the connections of least value until
+// low are left, below low it dials peers from the history. Connections younger than the grace period, protected peers,
+// peers with a transfer in progress, the relays we hold a reservation on and
+// the ends of circuits we relay are never trimmed. The value of a peer is the sum of the weights services
+// tagged it with.
+type ConnManager struct {
+	swarm *Swarm
+	log   *slog.Logger
+
+	mu        sync.Mutex
+	low, high int
+	grace     time.Duration
+	// tags hold the weights of connected peers, protected is kept across
+	// reconnects until the service unprotects the peer
+	tags      map[types.PeerID]map[string]int
+	protected map[types.PeerID]map[string]struct{}
+	lastTrim  time.Time
+
+	wake    chan struct{}
+	trimmed atomic.Uint64
+}
+
+func newConnManager(swarm *Swarm, low, high int, grace time.Duration, log *slog.Logger) *ConnManager {
+	return &ConnManager{
+		swarm:     swarm,
+		log:       log,
+		low:       low,
+		high:      high,
+		grace:     grace,
+		tags:      make(map[types.PeerID]map[string]int),
+		protected: make(map[types.PeerID]map[string]struct{}),
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// ConnManager decides which connections are closed when there are too many
+func (s *Swarm) ConnManager() *ConnManager {
+	return s.connMgr
+}
+
+// SetLimits changes the watermarks and the grace period at runtime
+func (cm *ConnManager) SetLimits(low, high int, grace time.Duration) {
+	cm.mu.Lock()
+	cm.low, cm.high, cm.grace = low, high, grace
+	cm.mu.Unlock()
+	cm.notify()
+}
+
+func (cm *ConnManager) Limits() (low, high int) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cm.low, cm.high
+}
+
+// TagPeer sets the weight of tag on a connected peer, the tags of a peer
+// are dropped when it disconnects
+func (cm *ConnManager) TagPeer(peerID types.PeerID, tag string, weight int) {
+	if !cm.swarm.ThisIsActivePeer(peerID) {
+		return
+	}
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if cm.tags[peerID] == nil {
+		cm.tags[peerID] = make(map[string]int)
+	}
+	cm.tags[peerID][tag] = weight
+}
+
+func (cm *ConnManager) UntagPeer(peerID types.PeerID, tag string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	delete(cm.tags[peerID], tag)
+	if len(cm.tags[peerID]) == 0 {
+		delete(cm.tags, peerID)
+	}
+}
+
+// Value is the sum of the tag weights of the peer
+func (cm *ConnManager) Value(peerID types.PeerID) int {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cm.value(peerID)
+}
+
+func (cm *ConnManager) value(peerID types.PeerID) int {
+	value := 0
+	for _, weight := range cm.tags[peerID] {
+		value += weight
+	}
+	return value
+}
+
+// Protect keeps the connection to the peer from being trimmed until every
+// service that protected it under its tag unprotects it
+func (cm *ConnManager) Protect(peerID types.PeerID, tag string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if cm.protected[peerID] == nil {
+		cm.protected[peerID] = make(map[string]struct{})
+	}
+	cm.protected[peerID][tag] = struct{}{}
+}
+
+// Unprotect removes the protection of tag and reports whether the peer is
+// still protected by another one
+func (cm *ConnManager) Unprotect(peerID types.PeerID, tag string) bool {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	delete(cm.protected[peerID], tag)
+	if len(cm.protected[peerID]) == 0 {
+		delete(cm.protected, peerID)
+		return false
+	}
+	return true
+}
+
+// IsProtected reports whether the peer is protected by a service, an active
+// transfer, a reservation we hold on it or a circuit we relay for it
+func (cm *ConnManager) IsProtected(peerID types.PeerID) bool {
+	cm.mu.Lock()
+	_, ok := cm.protected[peerID]
+	cm.mu.Unlock()
+	return ok || cm.swarm.sessionManager.HasPeer(peerID) ||
+		cm.swarm.relayClient.holds(peerID) || cm.swarm.relay.hasCircuits(peerID)
+}
+
+// Trimmed returns how many connections were trimmed so far
+func (cm *ConnManager) Trimmed() uint64 {
+	return cm.trimmed.Load()
+}
+
+// TrimOpenConns closes the connections of least value until low are left if
+// there are more than high, it returns how many it closed
+func (cm *ConnManager) TrimOpenConns() int {
+	peers := cm.swarm.GetAllPeers()
+	cm.mu.Lock()
+	low, high, grace := cm.low, cm.high, cm.grace
+	cm.lastTrim = time.Now()
+	cm.mu.Unlock()
+	if len(peers) <= high {
+		return 0
+	}
+
+	type candidate struct {
+		peer  *Peer
+		value int
+	}
+	now := time.Now()
+	candidates := make([]candidate, 0, len(peers))
+	for _, p := range peers {
+		if now.Sub(p.ConnectedAt()) < grace || cm.IsProtected(p.ID()) {
+			continue
+		}
+		candidates = append(candidates, candidate{peer: p, value: cm.Value(p.ID())})
+	}
+	// the newest of equal value go first, older connections proved stable
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		if a.value != b.value {
+			return a.value - b.value
+		}
+		return b.peer.ConnectedAt().Compare(a.peer.ConnectedAt())
+	})
+
+	excess := min(len(peers)-low, len(candidates))
+	if excess == 0 {
+		cm.log.Debug("Above high water but every connection is protected", "peers", len(peers), "high_water", high)
+		return 0
+	}
+	for _, c := range candidates[:excess] {
+		id := c.peer.ID()
+		cm.log.Debug("Trimming connection", "peer_id", hex.EncodeToString(id[:]), "value", c.value)
+		cm.swarm.closePeer(id, "trimmed")
+	}
+	cm.trimmed.Add(uint64(excess))
+	cm.log.Info("Trimmed connections", "closed", excess, "peers", len(peers), "high_water", high, "low_water", low)
+	return excess
+}
+
+// notify asks the loop to check the connection count
+func (cm *ConnManager) notify() {
+	select {
+	case cm.wake <- struct{}{}:
+	default:
+	}
+}
+
+// forget drops the tags of a peer that disconnected
+func (cm *ConnManager) forget(peerID types.PeerID) {
+	cm.mu.Lock()
+	delete(cm.tags, peerID)
+	cm.mu.Unlock()
+}
+
+func (cm *ConnManager) loop() {
+	defer cm.swarm.wg.Done()
+	ticker := time.NewTicker(connMgrInterval)
+	defer ticker.Stop()
+	for {
+		// dials only start on the ticker, a burst of disconnects does not
+		// start a burst of dials
+		tick := false
+		select {
+		case <-cm.wake:
+		case <-ticker.C:
+			tick = true
+		case <-cm.swarm.closing:
+			return
+		}
+
+		cm.mu.Lock()
+		low, high, recent := cm.low, cm.high, time.Since(cm.lastTrim) < minTrimInterval
+		cm.mu.Unlock()
+		switch count := cm.swarm.PeerCount(); {
+		case !recent && count > high:
+			cm.TrimOpenConns()
+		case tick && count < low:
+			cm.swarm.findAndConnectToPeers()
+		}
+	}
+}
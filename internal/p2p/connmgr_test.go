@@ -0,0 +1,136 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/network"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/events"
+)
+
+// connectSpokes connects n new swarms to hub one after another, so each is
+// connected later than the one before
+func connectSpokes(t *testing.T, mem *network.MemoryNetwork, hub *Swarm, n int) []*Swarm {
+	t.Helper()
+	spokes := make([]*Swarm, n)
+	for i := range spokes {
+		spokes[i] = newTestSwarm(t, mem, testProtocol)
+		connectSwarms(t, spokes[i], hub)
+		time.Sleep(time.Millisecond)
+	}
+	return spokes
+}
+
+func TestConnManagerTrimsLeastValuable(t *testing.T) {
+	mem := network.NewMemoryNetwork()
+	hub := newTestSwarm(t, mem, testProtocol)
+	spokes := connectSpokes(t, mem, hub, 6)
+	sub := hub.events.Subscribe(0, events.DropNewest, events.OfType(events.TypePeerDisconnected))
+	defer sub.Close()
+
+	cm := hub.ConnManager()
+	cm.Protect(spokes[0].selfID, "test")
+	// the newest peer is worth more than the older untagged ones
+	cm.TagPeer(spokes[5].selfID, "test", 10)
+
+	// lowering the watermarks wakes the loop, which trims down to low
+	cm.SetLimits(3, 4, 0)
+	waitUntil(t, "trim to low water", func() bool { return hub.PeerCount() == 3 })
+
+	// of the untagged peers the newest go first
+	for i, want := range []bool{true, true, false, false, false, true} {
+		if got := hub.ThisIsActivePeer(spokes[i].selfID); got != want {
+			t.Errorf("spoke %d connected = %v, want %v", i, got, want)
+		}
+	}
+	if got := cm.Trimmed(); got != 3 {
+		t.Errorf("Trimmed = %d, want 3", got)
+	}
+
+	trimmed := make(map[types.PeerID]bool)
+	for len(trimmed) < 3 {
+		select {
+		case e := <-sub.C:
+			d := e.(events.PeerDisconnected)
+			if d.Reason != "trimmed" {
+				t.Fatalf("peer disconnected with reason %q", d.Reason)
+			}
+			trimmed[d.PeerID] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d disconnect events, want 3", len(trimmed))
+		}
+	}
+	for _, s := range spokes[2:5] {
+		if !trimmed[s.selfID] {
+			t.Errorf("no disconnect event for %x", s.selfID[:4])
+		}
+	}
+}
+
+func TestConnManagerGracePeriod(t *testing.T) {
+	mem := network.NewMemoryNetwork()
+	hub := newTestSwarm(t, mem, testProtocol)
+	cm := hub.ConnManager()
+	cm.SetLimits(1, 2, time.Hour)
+	connectSpokes(t, mem, hub, 3)
+
+	if n := cm.TrimOpenConns(); n != 0 {
+		t.Fatalf("trimmed %d connections within the grace period", n)
+	}
+	if got := hub.PeerCount(); got != 3 {
+		t.Fatalf("PeerCount = %d, want 3", got)
+	}
+
+	// the loop skips a trim this soon after the last one, trim directly
+	cm.SetLimits(1, 2, 0)
+	if n := cm.TrimOpenConns(); n != 2 {
+		t.Fatalf("trimmed %d connections after the grace period, want 2", n)
+	}
+	waitUntil(t, "trim after the grace period", func() bool { return hub.PeerCount() == 1 })
+}
+
+func TestConnManagerKeepsRelays(t *testing.T) {
+	mem := network.NewMemoryNetwork()
+	hub := newTestSwarm(t, mem, testProtocol)
+	connectSpokes(t, mem, hub, 2)
+	// the newest peer goes first unless it is tagged as a relay
+	relay := newTestSwarm(t, mem, relayProtocol)
+	connectSwarms(t, relay, hub)
+
+	hub.ConnManager().SetLimits(2, 2, 0)
+	if n := hub.ConnManager().TrimOpenConns(); n != 1 {
+		t.Fatalf("trimmed %d connections, want 1", n)
+	}
+	waitUntil(t, "trim to low water", func() bool { return hub.PeerCount() == 2 })
+	if !hub.ThisIsActivePeer(relay.selfID) {
+		t.Fatal("relay was trimmed")
+	}
+}
+
+func TestConnManagerDialsKnownPeersBelowLowWater(t *testing.T) {
+	mem := network.NewMemoryNetwork()
+	hub := newTestSwarm(t, mem, testProtocol)
+	spokes := connectSpokes(t, mem, hub, 4)
+	waitUntil(t, "the peer history", func() bool { return len(hub.GetHistoryConnected(0)) == len(spokes) })
+
+	banned := spokes[0]
+	hub.BanPeer(banned.selfID)
+	for _, s := range spokes {
+		hub.RemovePeer(s.selfID)
+	}
+	if got := hub.PeerCount(); got != 0 {
+		t.Fatalf("PeerCount = %d after removing every spoke", got)
+	}
+
+	hub.ConnManager().SetLimits(2, 4, 0)
+	hub.findAndConnectToPeers()
+	waitUntil(t, "dials up to low water", func() bool { return hub.PeerCount() == 2 })
+	time.Sleep(100 * time.Millisecond)
+	if got := hub.PeerCount(); got != 2 {
+		t.Fatalf("PeerCount = %d, want low water 2", got)
+	}
+	if hub.ThisIsActivePeer(banned.selfID) {
+		t.Fatal("dialed a banned peer")
+	}
+}
@@ -48,11 +48,6 @@ func (d *DHT) SavePeer(peerID [32]byte, PubKey ed25519.PublicKey, addr string, l
 }
 
 func (d *DHT) SaveFile(file []byte) (metaLinkKey []byte, err error) {
-	hash := sha256.Sum256(file)
-	if d.merkle.hashExists(hash[:]) {
-		return nil, nil
-	}
-
 	metaLinkKey, err = d.merkle.calculateFileChunk(file)
 	if err != nil {
 		return nil, err
@@ -64,6 +59,11 @@ func (d *DHT) GetFileChunk(chunkHash []byte) ([]byte, error) {
 	return d.merkle.findFileByID(chunkHash)
 }
 
+// StoreSize returns the number of Merkle DAG nodes stored locally and their total size in bytes
+func (d *DHT) StoreSize() (nodes int, size int64, err error) {
+	return d.storage.PrefixSize([]byte(dagStorageKey))
+}
+
 func (d *DHT) CalculateDistance(peerID [32]byte) int {
 	return getBucketIndex(d.myID, peerID)
 }
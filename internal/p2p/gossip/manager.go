@@ -1,7 +1,10 @@
 package gossip
 
 import (
+	"context"
+	"errors"
 	"sync"
+	"sync/atomic"
 
 	"github.com/DmytroBuzhylov/echofog-core/internal/network"
 	"github.com/DmytroBuzhylov/echofog-core/internal/p2p"
@@ -9,62 +12,174 @@ import (
 	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
 )
 
+var ErrNoPeers = errors.New("no connected peers")
+
 type Manager struct {
 	swarm *p2p.Swarm
 
 	mu        sync.RWMutex
 	seenCache map[types.MessageID]bool
+
+	forward bool
+
+	received   atomic.Uint64
+	duplicates atomic.Uint64
 }
 
-func NewManager(swarm *p2p.Swarm) *Manager {
+// NewManager returns a gossip manager, with forward set received messages
+// are passed on to the other peers
+func NewManager(swarm *p2p.Swarm, forward bool) *Manager {
 	return &Manager{
 		swarm:     swarm,
 		seenCache: make(map[types.MessageID]bool),
+		forward:   forward,
 	}
 }
 
 func (g *Manager) Broadcast(msgType network.MessageType, msgData *internal_pb.MessageData) {
+	if !g.markSeen(msgData) {
+		return
+	}
+	g.send(msgType, msgData, types.PeerID{})
+}
+
+// Publish sends a message that originates here to every connected peer and
+// waits until it was written to their connections. It returns how many
+// peers it reached, an error only if it reached none.
+func (g *Manager) Publish(ctx context.Context, msgType network.MessageType, msgData *internal_pb.MessageData) (int, error) {
+	if !g.markSeen(msgData) {
+		return 0, errors.New("message already sent")
+	}
+	peers := g.targets(msgData, types.PeerID{})
+	if len(peers) == 0 {
+		return 0, ErrNoPeers
+	}
+
+	errs := make(chan error, len(peers))
+	for _, peer := range peers {
+		go func() {
+			if err := g.swarm.SendDataForPeer(peer.ID(), msgType, msgData); err != nil {
+				errs <- err
+				return
+			}
+			errs <- peer.Flush(ctx)
+		}()
+	}
+
+	var (
+		reached int
+		lastErr error
+	)
+	for range peers {
+		if err := <-errs; err != nil {
+			lastErr = err
+			continue
+		}
+		reached++
+	}
+	if reached == 0 {
+		return 0, lastErr
+	}
+	return reached, nil
+}
+
+// HandleIncoming records a message received from a peer and reports whether
+// it is new. New messages are forwarded when forwarding is enabled, unless
+// they are addressed to this node.
+func (g *Manager) HandleIncoming(msgType network.MessageType, msgData *internal_pb.MessageData, from types.PeerID, toSelf bool) bool {
+	g.received.Add(1)
+	if !g.markSeen(msgData) {
+		g.duplicates.Add(1)
+		return false
+	}
+
+	if g.forward && !toSelf {
+		g.send(msgType, msgData, from)
+	}
+	return true
+}
+
+func (g *Manager) markSeen(msgData *internal_pb.MessageData) bool {
 	mesID, err := types.ParseMessageID(msgData.GetMessageId())
 	if err != nil {
-		return
+		return false
 	}
+
 	g.mu.Lock()
+	defer g.mu.Unlock()
 	if g.seenCache[mesID] {
-		g.mu.Unlock()
-		return
+		return false
 	}
 	g.seenCache[mesID] = true
-	g.mu.Unlock()
+	return true
+}
+
+// send passes the message to every peer except its origin and the peer it came from
+func (g *Manager) send(msgType network.MessageType, msgData *internal_pb.MessageData, from types.PeerID) {
+	for _, peer := range g.targets(msgData, from) {
+		go g.swarm.SendDataForPeer(peer.ID(), msgType, msgData)
+	}
+}
 
+// targets uses up a hop of the message and returns the peers it goes to
+func (g *Manager) targets(msgData *internal_pb.MessageData, from types.PeerID) []*p2p.Peer {
 	msgData.HopLimit--
 	if msgData.HopLimit <= 0 {
-		return
+		return nil
 	}
 
-	peers := g.swarm.GetAllPeers()
-
-	for _, peer := range peers {
+	var originID types.PeerID
+	if origin, err := types.ToPeerPublicKey(msgData.GetOriginId()); err == nil {
+		originID = types.PeerPubKeyToID(origin)
+	}
 
-		if peer.ID() == types.PeerID(msgData.OriginId) {
+	var peers []*p2p.Peer
+	for _, peer := range g.swarm.GetAllPeers() {
+		if peer.ID() == originID || peer.ID() == from {
 			continue
 		}
+		peers = append(peers, peer)
+	}
+	return peers
+}
 
-		go g.swarm.SendDataForPeer(peer.ID(), msgType, msgData)
+// Gossiped reports whether the payload travels by gossip. Only those carry a
+// MessageId that is deduplicated, other payloads are exchanged directly.
+func Gossiped(msgData *internal_pb.MessageData) bool {
+	switch msgData.GetPayload().(type) {
+	case *internal_pb.MessageData_ChatMessage, *internal_pb.MessageData_PeerReq, *internal_pb.MessageData_PeerRes,
+		*internal_pb.MessageData_ContentAnnouncement:
+		return true
+	default:
+		return false
 	}
 }
 
-func (g *Manager) HandleIncoming(msgType network.MessageType, msgData *internal_pb.MessageData) {
-	mesID, err := types.ParseMessageID(msgData.GetMessageId())
-	if err != nil {
-		return
+// TypeOf returns the frame type a gossiped payload is sent with
+func TypeOf(msgData *internal_pb.MessageData) network.MessageType {
+	switch msgData.GetPayload().(type) {
+	case *internal_pb.MessageData_ChatMessage:
+		return network.TypeChatMessage
+	case *internal_pb.MessageData_PeerReq:
+		return network.TypeGetPeerRequest
+	case *internal_pb.MessageData_PeerRes:
+		return network.TypeGetPeerResponse
+	default:
+		return network.TypeGossip
 	}
+}
+
+func (g *Manager) SeenCacheSize() int {
 	g.mu.RLock()
-	seen := g.seenCache[mesID]
-	g.mu.RUnlock()
+	defer g.mu.RUnlock()
+	return len(g.seenCache)
+}
 
-	if seen {
-		return
-	}
+// Received counts incoming gossip messages, Duplicates the ones already seen
+func (g *Manager) Received() uint64 {
+	return g.received.Load()
+}
 
-	g.Broadcast(msgType, msgData)
+func (g *Manager) Duplicates() uint64 {
+	return g.duplicates.Load()
 }
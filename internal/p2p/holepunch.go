@@ -0,0 +1,365 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/network"
+	internal_pb "github.com/DmytroBuzhylov/echofog-core/internal/proto"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/events"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Hole punching lets two peers behind NATs connect directly. The initiator
+// asks a peer connected to both, the relay, to introduce it to the target.
+// The relay tells the target the address it observes for the initiator and
+// a start delay, then answers the initiator with the address of the target
+// and the delay left. At the start both sides dial each other from their
+// listening sockets, the target also sends packets to the initiator, so
+// both NATs have seen outgoing traffic to the other side. The first
+// connection either side gets ends its punch.
+const (
+	HolePunchConnectProtocol = "holepunch/connect/1"
+	HolePunchSyncProtocol    = "holepunch/sync/1"
+
+	// punchDelay covers the round trips of the introduction
+	punchDelay       = 500 * time.Millisecond
+	maxPunchDelay    = 5 * time.Second
+	punchAttempts    = 2
+	punchDialTimeout = 3 * time.Second
+	// holePunchTimeout bounds a punch started by the swarm itself
+	holePunchTimeout = 20 * time.Second
+
+	// maxPunchRelays is how many connected peers are asked to introduce us
+	maxPunchRelays = 3
+	// maxPunchAddrs bounds the addresses a peer may make us punch towards
+	maxPunchAddrs = 4
+	// maxPunchesServed bounds the punches we take part in as the target
+	maxPunchesServed = 8
+	// simultaneousConnWindow is how long after a direct connection a second
+	// one to the same peer counts as the other half of a simultaneous dial
+	simultaneousConnWindow = punchDialTimeout
+)
+
+var ErrHolePunchUnsupported = errors.New("transport cannot punch holes")
+
+type holePunch struct {
+	swarm *Swarm
+	log   *slog.Logger
+
+	slots     chan struct{}
+	succeeded atomic.Uint64
+	failed    atomic.Uint64
+}
+
+func newHolePunch(swarm *Swarm, log *slog.Logger) *holePunch {
+	h := &holePunch{
+		swarm: swarm,
+		log:   log,
+		slots: make(chan struct{}, maxPunchesServed),
+	}
+	swarm.rpc.Handle(HolePunchConnectProtocol, &internal_pb.HolePunchConnect{}, h.handleConnect)
+	swarm.rpc.Handle(HolePunchSyncProtocol, &internal_pb.HolePunchSync{}, h.handleSync)
+	return h
+}
+
+// HolePunch connects to peerID through the NATs of both sides with the help
+// of a mutual peer. The direct connection replaces any existing one.
+func (s *Swarm) HolePunch(ctx context.Context, peerID types.PeerID) error {
+	start := time.Now()
+	via, addr, err := s.holePunch.run(ctx, peerID)
+
+	ev := events.HolePunch{
+		PeerID:   peerID,
+		Via:      via,
+		Addr:     addr,
+		Success:  err == nil,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		ev.Error = err.Error()
+		s.holePunch.failed.Add(1)
+		s.log.Debug("Hole punch failed", "peer_id", hex.EncodeToString(peerID[:]), "err", err)
+	} else {
+		s.holePunch.succeeded.Add(1)
+		s.log.Info("Hole punch succeeded", "peer_id", hex.EncodeToString(peerID[:]), "addr", addr, "via", hex.EncodeToString(via[:]))
+	}
+	s.events.Publish(ev)
+	return err
+}
+
+// HolePunchStats returns the hole punches this node started so far
+func (s *Swarm) HolePunchStats() (succeeded, failed uint64) {
+	return s.holePunch.succeeded.Load(), s.holePunch.failed.Load()
+}
+
+// run asks up to maxPunchRelays connected peers for an introduction until
+// a dial gets through
+func (h *holePunch) run(ctx context.Context, target types.PeerID) (types.PeerID, string, error) {
+	puncher, ok := network.AsHolePuncher(h.swarm.netTransport)
+	if !ok {
+		return types.PeerID{}, "", ErrHolePunchUnsupported
+	}
+
+	var (
+		errs  []error
+		tried int
+	)
+	for _, relay := range h.swarm.GetAllPeers() {
+		if tried >= maxPunchRelays || ctx.Err() != nil {
+			break
+		}
+		if relay.ID() == target {
+			continue
+		}
+		tried++
+
+		relayID := relay.ID()
+		var plan internal_pb.HolePunchPlan
+		req := &internal_pb.HolePunchConnect{TargetId: target[:], Addrs: punchAddrs(puncher.Addr())}
+		if err := h.swarm.rpc.Call(ctx, relayID, HolePunchConnectProtocol, req, &plan); err != nil {
+			errs = append(errs, fmt.Errorf("via %s: %w", hex.EncodeToString(relayID[:4]), err))
+			continue
+		}
+
+		addr, err := h.dial(ctx, puncher, target, &plan)
+		if err == nil {
+			return relayID, addr, nil
+		}
+		errs = append(errs, fmt.Errorf("via %s: %w", hex.EncodeToString(relayID[:4]), err))
+	}
+
+	if tried == 0 {
+		return types.PeerID{}, "", errors.New("no peer to introduce us")
+	}
+	return types.PeerID{}, "", errors.Join(errs...)
+}
+
+func (h *holePunch) dial(ctx context.Context, puncher network.HolePuncher, target types.PeerID, plan *internal_pb.HolePunchPlan) (string, error) {
+	if len(plan.Addrs) == 0 {
+		return "", errors.New("no address of the target")
+	}
+	if err := sleepCtx(ctx, punchWait(plan.DelayMs)); err != nil {
+		return "", err
+	}
+	return h.race(ctx, puncher, target, plan.Addrs[:min(len(plan.Addrs), maxPunchAddrs)])
+}
+
+// race dials all addrs at once, up to punchAttempts times, while watching
+// for the direct connection the other side dials to us, and returns the
+// address of whichever connects first. Dials still running are not
+// canceled, the other side may have accepted them already, registration
+// keeps one of two connections that both get through.
+func (h *holePunch) race(ctx context.Context, puncher network.HolePuncher, target types.PeerID, addrs []string) (string, error) {
+	since := time.Now()
+	dialed := make(chan string, 1)
+	failed := make(chan error, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		var lastErr error
+		for range punchAttempts {
+			errs := make(chan error, len(addrs))
+			for _, addr := range addrs {
+				go func() {
+					dialCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), punchDialTimeout)
+					defer cancel()
+					peerID, err := puncher.Dial(dialCtx, addr)
+					if err == nil && peerID != target {
+						err = fmt.Errorf("%s answered as another peer", addr)
+					}
+					if err == nil {
+						select {
+						case dialed <- addr:
+						default:
+						}
+					}
+					errs <- err
+				}()
+			}
+
+			connected := false
+			for range addrs {
+				if err := <-errs; err != nil {
+					lastErr = err
+				} else {
+					connected = true
+				}
+			}
+			if connected {
+				return
+			}
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+		failed <- lastErr
+	}()
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case addr := <-dialed:
+			return addr, nil
+		case err := <-failed:
+			return "", err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-ticker.C:
+			if p := h.swarm.GetPeer(target); p != nil && !p.Relayed() && !p.ConnectedAt().Before(since) {
+				return p.Addr(), nil
+			}
+		}
+	}
+}
+
+// keepSimultaneous decides between the current direct connection to a peer
+// and a new one from the opposite direction that arrives right after it,
+// as both sides of a hole punch dial. Both sides keep the connection
+// dialed by the lower peer id so they never close the one the other kept.
+func (s *Swarm) keepSimultaneous(current *Peer, event network.NewConnEvent) bool {
+	if current.Relayed() || network.IsRelayAddr(event.Addr) || current.IsOutbound() == event.IsOut {
+		return false
+	}
+	if time.Since(current.ConnectedAt()) > simultaneousConnWindow {
+		return false
+	}
+	currentDialer, newDialer := event.PeerID, s.selfID
+	if current.IsOutbound() {
+		currentDialer, newDialer = s.selfID, event.PeerID
+	}
+	return bytes.Compare(currentDialer[:], newDialer[:]) < 0
+}
+
+// handleConnect runs on the relay
+func (h *holePunch) handleConnect(ctx context.Context, from types.PeerID, req proto.Message) (proto.Message, error) {
+	r := req.(*internal_pb.HolePunchConnect)
+	if len(r.TargetId) != len(types.PeerID{}) {
+		return nil, &RemoteError{Code: RPCErrInvalidRequest, Message: "bad target id"}
+	}
+	targetID := types.PeerID(r.TargetId)
+
+	caller, target := h.swarm.GetPeer(from), h.swarm.GetPeer(targetID)
+	if caller == nil || target == nil {
+		return nil, &RemoteError{Code: RPCErrUnavailable, Message: "target not connected"}
+	}
+	callerAddrs := observedUDPAddrs(caller, r.Addrs)
+	targetAddrs := observedUDPAddrs(target, nil)
+	if len(callerAddrs) == 0 || len(targetAddrs) == 0 {
+		return nil, &RemoteError{Code: RPCErrUnavailable, Message: "no UDP address observed"}
+	}
+
+	start := time.Now().Add(punchDelay)
+	syncReq := &internal_pb.HolePunchSync{PeerId: from[:], Addrs: callerAddrs, DelayMs: millisUntil(start)}
+	if err := h.swarm.rpc.Call(ctx, targetID, HolePunchSyncProtocol, syncReq, &internal_pb.HolePunchSyncResponse{}); err != nil {
+		return nil, fmt.Errorf("introduce target: %w", err)
+	}
+	return &internal_pb.HolePunchPlan{Addrs: targetAddrs, DelayMs: millisUntil(start)}, nil
+}
+
+// handleSync runs on the target, it punches and dials the initiator in the
+// background so the relay can answer the initiator before the start
+func (h *holePunch) handleSync(ctx context.Context, from types.PeerID, req proto.Message) (proto.Message, error) {
+	r := req.(*internal_pb.HolePunchSync)
+	if len(r.PeerId) != len(types.PeerID{}) || len(r.Addrs) == 0 {
+		return nil, &RemoteError{Code: RPCErrInvalidRequest, Message: "bad hole punch sync"}
+	}
+	peerID := types.PeerID(r.PeerId)
+	if h.swarm.CheckOnBan(peerID) {
+		return nil, &RemoteError{Code: RPCErrInvalidRequest, Message: "peer is banned"}
+	}
+	puncher, ok := network.AsHolePuncher(h.swarm.netTransport)
+	if !ok {
+		return nil, &RemoteError{Code: RPCErrUnavailable, Message: ErrHolePunchUnsupported.Error()}
+	}
+
+	select {
+	case h.slots <- struct{}{}:
+	default:
+		return nil, &RemoteError{Code: RPCErrResourceExhausted, Message: "too many hole punches"}
+	}
+
+	addrs := r.Addrs[:min(len(r.Addrs), maxPunchAddrs)]
+	wait := punchWait(r.DelayMs)
+	go func() {
+		defer func() { <-h.slots }()
+		ctx, cancel := context.WithTimeout(context.Background(), wait+punchAttempts*punchDialTimeout)
+		defer cancel()
+		if sleepCtx(ctx, wait) != nil {
+			return
+		}
+		for _, addr := range addrs {
+			go func() {
+				if err := puncher.Punch(ctx, addr); err != nil {
+					h.log.Debug("Hole punch packets failed", "addr", addr, "err", err)
+				}
+			}()
+		}
+		if addr, err := h.race(ctx, puncher, peerID, addrs); err != nil {
+			h.log.Debug("Hole punch dial failed", "peer_id", hex.EncodeToString(peerID[:]), "err", err)
+		} else {
+			h.log.Debug("Hole punch connected", "peer_id", hex.EncodeToString(peerID[:]), "addr", addr)
+		}
+	}()
+	return &internal_pb.HolePunchSyncResponse{}, nil
+}
+
+// observedUDPAddrs is the address p connects to us from, if it is UDP, and
+// the first of extra after it
+func observedUDPAddrs(p *Peer, extra []string) []string {
+	var addrs []string
+	if addr, ok := p.transport.RemoteAddr().(*net.UDPAddr); ok {
+		addrs = append(addrs, addr.String())
+	}
+	for _, addr := range extra {
+		if len(addrs) >= maxPunchAddrs {
+			break
+		}
+		if addr != "" && (len(addrs) == 0 || addr != addrs[0]) {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// punchAddrs is the listen address, unless it is a wildcard the relay
+// would learn nothing from
+func punchAddrs(listen net.Addr) []string {
+	addr, ok := listen.(*net.UDPAddr)
+	if !ok || addr.IP == nil || addr.IP.IsUnspecified() {
+		return nil
+	}
+	return []string{addr.String()}
+}
+
+func punchWait(delayMs uint32) time.Duration {
+	return min(time.Duration(delayMs)*time.Millisecond, maxPunchDelay)
+}
+
+func millisUntil(t time.Time) uint32 {
+	return uint32(max(time.Until(t).Milliseconds(), 0))
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
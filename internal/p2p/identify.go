@@ -0,0 +1,166 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/config"
+	"github.com/DmytroBuzhylov/echofog-core/internal/crypto"
+	internal_pb "github.com/DmytroBuzhylov/echofog-core/internal/proto"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// IdentifyProtocol exchanges signed Identify records once a connection is
+// registered. The dialer calls it with its own record and gets the callee's
+// back, both keep the other's in the peer store and report the address they
+// observe each other at.
+const IdentifyProtocol = "identify/1"
+
+const (
+	identifyTimeout = 10 * time.Second
+	// identifySigPrefix keeps identify signatures apart from other uses of the key
+	identifySigPrefix = "echofog identify:"
+
+	maxIdentifyAddrs     = 16
+	maxIdentifyProtocols = 64
+	// maxIdentifyString fits relay addresses
+	maxIdentifyString = 256
+)
+
+// Agent names this implementation in identify records, programs embedding
+// the node may set their own before it starts
+var Agent = fmt.Sprintf("echofog-core/%d", config.CurrentProtocolVersion)
+
+type identify struct {
+	swarm *Swarm
+	log   *slog.Logger
+}
+
+func newIdentify(swarm *Swarm, log *slog.Logger) *identify {
+	i := &identify{swarm: swarm, log: log}
+	swarm.rpc.Handle(IdentifyProtocol, &internal_pb.SignedIdentify{}, i.handleIdentify)
+	return i
+}
+
+// exchange runs on the dialer
+func (i *identify) exchange(p *Peer) {
+	ctx, cancel := context.WithTimeout(context.Background(), identifyTimeout)
+	defer cancel()
+
+	var res internal_pb.SignedIdentify
+	if err := i.swarm.rpc.Call(ctx, p.ID(), IdentifyProtocol, i.record(p), &res); err != nil {
+		i.log.Debug("Identify failed", "peer_id", hex.EncodeToString(p.id[:]), "err", err)
+		return
+	}
+	if err := i.accept(p, &res); err != nil {
+		i.log.Debug("Identify rejected", "peer_id", hex.EncodeToString(p.id[:]), "err", err)
+	}
+}
+
+func (i *identify) handleIdentify(ctx context.Context, from types.PeerID, req proto.Message) (proto.Message, error) {
+	p := i.swarm.GetPeer(from)
+	if p == nil {
+		return nil, &RemoteError{Code: RPCErrUnavailable, Message: "not connected"}
+	}
+	if err := i.accept(p, req.(*internal_pb.SignedIdentify)); err != nil {
+		return nil, &RemoteError{Code: RPCErrInvalidRequest, Message: err.Error()}
+	}
+	return i.record(p), nil
+}
+
+// record describes this node to p
+func (i *identify) record(p *Peer) *internal_pb.SignedIdentify {
+	cfg := i.swarm.cfg
+	pubKey := types.PeerPrivateKeyToPublic(i.swarm.myPrivKey)
+	observed, _ := observedAddr(p)
+	listenAddrs := i.swarm.ListenAddrs()
+
+	id := &internal_pb.Identify{
+		PubKey:          pubKey[:],
+		Agent:           Agent,
+		ProtocolVersion: cfg.ProtocolVersion(),
+		ListenAddrs:     listenAddrs[:min(len(listenAddrs), maxIdentifyAddrs)],
+		ObservedAddr:    observed,
+		Protocols:       i.swarm.rpc.Protocols(),
+		Role:            cfg.Node.Role,
+		Timestamp:       uint64(time.Now().UnixNano()),
+	}
+	if !cfg.Security.AnonymousMode {
+		id.Callsign = cfg.Identity.Callsign
+	}
+	data, _ := proto.Marshal(id)
+	return &internal_pb.SignedIdentify{
+		Identify:  data,
+		Signature: crypto.CreateSignature(append([]byte(identifySigPrefix), data...), ed25519.PrivateKey(i.swarm.myPrivKey[:])),
+	}
+}
+
+// accept verifies the record p sent against the key it authenticated with
+// and stores it
+func (i *identify) accept(p *Peer, signed *internal_pb.SignedIdentify) error {
+	pubKey := p.PubKey()
+	if !crypto.VerifySignature(pubKey, append([]byte(identifySigPrefix), signed.Identify...), signed.Signature) {
+		return errors.New("bad identify signature")
+	}
+	var id internal_pb.Identify
+	if err := proto.Unmarshal(signed.Identify, &id); err != nil {
+		return fmt.Errorf("decode identify: %w", err)
+	}
+	if !bytes.Equal(id.PubKey, pubKey[:]) {
+		return errors.New("identify of another key")
+	}
+	if len(id.ListenAddrs) > maxIdentifyAddrs || len(id.Protocols) > maxIdentifyProtocols {
+		return errors.New("identify too large")
+	}
+	for _, s := range slices.Concat([]string{id.Agent, id.Role, id.Callsign, id.ObservedAddr}, id.ListenAddrs, id.Protocols) {
+		if len(s) > maxIdentifyString {
+			return errors.New("identify field too long")
+		}
+	}
+
+	i.swarm.peerStore.put(p.ID(), PeerMetadata{
+		PubKey:          pubKey,
+		Agent:           id.Agent,
+		ProtocolVersion: id.ProtocolVersion,
+		ListenAddrs:     id.ListenAddrs,
+		ObservedAddr:    id.ObservedAddr,
+		Protocols:       id.Protocols,
+		Role:            id.Role,
+		Callsign:        id.Callsign,
+		IdentifiedAt:    time.Now(),
+	}, i.swarm.ThisIsActivePeer)
+	i.swarm.reach.record(p.ID(), id.ObservedAddr)
+
+	// the history kept the port an inbound peer connected from
+	if addr := dialAddr(p.Addr(), id.ListenAddrs); addr != "" && !p.IsOutbound() {
+		go i.swarm.SavePeer(pubKey, addr, 100)
+	}
+	return nil
+}
+
+// dialAddr is the first listen address of a peer connected from remote, a
+// wildcard host is replaced by the host of remote
+func dialAddr(remote string, listenAddrs []string) string {
+	remoteHost, _, err := net.SplitHostPort(remote)
+	if err != nil || len(listenAddrs) == 0 {
+		return ""
+	}
+	host, port, err := net.SplitHostPort(listenAddrs[0])
+	if err != nil {
+		return ""
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = remoteHost
+	}
+	return net.JoinHostPort(host, port)
+}
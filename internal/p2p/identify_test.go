@@ -0,0 +1,106 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/crypto"
+	"github.com/DmytroBuzhylov/echofog-core/internal/network"
+	internal_pb "github.com/DmytroBuzhylov/echofog-core/internal/proto"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// signIdentify signs a record naming pubKey with signer
+func signIdentify(t *testing.T, pubKey types.PeerPublicKey, agent string, signer types.PeerPrivateKey) *internal_pb.SignedIdentify {
+	t.Helper()
+	data, err := proto.Marshal(&internal_pb.Identify{
+		PubKey:    pubKey[:],
+		Agent:     agent,
+		Timestamp: uint64(time.Now().UnixNano()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &internal_pb.SignedIdentify{
+		Identify:  data,
+		Signature: crypto.CreateSignature(append([]byte(identifySigPrefix), data...), ed25519.PrivateKey(signer[:])),
+	}
+}
+
+func TestIdentifyExchange(t *testing.T) {
+	mem := network.NewMemoryNetwork()
+	a := newTestSwarm(t, mem, testProtocol)
+	b := newTestSwarm(t, mem, testProtocol)
+	connectSwarms(t, a, b)
+
+	waitUntil(t, "both records", func() bool {
+		_, okA := a.PeerMetadata(b.selfID)
+		_, okB := b.PeerMetadata(a.selfID)
+		return okA && okB
+	})
+	m, _ := b.PeerMetadata(a.selfID)
+	if m.PubKey != types.PeerPrivateKeyToPublic(a.myPrivKey) || m.Agent != Agent || !m.Supports(IdentifyProtocol) {
+		t.Fatalf("stored %+v", m)
+	}
+}
+
+// TestIdentifyRejectsForgedRecords sends b records that a did not sign, b
+// has to refuse them and keep nothing
+func TestIdentifyRejectsForgedRecords(t *testing.T) {
+	mem := network.NewMemoryNetwork()
+	a := newTestSwarm(t, mem, testProtocol)
+	b := newTestSwarm(t, mem, testProtocol)
+	connectSwarms(t, a, b)
+	waitUntil(t, "the record of a", func() bool {
+		_, ok := b.PeerMetadata(a.selfID)
+		return ok
+	})
+	b.peerStore.mu.Lock()
+	delete(b.peerStore.peers, a.selfID)
+	b.peerStore.mu.Unlock()
+
+	aPub := types.PeerPrivateKeyToPublic(a.myPrivKey)
+	other := newTestKey(t)
+	badSignature := signIdentify(t, aPub, "forged", a.myPrivKey)
+	badSignature.Signature[0] ^= 0xff
+
+	tests := []struct {
+		name   string
+		record *internal_pb.SignedIdentify
+	}{
+		{"bad signature", badSignature},
+		{"signed by another key", signIdentify(t, aPub, "forged", other)},
+		{"record of another key", signIdentify(t, types.PeerPrivateKeyToPublic(other), "forged", other)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			var res internal_pb.SignedIdentify
+			err := a.RPC().Call(ctx, b.selfID, IdentifyProtocol, tt.record, &res)
+			var remoteErr *RemoteError
+			if !errors.As(err, &remoteErr) || remoteErr.Code != RPCErrInvalidRequest {
+				t.Fatalf("identify returned %v, want an invalid request", err)
+			}
+			if m, ok := b.PeerMetadata(a.selfID); ok {
+				t.Fatalf("stored the record of agent %q", m.Agent)
+			}
+		})
+	}
+
+	// the same call with a record a signed is stored
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var res internal_pb.SignedIdentify
+	if err := a.RPC().Call(ctx, b.selfID, IdentifyProtocol, signIdentify(t, aPub, "signed", a.myPrivKey), &res); err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := b.PeerMetadata(a.selfID); !ok || m.Agent != "signed" {
+		t.Fatalf("stored %+v, want the signed record", m)
+	}
+}
@@ -3,9 +3,8 @@ package p2p
 import (
 	"context"
 	"crypto/sha256"
-
-	"log"
 	"sync"
+	"time"
 
 	"github.com/DmytroBuzhylov/echofog-core/internal/dispatcher"
 	"github.com/DmytroBuzhylov/echofog-core/internal/network"
@@ -21,6 +20,12 @@ type Peer struct {
 	pubKey    types.PeerPublicKey
 	addr      string
 	isOut     bool
+	// connectedAt is when the connection was registered
+	connectedAt time.Time
+
+	// version and capabilities were negotiated in the handshake
+	version      uint32
+	capabilities network.Capabilities
 
 	sendChan chan *internal_pb.Envelope
 	ctx      context.Context
@@ -40,19 +45,31 @@ func NewPeer(peerPubKey types.PeerPublicKey, dispatcher *dispatcher.Dispatcher,
 	hashID := sha256.Sum256(peerPubKey[:])
 
 	return &Peer{
-		id:         hashID,
-		pubKey:     peerPubKey,
-		addr:       addr,
-		isOut:      isOut,
-		sendChan:   make(chan *internal_pb.Envelope, 100),
-		ctx:        ctx,
-		cancel:     cancel,
-		dispatcher: dispatcher,
+		id:          hashID,
+		pubKey:      peerPubKey,
+		addr:        addr,
+		isOut:       isOut,
+		connectedAt: time.Now(),
+		sendChan:    make(chan *internal_pb.Envelope, 100),
+		ctx:         ctx,
+		cancel:      cancel,
+		dispatcher:  dispatcher,
 	}
 }
 
+// Close cancels the peer context and closes its connection with a goodbye
 func (p *Peer) Close() {
 	p.cancel()
+	if p.transport != nil {
+		p.transport.Close()
+	}
+}
+
+// Wait blocks until all connection goroutines of the peer have returned
+func (p *Peer) Wait() {
+	if p.transport != nil {
+		p.transport.Wait()
+	}
 }
 
 func (p *Peer) SetTransport(transport *network.PeerWrapper) {
@@ -72,16 +89,58 @@ func (p *Peer) Addr() string {
 	return p.addr
 }
 
+// Relayed reports whether the connection runs through a relay circuit
+func (p *Peer) Relayed() bool {
+	return network.IsRelayAddr(p.addr)
+}
+
+func (p *Peer) ConnectedAt() time.Time {
+	return p.connectedAt
+}
+
+func (p *Peer) IsOutbound() bool {
+	return p.isOut
+}
+
+// Version is the protocol version negotiated with the peer
+func (p *Peer) Version() uint32 {
+	return p.version
+}
+
+func (p *Peer) Capabilities() network.Capabilities {
+	return p.capabilities
+}
+
+// Supports reports whether the peer announced all of caps, check it before
+// sending the peer anything optional
+func (p *Peer) Supports(caps network.Capabilities) bool {
+	return p.capabilities.Has(caps)
+}
+
 func (p *Peer) Send(msgType network.MessageType, msgData *internal_pb.Envelope) error {
 	data, err := proto.Marshal(msgData)
 	if err != nil {
-		log.Println(err)
 		return err
 	}
 
 	return p.transport.SendGossipMessage(msgType, data)
 }
 
+// Flush waits until the messages sent before were written to the connection
+func (p *Peer) Flush(ctx context.Context) error {
+	return p.transport.Flush(ctx)
+}
+
+// SendDatagram is Send without delivery guarantees
+func (p *Peer) SendDatagram(msgType network.MessageType, msgData *internal_pb.Envelope) error {
+	data, err := proto.Marshal(msgData)
+	if err != nil {
+		return err
+	}
+
+	return p.transport.SendDatagram(msgType, data)
+}
+
 func (p *Peer) readLoop() {
 
 }
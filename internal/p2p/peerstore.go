@@ -0,0 +1,92 @@
+package p2p
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+)
+
+// maxStoredMetadata bounds the peers the store keeps, the ones identified
+// longest ago that are not connected go first
+const maxStoredMetadata = 1024
+
+// PeerMetadata is what a peer told about itself in the identify exchange,
+// the record was signed by its key
+type PeerMetadata struct {
+	PubKey          types.PeerPublicKey
+	Agent           string
+	ProtocolVersion uint32
+	ListenAddrs     []string
+	// ObservedAddr is the address the peer sees us at
+	ObservedAddr string
+	Protocols    []string
+	Role         string
+	Callsign     string
+	IdentifiedAt time.Time
+}
+
+// Supports reports whether the peer serves the RPC protocol
+func (m PeerMetadata) Supports(protocol string) bool {
+	return slices.Contains(m.Protocols, protocol)
+}
+
+// peerStore keeps the metadata of peers after they disconnect, so their
+// listen addresses are known when they are dialed again
+type peerStore struct {
+	mu    sync.RWMutex
+	peers map[types.PeerID]PeerMetadata
+}
+
+func newPeerStore() *peerStore {
+	return &peerStore{peers: make(map[types.PeerID]PeerMetadata)}
+}
+
+func (ps *peerStore) get(id types.PeerID) (PeerMetadata, bool) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	m, ok := ps.peers[id]
+	return m, ok
+}
+
+// put replaces the metadata of id, active tells which peers must not be
+// evicted to make room
+func (ps *peerStore) put(id types.PeerID, m PeerMetadata, active func(types.PeerID) bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if _, ok := ps.peers[id]; !ok && len(ps.peers) >= maxStoredMetadata {
+		var (
+			oldest   types.PeerID
+			oldestAt time.Time
+			found    bool
+		)
+		for other, meta := range ps.peers {
+			if !active(other) && (!found || meta.IdentifiedAt.Before(oldestAt)) {
+				oldest, oldestAt, found = other, meta.IdentifiedAt, true
+			}
+		}
+		if !found {
+			return
+		}
+		delete(ps.peers, oldest)
+	}
+	ps.peers[id] = m
+}
+
+// PeerMetadata returns what peerID told about itself, also after it
+// disconnected. Peers are identified shortly after they connect.
+func (s *Swarm) PeerMetadata(peerID types.PeerID) (PeerMetadata, bool) {
+	return s.peerStore.get(peerID)
+}
+
+// PeersSupporting returns the connected peers that serve the RPC protocol
+func (s *Swarm) PeersSupporting(protocol string) []*Peer {
+	var peers []*Peer
+	for _, p := range s.GetAllPeers() {
+		if m, ok := s.peerStore.get(p.ID()); ok && m.Supports(protocol) {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
@@ -0,0 +1,117 @@
+package p2p
+
+import (
+	"log/slog"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+)
+
+const (
+	// observedConfirmations is how many peers must report an address before
+	// it is advertised, a single peer could lie
+	observedConfirmations = 2
+	observedTTL           = 30 * time.Minute
+	// maxObservations bounds the reports kept, one per peer
+	maxObservations = 512
+)
+
+type observation struct {
+	addr string
+	at   time.Time
+}
+
+// reachability collects the addresses peers observe us at, the identify
+// exchange reports them
+type reachability struct {
+	log *slog.Logger
+
+	mu       sync.Mutex
+	observed map[types.PeerID]observation
+}
+
+func newReachability(log *slog.Logger) *reachability {
+	return &reachability{
+		log:      log,
+		observed: make(map[types.PeerID]observation),
+	}
+}
+
+// ObservedAddrs returns the addresses at least observedConfirmations peers
+// saw us at lately, the most reported first
+func (s *Swarm) ObservedAddrs() []string {
+	s.reach.mu.Lock()
+	defer s.reach.mu.Unlock()
+
+	now := time.Now()
+	counts := make(map[string]int)
+	for _, o := range s.reach.observed {
+		if now.Sub(o.at) < observedTTL {
+			counts[o.addr]++
+		}
+	}
+	var addrs []string
+	for addr, n := range counts {
+		if n >= observedConfirmations {
+			addrs = append(addrs, addr)
+		}
+	}
+	slices.SortFunc(addrs, func(a, b string) int {
+		if counts[a] != counts[b] {
+			return counts[b] - counts[a]
+		}
+		return strings.Compare(a, b)
+	})
+	return addrs
+}
+
+// record keeps the latest report of each peer, it ignores addresses a peer
+// could not have seen a UDP packet come from
+func (r *reachability) record(from types.PeerID, addr string) {
+	ap, err := netip.ParseAddrPort(addr)
+	if err != nil || ap.Addr().IsUnspecified() || ap.Port() == 0 {
+		return
+	}
+	addr = netip.AddrPortFrom(ap.Addr().Unmap(), ap.Port()).String()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if _, ok := r.observed[from]; !ok && len(r.observed) >= maxObservations {
+		for id, o := range r.observed {
+			if now.Sub(o.at) >= observedTTL {
+				delete(r.observed, id)
+			}
+		}
+		if len(r.observed) >= maxObservations {
+			return
+		}
+	}
+
+	prev, reported := r.observed[from]
+	confirmed := 0
+	for id, o := range r.observed {
+		if id != from && o.addr == addr && now.Sub(o.at) < observedTTL {
+			confirmed++
+		}
+	}
+	r.observed[from] = observation{addr: addr, at: now}
+	if (!reported || prev.addr != addr) && confirmed+1 == observedConfirmations {
+		r.log.Info("Observed address confirmed", "addr", addr)
+	}
+}
+
+// observedAddr is the address p connects to us from, only QUIC connections
+// share the listening socket so their addresses are worth reporting
+func observedAddr(p *Peer) (string, bool) {
+	addr, ok := p.transport.RemoteAddr().(*net.UDPAddr)
+	if !ok {
+		return "", false
+	}
+	return addr.String(), true
+}
@@ -0,0 +1,119 @@
+package p2p
+
+import (
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+)
+
+func testPeerID(b byte) types.PeerID {
+	var id types.PeerID
+	id[0] = b
+	return id
+}
+
+func TestObservedAddrsNeedConfirmation(t *testing.T) {
+	s := &Swarm{reach: newReachability(slog.New(slog.DiscardHandler))}
+
+	s.reach.record(testPeerID(1), "203.0.113.7:4000")
+	if got := s.ObservedAddrs(); len(got) != 0 {
+		t.Fatalf("one report advertised %v", got)
+	}
+	// a peer repeating itself does not confirm
+	s.reach.record(testPeerID(1), "203.0.113.7:4000")
+	if got := s.ObservedAddrs(); len(got) != 0 {
+		t.Fatalf("a repeated report advertised %v", got)
+	}
+
+	s.reach.record(testPeerID(2), "203.0.113.7:4000")
+	if got, want := s.ObservedAddrs(), []string{"203.0.113.7:4000"}; !slices.Equal(got, want) {
+		t.Fatalf("ObservedAddrs = %v, want %v", got, want)
+	}
+
+	// a peer reporting another address takes back its confirmation
+	s.reach.record(testPeerID(2), "203.0.113.7:5000")
+	if got := s.ObservedAddrs(); len(got) != 0 {
+		t.Fatalf("ObservedAddrs after a changed report = %v", got)
+	}
+}
+
+func TestObservedAddrsOrder(t *testing.T) {
+	s := &Swarm{reach: newReachability(slog.New(slog.DiscardHandler))}
+	reports := map[string]int{
+		"198.51.100.1:4000":  2,
+		"203.0.113.7:4000":   4,
+		"[2001:db8::1]:4000": 2,
+		"192.0.2.9:4000":     1,
+	}
+	next := byte(1)
+	for addr, n := range reports {
+		for range n {
+			s.reach.record(testPeerID(next), addr)
+			next++
+		}
+	}
+
+	want := []string{"203.0.113.7:4000", "198.51.100.1:4000", "[2001:db8::1]:4000"}
+	if got := s.ObservedAddrs(); !slices.Equal(got, want) {
+		t.Fatalf("ObservedAddrs = %v, want %v", got, want)
+	}
+}
+
+func TestObservedAddrsIgnoresBadReports(t *testing.T) {
+	s := &Swarm{reach: newReachability(slog.New(slog.DiscardHandler))}
+	for i, addr := range []string{"", "not an address", "0.0.0.0:4000", "[::]:4000", "203.0.113.7:0", "example.com:4000"} {
+		s.reach.record(testPeerID(byte(2*i+1)), addr)
+		s.reach.record(testPeerID(byte(2*i+2)), addr)
+	}
+	if got := s.ObservedAddrs(); len(got) != 0 {
+		t.Fatalf("ObservedAddrs = %v, want none", got)
+	}
+
+	// IPv4 seen over an IPv6 socket counts as the plain IPv4 address
+	s.reach.record(testPeerID(100), "[::ffff:203.0.113.7]:4000")
+	s.reach.record(testPeerID(101), "203.0.113.7:4000")
+	if got, want := s.ObservedAddrs(), []string{"203.0.113.7:4000"}; !slices.Equal(got, want) {
+		t.Fatalf("ObservedAddrs = %v, want %v", got, want)
+	}
+}
+
+func TestObservedAddrsExpire(t *testing.T) {
+	s := &Swarm{reach: newReachability(slog.New(slog.DiscardHandler))}
+	s.reach.record(testPeerID(1), "203.0.113.7:4000")
+	s.reach.record(testPeerID(2), "203.0.113.7:4000")
+
+	s.reach.mu.Lock()
+	o := s.reach.observed[testPeerID(1)]
+	o.at = time.Now().Add(-observedTTL)
+	s.reach.observed[testPeerID(1)] = o
+	s.reach.mu.Unlock()
+
+	if got := s.ObservedAddrs(); len(got) != 0 {
+		t.Fatalf("ObservedAddrs with an expired report = %v", got)
+	}
+}
+
+func TestDialAddr(t *testing.T) {
+	tests := []struct {
+		remote string
+		listen []string
+		want   string
+	}{
+		{"203.0.113.7:51000", []string{"0.0.0.0:4000"}, "203.0.113.7:4000"},
+		{"203.0.113.7:51000", []string{"[::]:4000"}, "203.0.113.7:4000"},
+		{"203.0.113.7:51000", []string{":4000"}, "203.0.113.7:4000"},
+		{"203.0.113.7:51000", []string{"198.51.100.1:4000", "0.0.0.0:5000"}, "198.51.100.1:4000"},
+		{"[2001:db8::1]:51000", []string{"[::]:4000"}, "[2001:db8::1]:4000"},
+		{"203.0.113.7:51000", nil, ""},
+		{"relay", []string{"0.0.0.0:4000"}, ""},
+		{"203.0.113.7:51000", []string{"bad"}, ""},
+	}
+	for _, tt := range tests {
+		if got := dialAddr(tt.remote, tt.listen); got != tt.want {
+			t.Errorf("dialAddr(%q, %q) = %q, want %q", tt.remote, tt.listen, got, tt.want)
+		}
+	}
+}
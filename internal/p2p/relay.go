@@ -0,0 +1,351 @@
+package p2p
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/network"
+	internal_pb "github.com/DmytroBuzhylov/echofog-core/internal/proto"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// A relay carries circuits to peers that cannot be dialed. Such a peer
+// reserves a slot on the relay and advertises a relay:// address. A dialer
+// opens a stream to the relay with a TypeRelayHop frame, the relay opens a
+// stream to the target with a TypeRelayStop frame, and once both accepted it
+// copies the TypeRelayData frames between the two streams. The circuit is
+// secured end to end by the RelayTransport of both sides.
+const (
+	RelayReserveProtocol = "relay/reserve/1"
+
+	relayReservationTTL = time.Hour
+	// relayBudgetPeriod is how often the byte budget of a peer refills
+	relayBudgetPeriod = time.Hour
+	// relayStatusTimeout bounds the wait for the answer to a hop or stop frame
+	relayStatusTimeout = 10 * time.Second
+)
+
+var ErrRelayDisabled = errors.New("relay service disabled")
+
+// RelayLimits bound what this node relays for others
+type RelayLimits struct {
+	MaxReservations    int
+	MaxCircuits        int
+	MaxCircuitsPerPeer int
+	// MaxCircuitBytes and MaxCircuitDuration end a circuit, the bytes of both directions count
+	MaxCircuitBytes    int64
+	MaxCircuitDuration time.Duration
+	// MaxPeerBytes bounds the bytes relayed from and to one peer per
+	// relayBudgetPeriod over all its circuits
+	MaxPeerBytes int64
+}
+
+// peerBudget is what is left of the bytes relayed for a peer until reset
+type peerBudget struct {
+	left  atomic.Int64
+	reset time.Time
+}
+
+// relayService serves circuits once enabled, reservations are refused before
+type relayService struct {
+	swarm *Swarm
+	log   *slog.Logger
+
+	mu           sync.Mutex
+	enabled      bool
+	limits       RelayLimits
+	reservations map[types.PeerID]time.Time
+	// circuits counts the open circuits per peer, both ends count
+	circuits map[types.PeerID]int
+	active   int
+	budgets  map[types.PeerID]*peerBudget
+
+	bytes atomic.Uint64
+}
+
+func newRelayService(swarm *Swarm, log *slog.Logger) *relayService {
+	return &relayService{
+		swarm:        swarm,
+		log:          log,
+		reservations: make(map[types.PeerID]time.Time),
+		circuits:     make(map[types.PeerID]int),
+		budgets:      make(map[types.PeerID]*peerBudget),
+	}
+}
+
+// EnableRelay starts relaying circuits for peers that reserve a slot, the
+// reserve protocol is only served from then on so identify announces it
+func (s *Swarm) EnableRelay(limits RelayLimits) {
+	s.relay.mu.Lock()
+	s.relay.enabled = true
+	s.relay.limits = limits
+	s.relay.mu.Unlock()
+	s.rpc.Handle(RelayReserveProtocol, &internal_pb.RelayReserve{}, s.relay.handleReserve)
+}
+
+// RelayStats returns the reservations and circuits this node holds as a
+// relay and the bytes it relayed so far
+func (s *Swarm) RelayStats() (reservations, circuits int, bytes uint64) {
+	s.relay.mu.Lock()
+	defer s.relay.mu.Unlock()
+	now := time.Now()
+	for _, expires := range s.relay.reservations {
+		if now.Before(expires) {
+			reservations++
+		}
+	}
+	return reservations, s.relay.active, s.relay.bytes.Load()
+}
+
+// hasCircuits reports whether we relay a circuit from or to the peer
+func (r *relayService) hasCircuits(peerID types.PeerID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.circuits[peerID] > 0
+}
+
+func (r *relayService) handleReserve(ctx context.Context, from types.PeerID, req proto.Message) (proto.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.enabled {
+		return nil, &RemoteError{Code: RPCErrUnavailable, Message: ErrRelayDisabled.Error()}
+	}
+
+	now := time.Now()
+	if _, renew := r.reservations[from]; !renew && len(r.reservations) >= r.limits.MaxReservations {
+		for id, expires := range r.reservations {
+			if !now.Before(expires) {
+				delete(r.reservations, id)
+			}
+		}
+		if len(r.reservations) >= r.limits.MaxReservations {
+			return nil, &RemoteError{Code: RPCErrResourceExhausted, Message: "no reservation slot left"}
+		}
+	}
+	r.reservations[from] = now.Add(relayReservationTTL)
+
+	return &internal_pb.RelayReservation{
+		TtlMs:             uint32(relayReservationTTL.Milliseconds()),
+		MaxCircuitBytes:   uint64(r.limits.MaxCircuitBytes),
+		MaxCircuitSeconds: uint32(r.limits.MaxCircuitDuration.Seconds()),
+	}, nil
+}
+
+// admit takes a circuit slot from src to target, release gives it back. The
+// circuit draws on the byte budgets of both ends.
+func (r *relayService) admit(src, target types.PeerID) (RelayLimits, []*peerBudget, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	switch {
+	case !r.enabled:
+		return RelayLimits{}, nil, ErrRelayDisabled
+	case !now.Before(r.reservations[target]):
+		return RelayLimits{}, nil, errors.New("target has no reservation")
+	case r.active >= r.limits.MaxCircuits:
+		return RelayLimits{}, nil, errors.New("too many circuits")
+	case r.circuits[src] >= r.limits.MaxCircuitsPerPeer || r.circuits[target] >= r.limits.MaxCircuitsPerPeer:
+		return RelayLimits{}, nil, errors.New("too many circuits for peer")
+	}
+
+	for id, b := range r.budgets {
+		if r.circuits[id] == 0 && !now.Before(b.reset) {
+			delete(r.budgets, id)
+		}
+	}
+	budgets := []*peerBudget{r.budget(src, now), r.budget(target, now)}
+	for _, b := range budgets {
+		if b.left.Load() <= 0 {
+			return RelayLimits{}, nil, errors.New("peer relay budget used up")
+		}
+	}
+
+	r.active++
+	r.circuits[src]++
+	r.circuits[target]++
+	return r.limits, budgets, nil
+}
+
+// budget returns the byte budget of the peer, refilled once its period is over
+func (r *relayService) budget(peerID types.PeerID, now time.Time) *peerBudget {
+	b, ok := r.budgets[peerID]
+	if !ok {
+		b = &peerBudget{}
+		r.budgets[peerID] = b
+	}
+	if !now.Before(b.reset) {
+		b.left.Store(r.limits.MaxPeerBytes)
+		b.reset = now.Add(relayBudgetPeriod)
+	}
+	return b
+}
+
+func (r *relayService) release(src, target types.PeerID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.active--
+	for _, id := range []types.PeerID{src, target} {
+		if r.circuits[id]--; r.circuits[id] <= 0 {
+			delete(r.circuits, id)
+		}
+	}
+}
+
+// serveHop runs on the relay for the stream a dialer opened with a
+// TypeRelayHop frame, it returns when the circuit ends
+func (r *relayService) serveHop(stream *network.Stream, first *network.StreamMessage) {
+	src := stream.RemoteID
+	var hop internal_pb.RelayHop
+	if err := proto.Unmarshal(first.Payload, &hop); err != nil || len(hop.TargetId) != len(types.PeerID{}) {
+		refuseCircuit(stream, "bad hop request")
+		return
+	}
+	target := types.PeerID(hop.TargetId)
+
+	limits, budgets, err := r.admit(src, target)
+	if err != nil {
+		refuseCircuit(stream, err.Error())
+		return
+	}
+	defer r.release(src, target)
+
+	dst, err := r.openStop(src, target)
+	if err != nil {
+		refuseCircuit(stream, err.Error())
+		return
+	}
+	if err := sendRelayStatus(stream, ""); err != nil {
+		stream.Close()
+		dst.Close()
+		return
+	}
+
+	r.log.Debug("Relay circuit opened", "peer_id", hex.EncodeToString(src[:]), "target_id", hex.EncodeToString(target[:]))
+	r.splice(stream, dst, limits, budgets)
+}
+
+// openStop offers the circuit from src to target
+func (r *relayService) openStop(src, target types.PeerID) (*network.Stream, error) {
+	peer := r.swarm.GetPeer(target)
+	if peer == nil {
+		return nil, errors.New("target not connected")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), relayStatusTimeout)
+	defer cancel()
+
+	stream, err := peer.transport.OpenBidirectionalStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, _ := proto.Marshal(&internal_pb.RelayStop{PeerId: src[:]})
+	if err := stream.Send(ctx, network.TypeRelayStop, data); err != nil {
+		stream.Close()
+		return nil, err
+	}
+	if err := awaitRelayStatus(ctx, stream); err != nil {
+		stream.Close()
+		return nil, fmt.Errorf("target: %w", err)
+	}
+	return stream, nil
+}
+
+// splice copies the frames of a circuit in both directions until either
+// side closes, a limit is reached or a budget of the peers is used up, then
+// closes both streams
+func (r *relayService) splice(a, b *network.Stream, limits RelayLimits, budgets []*peerBudget) {
+	ctx, cancel := context.WithTimeout(context.Background(), limits.MaxCircuitDuration)
+	defer cancel()
+
+	var budget atomic.Int64
+	budget.Store(limits.MaxCircuitBytes)
+
+	done := make(chan struct{}, 2)
+	forward := func(from, to *network.Stream) {
+		defer func() { done <- struct{}{} }()
+		for {
+			var msg *network.StreamMessage
+			select {
+			case msg = <-from.ReadCh():
+			case <-from.Done():
+				return
+			case <-ctx.Done():
+				return
+			}
+			n := int64(len(msg.Payload))
+			if msg.Type != network.TypeRelayData || budget.Add(-n) < 0 || !charge(budgets, n) {
+				return
+			}
+			if err := to.Send(ctx, network.TypeRelayData, msg.Payload); err != nil {
+				return
+			}
+			r.bytes.Add(uint64(len(msg.Payload)))
+		}
+	}
+	go forward(a, b)
+	go forward(b, a)
+
+	<-done
+	a.Close()
+	b.Close()
+	cancel()
+	<-done
+}
+
+// charge takes n bytes from every budget, it reports false once one is used up
+func charge(budgets []*peerBudget, n int64) bool {
+	ok := true
+	for _, b := range budgets {
+		if b.left.Add(-n) < 0 {
+			ok = false
+		}
+	}
+	return ok
+}
+
+// refuseCircuit tells the other side why and closes the stream once the
+// reason was written
+func refuseCircuit(stream *network.Stream, reason string) {
+	if sendRelayStatus(stream, reason) == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), relayStatusTimeout)
+		stream.Flush(ctx)
+		cancel()
+	}
+	stream.Close()
+}
+
+// sendRelayStatus queues the answer to a hop or stop frame, a nil error does
+// not mean it was written, the frames that follow it keep the order
+func sendRelayStatus(stream *network.Stream, reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), relayStatusTimeout)
+	defer cancel()
+	data, _ := proto.Marshal(&internal_pb.RelayStatus{Error: reason})
+	return stream.Send(ctx, network.TypeRelayStatus, data)
+}
+
+// awaitRelayStatus reads the answer to a hop or stop frame
+func awaitRelayStatus(ctx context.Context, stream *network.Stream) error {
+	select {
+	case msg := <-stream.ReadCh():
+		var status internal_pb.RelayStatus
+		if msg.Type != network.TypeRelayStatus || proto.Unmarshal(msg.Payload, &status) != nil {
+			return errors.New("bad relay status")
+		}
+		if status.Error != "" {
+			return errors.New(status.Error)
+		}
+		return nil
+	case <-stream.Done():
+		return network.ErrStreamClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
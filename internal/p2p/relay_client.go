@@ -0,0 +1,261 @@
+package p2p
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/network"
+	internal_pb "github.com/DmytroBuzhylov/echofog-core/internal/proto"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// relayRefreshInterval is how often the reservations are checked, renewed
+	// and replaced
+	relayRefreshInterval = 30 * time.Second
+	relayReserveTimeout  = 10 * time.Second
+)
+
+type relayReservation struct {
+	addr    network.RelayAddr
+	renewAt time.Time
+	expires time.Time
+}
+
+// relayClient keeps reservations on relays and connects the circuits of
+// the RelayTransport
+type relayClient struct {
+	swarm     *Swarm
+	log       *slog.Logger
+	transport *network.RelayTransport
+	// want is how many reservations the client keeps, 0 only reserves on request
+	want int
+
+	mu       sync.Mutex
+	reserved map[types.PeerID]relayReservation
+}
+
+func newRelayClient(swarm *Swarm, transport network.Transport, want int, log *slog.Logger) *relayClient {
+	c := &relayClient{
+		swarm:    swarm,
+		log:      log,
+		want:     want,
+		reserved: make(map[types.PeerID]relayReservation),
+	}
+	if rt, ok := network.AsRelayTransport(transport); ok {
+		c.transport = rt
+		rt.SetOpener(c.openCircuit)
+	}
+	return c
+}
+
+// ReserveRelay reserves a slot on relayID, a relay we dialed, and returns
+// the relay:// address that reaches this node through it
+func (s *Swarm) ReserveRelay(ctx context.Context, relayID types.PeerID) (string, error) {
+	if s.relayClient.transport == nil {
+		return "", errors.New("no relay transport")
+	}
+	relay := s.GetPeer(relayID)
+	if relay == nil {
+		return "", errors.New("relay not connected")
+	}
+	if !relay.Supports(network.CapRelay) || relay.Version() < network.VersionRelay {
+		return "", fmt.Errorf("relay: %w", ErrNotSupported)
+	}
+	if !relay.IsOutbound() || relay.Relayed() {
+		return "", errors.New("relay has no address we can dial")
+	}
+
+	var res internal_pb.RelayReservation
+	if err := s.rpc.Call(ctx, relayID, RelayReserveProtocol, &internal_pb.RelayReserve{}, &res); err != nil {
+		return "", fmt.Errorf("reserve: %w", err)
+	}
+
+	now := time.Now()
+	ttl := time.Duration(res.TtlMs) * time.Millisecond
+	reservation := relayReservation{
+		addr:    network.RelayAddr{Relay: relay.Addr(), RelayID: relayID, PeerID: s.selfID},
+		renewAt: now.Add(ttl / 2),
+		expires: now.Add(ttl),
+	}
+	s.relayClient.mu.Lock()
+	_, renewed := s.relayClient.reserved[relayID]
+	s.relayClient.reserved[relayID] = reservation
+	s.relayClient.mu.Unlock()
+
+	if !renewed {
+		s.log.Info("Relay reserved", "addr", reservation.addr.String(), "ttl", ttl)
+	}
+	return reservation.addr.String(), nil
+}
+
+// RelayAddrs returns the addresses that reach this node through the relays
+// it holds a reservation on
+func (s *Swarm) RelayAddrs() []string {
+	s.relayClient.mu.Lock()
+	defer s.relayClient.mu.Unlock()
+	now := time.Now()
+	addrs := make([]string, 0, len(s.relayClient.reserved))
+	for _, res := range s.relayClient.reserved {
+		if now.Before(res.expires) {
+			addrs = append(addrs, res.addr.String())
+		}
+	}
+	return addrs
+}
+
+// holds reports whether we hold a live reservation on relayID
+func (c *relayClient) holds(relayID types.PeerID) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res, ok := c.reserved[relayID]
+	return ok && time.Now().Before(res.expires)
+}
+
+// ListenAddrs returns the addresses the transport accepts connections on and
+// the relay addresses
+func (s *Swarm) ListenAddrs() []string {
+	return append(network.ListenAddrs(s.netTransport), s.RelayAddrs()...)
+}
+
+// loop keeps want reservations on connected relays
+func (c *relayClient) loop() {
+	defer c.swarm.wg.Done()
+	ticker := time.NewTicker(relayRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.refresh()
+		case <-c.swarm.closing:
+			return
+		}
+	}
+}
+
+// refresh drops the reservations of relays we lost, renews those past half
+// their lifetime and reserves on more relays while there are too few
+func (c *relayClient) refresh() {
+	now := time.Now()
+	var renew []types.PeerID
+	c.mu.Lock()
+	for id, res := range c.reserved {
+		switch {
+		case !c.swarm.ThisIsActivePeer(id) || !now.Before(res.expires):
+			delete(c.reserved, id)
+		case !now.Before(res.renewAt):
+			renew = append(renew, id)
+		}
+	}
+	held := len(c.reserved)
+	c.mu.Unlock()
+
+	for _, id := range renew {
+		if err := c.reserve(id); err != nil {
+			c.log.Debug("Relay reservation renewal failed", "relay_id", hex.EncodeToString(id[:]), "err", err)
+		}
+	}
+
+	for _, p := range c.swarm.GetAllPeers() {
+		if held >= c.want {
+			return
+		}
+		c.mu.Lock()
+		_, ok := c.reserved[p.ID()]
+		c.mu.Unlock()
+		if ok || !p.Supports(network.CapRelay) || p.Version() < network.VersionRelay || !p.IsOutbound() || p.Relayed() {
+			continue
+		}
+		if err := c.reserve(p.ID()); err != nil {
+			c.log.Debug("Relay reservation failed", "relay_id", hex.EncodeToString(p.id[:]), "err", err)
+			continue
+		}
+		held++
+	}
+}
+
+func (c *relayClient) reserve(relayID types.PeerID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), relayReserveTimeout)
+	defer cancel()
+	_, err := c.swarm.ReserveRelay(ctx, relayID)
+	return err
+}
+
+// openCircuit is the CircuitOpener of the RelayTransport, the relay is
+// dialed first if we are not connected to it
+func (c *relayClient) openCircuit(ctx context.Context, addr network.RelayAddr) (*network.Stream, error) {
+	relay := c.swarm.GetPeer(addr.RelayID)
+	if relay == nil {
+		id, err := c.swarm.netTransport.Dial(ctx, addr.Relay)
+		if err != nil {
+			return nil, fmt.Errorf("dial relay: %w", err)
+		}
+		if id != addr.RelayID {
+			return nil, errors.New("relay address answered as another peer")
+		}
+		if relay, err = c.swarm.waitForPeer(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	stream, err := relay.transport.OpenBidirectionalStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, _ := proto.Marshal(&internal_pb.RelayHop{TargetId: addr.PeerID[:]})
+	if err := stream.Send(ctx, network.TypeRelayHop, data); err != nil {
+		stream.Close()
+		return nil, err
+	}
+	if err := awaitRelayStatus(ctx, stream); err != nil {
+		stream.Close()
+		return nil, fmt.Errorf("relay refused circuit: %w", err)
+	}
+	return stream, nil
+}
+
+// acceptCircuit answers a circuit a relay we reserved on opened to us and
+// hands it to the RelayTransport
+func (c *relayClient) acceptCircuit(stream *network.Stream, first *network.StreamMessage) {
+	relayID := stream.RemoteID
+	c.mu.Lock()
+	res, ok := c.reserved[relayID]
+	c.mu.Unlock()
+	if !ok || c.transport == nil {
+		refuseCircuit(stream, "no reservation")
+		return
+	}
+
+	var stop internal_pb.RelayStop
+	if err := proto.Unmarshal(first.Payload, &stop); err != nil || len(stop.PeerId) != len(types.PeerID{}) {
+		refuseCircuit(stream, "bad stop request")
+		return
+	}
+	remote := res.addr
+	remote.PeerID = types.PeerID(stop.PeerId)
+	if c.swarm.CheckOnBan(remote.PeerID) {
+		refuseCircuit(stream, "banned")
+		return
+	}
+
+	if err := sendRelayStatus(stream, ""); err != nil {
+		stream.Close()
+		return
+	}
+	c.transport.Accept(stream, remote)
+}
+
+// upgradeRelayed tries to replace a relayed connection we dialed with a
+// direct one, the relay introduces both sides for a hole punch
+func (s *Swarm) upgradeRelayed(peerID types.PeerID) {
+	ctx, cancel := context.WithTimeout(context.Background(), holePunchTimeout)
+	defer cancel()
+	s.HolePunch(ctx, peerID)
+}
@@ -0,0 +1,331 @@
+package p2p
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/network"
+	internal_pb "github.com/DmytroBuzhylov/echofog-core/internal/proto"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+
+	"google.golang.org/protobuf/proto"
+)
+
+var relayProtocol = network.Protocol{
+	MinVersion:   testProtocol.MinVersion,
+	MaxVersion:   testProtocol.MaxVersion,
+	Capabilities: testProtocol.Capabilities | network.CapRelay,
+}
+
+// testRelayLimits is loose enough for every test, each tightens the limit it checks
+var testRelayLimits = RelayLimits{
+	MaxReservations:    8,
+	MaxCircuits:        8,
+	MaxCircuitsPerPeer: 4,
+	MaxCircuitBytes:    1 << 20,
+	MaxCircuitDuration: time.Minute,
+	MaxPeerBytes:       4 << 20,
+}
+
+// relayFixture is a relay with a target that reserved a slot on it, addr
+// reaches the target through the relay
+type relayFixture struct {
+	mem    *network.MemoryNetwork
+	relay  *Swarm
+	target *Swarm
+	addr   string
+}
+
+func newRelayFixture(t *testing.T, limits RelayLimits) *relayFixture {
+	t.Helper()
+	mem := network.NewMemoryNetwork()
+	relay := newTestSwarm(t, mem, relayProtocol)
+	relay.EnableRelay(limits)
+	target := newTestSwarm(t, mem, testProtocol)
+	connectSwarms(t, target, relay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addr, err := target.ReserveRelay(ctx, relay.selfID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &relayFixture{mem: mem, relay: relay, target: target, addr: addr}
+}
+
+// dial connects a new swarm to the target through the relay
+func (f *relayFixture) dial(t *testing.T) (*Swarm, error) {
+	t.Helper()
+	s := newTestSwarm(t, f.mem, testProtocol)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	id, err := s.netTransport.Dial(ctx, f.addr)
+	if err != nil {
+		return s, err
+	}
+	if id != f.target.selfID {
+		t.Fatalf("relayed dial reached %x", id[:4])
+	}
+	waitRegistered(t, s, f.target.selfID)
+	waitRegistered(t, f.target, s.selfID)
+	return s, nil
+}
+
+// fill sends chat messages to the target until the circuit is closed
+func (f *relayFixture) fill(t *testing.T, s *Swarm) {
+	t.Helper()
+	for i := 0; i < 1000 && s.ThisIsActivePeer(f.target.selfID); i++ {
+		s.SendDataForPeer(f.target.selfID, network.TypeGossip, chatData(randomBytes(t, 4096)))
+		time.Sleep(time.Millisecond)
+	}
+	waitUntil(t, "the circuit to close", func() bool { return !s.ThisIsActivePeer(f.target.selfID) })
+}
+
+func TestRelayedConnectionIsAuthenticated(t *testing.T) {
+	f := newRelayFixture(t, testRelayLimits)
+	chats := collectChats(f.target)
+	dialer, err := f.dial(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p := dialer.GetPeer(f.target.selfID); !p.Relayed() || !p.IsOutbound() {
+		t.Errorf("dialer sees the target at %s, outbound %v", p.Addr(), p.IsOutbound())
+	}
+	if p := f.target.GetPeer(dialer.selfID); !p.Relayed() || p.IsOutbound() {
+		t.Errorf("target sees the dialer at %s, outbound %v", p.Addr(), p.IsOutbound())
+	}
+
+	payload := randomBytes(t, 1000)
+	if err := dialer.SendDataForPeer(f.target.selfID, network.TypeGossip, chatData(payload)); err != nil {
+		t.Fatal(err)
+	}
+	if got := chats.next(t); string(got) != string(payload) {
+		t.Fatal("relayed message arrived corrupted")
+	}
+	if _, circuits, bytes := f.relay.RelayStats(); circuits != 1 || bytes == 0 {
+		t.Errorf("relay holds %d circuits with %d bytes, want 1 circuit with traffic", circuits, bytes)
+	}
+}
+
+// impostorRelay ends the circuits it gets itself, with its own key,
+// instead of relaying them to the peer they are meant for
+type impostorRelay struct {
+	*rawPeer
+	relay *network.RelayTransport
+}
+
+func newImpostorRelay(t *testing.T, mem *network.MemoryNetwork) *impostorRelay {
+	t.Helper()
+	peer := newRawPeer(t, mem)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := peer.tr.Listen(ctx); err != nil {
+		t.Fatal(err)
+	}
+	relay := network.NewRelayTransport(peer.priv, relayProtocol, slog.New(slog.DiscardHandler))
+	relay.Listen(ctx)
+	t.Cleanup(func() { relay.Close() })
+	return &impostorRelay{rawPeer: peer, relay: relay}
+}
+
+// accept returns the connection a swarm dialed to the impostor
+func (r *impostorRelay) accept(t *testing.T) network.Conn {
+	t.Helper()
+	select {
+	case ev := <-r.tr.ConnChan():
+		return ev.Conn
+	case <-time.After(5 * time.Second):
+		t.Fatal("no connection event")
+		return nil
+	}
+}
+
+// acceptFrame skips the streams of conn that start with another frame than msgType
+func (r *impostorRelay) acceptFrame(t *testing.T, conn network.Conn, remote types.PeerID, msgType network.MessageType) (*network.Stream, *network.StreamMessage) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		raw, err := conn.AcceptStream(ctx)
+		if err != nil {
+			t.Fatalf("no %v stream: %v", msgType, err)
+		}
+		stream := network.NewStream(context.Background(), raw, raw.StreamID(), remote, nil, nil, nil)
+		t.Cleanup(func() { stream.Close() })
+		select {
+		case msg := <-stream.ReadCh():
+			if msg.Type == msgType {
+				return stream, msg
+			}
+		case <-ctx.Done():
+			t.Fatalf("no %v stream", msgType)
+		}
+	}
+}
+
+func TestRelaySubstitutingItsKeyIsRejected(t *testing.T) {
+	t.Run("dialer", func(t *testing.T) {
+		mem := network.NewMemoryNetwork()
+		dialer := newTestSwarm(t, mem, testProtocol)
+		impostor := newImpostorRelay(t, mem)
+		target := types.PeerPubKeyToID(types.PeerPrivateKeyToPublic(newTestKey(t)))
+
+		addr := network.RelayAddr{Relay: impostor.tr.Addr().String(), RelayID: impostor.id, PeerID: target}
+		errc := make(chan error, 1)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, err := dialer.netTransport.Dial(ctx, addr.String())
+			errc <- err
+		}()
+
+		conn := impostor.accept(t)
+		stream, _ := impostor.acceptFrame(t, conn, dialer.selfID, network.TypeRelayHop)
+		if err := sendRelayStatus(stream, ""); err != nil {
+			t.Fatal(err)
+		}
+		impostor.relay.Accept(stream, network.RelayAddr{Relay: addr.Relay, RelayID: impostor.id, PeerID: dialer.selfID})
+
+		err := <-errc
+		if err == nil || !strings.Contains(err.Error(), "relay circuit reached another peer") {
+			t.Fatalf("dial through the impostor returned %v", err)
+		}
+		if dialer.ThisIsActivePeer(target) {
+			t.Fatal("dialer registered the target")
+		}
+	})
+
+	t.Run("target", func(t *testing.T) {
+		mem := network.NewMemoryNetwork()
+		target := newTestSwarm(t, mem, testProtocol)
+		impostor := newImpostorRelay(t, mem)
+		claimed := types.PeerPubKeyToID(types.PeerPrivateKeyToPublic(newTestKey(t)))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := target.netTransport.Dial(ctx, impostor.tr.Addr().String()); err != nil {
+			t.Fatal(err)
+		}
+		conn := impostor.accept(t)
+		// the impostor does not serve the reserve protocol, the target
+		// holds a reservation as if it did
+		addr := network.RelayAddr{Relay: impostor.tr.Addr().String(), RelayID: impostor.id, PeerID: target.selfID}
+		target.relayClient.mu.Lock()
+		target.relayClient.reserved[impostor.id] = relayReservation{addr: addr, renewAt: time.Now().Add(time.Hour), expires: time.Now().Add(time.Hour)}
+		target.relayClient.mu.Unlock()
+
+		raw, err := conn.OpenStream(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream := network.NewStream(context.Background(), raw, raw.StreamID(), target.selfID, nil, nil, nil)
+		defer stream.Close()
+		data, _ := proto.Marshal(&internal_pb.RelayStop{PeerId: claimed[:]})
+		if err := stream.Send(ctx, network.TypeRelayStop, data); err != nil {
+			t.Fatal(err)
+		}
+		if err := awaitRelayStatus(ctx, stream); err != nil {
+			t.Fatalf("target refused the circuit: %v", err)
+		}
+
+		impostor.relay.SetOpener(func(context.Context, network.RelayAddr) (*network.Stream, error) {
+			return stream, nil
+		})
+		// the target closes the circuit once Noise shows the impostor's key
+		if _, err := impostor.relay.Dial(ctx, addr.String()); err == nil {
+			t.Fatal("dial claiming another peer succeeded")
+		}
+		select {
+		case <-stream.Done():
+		case <-ctx.Done():
+			t.Fatal("target kept the circuit open")
+		}
+		if target.ThisIsActivePeer(claimed) {
+			t.Fatal("target registered the relayed connection")
+		}
+	})
+}
+
+func TestRelayMaxCircuitsPerPeer(t *testing.T) {
+	limits := testRelayLimits
+	limits.MaxCircuitsPerPeer = 1
+	f := newRelayFixture(t, limits)
+
+	first, err := f.dial(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.dial(t); err == nil || !strings.Contains(err.Error(), "too many circuits for peer") {
+		t.Fatalf("second circuit to the target: %v", err)
+	}
+
+	// the slot is given back once the circuit closes
+	first.closePeer(f.target.selfID, "test")
+	waitUntil(t, "the circuit to close", func() bool {
+		_, circuits, _ := f.relay.RelayStats()
+		return circuits == 0
+	})
+	if _, err := f.dial(t); err != nil {
+		t.Fatalf("circuit after the first closed: %v", err)
+	}
+}
+
+func TestRelayMaxCircuitBytes(t *testing.T) {
+	limits := testRelayLimits
+	limits.MaxCircuitBytes = 64 << 10
+	f := newRelayFixture(t, limits)
+
+	dialer, err := f.dial(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.fill(t, dialer)
+	if _, _, bytes := f.relay.RelayStats(); bytes > uint64(limits.MaxCircuitBytes) {
+		t.Fatalf("relayed %d bytes on a circuit limited to %d", bytes, limits.MaxCircuitBytes)
+	}
+
+	// the limit is per circuit, a new one gets through
+	if _, err := f.dial(t); err != nil {
+		t.Fatalf("circuit after the first used its bytes: %v", err)
+	}
+}
+
+func TestRelayMaxCircuitDuration(t *testing.T) {
+	limits := testRelayLimits
+	limits.MaxCircuitDuration = 300 * time.Millisecond
+	f := newRelayFixture(t, limits)
+
+	start := time.Now()
+	dialer, err := f.dial(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "the circuit to expire", func() bool { return !dialer.ThisIsActivePeer(f.target.selfID) })
+	if elapsed := time.Since(start); elapsed < limits.MaxCircuitDuration {
+		t.Fatalf("circuit closed after %v, before its %v", elapsed, limits.MaxCircuitDuration)
+	}
+}
+
+func TestRelayMaxPeerBytes(t *testing.T) {
+	limits := testRelayLimits
+	limits.MaxPeerBytes = 64 << 10
+	f := newRelayFixture(t, limits)
+
+	dialer, err := f.dial(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.fill(t, dialer)
+	if _, _, bytes := f.relay.RelayStats(); bytes > uint64(limits.MaxPeerBytes) {
+		t.Fatalf("relayed %d bytes for a peer limited to %d", bytes, limits.MaxPeerBytes)
+	}
+
+	// the budget of the target is used up for any dialer
+	if _, err := f.dial(t); err == nil || !strings.Contains(err.Error(), "peer relay budget used up") {
+		t.Fatalf("circuit after the budget was used up: %v", err)
+	}
+}
@@ -0,0 +1,431 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/network"
+	internal_pb "github.com/DmytroBuzhylov/echofog-core/internal/proto"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// maxRPCCalls is how many calls we run against one peer at a time,
+	// further calls wait for a slot
+	maxRPCCalls = 32
+	// maxRPCServed is how many calls of one peer we serve at a time,
+	// further calls fail with RPCErrResourceExhausted
+	maxRPCServed = 64
+	// rpcCancelTimeout bounds telling the callee about a canceled call
+	rpcCancelTimeout = time.Second
+)
+
+var ErrRPCClosed = errors.New("rpc stream closed")
+
+// RPCErrorCode says why a remote call failed
+type RPCErrorCode uint32
+
+const (
+	RPCErrInternal RPCErrorCode = iota + 1
+	RPCErrUnknownProtocol
+	RPCErrInvalidRequest
+	RPCErrDeadlineExceeded
+	RPCErrResourceExhausted
+	// RPCErrUnavailable means the callee cannot serve the call right now
+	RPCErrUnavailable
+)
+
+var rpcErrorNames = map[RPCErrorCode]string{
+	RPCErrInternal:          "internal",
+	RPCErrUnknownProtocol:   "unknown protocol",
+	RPCErrInvalidRequest:    "invalid request",
+	RPCErrDeadlineExceeded:  "deadline exceeded",
+	RPCErrResourceExhausted: "resource exhausted",
+	RPCErrUnavailable:       "unavailable",
+}
+
+func (c RPCErrorCode) String() string {
+	if name, ok := rpcErrorNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("code %d", uint32(c))
+}
+
+// RemoteError is a call that failed on the remote peer. Handlers return it
+// to choose the code, any other error is sent as RPCErrInternal.
+type RemoteError struct {
+	Code    RPCErrorCode
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("remote %s: %s", e.Code, e.Message)
+}
+
+// RPCHandler serves a call, req is a new message of the type given to
+// Handle. ctx ends with the caller's deadline, when the caller cancels or
+// when the peer disconnects.
+type RPCHandler func(ctx context.Context, peerID types.PeerID, req proto.Message) (proto.Message, error)
+
+type rpcProtocol struct {
+	req     proto.Message
+	handler RPCHandler
+}
+
+// RPC runs request/response protocols over streams. Calls to a peer share
+// one stream the caller opens, replies are matched by the id of the call.
+type RPC struct {
+	swarm *Swarm
+	log   *slog.Logger
+
+	handlersMu sync.RWMutex
+	handlers   map[string]rpcProtocol
+
+	mu      sync.Mutex
+	clients map[types.PeerID]*rpcClient
+	nextID  atomic.Uint64
+}
+
+func newRPC(swarm *Swarm, log *slog.Logger) *RPC {
+	return &RPC{
+		swarm:    swarm,
+		log:      log,
+		handlers: make(map[string]rpcProtocol),
+		clients:  make(map[types.PeerID]*rpcClient),
+	}
+}
+
+// Handle serves calls of protocol, req is the type of their requests
+func (r *RPC) Handle(protocol string, req proto.Message, handler RPCHandler) {
+	r.handlersMu.Lock()
+	defer r.handlersMu.Unlock()
+	r.handlers[protocol] = rpcProtocol{req: req, handler: handler}
+}
+
+// Protocols returns the protocols served, sorted
+func (r *RPC) Protocols() []string {
+	r.handlersMu.RLock()
+	defer r.handlersMu.RUnlock()
+	protocols := make([]string, 0, len(r.handlers))
+	for protocol := range r.handlers {
+		protocols = append(protocols, protocol)
+	}
+	slices.Sort(protocols)
+	return protocols
+}
+
+// Call sends req to the protocol on peerID and fills resp with the reply. It
+// returns a *RemoteError if the handler failed and ctx.Err() once ctx ends,
+// in which case the remote handler is canceled too.
+func (r *RPC) Call(ctx context.Context, peerID types.PeerID, protocol string, req, resp proto.Message) error {
+	payload, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	c, err := r.client(ctx, peerID)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case c.slots <- struct{}{}:
+		defer func() { <-c.slots }()
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.stream.Done():
+		return ErrRPCClosed
+	}
+
+	id := r.nextID.Add(1)
+	request := &internal_pb.RPCRequest{Id: id, Protocol: protocol, Payload: payload}
+	if deadline, ok := ctx.Deadline(); ok {
+		request.TimeoutMs = uint64(max(time.Until(deadline).Milliseconds(), 1))
+	}
+	data, err := proto.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	replies := c.register(id)
+	defer c.unregister(id)
+	if err := c.stream.Send(ctx, network.TypeRPCRequest, data); err != nil {
+		return err
+	}
+
+	select {
+	case reply := <-replies:
+		if e := reply.GetError(); e != nil {
+			return &RemoteError{Code: RPCErrorCode(e.Code), Message: e.Message}
+		}
+		return proto.Unmarshal(reply.Payload, resp)
+	case <-ctx.Done():
+		if data, err := proto.Marshal(&internal_pb.RPCCancel{Id: id}); err == nil {
+			sendCtx, cancel := context.WithTimeout(context.Background(), rpcCancelTimeout)
+			c.stream.Send(sendCtx, network.TypeStreamCancel, data)
+			cancel()
+		}
+		return ctx.Err()
+	case <-c.stream.Done():
+		// Send only queued the request, Err tells whether it was ever written
+		return fmt.Errorf("%w: %w", ErrRPCClosed, c.stream.Err())
+	}
+}
+
+// client returns the stream for calls to peerID, opening it on first use
+func (r *RPC) client(ctx context.Context, peerID types.PeerID) (*rpcClient, error) {
+	r.mu.Lock()
+	c, ok := r.clients[peerID]
+	r.mu.Unlock()
+	if ok && !c.closed() {
+		return c, nil
+	}
+
+	peer := r.swarm.GetPeer(peerID)
+	if peer == nil {
+		return nil, errors.New("this peer is not connected")
+	}
+	if peer.Version() < network.VersionRPC {
+		return nil, fmt.Errorf("rpc: %w", ErrNotSupported)
+	}
+	stream, err := peer.transport.OpenBidirectionalStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	if current, ok := r.clients[peerID]; ok && !current.closed() {
+		r.mu.Unlock()
+		stream.Close()
+		return current, nil
+	}
+	c = &rpcClient{
+		stream:  stream,
+		slots:   make(chan struct{}, maxRPCCalls),
+		pending: make(map[uint64]chan *internal_pb.RPCResponse),
+	}
+	r.clients[peerID] = c
+	r.mu.Unlock()
+
+	go r.readReplies(peerID, c)
+	return c, nil
+}
+
+func (r *RPC) readReplies(peerID types.PeerID, c *rpcClient) {
+	defer func() {
+		r.mu.Lock()
+		if r.clients[peerID] == c {
+			delete(r.clients, peerID)
+		}
+		r.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case msg := <-c.stream.ReadCh():
+			if msg.Type != network.TypeRPCResponse {
+				continue
+			}
+			var reply internal_pb.RPCResponse
+			if err := proto.Unmarshal(msg.Payload, &reply); err != nil {
+				r.log.Debug("Dropped malformed rpc reply", "err", err)
+				continue
+			}
+			c.deliver(&reply)
+		case <-c.stream.Done():
+			return
+		}
+	}
+}
+
+type rpcClient struct {
+	stream *network.Stream
+	slots  chan struct{}
+
+	mu      sync.Mutex
+	pending map[uint64]chan *internal_pb.RPCResponse
+}
+
+func (c *rpcClient) closed() bool {
+	select {
+	case <-c.stream.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *rpcClient) register(id uint64) <-chan *internal_pb.RPCResponse {
+	ch := make(chan *internal_pb.RPCResponse, 1)
+	c.mu.Lock()
+	c.pending[id] = ch
+	c.mu.Unlock()
+	return ch
+}
+
+func (c *rpcClient) unregister(id uint64) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+// deliver hands the reply to its call, replies to canceled calls are dropped
+func (c *rpcClient) deliver(reply *internal_pb.RPCResponse) {
+	c.mu.Lock()
+	ch, ok := c.pending[reply.Id]
+	delete(c.pending, reply.Id)
+	c.mu.Unlock()
+	if ok {
+		ch <- reply
+	}
+}
+
+// errRPCCanceled is the cause of a handler context the caller canceled
+var errRPCCanceled = errors.New("canceled by caller")
+
+// serve runs the calls arriving on stream until it closes, first is the
+// request that identified the stream as an RPC stream
+func (r *RPC) serve(stream *network.Stream, first *network.StreamMessage) {
+	var (
+		mu       sync.Mutex
+		inFlight = make(map[uint64]context.CancelCauseFunc)
+		slots    = make(chan struct{}, maxRPCServed)
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	handle := func(msg *network.StreamMessage) {
+		switch msg.Type {
+		case network.TypeRPCRequest:
+			var req internal_pb.RPCRequest
+			if err := proto.Unmarshal(msg.Payload, &req); err != nil {
+				r.log.Debug("Dropped malformed rpc request", "err", err)
+				return
+			}
+			select {
+			case slots <- struct{}{}:
+			default:
+				r.reply(stream, req.Id, nil, &RemoteError{Code: RPCErrResourceExhausted, Message: "too many calls in flight"})
+				return
+			}
+
+			callCtx, cancelCall := context.WithCancelCause(ctx)
+			mu.Lock()
+			if _, dup := inFlight[req.Id]; dup {
+				mu.Unlock()
+				cancelCall(nil)
+				<-slots
+				r.reply(stream, req.Id, nil, &RemoteError{Code: RPCErrInvalidRequest, Message: "duplicate call id"})
+				return
+			}
+			inFlight[req.Id] = cancelCall
+			mu.Unlock()
+
+			go func() {
+				defer func() {
+					mu.Lock()
+					delete(inFlight, req.Id)
+					mu.Unlock()
+					cancelCall(nil)
+					<-slots
+				}()
+				r.call(callCtx, stream, &req)
+			}()
+
+		case network.TypeStreamCancel:
+			var c internal_pb.RPCCancel
+			if err := proto.Unmarshal(msg.Payload, &c); err != nil {
+				return
+			}
+			mu.Lock()
+			if cancelCall, ok := inFlight[c.Id]; ok {
+				cancelCall(errRPCCanceled)
+			}
+			mu.Unlock()
+		}
+	}
+
+	handle(first)
+	for {
+		select {
+		case msg := <-stream.ReadCh():
+			handle(msg)
+		case <-stream.Done():
+			return
+		}
+	}
+}
+
+// call runs the handler of req and sends its reply
+func (r *RPC) call(ctx context.Context, stream *network.Stream, req *internal_pb.RPCRequest) {
+	r.handlersMu.RLock()
+	protocol, ok := r.handlers[req.Protocol]
+	r.handlersMu.RUnlock()
+	if !ok {
+		r.reply(stream, req.Id, nil, &RemoteError{Code: RPCErrUnknownProtocol, Message: req.Protocol})
+		return
+	}
+
+	msg := protocol.req.ProtoReflect().New().Interface()
+	if err := proto.Unmarshal(req.Payload, msg); err != nil {
+		r.reply(stream, req.Id, nil, &RemoteError{Code: RPCErrInvalidRequest, Message: err.Error()})
+		return
+	}
+
+	if req.TimeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.TimeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+
+	resp, err := protocol.handler(ctx, stream.RemoteID, msg)
+	if errors.Is(context.Cause(ctx), errRPCCanceled) {
+		return
+	}
+	r.reply(stream, req.Id, resp, err)
+}
+
+func (r *RPC) reply(stream *network.Stream, id uint64, resp proto.Message, err error) {
+	reply := &internal_pb.RPCResponse{Id: id}
+	if err == nil && resp != nil {
+		reply.Payload, err = proto.Marshal(resp)
+	}
+	if err != nil {
+		reply.Error = rpcError(err)
+	}
+
+	data, err := proto.Marshal(reply)
+	if err == nil && len(data) > network.MaxFrameSize(network.StreamKindContent) {
+		data, err = proto.Marshal(&internal_pb.RPCResponse{
+			Id:    id,
+			Error: &internal_pb.RPCError{Code: uint32(RPCErrInternal), Message: "response too large"},
+		})
+	}
+	if err != nil {
+		return
+	}
+	// blocks while the queue is full, the stream closes if the caller stops
+	// reading. A reply still queued when the stream closes is lost, the caller
+	// sees ErrRPCClosed for it
+	stream.Send(context.Background(), network.TypeRPCResponse, data)
+}
+
+func rpcError(err error) *internal_pb.RPCError {
+	var remote *RemoteError
+	switch {
+	case errors.As(err, &remote):
+		return &internal_pb.RPCError{Code: uint32(remote.Code), Message: remote.Message}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &internal_pb.RPCError{Code: uint32(RPCErrDeadlineExceeded), Message: err.Error()}
+	default:
+		return &internal_pb.RPCError{Code: uint32(RPCErrInternal), Message: err.Error()}
+	}
+}
@@ -0,0 +1,199 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/network"
+	internal_pb "github.com/DmytroBuzhylov/echofog-core/internal/proto"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	testEchoProtocol  = "test/echo/1"
+	testBlockProtocol = "test/block/1"
+)
+
+// handlerEnd is how a blocking handler saw its call end
+type handlerEnd struct {
+	cause       error
+	deadline    time.Time
+	hasDeadline bool
+}
+
+// newRPCPair connects a caller to a callee serving testEchoProtocol and
+// testBlockProtocol, whose handler waits for its ctx and reports on ended
+func newRPCPair(t *testing.T) (caller, callee *Swarm, started chan struct{}, ended chan handlerEnd) {
+	t.Helper()
+	mem := network.NewMemoryNetwork()
+	caller = newTestSwarm(t, mem, testProtocol)
+	callee = newTestSwarm(t, mem, testProtocol)
+
+	started = make(chan struct{}, 1)
+	ended = make(chan handlerEnd, 1)
+	callee.rpc.Handle(testEchoProtocol, &internal_pb.Ping{}, func(ctx context.Context, _ types.PeerID, req proto.Message) (proto.Message, error) {
+		nonce := req.(*internal_pb.Ping).Nonce
+		if nonce < 0 {
+			return nil, &RemoteError{Code: RPCErrInvalidRequest, Message: "negative nonce"}
+		}
+		return &internal_pb.Ping{Nonce: nonce + 1}, nil
+	})
+	callee.rpc.Handle(testBlockProtocol, &internal_pb.Ping{}, func(ctx context.Context, _ types.PeerID, req proto.Message) (proto.Message, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		deadline, ok := ctx.Deadline()
+		ended <- handlerEnd{cause: context.Cause(ctx), deadline: deadline, hasDeadline: ok}
+		return nil, ctx.Err()
+	})
+
+	connectSwarms(t, caller, callee)
+	return caller, callee, started, ended
+}
+
+func echo(t *testing.T, caller, callee *Swarm, nonce int64) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var resp internal_pb.Ping
+	if err := caller.RPC().Call(ctx, callee.selfID, testEchoProtocol, &internal_pb.Ping{Nonce: nonce}, &resp); err != nil {
+		t.Fatalf("echo: %v", err)
+	}
+	if resp.Nonce != nonce+1 {
+		t.Fatalf("echo %d answered %d", nonce, resp.Nonce)
+	}
+}
+
+func waitEnded(t *testing.T, ended chan handlerEnd) handlerEnd {
+	t.Helper()
+	select {
+	case end := <-ended:
+		return end
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler was not canceled")
+		return handlerEnd{}
+	}
+}
+
+func TestRPCCall(t *testing.T) {
+	caller, callee, _, _ := newRPCPair(t)
+	echo(t, caller, callee, 1)
+	echo(t, caller, callee, 41)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var remote *RemoteError
+	err := caller.RPC().Call(ctx, callee.selfID, testEchoProtocol, &internal_pb.Ping{Nonce: -1}, &internal_pb.Ping{})
+	if !errors.As(err, &remote) || remote.Code != RPCErrInvalidRequest {
+		t.Fatalf("handler error arrived as %v", err)
+	}
+	err = caller.RPC().Call(ctx, callee.selfID, "test/missing/1", &internal_pb.Ping{}, &internal_pb.Ping{})
+	if !errors.As(err, &remote) || remote.Code != RPCErrUnknownProtocol {
+		t.Fatalf("unknown protocol arrived as %v", err)
+	}
+}
+
+func TestRPCCancelReachesHandler(t *testing.T) {
+	caller, callee, started, ended := newRPCPair(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- caller.RPC().Call(ctx, callee.selfID, testBlockProtocol, &internal_pb.Ping{}, &internal_pb.Ping{})
+	}()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler did not start")
+	}
+	cancel()
+
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled call returned %v", err)
+	}
+	end := waitEnded(t, ended)
+	if !errors.Is(end.cause, errRPCCanceled) {
+		t.Fatalf("handler ended with %v, want the caller's cancel", end.cause)
+	}
+	if end.hasDeadline {
+		t.Fatal("call without deadline reached the handler with one")
+	}
+
+	// the stream the canceled call ran on keeps serving
+	echo(t, caller, callee, 7)
+}
+
+func TestRPCDeadlineReachesHandler(t *testing.T) {
+	caller, callee, _, ended := newRPCPair(t)
+
+	const timeout = 300 * time.Millisecond
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := caller.RPC().Call(ctx, callee.selfID, testBlockProtocol, &internal_pb.Ping{}, &internal_pb.Ping{})
+	// the callee deadline is rounded down to the millisecond, its answer
+	// may arrive before the caller gives up
+	var remote *RemoteError
+	if !errors.Is(err, context.DeadlineExceeded) && !(errors.As(err, &remote) && remote.Code == RPCErrDeadlineExceeded) {
+		t.Fatalf("call past its deadline returned %v", err)
+	}
+
+	end := waitEnded(t, ended)
+	if !end.hasDeadline {
+		t.Fatal("handler got no deadline")
+	}
+	if d := end.deadline.Sub(start); d <= 0 || d > timeout+100*time.Millisecond {
+		t.Fatalf("handler deadline is %v after the call, want about %v", d, timeout)
+	}
+	// whichever came first, the caller's cancel or the deadline, ended the handler
+	if !errors.Is(end.cause, context.DeadlineExceeded) && !errors.Is(end.cause, errRPCCanceled) {
+		t.Fatalf("handler ended with %v", end.cause)
+	}
+
+	echo(t, caller, callee, 9)
+}
+
+func TestRPCLateReplyIsDropped(t *testing.T) {
+	mem := network.NewMemoryNetwork()
+	caller := newTestSwarm(t, mem, testProtocol)
+	callee := newTestSwarm(t, mem, testProtocol)
+	release := make(chan struct{})
+	callee.rpc.Handle(testBlockProtocol, &internal_pb.Ping{}, func(ctx context.Context, _ types.PeerID, req proto.Message) (proto.Message, error) {
+		// ignores its ctx and answers after the caller gave up
+		<-release
+		return &internal_pb.Ping{Nonce: 1}, nil
+	})
+	callee.rpc.Handle(testEchoProtocol, &internal_pb.Ping{}, func(ctx context.Context, _ types.PeerID, req proto.Message) (proto.Message, error) {
+		return &internal_pb.Ping{Nonce: req.(*internal_pb.Ping).Nonce + 1}, nil
+	})
+	connectSwarms(t, caller, callee)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := caller.RPC().Call(ctx, callee.selfID, testBlockProtocol, &internal_pb.Ping{}, &internal_pb.Ping{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("call past its deadline returned %v", err)
+	}
+	close(release)
+
+	echo(t, caller, callee, 3)
+}
+
+func TestRPCNeedsVersion(t *testing.T) {
+	mem := network.NewMemoryNetwork()
+	old := testProtocol
+	old.MaxVersion = network.VersionRPC - 1
+	caller := newTestSwarm(t, mem, testProtocol)
+	callee := newTestSwarm(t, mem, old)
+	connectSwarms(t, caller, callee)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := caller.RPC().Call(ctx, callee.selfID, testEchoProtocol, &internal_pb.Ping{}, &internal_pb.Ping{})
+	if !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("call to a peer below VersionRPC returned %v", err)
+	}
+}
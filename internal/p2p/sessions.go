@@ -2,8 +2,10 @@ package p2p
 
 import (
 	"context"
+	"errors"
 
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/DmytroBuzhylov/echofog-core/internal/network"
@@ -15,6 +17,10 @@ import (
 type SessionManager struct {
 	mu       sync.RWMutex
 	sessions map[types.SessionID]*TransferSession
+
+	// bytes of sessions that are already closed, see Transferred
+	closedIn  atomic.Uint64
+	closedOut atomic.Uint64
 }
 
 func NewSessionManager() *SessionManager {
@@ -50,8 +56,6 @@ func (sm *SessionManager) AddStream(sessionID types.SessionID, stream *network.S
 	case <-transfer.Ctx.Done():
 		return false
 	}
-
-	return true
 }
 
 func (sm *SessionManager) Register(id types.SessionID, sess *TransferSession) {
@@ -66,6 +70,69 @@ func (sm *SessionManager) Get(id types.SessionID) *TransferSession {
 	return sm.sessions[id]
 }
 
+// CloseAll cancels every transfer session and closes its streams
+func (sm *SessionManager) CloseAll() {
+	sm.mu.Lock()
+	sessions := sm.sessions
+	sm.sessions = make(map[types.SessionID]*TransferSession)
+	sm.mu.Unlock()
+
+	for _, sess := range sessions {
+		sm.closeSession(sess)
+	}
+}
+
+// Remove closes a finished session and keeps its byte counts
+func (sm *SessionManager) Remove(id types.SessionID) {
+	sm.mu.Lock()
+	sess, ok := sm.sessions[id]
+	delete(sm.sessions, id)
+	sm.mu.Unlock()
+
+	if ok {
+		sm.closeSession(sess)
+	}
+}
+
+func (sm *SessionManager) closeSession(sess *TransferSession) {
+	in, out := sess.Transferred()
+	sess.Close()
+	sm.closedIn.Add(in)
+	sm.closedOut.Add(out)
+}
+
+// HasPeer reports whether a transfer with the peer is in progress
+func (sm *SessionManager) HasPeer(peerID types.PeerID) bool {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	for _, sess := range sm.sessions {
+		if sess.PeerID == peerID {
+			return true
+		}
+	}
+	return false
+}
+
+func (sm *SessionManager) Len() int {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return len(sm.sessions)
+}
+
+// Transferred returns the bytes received and sent by all transfer sessions so far
+func (sm *SessionManager) Transferred() (in, out uint64) {
+	in, out = sm.closedIn.Load(), sm.closedOut.Load()
+
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	for _, sess := range sm.sessions {
+		sin, sout := sess.Transferred()
+		in += sin
+		out += sout
+	}
+	return in, out
+}
+
 type TransferSession struct {
 	SessionID    types.SessionID
 	RootHash     types.ContentHash
@@ -97,7 +164,30 @@ func NewTransferSession(parentCtx context.Context, SessionID types.SessionID, Ro
 
 func (t *TransferSession) Close() error {
 	t.Cancel()
-	return nil
+
+	t.Mu.Lock()
+	streams := t.Streams
+	t.Streams = nil
+	t.Mu.Unlock()
+
+	var errs []error
+	for _, stream := range streams {
+		if err := stream.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Transferred returns the bytes received and sent on the session streams
+func (t *TransferSession) Transferred() (in, out uint64) {
+	t.Mu.Lock()
+	defer t.Mu.Unlock()
+	for _, stream := range t.Streams {
+		in += stream.BytesIn()
+		out += stream.BytesOut()
+	}
+	return in, out
 }
 
 func (t *TransferSession) addStream(stream *network.Stream) {
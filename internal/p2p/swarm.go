@@ -4,7 +4,9 @@ import (
 	"context"
 	"crypto/ed25519"
 	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	//"github.com/DmytroBuzhylov/echofog-core/api/proto"
 	"github.com/DmytroBuzhylov/echofog-core/internal/config"
 	"github.com/DmytroBuzhylov/echofog-core/internal/crypto"
@@ -14,85 +16,228 @@ import (
 	"github.com/DmytroBuzhylov/echofog-core/internal/storage"
 	"github.com/DmytroBuzhylov/echofog-core/pkg/api/proto"
 	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/events"
 	//"github.com/DmytroBuzhylov/echofog-core/pkg/api/proto"
-	"log"
+	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/quic-go/quic-go"
 	"google.golang.org/protobuf/proto"
 )
 
+// ErrNotSupported is returned when the peer did not announce the capability
+// or negotiate the protocol version a call needs
+var ErrNotSupported = errors.New("not supported by peer")
+
+// maxViolations is how many protocol violations get a peer banned
+const maxViolations = 3
+
+// maxViolationEntries bounds the violation counts kept for peers that never
+// reached maxViolations
+const maxViolationEntries = 4096
+
 type Swarm struct {
 	mu          sync.RWMutex
 	activePeers map[types.PeerID]*Peer
+	violations  map[types.PeerID]int
 
 	dispatcher *dispatcher.Dispatcher
 	storage    storage.Storage
 
 	selfID       types.PeerID // this sha256 from ed25519 pub key
 	myPrivKey    types.PeerPrivateKey
-	netTransport network.QuicTransport
+	netTransport network.Transport
 
 	sessionManager *SessionManager
-
-	cfg *config.AppConfig
+	rpc            *RPC
+	holePunch      *holePunch
+	relay          *relayService
+	relayClient    *relayClient
+	reach          *reachability
+	identify       *identify
+	peerStore      *peerStore
+	connMgr        *ConnManager
+
+	cfg      *config.AppConfig
+	events   *events.Bus
+	log      *slog.Logger
+	maxConns atomic.Int64
+
+	violationsTotal atomic.Uint64
+
+	closing   chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
 }
 
-func NewSwarm(selfID types.PeerID, privKey types.PeerPrivateKey, d *dispatcher.Dispatcher, connChan <-chan network.NewConnEvent, storage storage.Storage, cfg *config.AppConfig) *Swarm {
+// NewSwarm takes over the connections authenticated by transport and dials
+// discovered peers through it
+func NewSwarm(selfID types.PeerID, privKey types.PeerPrivateKey, d *dispatcher.Dispatcher, transport network.Transport, storage storage.Storage, cfg *config.AppConfig, bus *events.Bus, log *slog.Logger) *Swarm {
 	s := &Swarm{
 		activePeers:    make(map[types.PeerID]*Peer),
+		violations:     make(map[types.PeerID]int),
 		dispatcher:     d,
+		netTransport:   transport,
 		selfID:         selfID,
 		storage:        storage,
 		cfg:            cfg,
 		sessionManager: NewSessionManager(),
 		myPrivKey:      privKey,
+		events:         bus,
+		log:            log,
+		closing:        make(chan struct{}),
+	}
+	s.rpc = newRPC(s, log)
+	s.holePunch = newHolePunch(s, log)
+	s.relay = newRelayService(s, log)
+	s.relayClient = newRelayClient(s, transport, cfg.Relay.Reservations, log)
+	s.reach = newReachability(log)
+	s.identify = newIdentify(s, log)
+	s.peerStore = newPeerStore()
+	s.connMgr = newConnManager(s, cfg.ConnManager.LowWater, cfg.ConnManager.HighWater,
+		time.Duration(cfg.ConnManager.GracePeriodSeconds)*time.Second, log)
+	s.maxConns.Store(int64(cfg.Network.MaxConnections))
+
+	s.wg.Add(2)
+	go s.registrationLoop(transport.ConnChan())
+	go s.connMgr.loop()
+	if s.relayClient.want > 0 && s.relayClient.transport != nil {
+		s.wg.Add(1)
+		go s.relayClient.loop()
 	}
-
-	go s.registrationLoop(connChan)
 
 	return s
 }
 
 func (s *Swarm) registrationLoop(ch <-chan network.NewConnEvent) {
-	for event := range ch {
-		p := s.AddPeer(event.PeerPubKey, event.PeerID, event.Conn, event.Addr, event.IsOut)
+	defer s.wg.Done()
+	for {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				return
+			}
+			select {
+			case <-s.closing:
+				event.Conn.CloseWithError(network.ErrCodeNormalClose, "shutting down")
+				return
+			default:
+			}
+			if s.CheckOnBan(event.PeerID) {
+				event.Conn.CloseWithError(network.ErrCodeAuthFailed, "banned")
+				continue
+			}
+			if !event.IsOut && s.PeerCount() >= s.MaxConnections() {
+				event.Conn.CloseWithError(network.ErrCodeNormalClose, "too many connections")
+				continue
+			}
+			relayed := network.IsRelayAddr(event.Addr)
+			if current := s.GetPeer(event.PeerID); current != nil && !current.Relayed() && (relayed || s.keepSimultaneous(current, event)) {
+				event.Conn.CloseWithError(network.ErrCodeNormalClose, "direct connection exists")
+				continue
+			}
+			p := s.AddPeer(event)
+			s.connMgr.notify()
 
-		go p.transport.StartLoops()
+			p.transport.StartLoops()
+			if relayed && event.IsOut {
+				go s.upgradeRelayed(event.PeerID)
+			}
+			if event.IsOut && event.Version >= network.VersionIdentify {
+				go s.identify.exchange(p)
+			}
+		case <-s.closing:
+			return
+		}
 	}
 }
 
-func (s *Swarm) findAndConnectToPeers() {
-	go func() {
-		for _, peer := range s.GetHistoryConnected(0) {
+// Close disconnects every peer with a goodbye, cancels transfer sessions and
+// waits for the peer goroutines until ctx expires.
+func (s *Swarm) Close(ctx context.Context) error {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
 
-			hashPeerID := sha256.Sum256(peer.PubKey)
+	s.mu.Lock()
+	peers := s.activePeers
+	s.activePeers = make(map[types.PeerID]*Peer)
+	s.mu.Unlock()
 
-			s.mu.RLock()
-			if len(s.activePeers) > s.cfg.Network.MaxConnections {
-				break
-			}
-			_, ok := s.activePeers[hashPeerID]
-			s.mu.RUnlock()
-			if ok {
-				continue
-			}
+	for id, p := range peers {
+		p.Close()
+		s.events.Publish(events.PeerDisconnected{
+			PeerID: id,
+			Reason: "shutdown",
+			Time:   time.Now(),
+		})
+	}
 
-			go s.connect(peer.GetLastKnownAddr())
+	s.sessionManager.CloseAll()
+
+	done := make(chan struct{})
+	go func() {
+		for _, p := range peers {
+			p.Wait()
 		}
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for peer goroutines: %w", ctx.Err())
+	}
+}
 
-		s.mu.RLock()
-		if len(s.activePeers) > s.cfg.Network.MaxConnections {
+// findAndConnectToPeers dials as many peers from the history as are
+// missing to the low water mark, it is called by the loop of the
+// connection manager
+func (s *Swarm) findAndConnectToPeers() {
+	low, _ := s.connMgr.Limits()
+	missing := low - s.PeerCount()
+	for _, peer := range s.GetHistoryConnected(0) {
+		if missing <= 0 {
 			return
 		}
-		s.mu.RUnlock()
 
-	}()
+		hashPeerID := sha256.Sum256(peer.PubKey)
+		if s.ThisIsActivePeer(hashPeerID) || s.CheckOnBan(hashPeerID) {
+			continue
+		}
+
+		missing--
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.connectKnown(hashPeerID, peer.GetLastKnownAddr())
+		}()
+	}
+}
+
+// MaxConnections is network.max_connections, it can change at runtime
+func (s *Swarm) MaxConnections() int {
+	return int(s.maxConns.Load())
+}
+
+// SetMaxConnections changes the limit for new inbound connections, the
+// connection manager trims peers above its high water mark
+func (s *Swarm) SetMaxConnections(n int) {
+	s.maxConns.Store(int64(n))
+}
+
+func (s *Swarm) PeerCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.activePeers)
 }
 
-func (s *Swarm) AddPeer(peerPubKey types.PeerPublicKey, peerID types.PeerID, conn *quic.Conn, addr string, isOut bool) *Peer {
+func (s *Swarm) AddPeer(event network.NewConnEvent) *Peer {
+	peerPubKey, peerID, isOut := event.PeerPubKey, event.PeerID, event.IsOut
 
 	s.mu.Lock()
 	if old, exists := s.activePeers[peerID]; exists {
@@ -100,8 +245,10 @@ func (s *Swarm) AddPeer(peerPubKey types.PeerPublicKey, peerID types.PeerID, con
 	}
 	s.mu.Unlock()
 
-	p := NewPeer(peerPubKey, s.dispatcher, addr, isOut)
-	pw := network.NewPeerWrapper(p.ctx, conn)
+	p := NewPeer(peerPubKey, s.dispatcher, event.Addr, isOut)
+	p.version = event.Version
+	p.capabilities = event.Capabilities
+	pw := network.NewPeerWrapper(p.ctx, event.Conn, peerID, event.Version, event.Traffic, s.log)
 
 	go s.SavePeer(peerPubKey, p.addr, 100)
 
@@ -115,6 +262,13 @@ func (s *Swarm) AddPeer(peerPubKey types.PeerPublicKey, peerID types.PeerID, con
 
 		s.dispatcher.PushMessage(&data, peerID)
 	})
+	pw.OnDatagram(func(msgType network.MessageType, payload []byte, peerID types.PeerID) {
+		var data internal_pb.Envelope
+		if err := proto.Unmarshal(payload, &data); err != nil {
+			return
+		}
+		s.dispatcher.PushMessage(&data, peerID)
+	})
 	pw.OnNewStream(func(stream *network.Stream) {
 		go func() {
 			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
@@ -122,6 +276,20 @@ func (s *Swarm) AddPeer(peerPubKey types.PeerPublicKey, peerID types.PeerID, con
 
 			select {
 			case ch := <-stream.ReadCh():
+				switch ch.Type {
+				case network.TypeRPCRequest:
+					cancel()
+					s.rpc.serve(stream, ch)
+					return
+				case network.TypeRelayHop:
+					cancel()
+					s.relay.serveHop(stream, ch)
+					return
+				case network.TypeRelayStop:
+					cancel()
+					s.relayClient.acceptCircuit(stream, ch)
+					return
+				}
 				if ch.Type == network.TypeStreamInitRequest {
 					var msg api_pb.ContentMessage
 					if err := proto.Unmarshal(ch.Payload, &msg); err != nil {
@@ -150,22 +318,113 @@ func (s *Swarm) AddPeer(peerPubKey types.PeerPublicKey, peerID types.PeerID, con
 		}()
 	})
 
+	pw.OnViolation(func(err error) {
+		s.countViolation(peerID)
+	})
+
 	p.SetTransport(pw)
 
 	s.mu.Lock()
 	s.activePeers[peerID] = p
 	s.mu.Unlock()
+	if p.Supports(network.CapRelay) {
+		s.connMgr.TagPeer(peerID, relayTag, relayTagWeight)
+	}
+
+	s.events.Publish(events.PeerConnected{
+		PeerID:   peerID,
+		PubKey:   peerPubKey,
+		Addr:     p.Addr(),
+		Outbound: isOut,
+		Time:     time.Now(),
+	})
+	s.wg.Add(1)
+	go s.watchPeer(p)
 
 	return p
 }
 
+// countViolation records a protocol violation of the peer, whose connection
+// is already closed, and bans it after maxViolations
+func (s *Swarm) countViolation(peerID types.PeerID) {
+	s.violationsTotal.Add(1)
+
+	s.mu.Lock()
+	if len(s.violations) >= maxViolationEntries {
+		clear(s.violations)
+	}
+	s.violations[peerID]++
+	count := s.violations[peerID]
+	if count >= maxViolations {
+		delete(s.violations, peerID)
+	}
+	s.mu.Unlock()
+
+	if count >= maxViolations {
+		s.log.Warn("Banning peer for protocol violations", "peer_id", hex.EncodeToString(peerID[:]), "count", count)
+		s.BanPeer(peerID)
+	}
+}
+
+// Violations is the number of protocol violations seen since start
+func (s *Swarm) Violations() uint64 {
+	return s.violationsTotal.Load()
+}
+
+// watchPeer drops the peer from the active set once its connection is gone
+func (s *Swarm) watchPeer(p *Peer) {
+	defer s.wg.Done()
+	select {
+	case <-p.transport.Done():
+	case <-p.ctx.Done():
+	}
+
+	s.mu.Lock()
+	current, ok := s.activePeers[p.id]
+	if ok && current == p {
+		delete(s.activePeers, p.id)
+	}
+	s.mu.Unlock()
+
+	if !ok || current != p {
+		return
+	}
+	s.connMgr.forget(p.id)
+
+	reason := "closed"
+	if err := p.transport.CloseReason(); err != nil {
+		reason = err.Error()
+	}
+	s.events.Publish(events.PeerDisconnected{
+		PeerID: p.id,
+		Reason: reason,
+		Time:   time.Now(),
+	})
+}
+
 func (s *Swarm) RemovePeer(peerID types.PeerID) {
+	s.closePeer(peerID, "removed")
+}
+
+// closePeer disconnects peerID, reason goes into the PeerDisconnected event
+func (s *Swarm) closePeer(peerID types.PeerID, reason string) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	if p, ok := s.activePeers[peerID]; ok {
-		p.Close()
+	p, ok := s.activePeers[peerID]
+	if ok {
 		delete(s.activePeers, peerID)
 	}
+	s.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	p.Close()
+	s.connMgr.forget(peerID)
+	s.events.Publish(events.PeerDisconnected{
+		PeerID: peerID,
+		Reason: reason,
+		Time:   time.Now(),
+	})
 }
 
 func (s *Swarm) GetPeer(peerID types.PeerID) *Peer {
@@ -174,6 +433,23 @@ func (s *Swarm) GetPeer(peerID types.PeerID) *Peer {
 	return s.activePeers[peerID]
 }
 
+// waitForPeer waits until the connection to peerID, which was just dialed,
+// is registered
+func (s *Swarm) waitForPeer(ctx context.Context, peerID types.PeerID) (*Peer, error) {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if p := s.GetPeer(peerID); p != nil {
+			return p, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
 func (s *Swarm) GetAllPeers() []*Peer {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -229,13 +505,10 @@ func (s *Swarm) UnBanPeer(peerID types.PeerID) {
 	s.storage.Delete(key)
 }
 
+// CheckOnBan reports whether the peer is banned
 func (s *Swarm) CheckOnBan(peerID types.PeerID) bool {
 	key := append([]byte("bans:peer:"), peerID[:]...)
-	_, err := s.storage.Get(key)
-	if err != nil {
-		return true
-	}
-	return false
+	return s.storage.Exists(key)
 }
 
 func (s *Swarm) SavePeer(peerPubKey types.PeerPublicKey, addr string, trustScore uint32) {
@@ -261,9 +534,22 @@ func (s *Swarm) SavePeer(peerPubKey types.PeerPublicKey, addr string, trustScore
 	s.storage.Set(key, protoData)
 }
 
-func (s *Swarm) connect(addr string) {
-	ctx, _ := context.WithTimeout(context.Background(), time.Second*3)
-	s.netTransport.DialEarly(ctx, addr)
+func (s *Swarm) connect(addr string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	_, err := s.netTransport.Dial(ctx, addr)
+	return err
+}
+
+// connectKnown dials a peer from the history, if its address cannot be
+// reached it tries a hole punch through the peers we are connected to
+func (s *Swarm) connectKnown(peerID types.PeerID, addr string) {
+	if s.connect(addr) == nil || s.ThisIsActivePeer(peerID) || s.PeerCount() == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), holePunchTimeout)
+	defer cancel()
+	s.HolePunch(ctx, peerID)
 }
 
 func (s *Swarm) SendDataForPeer(peerID types.PeerID, msgType network.MessageType, data *internal_pb.MessageData) error {
@@ -280,14 +566,34 @@ func (s *Swarm) SendDataForPeer(peerID types.PeerID, msgType network.MessageType
 	return peer.Send(msgType, env)
 }
 
+// SendDatagramForPeer signs data and sends it unreliably, for small latency
+// sensitive messages such as pings and presence. Messages larger than
+// network.MaxDatagramPayload after signing fail with network.ErrDatagramLarge.
+func (s *Swarm) SendDatagramForPeer(peerID types.PeerID, msgType network.MessageType, data *internal_pb.MessageData) error {
+	peer := s.GetPeer(peerID)
+	if peer == nil {
+		return errors.New("this peer is not connected")
+	}
+	if !peer.Supports(network.CapDatagrams) || peer.Version() < network.VersionDatagrams {
+		return fmt.Errorf("datagrams: %w", ErrNotSupported)
+	}
+
+	env, err := s.signMessageData(data)
+	if err != nil {
+		return err
+	}
+
+	return peer.SendDatagram(msgType, env)
+}
+
 // GetHistoryConnected Set to 0 to get all peers
-func (s *Swarm) GetHistoryConnected(count uint) []storage.PeerStoreEntry {
+func (s *Swarm) GetHistoryConnected(count uint) []*storage.PeerStoreEntry {
 	findValues, err := s.storage.FindValues([]byte("saved:peers:"))
 	if err != nil {
 		return nil
 	}
 	var (
-		peers     []storage.PeerStoreEntry
+		peers     []*storage.PeerStoreEntry
 		peerCount uint
 	)
 
@@ -304,7 +610,7 @@ func (s *Swarm) GetHistoryConnected(count uint) []storage.PeerStoreEntry {
 			continue
 		}
 
-		peers = append(peers, peer)
+		peers = append(peers, &peer)
 
 		if count != 0 {
 			peerCount++
@@ -354,6 +660,11 @@ func (s *Swarm) GetSessionManager() *SessionManager {
 	return s.sessionManager
 }
 
+// RPC serves and calls the request/response protocols of services
+func (s *Swarm) RPC() *RPC {
+	return s.rpc
+}
+
 func (s *Swarm) signMessageData(mesData *internal_pb.MessageData) (*internal_pb.Envelope, error) {
 	Data, err := proto.Marshal(mesData)
 	if err != nil {
@@ -379,10 +690,10 @@ func (s *Swarm) AddPotentialPeer(id types.PeerID, addr string) {
 	}
 
 	go func() {
-		log.Printf("Attempting automatic local connection to %s", addr)
-		err := s.netTransport.DialEarly(context.Background(), addr)
+		s.log.Debug("Dialing discovered peer", "addr", addr)
+		_, err := s.netTransport.Dial(context.Background(), addr)
 		if err != nil {
-			log.Printf("Failed to dial discovered peer: %v", err)
+			s.log.Warn("Failed to dial discovered peer", "addr", addr, "err", err)
 		}
 	}()
 }
@@ -0,0 +1,433 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/config"
+	"github.com/DmytroBuzhylov/echofog-core/internal/dispatcher"
+	"github.com/DmytroBuzhylov/echofog-core/internal/network"
+	internal_pb "github.com/DmytroBuzhylov/echofog-core/internal/proto"
+	"github.com/DmytroBuzhylov/echofog-core/internal/storage"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/events"
+
+	"github.com/dgraph-io/badger/v4"
+	"google.golang.org/protobuf/proto"
+)
+
+var testProtocol = network.Protocol{
+	MinVersion:   config.MinProtocolVersion,
+	MaxVersion:   config.CurrentProtocolVersion,
+	Capabilities: network.CapDatagrams,
+}
+
+func newTestKey(t *testing.T) types.PeerPrivateKey {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return types.PeerPrivateKey(priv)
+}
+
+// newTestSwarm runs a swarm on mem with in-memory storage, it is closed
+// when the test ends. Like a node it dials relay:// addresses too.
+func newTestSwarm(t *testing.T, mem *network.MemoryNetwork, protocol network.Protocol) *Swarm {
+	t.Helper()
+	log := slog.New(slog.DiscardHandler)
+	priv := newTestKey(t)
+	id := types.PeerPubKeyToID(types.PeerPrivateKeyToPublic(priv))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	memTransport, err := mem.NewTransport("", priv, protocol, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := network.NewFallbackTransport([]network.Transport{memTransport, network.NewRelayTransport(priv, protocol, log)}, log)
+	if err := transport.Listen(ctx); err != nil {
+		t.Fatal(err)
+	}
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := dispatcher.NewDispatcher(ctx, log)
+	d.Start()
+	bus := events.NewBus()
+
+	s := NewSwarm(id, priv, d, transport, storage.NewBadgerStorage(db), config.DefaultConfigIn(t.TempDir()), bus, log)
+	t.Cleanup(func() {
+		closeCtx, closeCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer closeCancel()
+		s.Close(closeCtx)
+		d.Stop(closeCtx)
+		transport.Close()
+		bus.Close()
+		db.Close()
+		cancel()
+	})
+	return s
+}
+
+func swarmAddr(s *Swarm) string {
+	return s.netTransport.Addr().String()
+}
+
+// connectSwarms dials to from from and waits until both registered the connection
+func connectSwarms(t *testing.T, from, to *Swarm) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := from.netTransport.Dial(ctx, swarmAddr(to)); err != nil {
+		t.Fatal(err)
+	}
+	waitRegistered(t, from, to.selfID)
+	waitRegistered(t, to, from.selfID)
+}
+
+// waitRegistered waits until s registered the connection to id
+func waitRegistered(t *testing.T, s *Swarm, id types.PeerID) {
+	t.Helper()
+	waitUntil(t, "the connection to be registered", func() bool { return s.ThisIsActivePeer(id) })
+}
+
+// rawPeer is the far end of a connection to a swarm that writes whatever
+// a test wants, bypassing the framing of PeerWrapper
+type rawPeer struct {
+	id   types.PeerID
+	priv types.PeerPrivateKey
+	tr   *network.MemoryTransport
+}
+
+func newRawPeer(t *testing.T, mem *network.MemoryNetwork) *rawPeer {
+	t.Helper()
+	priv := newTestKey(t)
+	tr, err := mem.NewTransport("", priv, testProtocol, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tr.Close() })
+	return &rawPeer{id: types.PeerPubKeyToID(types.PeerPrivateKeyToPublic(priv)), priv: priv, tr: tr}
+}
+
+// dial connects to s and returns the connection once s registered it
+func (r *rawPeer) dial(t *testing.T, s *Swarm) network.Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := r.tr.Dial(ctx, swarmAddr(s)); err != nil {
+		t.Fatal(err)
+	}
+	var conn network.Conn
+	select {
+	case ev := <-r.tr.ConnChan():
+		conn = ev.Conn
+	case <-ctx.Done():
+		t.Fatal("no connection event")
+	}
+	waitRegistered(t, s, r.id)
+	return conn
+}
+
+// sign wraps data in an envelope signed by the raw peer
+func (r *rawPeer) sign(t *testing.T, data *internal_pb.MessageData) []byte {
+	t.Helper()
+	raw, err := proto.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := types.PeerPrivateKeyToPublic(r.priv)
+	env, err := proto.Marshal(&internal_pb.Envelope{
+		Data:      raw,
+		Signature: ed25519.Sign(ed25519.PrivateKey(r.priv[:]), raw),
+		PubKey:    pub[:],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
+
+func lengthPrefix(length uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, length)
+}
+
+// waitClosed waits until conn is closed and returns the cause
+func waitClosed(t *testing.T, conn network.Conn) error {
+	t.Helper()
+	select {
+	case <-conn.Context().Done():
+		return context.Cause(conn.Context())
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection stayed open")
+		return nil
+	}
+}
+
+func waitUntil(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func isViolationClose(err error) bool {
+	var connErr *network.ConnError
+	return errors.As(err, &connErr) && connErr.Code == network.ErrCodeProtocolViolation
+}
+
+func TestFramingViolationsGetPeerBanned(t *testing.T) {
+	mem := network.NewMemoryNetwork()
+	s := newTestSwarm(t, mem, testProtocol)
+	attacker := newRawPeer(t, mem)
+
+	violations := []struct {
+		name  string
+		write func(t *testing.T, conn network.Conn)
+	}{
+		{"gossip frame above the limit", func(t *testing.T, conn network.Conn) {
+			stream, err := conn.OpenUniStream(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			stream.Write(lengthPrefix(uint32(network.MaxFrameSize(network.StreamKindGossip) + 2)))
+		}},
+		{"empty gossip frame", func(t *testing.T, conn network.Conn) {
+			stream, err := conn.OpenUniStream(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			stream.Write(lengthPrefix(0))
+		}},
+		{"content frame above the limit", func(t *testing.T, conn network.Conn) {
+			stream, err := conn.OpenStream(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			stream.Write(lengthPrefix(uint32(network.MaxFrameSize(network.StreamKindContent) + 2)))
+		}},
+	}
+	for i, v := range violations {
+		conn := attacker.dial(t, s)
+		v.write(t, conn)
+		if err := waitClosed(t, conn); !isViolationClose(err) {
+			t.Fatalf("%s: connection closed with %v, want a protocol violation", v.name, err)
+		}
+		waitUntil(t, "the violation to be counted", func() bool { return s.Violations() == uint64(i+1) })
+		// the ban follows the count
+		if i+1 >= maxViolations {
+			waitUntil(t, "the ban", func() bool { return s.CheckOnBan(attacker.id) })
+		} else if s.CheckOnBan(attacker.id) {
+			t.Fatalf("banned after %d violations", i+1)
+		}
+	}
+
+	// a banned peer is turned away right after the handshake
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := attacker.tr.Dial(ctx, swarmAddr(s)); err != nil {
+		t.Fatal(err)
+	}
+	ev := <-attacker.tr.ConnChan()
+	var connErr *network.ConnError
+	if err := waitClosed(t, ev.Conn); !errors.As(err, &connErr) || connErr.Code != network.ErrCodeAuthFailed {
+		t.Fatalf("banned peer closed with %v, want ErrCodeAuthFailed", err)
+	}
+	if s.ThisIsActivePeer(attacker.id) {
+		t.Fatal("banned peer was registered")
+	}
+}
+
+func TestFrameAtLimitIsAccepted(t *testing.T) {
+	mem := network.NewMemoryNetwork()
+	s := newTestSwarm(t, mem, testProtocol)
+	peer := newRawPeer(t, mem)
+	conn := peer.dial(t, s)
+
+	stream, err := conn.OpenUniStream(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the length counts the type byte, the payload is not an envelope and is dropped
+	maxSize := network.MaxFrameSize(network.StreamKindGossip) + 1
+	frame := append(lengthPrefix(uint32(maxSize)), byte(network.TypeGossip))
+	frame = append(frame, make([]byte, maxSize-1)...)
+	if _, err := stream.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+	stream.Close()
+
+	time.Sleep(100 * time.Millisecond)
+	if s.Violations() != 0 || !s.ThisIsActivePeer(peer.id) {
+		t.Fatalf("frame at the limit: violations %d, connected %v", s.Violations(), s.ThisIsActivePeer(peer.id))
+	}
+}
+
+func TestTruncatedFrameIsNotAViolation(t *testing.T) {
+	mem := network.NewMemoryNetwork()
+	s := newTestSwarm(t, mem, testProtocol)
+	peer := newRawPeer(t, mem)
+	conn := peer.dial(t, s)
+
+	// a peer that stops in the middle of a frame loses the stream, not the connection
+	stream, err := conn.OpenUniStream(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Write(append(lengthPrefix(100), byte(network.TypeGossip)))
+	stream.Close()
+
+	time.Sleep(100 * time.Millisecond)
+	if s.Violations() != 0 || !s.ThisIsActivePeer(peer.id) {
+		t.Fatalf("truncated frame: violations %d, connected %v", s.Violations(), s.ThisIsActivePeer(peer.id))
+	}
+}
+
+// chatCollector records the chat payloads the dispatcher routes
+type chatCollector struct {
+	mu       sync.Mutex
+	payloads [][]byte
+	arrived  chan struct{}
+}
+
+func collectChats(s *Swarm) *chatCollector {
+	c := &chatCollector{arrived: make(chan struct{}, 100)}
+	s.dispatcher.Registry(&internal_pb.MessageData_ChatMessage{}, c)
+	return c
+}
+
+func (c *chatCollector) Handle(msg *internal_pb.MessageData, _ types.PeerID) {
+	c.mu.Lock()
+	c.payloads = append(c.payloads, msg.GetChatMessage().GetEncryptedPayload())
+	c.mu.Unlock()
+	c.arrived <- struct{}{}
+}
+
+func (c *chatCollector) next(t *testing.T) []byte {
+	t.Helper()
+	select {
+	case <-c.arrived:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message arrived")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.payloads[len(c.payloads)-1]
+}
+
+func (c *chatCollector) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.payloads)
+}
+
+func chatData(payload []byte) *internal_pb.MessageData {
+	return &internal_pb.MessageData{
+		MessageId: payload[:8],
+		Payload:   &internal_pb.MessageData_ChatMessage{ChatMessage: &internal_pb.ChatMessage{EncryptedPayload: payload}},
+	}
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
+
+func TestDatagramReassembly(t *testing.T) {
+	mem := network.NewMemoryNetwork()
+	sender := newTestSwarm(t, mem, testProtocol)
+	receiver := newTestSwarm(t, mem, testProtocol)
+	chats := collectChats(receiver)
+	connectSwarms(t, sender, receiver)
+
+	for _, size := range []int{16, 1000, 3000, network.MaxDatagramPayload - 200} {
+		payload := randomBytes(t, size)
+		if err := sender.SendDatagramForPeer(receiver.selfID, network.TypeGossip, chatData(payload)); err != nil {
+			t.Fatalf("%d bytes: %v", size, err)
+		}
+		if got := chats.next(t); !bytes.Equal(got, payload) {
+			t.Fatalf("%d bytes arrived as %d bytes", size, len(got))
+		}
+	}
+
+	err := sender.SendDatagramForPeer(receiver.selfID, network.TypeGossip, chatData(randomBytes(t, network.MaxDatagramPayload)))
+	if !errors.Is(err, network.ErrDatagramLarge) {
+		t.Fatalf("oversized message: %v, want ErrDatagramLarge", err)
+	}
+}
+
+// fragments splits env into datagrams the way PeerWrapper.SendDatagram
+// does: type, message id, fragment index and count, then the fragment
+func fragments(env []byte, id uint16) [][]byte {
+	// MaxDatagramPayload is four fragments
+	size := network.MaxDatagramPayload / 4
+	count := (len(env) + size - 1) / size
+	var out [][]byte
+	for i := range count {
+		d := []byte{byte(network.TypeGossip), 0, 0, byte(i), byte(count)}
+		binary.BigEndian.PutUint16(d[1:3], id)
+		out = append(out, append(d, env[i*size:min((i+1)*size, len(env))]...))
+	}
+	return out
+}
+
+func TestDatagramFragmentsReorderedLostAndDuplicated(t *testing.T) {
+	mem := network.NewMemoryNetwork()
+	s := newTestSwarm(t, mem, testProtocol)
+	chats := collectChats(s)
+	peer := newRawPeer(t, mem)
+	conn := peer.dial(t, s)
+
+	send := func(datagrams ...[]byte) {
+		for _, d := range datagrams {
+			if err := conn.SendDatagram(d); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// reversed with a duplicate fragment, the message arrives once
+	payload := randomBytes(t, 3000)
+	parts := fragments(peer.sign(t, chatData(payload)), 1)
+	if len(parts) < 3 {
+		t.Fatalf("want at least 3 fragments, got %d", len(parts))
+	}
+	send(parts[2], parts[1], parts[1], parts[0])
+	if got := chats.next(t); !bytes.Equal(got, payload) {
+		t.Fatal("reordered message arrived corrupted")
+	}
+
+	// a lost fragment loses the message, the next one still arrives
+	lost := fragments(peer.sign(t, chatData(randomBytes(t, 3000))), 2)
+	send(lost[0], lost[2])
+	payload = randomBytes(t, 3000)
+	send(fragments(peer.sign(t, chatData(payload)), 3)...)
+	if got := chats.next(t); !bytes.Equal(got, payload) {
+		t.Fatal("message after a lost one arrived corrupted")
+	}
+
+	// malformed headers are dropped without closing the connection
+	send([]byte{byte(network.TypeGossip), 0, 4, 5, 1, 0xff}, []byte{1, 2, 3})
+	time.Sleep(50 * time.Millisecond)
+	if n := chats.count(); n != 2 {
+		t.Fatalf("%d messages arrived, want 2", n)
+	}
+	if !s.ThisIsActivePeer(peer.id) || s.Violations() != 0 {
+		t.Fatal("malformed datagrams closed the connection")
+	}
+}
@@ -99,6 +99,7 @@ type MessageData struct {
 	//	*MessageData_HandshakeResponse
 	//	*MessageData_PeerReq
 	//	*MessageData_PeerRes
+	//	*MessageData_ContentAnnouncement
 	Payload       isMessageData_Payload `protobuf_oneof:"payload"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -257,6 +258,15 @@ func (x *MessageData) GetPeerRes() *PeerResponse {
 	return nil
 }
 
+func (x *MessageData) GetContentAnnouncement() *ContentAnnouncement {
+	if x != nil {
+		if x, ok := x.Payload.(*MessageData_ContentAnnouncement); ok {
+			return x.ContentAnnouncement
+		}
+	}
+	return nil
+}
+
 type isMessageData_Payload interface {
 	isMessageData_Payload()
 }
@@ -297,6 +307,10 @@ type MessageData_PeerRes struct {
 	PeerRes *PeerResponse `protobuf:"bytes,14,opt,name=peer_res,json=peerRes,proto3,oneof"`
 }
 
+type MessageData_ContentAnnouncement struct {
+	ContentAnnouncement *ContentAnnouncement `protobuf:"bytes,15,opt,name=content_announcement,json=contentAnnouncement,proto3,oneof"`
+}
+
 func (*MessageData_HandshakeInit) isMessageData_Payload() {}
 
 func (*MessageData_Ping) isMessageData_Payload() {}
@@ -315,6 +329,8 @@ func (*MessageData_PeerReq) isMessageData_Payload() {}
 
 func (*MessageData_PeerRes) isMessageData_Payload() {}
 
+func (*MessageData_ContentAnnouncement) isMessageData_Payload() {}
+
 type ChatMessage struct {
 	state            protoimpl.MessageState `protogen:"open.v1"`
 	EncryptedPayload []byte                 `protobuf:"bytes,1,opt,name=encrypted_payload,json=encryptedPayload,proto3" json:"encrypted_payload,omitempty"`
@@ -359,6 +375,60 @@ func (x *ChatMessage) GetEncryptedPayload() []byte {
 	return nil
 }
 
+// ContentAnnouncement tells the network that the origin shares the content
+// with this root hash
+type ContentAnnouncement struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RootHash      []byte                 `protobuf:"bytes,1,opt,name=root_hash,json=rootHash,proto3" json:"root_hash,omitempty"`
+	Size          uint64                 `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ContentAnnouncement) Reset() {
+	*x = ContentAnnouncement{}
+	mi := &file_internal_proto_message_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ContentAnnouncement) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ContentAnnouncement) ProtoMessage() {}
+
+func (x *ContentAnnouncement) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_message_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ContentAnnouncement.ProtoReflect.Descriptor instead.
+func (*ContentAnnouncement) Descriptor() ([]byte, []int) {
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ContentAnnouncement) GetRootHash() []byte {
+	if x != nil {
+		return x.RootHash
+	}
+	return nil
+}
+
+func (x *ContentAnnouncement) GetSize() uint64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
 type Transaction struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	AssetId       []byte                 `protobuf:"bytes,1,opt,name=asset_id,json=assetId,proto3" json:"asset_id,omitempty"`
@@ -370,7 +440,7 @@ type Transaction struct {
 
 func (x *Transaction) Reset() {
 	*x = Transaction{}
-	mi := &file_internal_proto_message_proto_msgTypes[3]
+	mi := &file_internal_proto_message_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -382,7 +452,7 @@ func (x *Transaction) String() string {
 func (*Transaction) ProtoMessage() {}
 
 func (x *Transaction) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_message_proto_msgTypes[3]
+	mi := &file_internal_proto_message_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -395,7 +465,7 @@ func (x *Transaction) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Transaction.ProtoReflect.Descriptor instead.
 func (*Transaction) Descriptor() ([]byte, []int) {
-	return file_internal_proto_message_proto_rawDescGZIP(), []int{3}
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *Transaction) GetAssetId() []byte {
@@ -428,7 +498,7 @@ type HandshakeInit struct {
 
 func (x *HandshakeInit) Reset() {
 	*x = HandshakeInit{}
-	mi := &file_internal_proto_message_proto_msgTypes[4]
+	mi := &file_internal_proto_message_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -440,7 +510,7 @@ func (x *HandshakeInit) String() string {
 func (*HandshakeInit) ProtoMessage() {}
 
 func (x *HandshakeInit) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_message_proto_msgTypes[4]
+	mi := &file_internal_proto_message_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -453,7 +523,7 @@ func (x *HandshakeInit) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HandshakeInit.ProtoReflect.Descriptor instead.
 func (*HandshakeInit) Descriptor() ([]byte, []int) {
-	return file_internal_proto_message_proto_rawDescGZIP(), []int{4}
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *HandshakeInit) GetNonce() []byte {
@@ -464,17 +534,22 @@ func (x *HandshakeInit) GetNonce() []byte {
 }
 
 type HandshakeResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	PubKey        []byte                 `protobuf:"bytes,1,opt,name=pub_key,json=pubKey,proto3" json:"pub_key,omitempty"`
-	Signature     []byte                 `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
-	Version       uint32                 `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	PubKey    []byte                 `protobuf:"bytes,1,opt,name=pub_key,json=pubKey,proto3" json:"pub_key,omitempty"`
+	Signature []byte                 `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+	// version is the highest protocol version the peer speaks
+	Version uint32 `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+	// min_version is the lowest one, 0 from peers that only speak version
+	MinVersion uint32 `protobuf:"varint,4,opt,name=min_version,json=minVersion,proto3" json:"min_version,omitempty"`
+	// capabilities is a bit set of network.Capabilities
+	Capabilities  uint64 `protobuf:"varint,5,opt,name=capabilities,proto3" json:"capabilities,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *HandshakeResponse) Reset() {
 	*x = HandshakeResponse{}
-	mi := &file_internal_proto_message_proto_msgTypes[5]
+	mi := &file_internal_proto_message_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -486,7 +561,7 @@ func (x *HandshakeResponse) String() string {
 func (*HandshakeResponse) ProtoMessage() {}
 
 func (x *HandshakeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_message_proto_msgTypes[5]
+	mi := &file_internal_proto_message_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -499,7 +574,7 @@ func (x *HandshakeResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HandshakeResponse.ProtoReflect.Descriptor instead.
 func (*HandshakeResponse) Descriptor() ([]byte, []int) {
-	return file_internal_proto_message_proto_rawDescGZIP(), []int{5}
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *HandshakeResponse) GetPubKey() []byte {
@@ -523,6 +598,20 @@ func (x *HandshakeResponse) GetVersion() uint32 {
 	return 0
 }
 
+func (x *HandshakeResponse) GetMinVersion() uint32 {
+	if x != nil {
+		return x.MinVersion
+	}
+	return 0
+}
+
+func (x *HandshakeResponse) GetCapabilities() uint64 {
+	if x != nil {
+		return x.Capabilities
+	}
+	return 0
+}
+
 type Ping struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Nonce         int64                  `protobuf:"varint,1,opt,name=nonce,proto3" json:"nonce,omitempty"`
@@ -532,7 +621,7 @@ type Ping struct {
 
 func (x *Ping) Reset() {
 	*x = Ping{}
-	mi := &file_internal_proto_message_proto_msgTypes[6]
+	mi := &file_internal_proto_message_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -544,7 +633,7 @@ func (x *Ping) String() string {
 func (*Ping) ProtoMessage() {}
 
 func (x *Ping) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_message_proto_msgTypes[6]
+	mi := &file_internal_proto_message_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -557,7 +646,7 @@ func (x *Ping) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Ping.ProtoReflect.Descriptor instead.
 func (*Ping) Descriptor() ([]byte, []int) {
-	return file_internal_proto_message_proto_rawDescGZIP(), []int{6}
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *Ping) GetNonce() int64 {
@@ -576,7 +665,7 @@ type PeerList struct {
 
 func (x *PeerList) Reset() {
 	*x = PeerList{}
-	mi := &file_internal_proto_message_proto_msgTypes[7]
+	mi := &file_internal_proto_message_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -588,7 +677,7 @@ func (x *PeerList) String() string {
 func (*PeerList) ProtoMessage() {}
 
 func (x *PeerList) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_message_proto_msgTypes[7]
+	mi := &file_internal_proto_message_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -601,7 +690,7 @@ func (x *PeerList) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PeerList.ProtoReflect.Descriptor instead.
 func (*PeerList) Descriptor() ([]byte, []int) {
-	return file_internal_proto_message_proto_rawDescGZIP(), []int{7}
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *PeerList) GetPeers() []*PeerList_Peer {
@@ -620,7 +709,7 @@ type Ack struct {
 
 func (x *Ack) Reset() {
 	*x = Ack{}
-	mi := &file_internal_proto_message_proto_msgTypes[8]
+	mi := &file_internal_proto_message_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -632,7 +721,7 @@ func (x *Ack) String() string {
 func (*Ack) ProtoMessage() {}
 
 func (x *Ack) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_message_proto_msgTypes[8]
+	mi := &file_internal_proto_message_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -645,7 +734,7 @@ func (x *Ack) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Ack.ProtoReflect.Descriptor instead.
 func (*Ack) Descriptor() ([]byte, []int) {
-	return file_internal_proto_message_proto_rawDescGZIP(), []int{8}
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *Ack) GetRefMessageId() []byte {
@@ -656,16 +745,19 @@ func (x *Ack) GetRefMessageId() []byte {
 }
 
 type PeerInfo struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	PubKey        []byte                 `protobuf:"bytes,1,opt,name=pub_key,json=pubKey,proto3" json:"pub_key,omitempty"`
-	Address       string                 `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	PubKey  []byte                 `protobuf:"bytes,1,opt,name=pub_key,json=pubKey,proto3" json:"pub_key,omitempty"`
+	Address string                 `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	// addrs are the public addresses the peer advertises, address is one of them
+	// or the one it is connected from
+	Addrs         []string `protobuf:"bytes,3,rep,name=addrs,proto3" json:"addrs,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *PeerInfo) Reset() {
 	*x = PeerInfo{}
-	mi := &file_internal_proto_message_proto_msgTypes[9]
+	mi := &file_internal_proto_message_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -677,7 +769,7 @@ func (x *PeerInfo) String() string {
 func (*PeerInfo) ProtoMessage() {}
 
 func (x *PeerInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_message_proto_msgTypes[9]
+	mi := &file_internal_proto_message_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -690,7 +782,7 @@ func (x *PeerInfo) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PeerInfo.ProtoReflect.Descriptor instead.
 func (*PeerInfo) Descriptor() ([]byte, []int) {
-	return file_internal_proto_message_proto_rawDescGZIP(), []int{9}
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *PeerInfo) GetPubKey() []byte {
@@ -707,6 +799,13 @@ func (x *PeerInfo) GetAddress() string {
 	return ""
 }
 
+func (x *PeerInfo) GetAddrs() []string {
+	if x != nil {
+		return x.Addrs
+	}
+	return nil
+}
+
 type PeerRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Count         uint32                 `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
@@ -716,7 +815,7 @@ type PeerRequest struct {
 
 func (x *PeerRequest) Reset() {
 	*x = PeerRequest{}
-	mi := &file_internal_proto_message_proto_msgTypes[10]
+	mi := &file_internal_proto_message_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -728,7 +827,7 @@ func (x *PeerRequest) String() string {
 func (*PeerRequest) ProtoMessage() {}
 
 func (x *PeerRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_message_proto_msgTypes[10]
+	mi := &file_internal_proto_message_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -741,7 +840,7 @@ func (x *PeerRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PeerRequest.ProtoReflect.Descriptor instead.
 func (*PeerRequest) Descriptor() ([]byte, []int) {
-	return file_internal_proto_message_proto_rawDescGZIP(), []int{10}
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *PeerRequest) GetCount() uint32 {
@@ -760,7 +859,7 @@ type PeerResponse struct {
 
 func (x *PeerResponse) Reset() {
 	*x = PeerResponse{}
-	mi := &file_internal_proto_message_proto_msgTypes[11]
+	mi := &file_internal_proto_message_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -772,7 +871,7 @@ func (x *PeerResponse) String() string {
 func (*PeerResponse) ProtoMessage() {}
 
 func (x *PeerResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_message_proto_msgTypes[11]
+	mi := &file_internal_proto_message_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -785,7 +884,7 @@ func (x *PeerResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PeerResponse.ProtoReflect.Descriptor instead.
 func (*PeerResponse) Descriptor() ([]byte, []int) {
-	return file_internal_proto_message_proto_rawDescGZIP(), []int{11}
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *PeerResponse) GetPeers() []*PeerInfo {
@@ -795,29 +894,34 @@ func (x *PeerResponse) GetPeers() []*PeerInfo {
 	return nil
 }
 
-type PeerList_Peer struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            []byte                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	LastKnownAddr string                 `protobuf:"bytes,2,opt,name=last_known_addr,json=lastKnownAddr,proto3" json:"last_known_addr,omitempty"`
+// RPCRequest calls a protocol registered with p2p.RPC, it travels over a
+// stream the caller opened and the reply carries the same id
+type RPCRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Id       uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Protocol string                 `protobuf:"bytes,2,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	Payload  []byte                 `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
+	// timeout_ms is what is left of the caller's deadline, 0 for none
+	TimeoutMs     uint64 `protobuf:"varint,4,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *PeerList_Peer) Reset() {
-	*x = PeerList_Peer{}
-	mi := &file_internal_proto_message_proto_msgTypes[12]
+func (x *RPCRequest) Reset() {
+	*x = RPCRequest{}
+	mi := &file_internal_proto_message_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PeerList_Peer) String() string {
+func (x *RPCRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PeerList_Peer) ProtoMessage() {}
+func (*RPCRequest) ProtoMessage() {}
 
-func (x *PeerList_Peer) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_message_proto_msgTypes[12]
+func (x *RPCRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_message_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -828,64 +932,905 @@ func (x *PeerList_Peer) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PeerList_Peer.ProtoReflect.Descriptor instead.
-func (*PeerList_Peer) Descriptor() ([]byte, []int) {
-	return file_internal_proto_message_proto_rawDescGZIP(), []int{7, 0}
+// Deprecated: Use RPCRequest.ProtoReflect.Descriptor instead.
+func (*RPCRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{13}
 }
 
-func (x *PeerList_Peer) GetId() []byte {
+func (x *RPCRequest) GetId() uint64 {
 	if x != nil {
 		return x.Id
 	}
+	return 0
+}
+
+func (x *RPCRequest) GetProtocol() string {
+	if x != nil {
+		return x.Protocol
+	}
+	return ""
+}
+
+func (x *RPCRequest) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
 	return nil
 }
 
-func (x *PeerList_Peer) GetLastKnownAddr() string {
+func (x *RPCRequest) GetTimeoutMs() uint64 {
 	if x != nil {
-		return x.LastKnownAddr
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+type RPCResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Id      uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Payload []byte                 `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	// error is set instead of payload when the call failed
+	Error         *RPCError `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RPCResponse) Reset() {
+	*x = RPCResponse{}
+	mi := &file_internal_proto_message_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RPCResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RPCResponse) ProtoMessage() {}
+
+func (x *RPCResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_message_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RPCResponse.ProtoReflect.Descriptor instead.
+func (*RPCResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *RPCResponse) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *RPCResponse) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *RPCResponse) GetError() *RPCError {
+	if x != nil {
+		return x.Error
+	}
+	return nil
+}
+
+type RPCError struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          uint32                 `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RPCError) Reset() {
+	*x = RPCError{}
+	mi := &file_internal_proto_message_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RPCError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RPCError) ProtoMessage() {}
+
+func (x *RPCError) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_message_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RPCError.ProtoReflect.Descriptor instead.
+func (*RPCError) Descriptor() ([]byte, []int) {
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *RPCError) GetCode() uint32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *RPCError) GetMessage() string {
+	if x != nil {
+		return x.Message
 	}
 	return ""
 }
 
-var File_internal_proto_message_proto protoreflect.FileDescriptor
+// RPCCancel tells the callee the caller gave up on the call id
+type RPCCancel struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-const file_internal_proto_message_proto_rawDesc = "" +
-	"\n" +
-	"\x1cinternal/proto/message.proto\x12\x03p2p\"U\n" +
-	"\bEnvelope\x12\x12\n" +
-	"\x04data\x18\x01 \x01(\fR\x04data\x12\x1c\n" +
-	"\tsignature\x18\x02 \x01(\fR\tsignature\x12\x17\n" +
-	"\apub_key\x18\x03 \x01(\fR\x06pubKey\"\xe5\x04\n" +
-	"\vMessageData\x12\x1d\n" +
-	"\n" +
-	"message_id\x18\x01 \x01(\fR\tmessageId\x12\x1b\n" +
-	"\torigin_id\x18\x02 \x01(\fR\boriginId\x12\x1b\n" +
-	"\ttarget_id\x18\x03 \x01(\fR\btargetId\x12\x1c\n" +
-	"\ttimestamp\x18\x04 \x01(\x04R\ttimestamp\x12\x1b\n" +
-	"\thop_limit\x18\x05 \x01(\rR\bhopLimit\x12;\n" +
-	"\x0ehandshake_init\x18\x06 \x01(\v2\x12.p2p.HandshakeInitH\x00R\rhandshakeInit\x12\x1f\n" +
-	"\x04ping\x18\a \x01(\v2\t.p2p.PingH\x00R\x04ping\x12\x1c\n" +
-	"\x03ack\x18\b \x01(\v2\b.p2p.AckH\x00R\x03ack\x125\n" +
-	"\fchat_message\x18\t \x01(\v2\x10.p2p.ChatMessageH\x00R\vchatMessage\x12,\n" +
-	"\tpeer_list\x18\n" +
-	" \x01(\v2\r.p2p.PeerListH\x00R\bpeerList\x12.\n" +
-	"\btransfer\x18\v \x01(\v2\x10.p2p.TransactionH\x00R\btransfer\x12G\n" +
-	"\x12handshake_response\x18\f \x01(\v2\x16.p2p.HandshakeResponseH\x00R\x11handshakeResponse\x12-\n" +
-	"\bpeer_req\x18\r \x01(\v2\x10.p2p.PeerRequestH\x00R\apeerReq\x12.\n" +
-	"\bpeer_res\x18\x0e \x01(\v2\x11.p2p.PeerResponseH\x00R\apeerResB\t\n" +
-	"\apayload\":\n" +
-	"\vChatMessage\x12+\n" +
-	"\x11encrypted_payload\x18\x01 \x01(\fR\x10encryptedPayload\"T\n" +
-	"\vTransaction\x12\x19\n" +
-	"\basset_id\x18\x01 \x01(\fR\aassetId\x12\x16\n" +
-	"\x06amount\x18\x02 \x01(\x04R\x06amount\x12\x12\n" +
-	"\x04memo\x18\x03 \x01(\tR\x04memo\"%\n" +
-	"\rHandshakeInit\x12\x14\n" +
-	"\x05nonce\x18\x01 \x01(\fR\x05nonce\"d\n" +
+func (x *RPCCancel) Reset() {
+	*x = RPCCancel{}
+	mi := &file_internal_proto_message_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RPCCancel) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RPCCancel) ProtoMessage() {}
+
+func (x *RPCCancel) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_message_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RPCCancel.ProtoReflect.Descriptor instead.
+func (*RPCCancel) Descriptor() ([]byte, []int) {
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *RPCCancel) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+// HolePunchConnect asks a peer connected to both sides to introduce the
+// caller to target_id, addrs are addresses the caller listens on
+type HolePunchConnect struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TargetId      []byte                 `protobuf:"bytes,1,opt,name=target_id,json=targetId,proto3" json:"target_id,omitempty"`
+	Addrs         []string               `protobuf:"bytes,2,rep,name=addrs,proto3" json:"addrs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HolePunchConnect) Reset() {
+	*x = HolePunchConnect{}
+	mi := &file_internal_proto_message_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HolePunchConnect) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HolePunchConnect) ProtoMessage() {}
+
+func (x *HolePunchConnect) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_message_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HolePunchConnect.ProtoReflect.Descriptor instead.
+func (*HolePunchConnect) Descriptor() ([]byte, []int) {
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *HolePunchConnect) GetTargetId() []byte {
+	if x != nil {
+		return x.TargetId
+	}
+	return nil
+}
+
+func (x *HolePunchConnect) GetAddrs() []string {
+	if x != nil {
+		return x.Addrs
+	}
+	return nil
+}
+
+// HolePunchSync tells the target of a hole punch to send packets to addrs
+// of peer_id in delay_ms
+type HolePunchSync struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PeerId        []byte                 `protobuf:"bytes,1,opt,name=peer_id,json=peerId,proto3" json:"peer_id,omitempty"`
+	Addrs         []string               `protobuf:"bytes,2,rep,name=addrs,proto3" json:"addrs,omitempty"`
+	DelayMs       uint32                 `protobuf:"varint,3,opt,name=delay_ms,json=delayMs,proto3" json:"delay_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HolePunchSync) Reset() {
+	*x = HolePunchSync{}
+	mi := &file_internal_proto_message_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HolePunchSync) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HolePunchSync) ProtoMessage() {}
+
+func (x *HolePunchSync) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_message_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HolePunchSync.ProtoReflect.Descriptor instead.
+func (*HolePunchSync) Descriptor() ([]byte, []int) {
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *HolePunchSync) GetPeerId() []byte {
+	if x != nil {
+		return x.PeerId
+	}
+	return nil
+}
+
+func (x *HolePunchSync) GetAddrs() []string {
+	if x != nil {
+		return x.Addrs
+	}
+	return nil
+}
+
+func (x *HolePunchSync) GetDelayMs() uint32 {
+	if x != nil {
+		return x.DelayMs
+	}
+	return 0
+}
+
+type HolePunchSyncResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HolePunchSyncResponse) Reset() {
+	*x = HolePunchSyncResponse{}
+	mi := &file_internal_proto_message_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HolePunchSyncResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HolePunchSyncResponse) ProtoMessage() {}
+
+func (x *HolePunchSyncResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_message_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HolePunchSyncResponse.ProtoReflect.Descriptor instead.
+func (*HolePunchSyncResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{19}
+}
+
+// HolePunchPlan is the answer to HolePunchConnect, the caller dials addrs
+// of the target in delay_ms
+type HolePunchPlan struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Addrs         []string               `protobuf:"bytes,1,rep,name=addrs,proto3" json:"addrs,omitempty"`
+	DelayMs       uint32                 `protobuf:"varint,2,opt,name=delay_ms,json=delayMs,proto3" json:"delay_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HolePunchPlan) Reset() {
+	*x = HolePunchPlan{}
+	mi := &file_internal_proto_message_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HolePunchPlan) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HolePunchPlan) ProtoMessage() {}
+
+func (x *HolePunchPlan) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_message_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HolePunchPlan.ProtoReflect.Descriptor instead.
+func (*HolePunchPlan) Descriptor() ([]byte, []int) {
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *HolePunchPlan) GetAddrs() []string {
+	if x != nil {
+		return x.Addrs
+	}
+	return nil
+}
+
+func (x *HolePunchPlan) GetDelayMs() uint32 {
+	if x != nil {
+		return x.DelayMs
+	}
+	return 0
+}
+
+// RelayReserve asks a relay for a slot, circuits to the caller are only
+// relayed while its reservation lasts
+type RelayReserve struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RelayReserve) Reset() {
+	*x = RelayReserve{}
+	mi := &file_internal_proto_message_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RelayReserve) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RelayReserve) ProtoMessage() {}
+
+func (x *RelayReserve) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_message_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RelayReserve.ProtoReflect.Descriptor instead.
+func (*RelayReserve) Descriptor() ([]byte, []int) {
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{21}
+}
+
+// RelayReservation grants RelayReserve, the circuit limits apply to every
+// circuit to the caller
+type RelayReservation struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	TtlMs             uint32                 `protobuf:"varint,1,opt,name=ttl_ms,json=ttlMs,proto3" json:"ttl_ms,omitempty"`
+	MaxCircuitBytes   uint64                 `protobuf:"varint,2,opt,name=max_circuit_bytes,json=maxCircuitBytes,proto3" json:"max_circuit_bytes,omitempty"`
+	MaxCircuitSeconds uint32                 `protobuf:"varint,3,opt,name=max_circuit_seconds,json=maxCircuitSeconds,proto3" json:"max_circuit_seconds,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *RelayReservation) Reset() {
+	*x = RelayReservation{}
+	mi := &file_internal_proto_message_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RelayReservation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RelayReservation) ProtoMessage() {}
+
+func (x *RelayReservation) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_message_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RelayReservation.ProtoReflect.Descriptor instead.
+func (*RelayReservation) Descriptor() ([]byte, []int) {
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *RelayReservation) GetTtlMs() uint32 {
+	if x != nil {
+		return x.TtlMs
+	}
+	return 0
+}
+
+func (x *RelayReservation) GetMaxCircuitBytes() uint64 {
+	if x != nil {
+		return x.MaxCircuitBytes
+	}
+	return 0
+}
+
+func (x *RelayReservation) GetMaxCircuitSeconds() uint32 {
+	if x != nil {
+		return x.MaxCircuitSeconds
+	}
+	return 0
+}
+
+// RelayHop is the first frame of a stream to a relay, it asks for a circuit
+// to target_id
+type RelayHop struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TargetId      []byte                 `protobuf:"bytes,1,opt,name=target_id,json=targetId,proto3" json:"target_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RelayHop) Reset() {
+	*x = RelayHop{}
+	mi := &file_internal_proto_message_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RelayHop) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RelayHop) ProtoMessage() {}
+
+func (x *RelayHop) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_message_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RelayHop.ProtoReflect.Descriptor instead.
+func (*RelayHop) Descriptor() ([]byte, []int) {
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *RelayHop) GetTargetId() []byte {
+	if x != nil {
+		return x.TargetId
+	}
+	return nil
+}
+
+// RelayStop is the first frame of a stream from a relay, it offers a circuit
+// from peer_id
+type RelayStop struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PeerId        []byte                 `protobuf:"bytes,1,opt,name=peer_id,json=peerId,proto3" json:"peer_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RelayStop) Reset() {
+	*x = RelayStop{}
+	mi := &file_internal_proto_message_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RelayStop) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RelayStop) ProtoMessage() {}
+
+func (x *RelayStop) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_message_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RelayStop.ProtoReflect.Descriptor instead.
+func (*RelayStop) Descriptor() ([]byte, []int) {
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *RelayStop) GetPeerId() []byte {
+	if x != nil {
+		return x.PeerId
+	}
+	return nil
+}
+
+// RelayStatus answers RelayHop and RelayStop, an empty error accepts the circuit
+type RelayStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Error         string                 `protobuf:"bytes,1,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RelayStatus) Reset() {
+	*x = RelayStatus{}
+	mi := &file_internal_proto_message_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RelayStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RelayStatus) ProtoMessage() {}
+
+func (x *RelayStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_message_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RelayStatus.ProtoReflect.Descriptor instead.
+func (*RelayStatus) Descriptor() ([]byte, []int) {
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *RelayStatus) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// Identify describes the sender to the other side of a connection, it
+// travels serialized in a SignedIdentify
+type Identify struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	PubKey []byte                 `protobuf:"bytes,1,opt,name=pub_key,json=pubKey,proto3" json:"pub_key,omitempty"`
+	// agent names the implementation and its version
+	Agent           string   `protobuf:"bytes,2,opt,name=agent,proto3" json:"agent,omitempty"`
+	ProtocolVersion uint32   `protobuf:"varint,3,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	ListenAddrs     []string `protobuf:"bytes,4,rep,name=listen_addrs,json=listenAddrs,proto3" json:"listen_addrs,omitempty"`
+	// observed_addr is the address the sender sees the receiver at, empty if
+	// the connection is not over the listening socket
+	ObservedAddr string `protobuf:"bytes,5,opt,name=observed_addr,json=observedAddr,proto3" json:"observed_addr,omitempty"`
+	// protocols are the RPC protocols the sender serves
+	Protocols     []string `protobuf:"bytes,6,rep,name=protocols,proto3" json:"protocols,omitempty"`
+	Role          string   `protobuf:"bytes,7,opt,name=role,proto3" json:"role,omitempty"`
+	Callsign      string   `protobuf:"bytes,8,opt,name=callsign,proto3" json:"callsign,omitempty"`
+	Timestamp     uint64   `protobuf:"varint,9,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Identify) Reset() {
+	*x = Identify{}
+	mi := &file_internal_proto_message_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Identify) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Identify) ProtoMessage() {}
+
+func (x *Identify) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_message_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Identify.ProtoReflect.Descriptor instead.
+func (*Identify) Descriptor() ([]byte, []int) {
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *Identify) GetPubKey() []byte {
+	if x != nil {
+		return x.PubKey
+	}
+	return nil
+}
+
+func (x *Identify) GetAgent() string {
+	if x != nil {
+		return x.Agent
+	}
+	return ""
+}
+
+func (x *Identify) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *Identify) GetListenAddrs() []string {
+	if x != nil {
+		return x.ListenAddrs
+	}
+	return nil
+}
+
+func (x *Identify) GetObservedAddr() string {
+	if x != nil {
+		return x.ObservedAddr
+	}
+	return ""
+}
+
+func (x *Identify) GetProtocols() []string {
+	if x != nil {
+		return x.Protocols
+	}
+	return nil
+}
+
+func (x *Identify) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
+func (x *Identify) GetCallsign() string {
+	if x != nil {
+		return x.Callsign
+	}
+	return ""
+}
+
+func (x *Identify) GetTimestamp() uint64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+// SignedIdentify is an Identify signed by the key in its pub_key
+type SignedIdentify struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Identify      []byte                 `protobuf:"bytes,1,opt,name=identify,proto3" json:"identify,omitempty"`
+	Signature     []byte                 `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SignedIdentify) Reset() {
+	*x = SignedIdentify{}
+	mi := &file_internal_proto_message_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SignedIdentify) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SignedIdentify) ProtoMessage() {}
+
+func (x *SignedIdentify) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_message_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SignedIdentify.ProtoReflect.Descriptor instead.
+func (*SignedIdentify) Descriptor() ([]byte, []int) {
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *SignedIdentify) GetIdentify() []byte {
+	if x != nil {
+		return x.Identify
+	}
+	return nil
+}
+
+func (x *SignedIdentify) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+type PeerList_Peer struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            []byte                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	LastKnownAddr string                 `protobuf:"bytes,2,opt,name=last_known_addr,json=lastKnownAddr,proto3" json:"last_known_addr,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PeerList_Peer) Reset() {
+	*x = PeerList_Peer{}
+	mi := &file_internal_proto_message_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PeerList_Peer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerList_Peer) ProtoMessage() {}
+
+func (x *PeerList_Peer) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_message_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerList_Peer.ProtoReflect.Descriptor instead.
+func (*PeerList_Peer) Descriptor() ([]byte, []int) {
+	return file_internal_proto_message_proto_rawDescGZIP(), []int{8, 0}
+}
+
+func (x *PeerList_Peer) GetId() []byte {
+	if x != nil {
+		return x.Id
+	}
+	return nil
+}
+
+func (x *PeerList_Peer) GetLastKnownAddr() string {
+	if x != nil {
+		return x.LastKnownAddr
+	}
+	return ""
+}
+
+var File_internal_proto_message_proto protoreflect.FileDescriptor
+
+const file_internal_proto_message_proto_rawDesc = "" +
+	"\n" +
+	"\x1cinternal/proto/message.proto\x12\x03p2p\"U\n" +
+	"\bEnvelope\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\x12\x1c\n" +
+	"\tsignature\x18\x02 \x01(\fR\tsignature\x12\x17\n" +
+	"\apub_key\x18\x03 \x01(\fR\x06pubKey\"\xb4\x05\n" +
+	"\vMessageData\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\fR\tmessageId\x12\x1b\n" +
+	"\torigin_id\x18\x02 \x01(\fR\boriginId\x12\x1b\n" +
+	"\ttarget_id\x18\x03 \x01(\fR\btargetId\x12\x1c\n" +
+	"\ttimestamp\x18\x04 \x01(\x04R\ttimestamp\x12\x1b\n" +
+	"\thop_limit\x18\x05 \x01(\rR\bhopLimit\x12;\n" +
+	"\x0ehandshake_init\x18\x06 \x01(\v2\x12.p2p.HandshakeInitH\x00R\rhandshakeInit\x12\x1f\n" +
+	"\x04ping\x18\a \x01(\v2\t.p2p.PingH\x00R\x04ping\x12\x1c\n" +
+	"\x03ack\x18\b \x01(\v2\b.p2p.AckH\x00R\x03ack\x125\n" +
+	"\fchat_message\x18\t \x01(\v2\x10.p2p.ChatMessageH\x00R\vchatMessage\x12,\n" +
+	"\tpeer_list\x18\n" +
+	" \x01(\v2\r.p2p.PeerListH\x00R\bpeerList\x12.\n" +
+	"\btransfer\x18\v \x01(\v2\x10.p2p.TransactionH\x00R\btransfer\x12G\n" +
+	"\x12handshake_response\x18\f \x01(\v2\x16.p2p.HandshakeResponseH\x00R\x11handshakeResponse\x12-\n" +
+	"\bpeer_req\x18\r \x01(\v2\x10.p2p.PeerRequestH\x00R\apeerReq\x12.\n" +
+	"\bpeer_res\x18\x0e \x01(\v2\x11.p2p.PeerResponseH\x00R\apeerRes\x12M\n" +
+	"\x14content_announcement\x18\x0f \x01(\v2\x18.p2p.ContentAnnouncementH\x00R\x13contentAnnouncementB\t\n" +
+	"\apayload\":\n" +
+	"\vChatMessage\x12+\n" +
+	"\x11encrypted_payload\x18\x01 \x01(\fR\x10encryptedPayload\"F\n" +
+	"\x13ContentAnnouncement\x12\x1b\n" +
+	"\troot_hash\x18\x01 \x01(\fR\brootHash\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x04R\x04size\"T\n" +
+	"\vTransaction\x12\x19\n" +
+	"\basset_id\x18\x01 \x01(\fR\aassetId\x12\x16\n" +
+	"\x06amount\x18\x02 \x01(\x04R\x06amount\x12\x12\n" +
+	"\x04memo\x18\x03 \x01(\tR\x04memo\"%\n" +
+	"\rHandshakeInit\x12\x14\n" +
+	"\x05nonce\x18\x01 \x01(\fR\x05nonce\"\xa9\x01\n" +
 	"\x11HandshakeResponse\x12\x17\n" +
 	"\apub_key\x18\x01 \x01(\fR\x06pubKey\x12\x1c\n" +
 	"\tsignature\x18\x02 \x01(\fR\tsignature\x12\x18\n" +
-	"\aversion\x18\x03 \x01(\rR\aversion\"\x1c\n" +
+	"\aversion\x18\x03 \x01(\rR\aversion\x12\x1f\n" +
+	"\vmin_version\x18\x04 \x01(\rR\n" +
+	"minVersion\x12\"\n" +
+	"\fcapabilities\x18\x05 \x01(\x04R\fcapabilities\"\x1c\n" +
 	"\x04Ping\x12\x14\n" +
 	"\x05nonce\x18\x01 \x01(\x03R\x05nonce\"t\n" +
 	"\bPeerList\x12(\n" +
@@ -894,14 +1839,66 @@ const file_internal_proto_message_proto_rawDesc = "" +
 	"\x02id\x18\x01 \x01(\fR\x02id\x12&\n" +
 	"\x0flast_known_addr\x18\x02 \x01(\tR\rlastKnownAddr\"+\n" +
 	"\x03Ack\x12$\n" +
-	"\x0eref_message_id\x18\x01 \x01(\fR\frefMessageId\"=\n" +
+	"\x0eref_message_id\x18\x01 \x01(\fR\frefMessageId\"S\n" +
 	"\bPeerInfo\x12\x17\n" +
 	"\apub_key\x18\x01 \x01(\fR\x06pubKey\x12\x18\n" +
-	"\aaddress\x18\x02 \x01(\tR\aaddress\"#\n" +
+	"\aaddress\x18\x02 \x01(\tR\aaddress\x12\x14\n" +
+	"\x05addrs\x18\x03 \x03(\tR\x05addrs\"#\n" +
 	"\vPeerRequest\x12\x14\n" +
 	"\x05count\x18\x01 \x01(\rR\x05count\"3\n" +
 	"\fPeerResponse\x12#\n" +
-	"\x05peers\x18\x01 \x03(\v2\r.p2p.PeerInfoR\x05peersBCZAgithub.com/DmytroBuzhylov/echofog-core/internal/proto;internal_pbb\x06proto3"
+	"\x05peers\x18\x01 \x03(\v2\r.p2p.PeerInfoR\x05peers\"q\n" +
+	"\n" +
+	"RPCRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x1a\n" +
+	"\bprotocol\x18\x02 \x01(\tR\bprotocol\x12\x18\n" +
+	"\apayload\x18\x03 \x01(\fR\apayload\x12\x1d\n" +
+	"\n" +
+	"timeout_ms\x18\x04 \x01(\x04R\ttimeoutMs\"\\\n" +
+	"\vRPCResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x18\n" +
+	"\apayload\x18\x02 \x01(\fR\apayload\x12#\n" +
+	"\x05error\x18\x03 \x01(\v2\r.p2p.RPCErrorR\x05error\"8\n" +
+	"\bRPCError\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\rR\x04code\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"\x1b\n" +
+	"\tRPCCancel\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\"E\n" +
+	"\x10HolePunchConnect\x12\x1b\n" +
+	"\ttarget_id\x18\x01 \x01(\fR\btargetId\x12\x14\n" +
+	"\x05addrs\x18\x02 \x03(\tR\x05addrs\"Y\n" +
+	"\rHolePunchSync\x12\x17\n" +
+	"\apeer_id\x18\x01 \x01(\fR\x06peerId\x12\x14\n" +
+	"\x05addrs\x18\x02 \x03(\tR\x05addrs\x12\x19\n" +
+	"\bdelay_ms\x18\x03 \x01(\rR\adelayMs\"\x17\n" +
+	"\x15HolePunchSyncResponse\"@\n" +
+	"\rHolePunchPlan\x12\x14\n" +
+	"\x05addrs\x18\x01 \x03(\tR\x05addrs\x12\x19\n" +
+	"\bdelay_ms\x18\x02 \x01(\rR\adelayMs\"\x0e\n" +
+	"\fRelayReserve\"\x85\x01\n" +
+	"\x10RelayReservation\x12\x15\n" +
+	"\x06ttl_ms\x18\x01 \x01(\rR\x05ttlMs\x12*\n" +
+	"\x11max_circuit_bytes\x18\x02 \x01(\x04R\x0fmaxCircuitBytes\x12.\n" +
+	"\x13max_circuit_seconds\x18\x03 \x01(\rR\x11maxCircuitSeconds\"'\n" +
+	"\bRelayHop\x12\x1b\n" +
+	"\ttarget_id\x18\x01 \x01(\fR\btargetId\"$\n" +
+	"\tRelayStop\x12\x17\n" +
+	"\apeer_id\x18\x01 \x01(\fR\x06peerId\"#\n" +
+	"\vRelayStatus\x12\x14\n" +
+	"\x05error\x18\x01 \x01(\tR\x05error\"\x98\x02\n" +
+	"\bIdentify\x12\x17\n" +
+	"\apub_key\x18\x01 \x01(\fR\x06pubKey\x12\x14\n" +
+	"\x05agent\x18\x02 \x01(\tR\x05agent\x12)\n" +
+	"\x10protocol_version\x18\x03 \x01(\rR\x0fprotocolVersion\x12!\n" +
+	"\flisten_addrs\x18\x04 \x03(\tR\vlistenAddrs\x12#\n" +
+	"\robserved_addr\x18\x05 \x01(\tR\fobservedAddr\x12\x1c\n" +
+	"\tprotocols\x18\x06 \x03(\tR\tprotocols\x12\x12\n" +
+	"\x04role\x18\a \x01(\tR\x04role\x12\x1a\n" +
+	"\bcallsign\x18\b \x01(\tR\bcallsign\x12\x1c\n" +
+	"\ttimestamp\x18\t \x01(\x04R\ttimestamp\"J\n" +
+	"\x0eSignedIdentify\x12\x1a\n" +
+	"\bidentify\x18\x01 \x01(\fR\bidentify\x12\x1c\n" +
+	"\tsignature\x18\x02 \x01(\fR\tsignatureBCZAgithub.com/DmytroBuzhylov/echofog-core/internal/proto;internal_pbb\x06proto3"
 
 var (
 	file_internal_proto_message_proto_rawDescOnce sync.Once
@@ -915,39 +1912,57 @@ func file_internal_proto_message_proto_rawDescGZIP() []byte {
 	return file_internal_proto_message_proto_rawDescData
 }
 
-var file_internal_proto_message_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_internal_proto_message_proto_msgTypes = make([]protoimpl.MessageInfo, 29)
 var file_internal_proto_message_proto_goTypes = []any{
-	(*Envelope)(nil),          // 0: p2p.Envelope
-	(*MessageData)(nil),       // 1: p2p.MessageData
-	(*ChatMessage)(nil),       // 2: p2p.ChatMessage
-	(*Transaction)(nil),       // 3: p2p.Transaction
-	(*HandshakeInit)(nil),     // 4: p2p.HandshakeInit
-	(*HandshakeResponse)(nil), // 5: p2p.HandshakeResponse
-	(*Ping)(nil),              // 6: p2p.Ping
-	(*PeerList)(nil),          // 7: p2p.PeerList
-	(*Ack)(nil),               // 8: p2p.Ack
-	(*PeerInfo)(nil),          // 9: p2p.PeerInfo
-	(*PeerRequest)(nil),       // 10: p2p.PeerRequest
-	(*PeerResponse)(nil),      // 11: p2p.PeerResponse
-	(*PeerList_Peer)(nil),     // 12: p2p.PeerList.Peer
+	(*Envelope)(nil),              // 0: p2p.Envelope
+	(*MessageData)(nil),           // 1: p2p.MessageData
+	(*ChatMessage)(nil),           // 2: p2p.ChatMessage
+	(*ContentAnnouncement)(nil),   // 3: p2p.ContentAnnouncement
+	(*Transaction)(nil),           // 4: p2p.Transaction
+	(*HandshakeInit)(nil),         // 5: p2p.HandshakeInit
+	(*HandshakeResponse)(nil),     // 6: p2p.HandshakeResponse
+	(*Ping)(nil),                  // 7: p2p.Ping
+	(*PeerList)(nil),              // 8: p2p.PeerList
+	(*Ack)(nil),                   // 9: p2p.Ack
+	(*PeerInfo)(nil),              // 10: p2p.PeerInfo
+	(*PeerRequest)(nil),           // 11: p2p.PeerRequest
+	(*PeerResponse)(nil),          // 12: p2p.PeerResponse
+	(*RPCRequest)(nil),            // 13: p2p.RPCRequest
+	(*RPCResponse)(nil),           // 14: p2p.RPCResponse
+	(*RPCError)(nil),              // 15: p2p.RPCError
+	(*RPCCancel)(nil),             // 16: p2p.RPCCancel
+	(*HolePunchConnect)(nil),      // 17: p2p.HolePunchConnect
+	(*HolePunchSync)(nil),         // 18: p2p.HolePunchSync
+	(*HolePunchSyncResponse)(nil), // 19: p2p.HolePunchSyncResponse
+	(*HolePunchPlan)(nil),         // 20: p2p.HolePunchPlan
+	(*RelayReserve)(nil),          // 21: p2p.RelayReserve
+	(*RelayReservation)(nil),      // 22: p2p.RelayReservation
+	(*RelayHop)(nil),              // 23: p2p.RelayHop
+	(*RelayStop)(nil),             // 24: p2p.RelayStop
+	(*RelayStatus)(nil),           // 25: p2p.RelayStatus
+	(*Identify)(nil),              // 26: p2p.Identify
+	(*SignedIdentify)(nil),        // 27: p2p.SignedIdentify
+	(*PeerList_Peer)(nil),         // 28: p2p.PeerList.Peer
 }
 var file_internal_proto_message_proto_depIdxs = []int32{
-	4,  // 0: p2p.MessageData.handshake_init:type_name -> p2p.HandshakeInit
-	6,  // 1: p2p.MessageData.ping:type_name -> p2p.Ping
-	8,  // 2: p2p.MessageData.ack:type_name -> p2p.Ack
+	5,  // 0: p2p.MessageData.handshake_init:type_name -> p2p.HandshakeInit
+	7,  // 1: p2p.MessageData.ping:type_name -> p2p.Ping
+	9,  // 2: p2p.MessageData.ack:type_name -> p2p.Ack
 	2,  // 3: p2p.MessageData.chat_message:type_name -> p2p.ChatMessage
-	7,  // 4: p2p.MessageData.peer_list:type_name -> p2p.PeerList
-	3,  // 5: p2p.MessageData.transfer:type_name -> p2p.Transaction
-	5,  // 6: p2p.MessageData.handshake_response:type_name -> p2p.HandshakeResponse
-	10, // 7: p2p.MessageData.peer_req:type_name -> p2p.PeerRequest
-	11, // 8: p2p.MessageData.peer_res:type_name -> p2p.PeerResponse
-	12, // 9: p2p.PeerList.peers:type_name -> p2p.PeerList.Peer
-	9,  // 10: p2p.PeerResponse.peers:type_name -> p2p.PeerInfo
-	11, // [11:11] is the sub-list for method output_type
-	11, // [11:11] is the sub-list for method input_type
-	11, // [11:11] is the sub-list for extension type_name
-	11, // [11:11] is the sub-list for extension extendee
-	0,  // [0:11] is the sub-list for field type_name
+	8,  // 4: p2p.MessageData.peer_list:type_name -> p2p.PeerList
+	4,  // 5: p2p.MessageData.transfer:type_name -> p2p.Transaction
+	6,  // 6: p2p.MessageData.handshake_response:type_name -> p2p.HandshakeResponse
+	11, // 7: p2p.MessageData.peer_req:type_name -> p2p.PeerRequest
+	12, // 8: p2p.MessageData.peer_res:type_name -> p2p.PeerResponse
+	3,  // 9: p2p.MessageData.content_announcement:type_name -> p2p.ContentAnnouncement
+	28, // 10: p2p.PeerList.peers:type_name -> p2p.PeerList.Peer
+	10, // 11: p2p.PeerResponse.peers:type_name -> p2p.PeerInfo
+	15, // 12: p2p.RPCResponse.error:type_name -> p2p.RPCError
+	13, // [13:13] is the sub-list for method output_type
+	13, // [13:13] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
 }
 
 func init() { file_internal_proto_message_proto_init() }
@@ -965,6 +1980,7 @@ func file_internal_proto_message_proto_init() {
 		(*MessageData_HandshakeResponse)(nil),
 		(*MessageData_PeerReq)(nil),
 		(*MessageData_PeerRes)(nil),
+		(*MessageData_ContentAnnouncement)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -972,7 +1988,7 @@ func file_internal_proto_message_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_proto_message_proto_rawDesc), len(file_internal_proto_message_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   13,
+			NumMessages:   29,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -1,34 +1,87 @@
 package content
 
 import (
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/network"
 	"github.com/DmytroBuzhylov/echofog-core/internal/p2p"
 	"github.com/DmytroBuzhylov/echofog-core/internal/p2p/dht"
+	"github.com/DmytroBuzhylov/echofog-core/internal/p2p/gossip"
 	internal_pb "github.com/DmytroBuzhylov/echofog-core/internal/proto"
 	"github.com/DmytroBuzhylov/echofog-core/internal/storage"
 	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/events"
+
+	"github.com/google/uuid"
 )
 
+// ContentService gossips the content this node shares and publishes a
+// ContentAnnounced event for what other peers share
 type ContentService struct {
-	db    storage.Storage
-	swarm *p2p.Swarm
-	dht   *dht.DHT
+	db       storage.Storage
+	swarm    *p2p.Swarm
+	dht      *dht.DHT
+	gsp      *gossip.Manager
+	myPubKey types.PeerPublicKey
+	events   *events.Bus
+	log      *slog.Logger
 }
 
 func (c *ContentService) GetSubscribedTypes() []interface{} {
-	//TODO implement me
-	panic("implement me")
+	return []interface{}{
+		(*internal_pb.MessageData_ContentAnnouncement)(nil),
+	}
+}
+
+func (c *ContentService) Handle(msg *internal_pb.MessageData, peerID types.PeerID) {
+	announcement := msg.Payload.(*internal_pb.MessageData_ContentAnnouncement).ContentAnnouncement
+
+	origin, err := types.ToPeerPublicKey(msg.GetOriginId())
+	if err != nil {
+		return
+	}
+	hash, err := types.ToContentHash(announcement.GetRootHash())
+	if err != nil {
+		c.log.Debug("Dropped content announcement", "from", hex.EncodeToString(origin[:]), "err", err)
+		return
+	}
+
+	c.events.Publish(events.ContentAnnounced{
+		Hash:   hash,
+		PeerID: types.PeerPubKeyToID(origin),
+		Size:   announcement.GetSize(),
+	})
 }
 
-func (c *ContentService) Handle(msg *internal_pb.MessageData, peerID types.PeerPublicKey) {
-	//TODO implement me
-	panic("implement me")
+// Announce gossips that this node shares the content, peers that are not
+// connected yet do not learn about it
+func (c *ContentService) Announce(hash types.ContentHash, size uint64) {
+	id := uuid.New()
+	c.gsp.Broadcast(network.TypeGossip, &internal_pb.MessageData{
+		MessageId: id[:],
+		OriginId:  c.myPubKey[:],
+		Timestamp: uint64(time.Now().UnixNano()),
+		HopLimit:  20,
+		Payload: &internal_pb.MessageData_ContentAnnouncement{
+			ContentAnnouncement: &internal_pb.ContentAnnouncement{
+				RootHash: hash[:],
+				Size:     size,
+			},
+		},
+	})
 }
 
-func NewContentService(db storage.Storage, swarm *p2p.Swarm, dht *dht.DHT) *ContentService {
+func NewContentService(db storage.Storage, swarm *p2p.Swarm, dht *dht.DHT, gsp *gossip.Manager, myPubKey types.PeerPublicKey, bus *events.Bus, log *slog.Logger) *ContentService {
 	return &ContentService{
-		db:    db,
-		swarm: swarm,
-		dht:   dht,
+		db:       db,
+		swarm:    swarm,
+		dht:      dht,
+		gsp:      gsp,
+		myPubKey: myPubKey,
+		events:   bus,
+		log:      log,
 	}
 }
 
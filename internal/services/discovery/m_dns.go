@@ -4,8 +4,7 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
-
-	"log"
+	"log/slog"
 	"strings"
 
 	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
@@ -28,13 +27,15 @@ type MDNSService struct {
 	myID   types.PeerID
 	port   int
 	notif  PeerNotifier
+	log    *slog.Logger
 }
 
-func NewMDNS(id types.PeerID, port int, notifier PeerNotifier) *MDNSService {
+func NewMDNS(id types.PeerID, port int, notifier PeerNotifier, log *slog.Logger) *MDNSService {
 	return &MDNSService{
 		myID:  id,
 		port:  port,
 		notif: notifier,
+		log:   log,
 	}
 }
 
@@ -63,7 +64,7 @@ func (s *MDNSService) Start(ctx context.Context) error {
 func (s *MDNSService) browseLoop(ctx context.Context) {
 	resolver, err := zeroconf.NewResolver(nil)
 	if err != nil {
-		log.Printf("[mDNS] Failed to initialize resolver: %v", err)
+		s.log.Warn("mDNS resolver failed to start", "err", err)
 		return
 	}
 
@@ -71,7 +72,7 @@ func (s *MDNSService) browseLoop(ctx context.Context) {
 
 	go func() {
 		if err := resolver.Browse(ctx, ServiceType, Domain, entries); err != nil {
-			log.Printf("[mDNS] Browse failed: %v", err)
+			s.log.Warn("mDNS browse failed", "err", err)
 		}
 	}()
 	for {
@@ -116,7 +117,7 @@ func (s *MDNSService) handleEntry(entry *zeroconf.ServiceEntry) {
 	} else {
 		return
 	}
-	log.Printf("[Discovery] Found peer %x at %s", remoteID[:4], bestAddr)
+	s.log.Debug("mDNS found peer", "peer_id", hex.EncodeToString(remoteID[:]), "addr", bestAddr)
 	s.notif.AddPotentialPeer(remoteID, bestAddr)
 }
 
@@ -1,6 +1,8 @@
 package discovery
 
 import (
+	"context"
+	"fmt"
 	"time"
 
 	"github.com/DmytroBuzhylov/echofog-core/internal/network"
@@ -11,8 +13,15 @@ import (
 	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
 
 	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
 )
 
+// PeersProtocol is the RPC protocol that returns random peers of the callee
+const PeersProtocol = "discovery/peers/1"
+
+// maxPeersPerRequest caps the count a caller may ask for
+const maxPeersPerRequest = 50
+
 type DiscoveryService struct {
 	storage  storage.Storage
 	gsp      *gossip.Manager
@@ -20,23 +29,66 @@ type DiscoveryService struct {
 	getter   *peerGetter
 	giver    *peerGiver
 	myPubKey types.PeerPublicKey
+	// selfAddrs returns the public addresses this node advertises
+	selfAddrs func() []string
 }
 
-func NewDiscoveryService(storage storage.Storage, gsp *gossip.Manager, swarm *p2p.Swarm, myPubKey types.PeerPublicKey) *DiscoveryService {
+func NewDiscoveryService(storage storage.Storage, gsp *gossip.Manager, swarm *p2p.Swarm, myPubKey types.PeerPublicKey, selfAddrs func() []string) *DiscoveryService {
 	ds := &DiscoveryService{
-		storage:  storage,
-		gsp:      gsp,
-		swarm:    swarm,
-		myPubKey: myPubKey,
+		storage:   storage,
+		gsp:       gsp,
+		swarm:     swarm,
+		myPubKey:  myPubKey,
+		selfAddrs: selfAddrs,
 	}
 	var getter = newPeerGetter(ds)
 	giver := newPeerGiver(ds)
 	ds.getter = getter
 	ds.giver = giver
 
+	swarm.RPC().Handle(PeersProtocol, &internal_pb.PeerRequest{}, ds.servePeers)
+
 	return ds
 }
 
+// RequestPeers asks peerID for up to count of its peers, peerID lists
+// itself first with the addresses it advertises
+func (d *DiscoveryService) RequestPeers(ctx context.Context, peerID types.PeerID, count uint32) ([]*internal_pb.PeerInfo, error) {
+	var resp internal_pb.PeerResponse
+	if err := d.swarm.RPC().Call(ctx, peerID, PeersProtocol, &internal_pb.PeerRequest{Count: count}, &resp); err != nil {
+		return nil, fmt.Errorf("request peers: %w", err)
+	}
+	return resp.Peers, nil
+}
+
+func (d *DiscoveryService) servePeers(ctx context.Context, peerID types.PeerID, req proto.Message) (proto.Message, error) {
+	count := min(req.(*internal_pb.PeerRequest).Count, maxPeersPerRequest)
+	return &internal_pb.PeerResponse{Peers: d.randomPeers(count)}, nil
+}
+
+// randomPeers answers peer exchange, this node comes first if it knows
+// addresses to advertise
+func (d *DiscoveryService) randomPeers(count uint32) []*internal_pb.PeerInfo {
+	peers := d.swarm.GetMyRandomPeers(uint(count))
+
+	peersInfo := make([]*internal_pb.PeerInfo, 0, len(peers)+1)
+	if addrs := d.selfAddrs(); len(addrs) > 0 {
+		peersInfo = append(peersInfo, &internal_pb.PeerInfo{
+			PubKey:  d.myPubKey[:],
+			Address: addrs[0],
+			Addrs:   addrs,
+		})
+	}
+	for _, p := range peers {
+		pubKey := p.PubKey()
+		peersInfo = append(peersInfo, &internal_pb.PeerInfo{
+			PubKey:  pubKey[:],
+			Address: p.Addr(),
+		})
+	}
+	return peersInfo
+}
+
 func (d *DiscoveryService) Handle(msg *internal_pb.MessageData, peerID types.PeerID) {
 	switch msg.Payload.(type) {
 	case *internal_pb.MessageData_PeerRes:
@@ -79,16 +131,7 @@ func (g *peerGiver) Handle(msg *internal_pb.MessageData, peerID types.PeerID) {
 		return
 	}
 
-	peers := g.service.swarm.GetMyRandomPeers(uint(msgPeer.PeerReq.Count))
-
-	peersInfo := make([]*internal_pb.PeerInfo, 0, len(peers))
-	for _, p := range peers {
-		pubKey := p.PubKey()
-		peersInfo = append(peersInfo, &internal_pb.PeerInfo{
-			PubKey:  pubKey[:],
-			Address: p.Addr(),
-		})
-	}
+	peersInfo := g.service.randomPeers(msgPeer.PeerReq.Count)
 
 	mesID := uuid.New()
 	peerResponse := &internal_pb.MessageData{
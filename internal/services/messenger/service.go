@@ -1,7 +1,11 @@
 package messenger
 
 import (
-	"log"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/DmytroBuzhylov/echofog-core/internal/crypto"
@@ -10,6 +14,7 @@ import (
 	internal_pb "github.com/DmytroBuzhylov/echofog-core/internal/proto"
 	"github.com/DmytroBuzhylov/echofog-core/internal/storage"
 	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/events"
 
 	"github.com/google/uuid"
 )
@@ -19,28 +24,45 @@ type MessageService struct {
 	storage      storage.Storage
 	gsp          *gossip.Manager
 	myPrivKey    types.PeerPrivateKey
+	events       *events.Bus
+	log          *slog.Logger
 }
 
-func NewMessageService(myPrivKey types.PeerPrivateKey, cryptoEngine *crypto.Engine, storage storage.Storage, gsp *gossip.Manager) *MessageService {
+func NewMessageService(myPrivKey types.PeerPrivateKey, cryptoEngine *crypto.Engine, storage storage.Storage, gsp *gossip.Manager, bus *events.Bus, log *slog.Logger) *MessageService {
 	return &MessageService{
 		cryptoEngine: cryptoEngine,
 		storage:      storage,
 		gsp:          gsp,
 		myPrivKey:    myPrivKey,
+		events:       bus,
+		log:          log,
 	}
 }
 
 func (s *MessageService) Handle(msg *internal_pb.MessageData, peerID types.PeerID) {
+	myPubKey := types.PeerPrivateKeyToPublic(s.myPrivKey)
+	if !bytes.Equal(msg.GetTargetId(), myPubKey[:]) {
+		// only passing through, the gossip manager forwards it
+		return
+	}
+
 	encryptedPayload := msg.Payload.(*internal_pb.MessageData_ChatMessage).ChatMessage.GetEncryptedPayload()
 
 	pubKey := types.PeerPublicKey(msg.GetOriginId())
 	data, err := s.cryptoEngine.Decrypt(encryptedPayload, pubKey)
 	if err != nil {
-		log.Println(err)
+		s.log.Warn("Failed to decrypt chat message", "from", hex.EncodeToString(pubKey[:]), "err", err)
 		return
 	}
 
-	log.Println("Decrypted data: ", string(data))
+	mesID, _ := types.ParseMessageID(msg.GetMessageId())
+	s.events.Publish(events.MessageReceived{
+		MessageID: mesID,
+		From:      pubKey,
+		ViaPeer:   peerID,
+		Text:      data,
+		SentAt:    time.Unix(0, int64(msg.GetTimestamp())),
+	})
 }
 
 func (s *MessageService) GetSubscribedTypes() []interface{} {
@@ -48,7 +70,10 @@ func (s *MessageService) GetSubscribedTypes() []interface{} {
 		(*internal_pb.MessageData_ChatMessage)(nil),
 	}
 }
-func (s *MessageService) Send(toPeerPubKey types.PeerPublicKey, data []byte) error {
+
+// Send encrypts data for toPeerPubKey and gossips it, it returns once the
+// message was written to the connections of the peers it was passed to
+func (s *MessageService) Send(ctx context.Context, toPeerPubKey types.PeerPublicKey, data []byte) error {
 	encryptData, err := s.cryptoEngine.Encrypt(data, toPeerPubKey)
 	if err != nil {
 		return err
@@ -57,7 +82,9 @@ func (s *MessageService) Send(toPeerPubKey types.PeerPublicKey, data []byte) err
 	pubKey := types.PeerPrivateKeyToPublic(s.myPrivKey)
 
 	chatMessage := s.PackChatMessage(encryptData, pubKey[:], toPeerPubKey[:])
-	s.gsp.Broadcast(network.TypeChatMessage, chatMessage)
+	if _, err := s.gsp.Publish(ctx, network.TypeChatMessage, chatMessage); err != nil {
+		return fmt.Errorf("send message: %w", err)
+	}
 	return nil
 }
 
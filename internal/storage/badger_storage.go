@@ -1,24 +1,43 @@
 package storage
 
 import (
+	"errors"
+	"sync"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
-	jsoniter "github.com/json-iterator/go"
 )
 
 type BadgerStorage struct {
 	db *badger.DB
+
+	stopGC    chan struct{}
+	gcDone    chan struct{}
+	closeOnce sync.Once
 }
 
 func NewBadgerStorage(db *badger.DB) *BadgerStorage {
 	bs := &BadgerStorage{
-		db: db,
+		db:     db,
+		stopGC: make(chan struct{}),
+		gcDone: make(chan struct{}),
 	}
 	bs.GCWorker()
 	return bs
 }
 
+// Close stops the GC worker, flushes pending writes to disk and releases the database lock
+func (s *BadgerStorage) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.stopGC)
+		<-s.gcDone
+
+		err = errors.Join(s.db.Sync(), s.db.Close())
+	})
+	return err
+}
+
 func (s *BadgerStorage) Update(key, value []byte) error {
 	return s.db.Update(func(txn *badger.Txn) error {
 		return txn.Set(key, value)
@@ -58,9 +77,15 @@ func (s *BadgerStorage) Delete(key []byte) error {
 
 func (s *BadgerStorage) GCWorker() {
 	go func() {
+		defer close(s.gcDone)
 		ticker := time.NewTicker(5 * time.Minute)
 		defer ticker.Stop()
-		for range ticker.C {
+		for {
+			select {
+			case <-ticker.C:
+			case <-s.stopGC:
+				return
+			}
 		again:
 			err := s.db.RunValueLogGC(0.5)
 			if err == nil {
@@ -89,10 +114,8 @@ func (s *BadgerStorage) FindValues(prefix []byte) ([]interface{}, error) {
 			item := it.Item()
 
 			err := item.Value(func(v []byte) error {
-				var val interface{}
-				if err := jsoniter.Unmarshal(v, &val); err != nil {
-					return err
-				}
+				val := make([]byte, len(v))
+				copy(val, v)
 				values = append(values, val)
 				return nil
 			})
@@ -105,6 +128,25 @@ func (s *BadgerStorage) FindValues(prefix []byte) ([]interface{}, error) {
 	return values, err
 }
 
+// PrefixSize counts the keys under prefix and the size of their values without reading them
+func (s *BadgerStorage) PrefixSize(prefix []byte) (keys int, size int64, err error) {
+	err = s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefix
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			keys++
+			size += it.Item().ValueSize()
+		}
+		return nil
+	})
+	return keys, size, err
+}
+
 func (s *BadgerStorage) Exists(key []byte) bool {
 	err := s.db.View(func(txn *badger.Txn) error {
 		_, err := txn.Get(key)
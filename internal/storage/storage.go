@@ -6,4 +6,6 @@ type Storage interface {
 	Delete(key []byte) error
 	FindValues(prefix []byte) ([]interface{}, error)
 	Exists(key []byte) bool
+	PrefixSize(prefix []byte) (keys int, size int64, err error)
+	Close() error
 }
@@ -0,0 +1,42 @@
+package control
+
+import (
+	"context"
+	"time"
+
+	api_pb "github.com/DmytroBuzhylov/echofog-core/pkg/api/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+type Client struct {
+	api_pb.ControlServiceClient
+	conn *grpc.ClientConn
+}
+
+// Dial connects to the control socket of a running node and checks that it answers
+func Dial(ctx context.Context, socketPath string) (*Client, error) {
+	conn, err := grpc.NewClient("unix://"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		ControlServiceClient: api_pb.NewControlServiceClient(conn),
+		conn:                 conn,
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if _, err := c.GetIdentity(pingCtx, &api_pb.GetIdentityRequest{}, grpc.WaitForReady(false)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,329 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	api_pb "github.com/DmytroBuzhylov/echofog-core/pkg/api/proto"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/events"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/node"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const fetchChunkSize = 64 * 1024
+
+// Server exposes a running node over gRPC, see control.proto
+type Server struct {
+	api_pb.UnimplementedControlServiceServer
+
+	node *node.Node
+	srv  *grpc.Server
+}
+
+func NewServer(n *node.Node) *Server {
+	s := &Server{
+		node: n,
+		srv:  grpc.NewServer(),
+	}
+	api_pb.RegisterControlServiceServer(s.srv, s)
+	return s
+}
+
+// ListenUnix listens on a Unix socket only accessible by the current user.
+// A stale socket file left by a crashed daemon is removed.
+func ListenUnix(path string) (net.Listener, error) {
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("control socket %s is already in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale control socket: %w", err)
+		}
+	}
+
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		lis.Close()
+		return nil, err
+	}
+	return lis, nil
+}
+
+func (s *Server) Serve(lis net.Listener) error {
+	return s.srv.Serve(lis)
+}
+
+// Stop waits for unary calls to finish until ctx expires, then drops open streams
+func (s *Server) Stop(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.srv.Stop()
+	}
+}
+
+func (s *Server) GetIdentity(ctx context.Context, req *api_pb.GetIdentityRequest) (*api_pb.GetIdentityResponse, error) {
+	return &api_pb.GetIdentityResponse{
+		PeerId:          s.node.ID[:],
+		PubKey:          s.node.PubKey[:],
+		Callsign:        s.node.Cfg.Identity.Callsign,
+		ListenAddrs:     s.node.ListenAddrs(),
+		ProtocolVersion: s.node.Cfg.ProtocolVersion(),
+		PublicAddrs:     s.node.PublicAddrs(),
+		NatMapping:      s.node.NAT().Mapping.String(),
+	}, nil
+}
+
+func (s *Server) ListPeers(ctx context.Context, req *api_pb.ListPeersRequest) (*api_pb.ListPeersResponse, error) {
+	peers := s.node.Peers()
+	if req.GetKnown() {
+		known, err := s.node.KnownPeers()
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		peers = known
+	}
+
+	res := &api_pb.ListPeersResponse{Peers: make([]*api_pb.Peer, 0, len(peers))}
+	for _, p := range peers {
+		res.Peers = append(res.Peers, peerToProto(p))
+	}
+	return res, nil
+}
+
+func (s *Server) ConnectPeer(ctx context.Context, req *api_pb.ConnectPeerRequest) (*api_pb.ConnectPeerResponse, error) {
+	if req.GetAddress() == "" {
+		return nil, status.Error(codes.InvalidArgument, "address is required")
+	}
+
+	peerID, err := s.node.Connect(ctx, req.GetAddress())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &api_pb.ConnectPeerResponse{PeerId: peerID[:]}, nil
+}
+
+func (s *Server) DisconnectPeer(ctx context.Context, req *api_pb.DisconnectPeerRequest) (*api_pb.DisconnectPeerResponse, error) {
+	peerID, err := types.ToPeerID(req.GetPeerId())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "peer_id: %v", err)
+	}
+
+	if err := s.node.Disconnect(peerID); err != nil {
+		return nil, toStatus(err)
+	}
+	return &api_pb.DisconnectPeerResponse{}, nil
+}
+
+func (s *Server) BanPeer(ctx context.Context, req *api_pb.BanPeerRequest) (*api_pb.BanPeerResponse, error) {
+	peerID, err := types.ToPeerID(req.GetPeerId())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "peer_id: %v", err)
+	}
+
+	if req.GetUnban() {
+		err = s.node.Unban(peerID)
+	} else {
+		err = s.node.Ban(peerID)
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &api_pb.BanPeerResponse{}, nil
+}
+
+func (s *Server) SendMessage(ctx context.Context, req *api_pb.SendMessageRequest) (*api_pb.SendMessageResponse, error) {
+	to, err := types.ToPeerPublicKey(req.GetToPubKey())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "to_pub_key: %v", err)
+	}
+	if len(req.GetText()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "text is required")
+	}
+
+	if err := s.node.SendMessage(ctx, to, req.GetText()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &api_pb.SendMessageResponse{}, nil
+}
+
+func (s *Server) ShareContent(ctx context.Context, req *api_pb.ShareContentRequest) (*api_pb.ShareContentResponse, error) {
+	if req.GetPath() == "" {
+		return nil, status.Error(codes.InvalidArgument, "path is required")
+	}
+
+	info, err := os.Stat(req.GetPath())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	hash, err := s.node.Share(req.GetPath())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &api_pb.ShareContentResponse{RootHash: hash[:], Size: uint64(info.Size())}, nil
+}
+
+func (s *Server) FetchContent(req *api_pb.FetchContentRequest, stream grpc.ServerStreamingServer[api_pb.ContentChunk]) error {
+	hash, err := types.ToContentHash(req.GetRootHash())
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "root_hash: %v", err)
+	}
+
+	data, err := s.node.Get(hash)
+	if err != nil {
+		return status.Errorf(codes.NotFound, "content not found: %v", err)
+	}
+
+	total := uint64(len(data))
+	offset := 0
+	for {
+		end := min(offset+fetchChunkSize, len(data))
+		err := stream.Send(&api_pb.ContentChunk{
+			Offset:    uint64(offset),
+			TotalSize: total,
+			Data:      data[offset:end],
+		})
+		if err != nil {
+			return err
+		}
+		if end == len(data) {
+			return nil
+		}
+		offset = end
+	}
+}
+
+func (s *Server) SubscribeEvents(req *api_pb.SubscribeEventsRequest, stream grpc.ServerStreamingServer[api_pb.Event]) error {
+	var filter events.Filter
+	if len(req.GetTypes()) > 0 {
+		wanted := make([]events.Type, 0, len(req.GetTypes()))
+		for _, t := range req.GetTypes() {
+			// EventType values mirror events.Type
+			wanted = append(wanted, events.Type(t))
+		}
+		filter = events.OfType(wanted...)
+	}
+
+	sub := s.node.Subscribe(int(req.GetBufferSize()), events.DropOldest, filter)
+	defer sub.Close()
+
+	for {
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.Unavailable, "node is shutting down")
+			}
+			msg := eventToProto(e)
+			if msg == nil {
+				continue
+			}
+			msg.Dropped = sub.Dropped()
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, node.ErrNotStarted), errors.Is(err, node.ErrNoPeers):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, node.ErrPeerNotConnected), errors.Is(err, os.ErrNotExist):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, node.ErrDisabledByRole):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func peerToProto(p node.PeerInfo) *api_pb.Peer {
+	res := &api_pb.Peer{
+		PeerId:          p.ID[:],
+		PubKey:          p.PubKey[:],
+		Address:         p.Addr,
+		Outbound:        p.Outbound,
+		ProtocolVersion: p.Version,
+		Capabilities:    uint64(p.Caps),
+		Agent:           p.Agent,
+		Role:            p.Role,
+		Callsign:        p.Callsign,
+	}
+	if !p.LastSeen.IsZero() {
+		res.LastSeen = uint64(p.LastSeen.UnixNano())
+	}
+	return res
+}
+
+func eventToProto(e events.Event) *api_pb.Event {
+	msg := &api_pb.Event{Time: uint64(time.Now().UnixNano())}
+
+	switch ev := e.(type) {
+	case events.PeerConnected:
+		msg.Payload = &api_pb.Event_PeerConnected{PeerConnected: &api_pb.PeerConnectedEvent{
+			Peer: &api_pb.Peer{
+				PeerId:   ev.PeerID[:],
+				PubKey:   ev.PubKey[:],
+				Address:  ev.Addr,
+				Outbound: ev.Outbound,
+			},
+		}}
+	case events.PeerDisconnected:
+		msg.Payload = &api_pb.Event_PeerDisconnected{PeerDisconnected: &api_pb.PeerDisconnectedEvent{
+			PeerId: ev.PeerID[:],
+			Reason: ev.Reason,
+		}}
+	case events.MessageReceived:
+		msg.Payload = &api_pb.Event_MessageReceived{MessageReceived: &api_pb.MessageReceivedEvent{
+			MessageId:  ev.MessageID[:],
+			FromPubKey: ev.From[:],
+			ViaPeerId:  ev.ViaPeer[:],
+			Text:       ev.Text,
+			SentAt:     uint64(ev.SentAt.UnixNano()),
+		}}
+	case events.ContentAnnounced:
+		msg.Payload = &api_pb.Event_ContentAnnounced{ContentAnnounced: &api_pb.ContentAnnouncedEvent{
+			RootHash: ev.Hash[:],
+			PeerId:   ev.PeerID[:],
+			Size:     ev.Size,
+		}}
+	case events.HolePunch:
+		msg.Payload = &api_pb.Event_HolePunch{HolePunch: &api_pb.HolePunchEvent{
+			PeerId:     ev.PeerID[:],
+			ViaPeerId:  ev.Via[:],
+			Address:    ev.Addr,
+			Success:    ev.Success,
+			Error:      ev.Error,
+			DurationMs: uint64(ev.Duration.Milliseconds()),
+		}}
+	default:
+		return nil
+	}
+	return msg
+}
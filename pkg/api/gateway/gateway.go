@@ -0,0 +1,145 @@
+package gateway
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/pkg/node"
+)
+
+const (
+	maxRequestBody = 1 << 20
+	connectTimeout = 30 * time.Second
+)
+
+// Server is the HTTP/JSON counterpart of the gRPC control service. Every
+// request must carry the token from `echofog init`, either as
+// "Authorization: Bearer <token>" or as the access_token query parameter
+// for clients like EventSource that cannot set headers.
+type Server struct {
+	node    *node.Node
+	token   string
+	srv     *http.Server
+	closing chan struct{}
+}
+
+func NewServer(n *node.Node, token string) *Server {
+	s := &Server{
+		node:    n,
+		token:   token,
+		closing: make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/identity", s.handleIdentity)
+	mux.HandleFunc("GET /v1/peers", s.handleListPeers)
+	mux.HandleFunc("POST /v1/peers", s.handleConnectPeer)
+	mux.HandleFunc("DELETE /v1/peers/{id}", s.handleDisconnectPeer)
+	mux.HandleFunc("PUT /v1/peers/{id}/ban", s.handleBanPeer)
+	mux.HandleFunc("DELETE /v1/peers/{id}/ban", s.handleUnbanPeer)
+	mux.HandleFunc("POST /v1/messages", s.handleSendMessage)
+	mux.HandleFunc("POST /v1/content", s.handleShareContent)
+	mux.HandleFunc("GET /v1/content/{hash}", s.handleFetchContent)
+	mux.HandleFunc("GET /v1/events", s.handleEvents)
+	mux.HandleFunc("GET /v1/logs", s.handleLogs)
+
+	s.srv = &http.Server{
+		Handler:           s.authenticate(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	// event streams never become idle, end them so Shutdown can complete
+	s.srv.RegisterOnShutdown(func() { close(s.closing) })
+
+	return s
+}
+
+// Listen only accepts loopback addresses, the gateway is not meant to be
+// exposed to the network
+func Listen(addr string) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if host != "localhost" {
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsLoopback() {
+			return nil, fmt.Errorf("gateway address %s is not a loopback address", addr)
+		}
+	}
+	return net.Listen("tcp", addr)
+}
+
+func (s *Server) Serve(lis net.Listener) error {
+	err := s.srv.Serve(lis)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Stop ends event streams and waits for other requests until ctx expires
+func (s *Server) Stop(ctx context.Context) error {
+	if err := s.srv.Shutdown(ctx); err != nil {
+		s.srv.Close()
+		return err
+	}
+	return nil
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			token = r.URL.Query().Get("access_token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="echofog"`)
+			writeError(w, http.StatusUnauthorized, errors.New("invalid or missing token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, errorResponse{Error: err.Error()})
+}
+
+// writeNodeError maps node errors the same way the control service does
+func writeNodeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, node.ErrNotStarted), errors.Is(err, node.ErrNoPeers):
+		code = http.StatusServiceUnavailable
+	case errors.Is(err, node.ErrPeerNotConnected), errors.Is(err, os.ErrNotExist):
+		code = http.StatusNotFound
+	case errors.Is(err, node.ErrDisabledByRole):
+		code = http.StatusConflict
+	case errors.Is(err, context.DeadlineExceeded):
+		code = http.StatusGatewayTimeout
+	}
+	writeError(w, code, err)
+}
@@ -0,0 +1,417 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/events"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/node"
+)
+
+// Binary values are hex encoded, like everywhere in the CLI
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type identityResponse struct {
+	PeerID          string   `json:"peer_id"`
+	PubKey          string   `json:"pub_key"`
+	Callsign        string   `json:"callsign,omitempty"`
+	ListenAddrs     []string `json:"listen_addrs"`
+	ProtocolVersion uint32   `json:"protocol_version"`
+	PublicAddrs     []string `json:"public_addrs"`
+	NATMapping      string   `json:"nat_mapping"`
+}
+
+type peerJSON struct {
+	PeerID   string     `json:"peer_id"`
+	PubKey   string     `json:"pub_key"`
+	Address  string     `json:"address,omitempty"`
+	Outbound bool       `json:"outbound"`
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+	// ProtocolVersion and Capabilities are only known for connected peers
+	ProtocolVersion uint32 `json:"protocol_version,omitempty"`
+	Capabilities    string `json:"capabilities,omitempty"`
+	// Agent, Role and Callsign are known once the peer identified itself
+	Agent    string `json:"agent,omitempty"`
+	Role     string `json:"role,omitempty"`
+	Callsign string `json:"callsign,omitempty"`
+}
+
+type connectRequest struct {
+	Address string `json:"address"`
+}
+
+type sendMessageRequest struct {
+	To   string `json:"to"`
+	Text string `json:"text"`
+}
+
+type shareRequest struct {
+	// Path is read by the node, so it must be accessible to it
+	Path string `json:"path"`
+}
+
+type shareResponse struct {
+	RootHash string `json:"root_hash"`
+	Size     int64  `json:"size"`
+}
+
+type eventJSON struct {
+	Type    string `json:"type"`
+	Time    string `json:"time"`
+	Dropped uint64 `json:"dropped"`
+	Data    any    `json:"data"`
+}
+
+func (s *Server) handleIdentity(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, identityResponse{
+		PeerID:          hex.EncodeToString(s.node.ID[:]),
+		PubKey:          hex.EncodeToString(s.node.PubKey[:]),
+		Callsign:        s.node.Cfg.Identity.Callsign,
+		ListenAddrs:     s.node.ListenAddrs(),
+		ProtocolVersion: s.node.Cfg.ProtocolVersion(),
+		PublicAddrs:     s.node.PublicAddrs(),
+		NATMapping:      s.node.NAT().Mapping.String(),
+	})
+}
+
+func (s *Server) handleListPeers(w http.ResponseWriter, r *http.Request) {
+	peers := s.node.Peers()
+	if known, _ := strconv.ParseBool(r.URL.Query().Get("known")); known {
+		var err error
+		if peers, err = s.node.KnownPeers(); err != nil {
+			writeNodeError(w, err)
+			return
+		}
+	}
+
+	res := make([]peerJSON, 0, len(peers))
+	for _, p := range peers {
+		res = append(res, toPeerJSON(p))
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) handleConnectPeer(w http.ResponseWriter, r *http.Request) {
+	var req connectRequest
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Address == "" {
+		writeError(w, http.StatusBadRequest, errors.New("address is required"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), connectTimeout)
+	defer cancel()
+
+	peerID, err := s.node.Connect(ctx, req.Address)
+	if err != nil {
+		writeNodeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"peer_id": hex.EncodeToString(peerID[:])})
+}
+
+func (s *Server) handleDisconnectPeer(w http.ResponseWriter, r *http.Request) {
+	peerID, err := types.ParsePeerID(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.node.Disconnect(peerID); err != nil {
+		writeNodeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleBanPeer(w http.ResponseWriter, r *http.Request) {
+	peerID, err := types.ParsePeerID(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.node.Ban(peerID); err != nil {
+		writeNodeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleUnbanPeer(w http.ResponseWriter, r *http.Request) {
+	peerID, err := types.ParsePeerID(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.node.Unban(peerID); err != nil {
+		writeNodeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleSendMessage(w http.ResponseWriter, r *http.Request) {
+	var req sendMessageRequest
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	to, err := types.ParsePeerPublicKey(req.To)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Text == "" {
+		writeError(w, http.StatusBadRequest, errors.New("text is required"))
+		return
+	}
+
+	if err := s.node.SendMessage(r.Context(), to, []byte(req.Text)); err != nil {
+		writeNodeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) handleShareContent(w http.ResponseWriter, r *http.Request) {
+	var req shareRequest
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Path == "" {
+		writeError(w, http.StatusBadRequest, errors.New("path is required"))
+		return
+	}
+
+	info, err := os.Stat(req.Path)
+	if err != nil {
+		writeNodeError(w, err)
+		return
+	}
+	hash, err := s.node.Share(req.Path)
+	if err != nil {
+		writeNodeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, shareResponse{
+		RootHash: hex.EncodeToString(hash[:]),
+		Size:     info.Size(),
+	})
+}
+
+// handleFetchContent serves the reassembled content from the local store,
+// it is not fetched from peers. http.ServeContent sets
+// Content-Length and answers Range and If-Range requests, the root hash is
+// used as a strong ETag since content is immutable.
+func (s *Server) handleFetchContent(w http.ResponseWriter, r *http.Request) {
+	hash, err := types.ParseContentHash(r.PathValue("hash"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	data, err := s.node.Get(hash)
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("content not found: %w", err))
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = hex.EncodeToString(hash[:])
+	}
+	w.Header().Set("ETag", `"`+hex.EncodeToString(hash[:])+`"`)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
+
+// handleEvents streams node events as Server-Sent Events, the event name is
+// the event type. ?types=peer_connected,message_received limits the stream.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	var filter events.Filter
+	if q := r.URL.Query().Get("types"); q != "" {
+		var wanted []events.Type
+		for _, name := range strings.Split(q, ",") {
+			t, ok := parseEventType(strings.TrimSpace(name))
+			if !ok {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("unknown event type %q", name))
+				return
+			}
+			wanted = append(wanted, t)
+		}
+		filter = events.OfType(wanted...)
+	}
+
+	sub := s.node.Subscribe(0, events.DropOldest, filter)
+	defer sub.Close()
+
+	flusher, ok := startStream(w)
+	if !ok {
+		return
+	}
+
+	for {
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			msg := eventJSON{
+				Type:    e.Type().String(),
+				Time:    time.Now().Format(time.RFC3339Nano),
+				Dropped: sub.Dropped(),
+				Data:    eventData(e),
+			}
+			if err := writeSSE(w, msg.Type, msg); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-s.closing:
+			return
+		}
+	}
+}
+
+// handleLogs streams the node log entries as Server-Sent Events
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	entries, unsubscribe := s.node.SubscribeLogs(0)
+	defer unsubscribe()
+
+	flusher, ok := startStream(w)
+	if !ok {
+		return
+	}
+
+	for {
+		select {
+		case entry, ok := <-entries:
+			if !ok {
+				return
+			}
+			if err := writeSSE(w, "log", entry); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-s.closing:
+			return
+		}
+	}
+}
+
+func startStream(w http.ResponseWriter) (http.Flusher, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return nil, false
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	return flusher, true
+}
+
+func writeSSE(w http.ResponseWriter, event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
+
+func parseEventType(name string) (events.Type, bool) {
+	for t := events.TypePeerConnected; t <= events.TypeHolePunch; t++ {
+		if t.String() == name && name != events.TypeUnknown.String() {
+			return t, true
+		}
+	}
+	return events.TypeUnknown, false
+}
+
+func toPeerJSON(p node.PeerInfo) peerJSON {
+	res := peerJSON{
+		PeerID:   hex.EncodeToString(p.ID[:]),
+		PubKey:   hex.EncodeToString(p.PubKey[:]),
+		Address:  p.Addr,
+		Outbound: p.Outbound,
+		Agent:    p.Agent,
+		Role:     p.Role,
+		Callsign: p.Callsign,
+	}
+	if !p.LastSeen.IsZero() {
+		res.LastSeen = &p.LastSeen
+	}
+	if p.Version != 0 {
+		res.ProtocolVersion = p.Version
+		res.Capabilities = p.Caps.String()
+	}
+	return res
+}
+
+func eventData(e events.Event) any {
+	switch ev := e.(type) {
+	case events.PeerConnected:
+		return peerJSON{
+			PeerID:   hex.EncodeToString(ev.PeerID[:]),
+			PubKey:   hex.EncodeToString(ev.PubKey[:]),
+			Address:  ev.Addr,
+			Outbound: ev.Outbound,
+		}
+	case events.PeerDisconnected:
+		return map[string]string{
+			"peer_id": hex.EncodeToString(ev.PeerID[:]),
+			"reason":  ev.Reason,
+		}
+	case events.MessageReceived:
+		return map[string]any{
+			"message_id":  hex.EncodeToString(ev.MessageID[:]),
+			"from":        hex.EncodeToString(ev.From[:]),
+			"via_peer_id": hex.EncodeToString(ev.ViaPeer[:]),
+			"text":        string(ev.Text),
+			"sent_at":     ev.SentAt,
+		}
+	case events.ContentAnnounced:
+		return map[string]any{
+			"root_hash": hex.EncodeToString(ev.Hash[:]),
+			"peer_id":   hex.EncodeToString(ev.PeerID[:]),
+			"size":      ev.Size,
+		}
+	case events.HolePunch:
+		return map[string]any{
+			"peer_id":     hex.EncodeToString(ev.PeerID[:]),
+			"via_peer_id": hex.EncodeToString(ev.Via[:]),
+			"address":     ev.Addr,
+			"success":     ev.Success,
+			"error":       ev.Error,
+			"duration_ms": ev.Duration.Milliseconds(),
+		}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,67 @@
+package gateway
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const tokenSize = 32
+
+var ErrNoToken = errors.New("gateway token not found, run `echofog init` first")
+
+// GenerateToken creates a random access token in path, readable only by the
+// current user. An existing token is kept and returned.
+func GenerateToken(path string) (string, error) {
+	token, err := LoadToken(path)
+	if err == nil || !errors.Is(err, ErrNoToken) {
+		return token, err
+	}
+
+	buf := make([]byte, tokenSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token = hex.EncodeToString(buf)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(token + "\n"); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func LoadToken(path string) (string, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNoToken
+	}
+	if err != nil {
+		return "", err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("token file %s is accessible by other users (mode %04o), run chmod 600", path, info.Mode().Perm())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+	return token, nil
+}
@@ -0,0 +1,1702 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: api/proto/control.proto
+
+package api_pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type EventType int32
+
+const (
+	EventType_EVENT_TYPE_UNSPECIFIED       EventType = 0
+	EventType_EVENT_TYPE_PEER_CONNECTED    EventType = 1
+	EventType_EVENT_TYPE_PEER_DISCONNECTED EventType = 2
+	EventType_EVENT_TYPE_MESSAGE_RECEIVED  EventType = 3
+	EventType_EVENT_TYPE_CONTENT_ANNOUNCED EventType = 5
+	EventType_EVENT_TYPE_HOLE_PUNCH        EventType = 6
+)
+
+// Enum value maps for EventType.
+var (
+	EventType_name = map[int32]string{
+		0: "EVENT_TYPE_UNSPECIFIED",
+		1: "EVENT_TYPE_PEER_CONNECTED",
+		2: "EVENT_TYPE_PEER_DISCONNECTED",
+		3: "EVENT_TYPE_MESSAGE_RECEIVED",
+		5: "EVENT_TYPE_CONTENT_ANNOUNCED",
+		6: "EVENT_TYPE_HOLE_PUNCH",
+	}
+	EventType_value = map[string]int32{
+		"EVENT_TYPE_UNSPECIFIED":       0,
+		"EVENT_TYPE_PEER_CONNECTED":    1,
+		"EVENT_TYPE_PEER_DISCONNECTED": 2,
+		"EVENT_TYPE_MESSAGE_RECEIVED":  3,
+		"EVENT_TYPE_CONTENT_ANNOUNCED": 5,
+		"EVENT_TYPE_HOLE_PUNCH":        6,
+	}
+)
+
+func (x EventType) Enum() *EventType {
+	p := new(EventType)
+	*p = x
+	return p
+}
+
+func (x EventType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (EventType) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_proto_control_proto_enumTypes[0].Descriptor()
+}
+
+func (EventType) Type() protoreflect.EnumType {
+	return &file_api_proto_control_proto_enumTypes[0]
+}
+
+func (x EventType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use EventType.Descriptor instead.
+func (EventType) EnumDescriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{0}
+}
+
+type GetIdentityRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetIdentityRequest) Reset() {
+	*x = GetIdentityRequest{}
+	mi := &file_api_proto_control_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetIdentityRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetIdentityRequest) ProtoMessage() {}
+
+func (x *GetIdentityRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetIdentityRequest.ProtoReflect.Descriptor instead.
+func (*GetIdentityRequest) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{0}
+}
+
+type GetIdentityResponse struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	PeerId          []byte                 `protobuf:"bytes,1,opt,name=peer_id,json=peerId,proto3" json:"peer_id,omitempty"`
+	PubKey          []byte                 `protobuf:"bytes,2,opt,name=pub_key,json=pubKey,proto3" json:"pub_key,omitempty"`
+	Callsign        string                 `protobuf:"bytes,3,opt,name=callsign,proto3" json:"callsign,omitempty"`
+	ListenAddrs     []string               `protobuf:"bytes,4,rep,name=listen_addrs,json=listenAddrs,proto3" json:"listen_addrs,omitempty"`
+	ProtocolVersion uint32                 `protobuf:"varint,5,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	// public_addrs are the addresses the node advertises to peers
+	PublicAddrs []string `protobuf:"bytes,6,rep,name=public_addrs,json=publicAddrs,proto3" json:"public_addrs,omitempty"`
+	// nat_mapping is none, endpoint-independent, endpoint-dependent or unknown
+	NatMapping    string `protobuf:"bytes,7,opt,name=nat_mapping,json=natMapping,proto3" json:"nat_mapping,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetIdentityResponse) Reset() {
+	*x = GetIdentityResponse{}
+	mi := &file_api_proto_control_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetIdentityResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetIdentityResponse) ProtoMessage() {}
+
+func (x *GetIdentityResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetIdentityResponse.ProtoReflect.Descriptor instead.
+func (*GetIdentityResponse) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetIdentityResponse) GetPeerId() []byte {
+	if x != nil {
+		return x.PeerId
+	}
+	return nil
+}
+
+func (x *GetIdentityResponse) GetPubKey() []byte {
+	if x != nil {
+		return x.PubKey
+	}
+	return nil
+}
+
+func (x *GetIdentityResponse) GetCallsign() string {
+	if x != nil {
+		return x.Callsign
+	}
+	return ""
+}
+
+func (x *GetIdentityResponse) GetListenAddrs() []string {
+	if x != nil {
+		return x.ListenAddrs
+	}
+	return nil
+}
+
+func (x *GetIdentityResponse) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *GetIdentityResponse) GetPublicAddrs() []string {
+	if x != nil {
+		return x.PublicAddrs
+	}
+	return nil
+}
+
+func (x *GetIdentityResponse) GetNatMapping() string {
+	if x != nil {
+		return x.NatMapping
+	}
+	return ""
+}
+
+type Peer struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	PeerId   []byte                 `protobuf:"bytes,1,opt,name=peer_id,json=peerId,proto3" json:"peer_id,omitempty"`
+	PubKey   []byte                 `protobuf:"bytes,2,opt,name=pub_key,json=pubKey,proto3" json:"pub_key,omitempty"`
+	Address  string                 `protobuf:"bytes,3,opt,name=address,proto3" json:"address,omitempty"`
+	Outbound bool                   `protobuf:"varint,4,opt,name=outbound,proto3" json:"outbound,omitempty"`
+	LastSeen uint64                 `protobuf:"varint,5,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`
+	// protocol_version and capabilities were negotiated with a connected peer
+	ProtocolVersion uint32 `protobuf:"varint,6,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	Capabilities    uint64 `protobuf:"varint,7,opt,name=capabilities,proto3" json:"capabilities,omitempty"`
+	// agent, role and callsign are what a connected peer told in identify
+	Agent         string `protobuf:"bytes,8,opt,name=agent,proto3" json:"agent,omitempty"`
+	Role          string `protobuf:"bytes,9,opt,name=role,proto3" json:"role,omitempty"`
+	Callsign      string `protobuf:"bytes,10,opt,name=callsign,proto3" json:"callsign,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Peer) Reset() {
+	*x = Peer{}
+	mi := &file_api_proto_control_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Peer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Peer) ProtoMessage() {}
+
+func (x *Peer) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Peer.ProtoReflect.Descriptor instead.
+func (*Peer) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Peer) GetPeerId() []byte {
+	if x != nil {
+		return x.PeerId
+	}
+	return nil
+}
+
+func (x *Peer) GetPubKey() []byte {
+	if x != nil {
+		return x.PubKey
+	}
+	return nil
+}
+
+func (x *Peer) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *Peer) GetOutbound() bool {
+	if x != nil {
+		return x.Outbound
+	}
+	return false
+}
+
+func (x *Peer) GetLastSeen() uint64 {
+	if x != nil {
+		return x.LastSeen
+	}
+	return 0
+}
+
+func (x *Peer) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *Peer) GetCapabilities() uint64 {
+	if x != nil {
+		return x.Capabilities
+	}
+	return 0
+}
+
+func (x *Peer) GetAgent() string {
+	if x != nil {
+		return x.Agent
+	}
+	return ""
+}
+
+func (x *Peer) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
+func (x *Peer) GetCallsign() string {
+	if x != nil {
+		return x.Callsign
+	}
+	return ""
+}
+
+type ListPeersRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// known also returns peers remembered from previous sessions
+	Known         bool `protobuf:"varint,1,opt,name=known,proto3" json:"known,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPeersRequest) Reset() {
+	*x = ListPeersRequest{}
+	mi := &file_api_proto_control_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPeersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPeersRequest) ProtoMessage() {}
+
+func (x *ListPeersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPeersRequest.ProtoReflect.Descriptor instead.
+func (*ListPeersRequest) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListPeersRequest) GetKnown() bool {
+	if x != nil {
+		return x.Known
+	}
+	return false
+}
+
+type ListPeersResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Peers         []*Peer                `protobuf:"bytes,1,rep,name=peers,proto3" json:"peers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPeersResponse) Reset() {
+	*x = ListPeersResponse{}
+	mi := &file_api_proto_control_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPeersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPeersResponse) ProtoMessage() {}
+
+func (x *ListPeersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPeersResponse.ProtoReflect.Descriptor instead.
+func (*ListPeersResponse) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListPeersResponse) GetPeers() []*Peer {
+	if x != nil {
+		return x.Peers
+	}
+	return nil
+}
+
+type ConnectPeerRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Address       string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConnectPeerRequest) Reset() {
+	*x = ConnectPeerRequest{}
+	mi := &file_api_proto_control_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConnectPeerRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConnectPeerRequest) ProtoMessage() {}
+
+func (x *ConnectPeerRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConnectPeerRequest.ProtoReflect.Descriptor instead.
+func (*ConnectPeerRequest) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ConnectPeerRequest) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+type ConnectPeerResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PeerId        []byte                 `protobuf:"bytes,1,opt,name=peer_id,json=peerId,proto3" json:"peer_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConnectPeerResponse) Reset() {
+	*x = ConnectPeerResponse{}
+	mi := &file_api_proto_control_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConnectPeerResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConnectPeerResponse) ProtoMessage() {}
+
+func (x *ConnectPeerResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConnectPeerResponse.ProtoReflect.Descriptor instead.
+func (*ConnectPeerResponse) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ConnectPeerResponse) GetPeerId() []byte {
+	if x != nil {
+		return x.PeerId
+	}
+	return nil
+}
+
+type DisconnectPeerRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PeerId        []byte                 `protobuf:"bytes,1,opt,name=peer_id,json=peerId,proto3" json:"peer_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DisconnectPeerRequest) Reset() {
+	*x = DisconnectPeerRequest{}
+	mi := &file_api_proto_control_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DisconnectPeerRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DisconnectPeerRequest) ProtoMessage() {}
+
+func (x *DisconnectPeerRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DisconnectPeerRequest.ProtoReflect.Descriptor instead.
+func (*DisconnectPeerRequest) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DisconnectPeerRequest) GetPeerId() []byte {
+	if x != nil {
+		return x.PeerId
+	}
+	return nil
+}
+
+type DisconnectPeerResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DisconnectPeerResponse) Reset() {
+	*x = DisconnectPeerResponse{}
+	mi := &file_api_proto_control_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DisconnectPeerResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DisconnectPeerResponse) ProtoMessage() {}
+
+func (x *DisconnectPeerResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DisconnectPeerResponse.ProtoReflect.Descriptor instead.
+func (*DisconnectPeerResponse) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{8}
+}
+
+type BanPeerRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PeerId        []byte                 `protobuf:"bytes,1,opt,name=peer_id,json=peerId,proto3" json:"peer_id,omitempty"`
+	Unban         bool                   `protobuf:"varint,2,opt,name=unban,proto3" json:"unban,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BanPeerRequest) Reset() {
+	*x = BanPeerRequest{}
+	mi := &file_api_proto_control_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BanPeerRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BanPeerRequest) ProtoMessage() {}
+
+func (x *BanPeerRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BanPeerRequest.ProtoReflect.Descriptor instead.
+func (*BanPeerRequest) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *BanPeerRequest) GetPeerId() []byte {
+	if x != nil {
+		return x.PeerId
+	}
+	return nil
+}
+
+func (x *BanPeerRequest) GetUnban() bool {
+	if x != nil {
+		return x.Unban
+	}
+	return false
+}
+
+type BanPeerResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BanPeerResponse) Reset() {
+	*x = BanPeerResponse{}
+	mi := &file_api_proto_control_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BanPeerResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BanPeerResponse) ProtoMessage() {}
+
+func (x *BanPeerResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BanPeerResponse.ProtoReflect.Descriptor instead.
+func (*BanPeerResponse) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{10}
+}
+
+type SendMessageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ToPubKey      []byte                 `protobuf:"bytes,1,opt,name=to_pub_key,json=toPubKey,proto3" json:"to_pub_key,omitempty"`
+	Text          []byte                 `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendMessageRequest) Reset() {
+	*x = SendMessageRequest{}
+	mi := &file_api_proto_control_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendMessageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendMessageRequest) ProtoMessage() {}
+
+func (x *SendMessageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendMessageRequest.ProtoReflect.Descriptor instead.
+func (*SendMessageRequest) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *SendMessageRequest) GetToPubKey() []byte {
+	if x != nil {
+		return x.ToPubKey
+	}
+	return nil
+}
+
+func (x *SendMessageRequest) GetText() []byte {
+	if x != nil {
+		return x.Text
+	}
+	return nil
+}
+
+type SendMessageResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendMessageResponse) Reset() {
+	*x = SendMessageResponse{}
+	mi := &file_api_proto_control_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendMessageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendMessageResponse) ProtoMessage() {}
+
+func (x *SendMessageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendMessageResponse.ProtoReflect.Descriptor instead.
+func (*SendMessageResponse) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{12}
+}
+
+type ShareContentRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// path is read by the daemon, so it must be accessible to it
+	Path          string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ShareContentRequest) Reset() {
+	*x = ShareContentRequest{}
+	mi := &file_api_proto_control_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ShareContentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShareContentRequest) ProtoMessage() {}
+
+func (x *ShareContentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShareContentRequest.ProtoReflect.Descriptor instead.
+func (*ShareContentRequest) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ShareContentRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type ShareContentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RootHash      []byte                 `protobuf:"bytes,1,opt,name=root_hash,json=rootHash,proto3" json:"root_hash,omitempty"`
+	Size          uint64                 `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ShareContentResponse) Reset() {
+	*x = ShareContentResponse{}
+	mi := &file_api_proto_control_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ShareContentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShareContentResponse) ProtoMessage() {}
+
+func (x *ShareContentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShareContentResponse.ProtoReflect.Descriptor instead.
+func (*ShareContentResponse) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ShareContentResponse) GetRootHash() []byte {
+	if x != nil {
+		return x.RootHash
+	}
+	return nil
+}
+
+func (x *ShareContentResponse) GetSize() uint64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+type FetchContentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RootHash      []byte                 `protobuf:"bytes,1,opt,name=root_hash,json=rootHash,proto3" json:"root_hash,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FetchContentRequest) Reset() {
+	*x = FetchContentRequest{}
+	mi := &file_api_proto_control_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FetchContentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FetchContentRequest) ProtoMessage() {}
+
+func (x *FetchContentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FetchContentRequest.ProtoReflect.Descriptor instead.
+func (*FetchContentRequest) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *FetchContentRequest) GetRootHash() []byte {
+	if x != nil {
+		return x.RootHash
+	}
+	return nil
+}
+
+type ContentChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Offset        uint64                 `protobuf:"varint,1,opt,name=offset,proto3" json:"offset,omitempty"`
+	TotalSize     uint64                 `protobuf:"varint,2,opt,name=total_size,json=totalSize,proto3" json:"total_size,omitempty"`
+	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ContentChunk) Reset() {
+	*x = ContentChunk{}
+	mi := &file_api_proto_control_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ContentChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ContentChunk) ProtoMessage() {}
+
+func (x *ContentChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ContentChunk.ProtoReflect.Descriptor instead.
+func (*ContentChunk) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ContentChunk) GetOffset() uint64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *ContentChunk) GetTotalSize() uint64 {
+	if x != nil {
+		return x.TotalSize
+	}
+	return 0
+}
+
+func (x *ContentChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type SubscribeEventsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// empty means all event types
+	Types         []EventType `protobuf:"varint,1,rep,packed,name=types,proto3,enum=api_pb.EventType" json:"types,omitempty"`
+	BufferSize    uint32      `protobuf:"varint,2,opt,name=buffer_size,json=bufferSize,proto3" json:"buffer_size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeEventsRequest) Reset() {
+	*x = SubscribeEventsRequest{}
+	mi := &file_api_proto_control_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeEventsRequest) ProtoMessage() {}
+
+func (x *SubscribeEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeEventsRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeEventsRequest) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *SubscribeEventsRequest) GetTypes() []EventType {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+func (x *SubscribeEventsRequest) GetBufferSize() uint32 {
+	if x != nil {
+		return x.BufferSize
+	}
+	return 0
+}
+
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Time  uint64                 `protobuf:"varint,1,opt,name=time,proto3" json:"time,omitempty"`
+	// dropped is the number of events lost so far because this stream was too slow
+	Dropped uint64 `protobuf:"varint,2,opt,name=dropped,proto3" json:"dropped,omitempty"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*Event_PeerConnected
+	//	*Event_PeerDisconnected
+	//	*Event_MessageReceived
+	//	*Event_ContentAnnounced
+	//	*Event_HolePunch
+	Payload       isEvent_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_api_proto_control_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *Event) GetTime() uint64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *Event) GetDropped() uint64 {
+	if x != nil {
+		return x.Dropped
+	}
+	return 0
+}
+
+func (x *Event) GetPayload() isEvent_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Event) GetPeerConnected() *PeerConnectedEvent {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_PeerConnected); ok {
+			return x.PeerConnected
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetPeerDisconnected() *PeerDisconnectedEvent {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_PeerDisconnected); ok {
+			return x.PeerDisconnected
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetMessageReceived() *MessageReceivedEvent {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_MessageReceived); ok {
+			return x.MessageReceived
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetContentAnnounced() *ContentAnnouncedEvent {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_ContentAnnounced); ok {
+			return x.ContentAnnounced
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetHolePunch() *HolePunchEvent {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_HolePunch); ok {
+			return x.HolePunch
+		}
+	}
+	return nil
+}
+
+type isEvent_Payload interface {
+	isEvent_Payload()
+}
+
+type Event_PeerConnected struct {
+	PeerConnected *PeerConnectedEvent `protobuf:"bytes,10,opt,name=peer_connected,json=peerConnected,proto3,oneof"`
+}
+
+type Event_PeerDisconnected struct {
+	PeerDisconnected *PeerDisconnectedEvent `protobuf:"bytes,11,opt,name=peer_disconnected,json=peerDisconnected,proto3,oneof"`
+}
+
+type Event_MessageReceived struct {
+	MessageReceived *MessageReceivedEvent `protobuf:"bytes,12,opt,name=message_received,json=messageReceived,proto3,oneof"`
+}
+
+type Event_ContentAnnounced struct {
+	ContentAnnounced *ContentAnnouncedEvent `protobuf:"bytes,14,opt,name=content_announced,json=contentAnnounced,proto3,oneof"`
+}
+
+type Event_HolePunch struct {
+	HolePunch *HolePunchEvent `protobuf:"bytes,15,opt,name=hole_punch,json=holePunch,proto3,oneof"`
+}
+
+func (*Event_PeerConnected) isEvent_Payload() {}
+
+func (*Event_PeerDisconnected) isEvent_Payload() {}
+
+func (*Event_MessageReceived) isEvent_Payload() {}
+
+func (*Event_ContentAnnounced) isEvent_Payload() {}
+
+func (*Event_HolePunch) isEvent_Payload() {}
+
+type PeerConnectedEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Peer          *Peer                  `protobuf:"bytes,1,opt,name=peer,proto3" json:"peer,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PeerConnectedEvent) Reset() {
+	*x = PeerConnectedEvent{}
+	mi := &file_api_proto_control_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PeerConnectedEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerConnectedEvent) ProtoMessage() {}
+
+func (x *PeerConnectedEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerConnectedEvent.ProtoReflect.Descriptor instead.
+func (*PeerConnectedEvent) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *PeerConnectedEvent) GetPeer() *Peer {
+	if x != nil {
+		return x.Peer
+	}
+	return nil
+}
+
+type PeerDisconnectedEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PeerId        []byte                 `protobuf:"bytes,1,opt,name=peer_id,json=peerId,proto3" json:"peer_id,omitempty"`
+	Reason        string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PeerDisconnectedEvent) Reset() {
+	*x = PeerDisconnectedEvent{}
+	mi := &file_api_proto_control_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PeerDisconnectedEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerDisconnectedEvent) ProtoMessage() {}
+
+func (x *PeerDisconnectedEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerDisconnectedEvent.ProtoReflect.Descriptor instead.
+func (*PeerDisconnectedEvent) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *PeerDisconnectedEvent) GetPeerId() []byte {
+	if x != nil {
+		return x.PeerId
+	}
+	return nil
+}
+
+func (x *PeerDisconnectedEvent) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type MessageReceivedEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     []byte                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	FromPubKey    []byte                 `protobuf:"bytes,2,opt,name=from_pub_key,json=fromPubKey,proto3" json:"from_pub_key,omitempty"`
+	ViaPeerId     []byte                 `protobuf:"bytes,3,opt,name=via_peer_id,json=viaPeerId,proto3" json:"via_peer_id,omitempty"`
+	Text          []byte                 `protobuf:"bytes,4,opt,name=text,proto3" json:"text,omitempty"`
+	SentAt        uint64                 `protobuf:"varint,5,opt,name=sent_at,json=sentAt,proto3" json:"sent_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MessageReceivedEvent) Reset() {
+	*x = MessageReceivedEvent{}
+	mi := &file_api_proto_control_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MessageReceivedEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageReceivedEvent) ProtoMessage() {}
+
+func (x *MessageReceivedEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageReceivedEvent.ProtoReflect.Descriptor instead.
+func (*MessageReceivedEvent) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *MessageReceivedEvent) GetMessageId() []byte {
+	if x != nil {
+		return x.MessageId
+	}
+	return nil
+}
+
+func (x *MessageReceivedEvent) GetFromPubKey() []byte {
+	if x != nil {
+		return x.FromPubKey
+	}
+	return nil
+}
+
+func (x *MessageReceivedEvent) GetViaPeerId() []byte {
+	if x != nil {
+		return x.ViaPeerId
+	}
+	return nil
+}
+
+func (x *MessageReceivedEvent) GetText() []byte {
+	if x != nil {
+		return x.Text
+	}
+	return nil
+}
+
+func (x *MessageReceivedEvent) GetSentAt() uint64 {
+	if x != nil {
+		return x.SentAt
+	}
+	return 0
+}
+
+type ContentAnnouncedEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RootHash      []byte                 `protobuf:"bytes,1,opt,name=root_hash,json=rootHash,proto3" json:"root_hash,omitempty"`
+	PeerId        []byte                 `protobuf:"bytes,2,opt,name=peer_id,json=peerId,proto3" json:"peer_id,omitempty"`
+	Size          uint64                 `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ContentAnnouncedEvent) Reset() {
+	*x = ContentAnnouncedEvent{}
+	mi := &file_api_proto_control_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ContentAnnouncedEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ContentAnnouncedEvent) ProtoMessage() {}
+
+func (x *ContentAnnouncedEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ContentAnnouncedEvent.ProtoReflect.Descriptor instead.
+func (*ContentAnnouncedEvent) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *ContentAnnouncedEvent) GetRootHash() []byte {
+	if x != nil {
+		return x.RootHash
+	}
+	return nil
+}
+
+func (x *ContentAnnouncedEvent) GetPeerId() []byte {
+	if x != nil {
+		return x.PeerId
+	}
+	return nil
+}
+
+func (x *ContentAnnouncedEvent) GetSize() uint64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+type HolePunchEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PeerId        []byte                 `protobuf:"bytes,1,opt,name=peer_id,json=peerId,proto3" json:"peer_id,omitempty"`
+	ViaPeerId     []byte                 `protobuf:"bytes,2,opt,name=via_peer_id,json=viaPeerId,proto3" json:"via_peer_id,omitempty"`
+	Address       string                 `protobuf:"bytes,3,opt,name=address,proto3" json:"address,omitempty"`
+	Success       bool                   `protobuf:"varint,4,opt,name=success,proto3" json:"success,omitempty"`
+	Error         string                 `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
+	DurationMs    uint64                 `protobuf:"varint,6,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HolePunchEvent) Reset() {
+	*x = HolePunchEvent{}
+	mi := &file_api_proto_control_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HolePunchEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HolePunchEvent) ProtoMessage() {}
+
+func (x *HolePunchEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_control_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HolePunchEvent.ProtoReflect.Descriptor instead.
+func (*HolePunchEvent) Descriptor() ([]byte, []int) {
+	return file_api_proto_control_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *HolePunchEvent) GetPeerId() []byte {
+	if x != nil {
+		return x.PeerId
+	}
+	return nil
+}
+
+func (x *HolePunchEvent) GetViaPeerId() []byte {
+	if x != nil {
+		return x.ViaPeerId
+	}
+	return nil
+}
+
+func (x *HolePunchEvent) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *HolePunchEvent) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *HolePunchEvent) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *HolePunchEvent) GetDurationMs() uint64 {
+	if x != nil {
+		return x.DurationMs
+	}
+	return 0
+}
+
+var File_api_proto_control_proto protoreflect.FileDescriptor
+
+const file_api_proto_control_proto_rawDesc = "" +
+	"\n" +
+	"\x17api/proto/control.proto\x12\x06api_pb\"\x14\n" +
+	"\x12GetIdentityRequest\"\xf5\x01\n" +
+	"\x13GetIdentityResponse\x12\x17\n" +
+	"\apeer_id\x18\x01 \x01(\fR\x06peerId\x12\x17\n" +
+	"\apub_key\x18\x02 \x01(\fR\x06pubKey\x12\x1a\n" +
+	"\bcallsign\x18\x03 \x01(\tR\bcallsign\x12!\n" +
+	"\flisten_addrs\x18\x04 \x03(\tR\vlistenAddrs\x12)\n" +
+	"\x10protocol_version\x18\x05 \x01(\rR\x0fprotocolVersion\x12!\n" +
+	"\fpublic_addrs\x18\x06 \x03(\tR\vpublicAddrs\x12\x1f\n" +
+	"\vnat_mapping\x18\a \x01(\tR\n" +
+	"natMapping\"\xa0\x02\n" +
+	"\x04Peer\x12\x17\n" +
+	"\apeer_id\x18\x01 \x01(\fR\x06peerId\x12\x17\n" +
+	"\apub_key\x18\x02 \x01(\fR\x06pubKey\x12\x18\n" +
+	"\aaddress\x18\x03 \x01(\tR\aaddress\x12\x1a\n" +
+	"\boutbound\x18\x04 \x01(\bR\boutbound\x12\x1b\n" +
+	"\tlast_seen\x18\x05 \x01(\x04R\blastSeen\x12)\n" +
+	"\x10protocol_version\x18\x06 \x01(\rR\x0fprotocolVersion\x12\"\n" +
+	"\fcapabilities\x18\a \x01(\x04R\fcapabilities\x12\x14\n" +
+	"\x05agent\x18\b \x01(\tR\x05agent\x12\x12\n" +
+	"\x04role\x18\t \x01(\tR\x04role\x12\x1a\n" +
+	"\bcallsign\x18\n" +
+	" \x01(\tR\bcallsign\"(\n" +
+	"\x10ListPeersRequest\x12\x14\n" +
+	"\x05known\x18\x01 \x01(\bR\x05known\"7\n" +
+	"\x11ListPeersResponse\x12\"\n" +
+	"\x05peers\x18\x01 \x03(\v2\f.api_pb.PeerR\x05peers\".\n" +
+	"\x12ConnectPeerRequest\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\".\n" +
+	"\x13ConnectPeerResponse\x12\x17\n" +
+	"\apeer_id\x18\x01 \x01(\fR\x06peerId\"0\n" +
+	"\x15DisconnectPeerRequest\x12\x17\n" +
+	"\apeer_id\x18\x01 \x01(\fR\x06peerId\"\x18\n" +
+	"\x16DisconnectPeerResponse\"?\n" +
+	"\x0eBanPeerRequest\x12\x17\n" +
+	"\apeer_id\x18\x01 \x01(\fR\x06peerId\x12\x14\n" +
+	"\x05unban\x18\x02 \x01(\bR\x05unban\"\x11\n" +
+	"\x0fBanPeerResponse\"F\n" +
+	"\x12SendMessageRequest\x12\x1c\n" +
+	"\n" +
+	"to_pub_key\x18\x01 \x01(\fR\btoPubKey\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\fR\x04text\"\x15\n" +
+	"\x13SendMessageResponse\")\n" +
+	"\x13ShareContentRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\"G\n" +
+	"\x14ShareContentResponse\x12\x1b\n" +
+	"\troot_hash\x18\x01 \x01(\fR\brootHash\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x04R\x04size\"2\n" +
+	"\x13FetchContentRequest\x12\x1b\n" +
+	"\troot_hash\x18\x01 \x01(\fR\brootHash\"Y\n" +
+	"\fContentChunk\x12\x16\n" +
+	"\x06offset\x18\x01 \x01(\x04R\x06offset\x12\x1d\n" +
+	"\n" +
+	"total_size\x18\x02 \x01(\x04R\ttotalSize\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\"b\n" +
+	"\x16SubscribeEventsRequest\x12'\n" +
+	"\x05types\x18\x01 \x03(\x0e2\x11.api_pb.EventTypeR\x05types\x12\x1f\n" +
+	"\vbuffer_size\x18\x02 \x01(\rR\n" +
+	"bufferSize\"\xbe\x03\n" +
+	"\x05Event\x12\x12\n" +
+	"\x04time\x18\x01 \x01(\x04R\x04time\x12\x18\n" +
+	"\adropped\x18\x02 \x01(\x04R\adropped\x12C\n" +
+	"\x0epeer_connected\x18\n" +
+	" \x01(\v2\x1a.api_pb.PeerConnectedEventH\x00R\rpeerConnected\x12L\n" +
+	"\x11peer_disconnected\x18\v \x01(\v2\x1d.api_pb.PeerDisconnectedEventH\x00R\x10peerDisconnected\x12I\n" +
+	"\x10message_received\x18\f \x01(\v2\x1c.api_pb.MessageReceivedEventH\x00R\x0fmessageReceived\x12L\n" +
+	"\x11content_announced\x18\x0e \x01(\v2\x1d.api_pb.ContentAnnouncedEventH\x00R\x10contentAnnounced\x127\n" +
+	"\n" +
+	"hole_punch\x18\x0f \x01(\v2\x16.api_pb.HolePunchEventH\x00R\tholePunchB\t\n" +
+	"\apayloadJ\x04\b\r\x10\x0eR\x11download_progress\"6\n" +
+	"\x12PeerConnectedEvent\x12 \n" +
+	"\x04peer\x18\x01 \x01(\v2\f.api_pb.PeerR\x04peer\"H\n" +
+	"\x15PeerDisconnectedEvent\x12\x17\n" +
+	"\apeer_id\x18\x01 \x01(\fR\x06peerId\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\"\xa4\x01\n" +
+	"\x14MessageReceivedEvent\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\fR\tmessageId\x12 \n" +
+	"\ffrom_pub_key\x18\x02 \x01(\fR\n" +
+	"fromPubKey\x12\x1e\n" +
+	"\vvia_peer_id\x18\x03 \x01(\fR\tviaPeerId\x12\x12\n" +
+	"\x04text\x18\x04 \x01(\fR\x04text\x12\x17\n" +
+	"\asent_at\x18\x05 \x01(\x04R\x06sentAt\"a\n" +
+	"\x15ContentAnnouncedEvent\x12\x1b\n" +
+	"\troot_hash\x18\x01 \x01(\fR\brootHash\x12\x17\n" +
+	"\apeer_id\x18\x02 \x01(\fR\x06peerId\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x04R\x04size\"\xb4\x01\n" +
+	"\x0eHolePunchEvent\x12\x17\n" +
+	"\apeer_id\x18\x01 \x01(\fR\x06peerId\x12\x1e\n" +
+	"\vvia_peer_id\x18\x02 \x01(\fR\tviaPeerId\x12\x18\n" +
+	"\aaddress\x18\x03 \x01(\tR\aaddress\x12\x18\n" +
+	"\asuccess\x18\x04 \x01(\bR\asuccess\x12\x14\n" +
+	"\x05error\x18\x05 \x01(\tR\x05error\x12\x1f\n" +
+	"\vduration_ms\x18\x06 \x01(\x04R\n" +
+	"durationMs*\xea\x01\n" +
+	"\tEventType\x12\x1a\n" +
+	"\x16EVENT_TYPE_UNSPECIFIED\x10\x00\x12\x1d\n" +
+	"\x19EVENT_TYPE_PEER_CONNECTED\x10\x01\x12 \n" +
+	"\x1cEVENT_TYPE_PEER_DISCONNECTED\x10\x02\x12\x1f\n" +
+	"\x1bEVENT_TYPE_MESSAGE_RECEIVED\x10\x03\x12 \n" +
+	"\x1cEVENT_TYPE_CONTENT_ANNOUNCED\x10\x05\x12\x19\n" +
+	"\x15EVENT_TYPE_HOLE_PUNCH\x10\x06\"\x04\b\x04\x10\x04*\x1cEVENT_TYPE_DOWNLOAD_PROGRESS2\x8b\x05\n" +
+	"\x0eControlService\x12F\n" +
+	"\vGetIdentity\x12\x1a.api_pb.GetIdentityRequest\x1a\x1b.api_pb.GetIdentityResponse\x12@\n" +
+	"\tListPeers\x12\x18.api_pb.ListPeersRequest\x1a\x19.api_pb.ListPeersResponse\x12F\n" +
+	"\vConnectPeer\x12\x1a.api_pb.ConnectPeerRequest\x1a\x1b.api_pb.ConnectPeerResponse\x12O\n" +
+	"\x0eDisconnectPeer\x12\x1d.api_pb.DisconnectPeerRequest\x1a\x1e.api_pb.DisconnectPeerResponse\x12:\n" +
+	"\aBanPeer\x12\x16.api_pb.BanPeerRequest\x1a\x17.api_pb.BanPeerResponse\x12F\n" +
+	"\vSendMessage\x12\x1a.api_pb.SendMessageRequest\x1a\x1b.api_pb.SendMessageResponse\x12I\n" +
+	"\fShareContent\x12\x1b.api_pb.ShareContentRequest\x1a\x1c.api_pb.ShareContentResponse\x12C\n" +
+	"\fFetchContent\x12\x1b.api_pb.FetchContentRequest\x1a\x14.api_pb.ContentChunk0\x01\x12B\n" +
+	"\x0fSubscribeEvents\x12\x1e.api_pb.SubscribeEventsRequest\x1a\r.api_pb.Event0\x01B9Z7github.com/DmytroBuzhylov/echofog-core/api/proto;api_pbb\x06proto3"
+
+var (
+	file_api_proto_control_proto_rawDescOnce sync.Once
+	file_api_proto_control_proto_rawDescData []byte
+)
+
+func file_api_proto_control_proto_rawDescGZIP() []byte {
+	file_api_proto_control_proto_rawDescOnce.Do(func() {
+		file_api_proto_control_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_proto_control_proto_rawDesc), len(file_api_proto_control_proto_rawDesc)))
+	})
+	return file_api_proto_control_proto_rawDescData
+}
+
+var file_api_proto_control_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_api_proto_control_proto_msgTypes = make([]protoimpl.MessageInfo, 24)
+var file_api_proto_control_proto_goTypes = []any{
+	(EventType)(0),                 // 0: api_pb.EventType
+	(*GetIdentityRequest)(nil),     // 1: api_pb.GetIdentityRequest
+	(*GetIdentityResponse)(nil),    // 2: api_pb.GetIdentityResponse
+	(*Peer)(nil),                   // 3: api_pb.Peer
+	(*ListPeersRequest)(nil),       // 4: api_pb.ListPeersRequest
+	(*ListPeersResponse)(nil),      // 5: api_pb.ListPeersResponse
+	(*ConnectPeerRequest)(nil),     // 6: api_pb.ConnectPeerRequest
+	(*ConnectPeerResponse)(nil),    // 7: api_pb.ConnectPeerResponse
+	(*DisconnectPeerRequest)(nil),  // 8: api_pb.DisconnectPeerRequest
+	(*DisconnectPeerResponse)(nil), // 9: api_pb.DisconnectPeerResponse
+	(*BanPeerRequest)(nil),         // 10: api_pb.BanPeerRequest
+	(*BanPeerResponse)(nil),        // 11: api_pb.BanPeerResponse
+	(*SendMessageRequest)(nil),     // 12: api_pb.SendMessageRequest
+	(*SendMessageResponse)(nil),    // 13: api_pb.SendMessageResponse
+	(*ShareContentRequest)(nil),    // 14: api_pb.ShareContentRequest
+	(*ShareContentResponse)(nil),   // 15: api_pb.ShareContentResponse
+	(*FetchContentRequest)(nil),    // 16: api_pb.FetchContentRequest
+	(*ContentChunk)(nil),           // 17: api_pb.ContentChunk
+	(*SubscribeEventsRequest)(nil), // 18: api_pb.SubscribeEventsRequest
+	(*Event)(nil),                  // 19: api_pb.Event
+	(*PeerConnectedEvent)(nil),     // 20: api_pb.PeerConnectedEvent
+	(*PeerDisconnectedEvent)(nil),  // 21: api_pb.PeerDisconnectedEvent
+	(*MessageReceivedEvent)(nil),   // 22: api_pb.MessageReceivedEvent
+	(*ContentAnnouncedEvent)(nil),  // 23: api_pb.ContentAnnouncedEvent
+	(*HolePunchEvent)(nil),         // 24: api_pb.HolePunchEvent
+}
+var file_api_proto_control_proto_depIdxs = []int32{
+	3,  // 0: api_pb.ListPeersResponse.peers:type_name -> api_pb.Peer
+	0,  // 1: api_pb.SubscribeEventsRequest.types:type_name -> api_pb.EventType
+	20, // 2: api_pb.Event.peer_connected:type_name -> api_pb.PeerConnectedEvent
+	21, // 3: api_pb.Event.peer_disconnected:type_name -> api_pb.PeerDisconnectedEvent
+	22, // 4: api_pb.Event.message_received:type_name -> api_pb.MessageReceivedEvent
+	23, // 5: api_pb.Event.content_announced:type_name -> api_pb.ContentAnnouncedEvent
+	24, // 6: api_pb.Event.hole_punch:type_name -> api_pb.HolePunchEvent
+	3,  // 7: api_pb.PeerConnectedEvent.peer:type_name -> api_pb.Peer
+	1,  // 8: api_pb.ControlService.GetIdentity:input_type -> api_pb.GetIdentityRequest
+	4,  // 9: api_pb.ControlService.ListPeers:input_type -> api_pb.ListPeersRequest
+	6,  // 10: api_pb.ControlService.ConnectPeer:input_type -> api_pb.ConnectPeerRequest
+	8,  // 11: api_pb.ControlService.DisconnectPeer:input_type -> api_pb.DisconnectPeerRequest
+	10, // 12: api_pb.ControlService.BanPeer:input_type -> api_pb.BanPeerRequest
+	12, // 13: api_pb.ControlService.SendMessage:input_type -> api_pb.SendMessageRequest
+	14, // 14: api_pb.ControlService.ShareContent:input_type -> api_pb.ShareContentRequest
+	16, // 15: api_pb.ControlService.FetchContent:input_type -> api_pb.FetchContentRequest
+	18, // 16: api_pb.ControlService.SubscribeEvents:input_type -> api_pb.SubscribeEventsRequest
+	2,  // 17: api_pb.ControlService.GetIdentity:output_type -> api_pb.GetIdentityResponse
+	5,  // 18: api_pb.ControlService.ListPeers:output_type -> api_pb.ListPeersResponse
+	7,  // 19: api_pb.ControlService.ConnectPeer:output_type -> api_pb.ConnectPeerResponse
+	9,  // 20: api_pb.ControlService.DisconnectPeer:output_type -> api_pb.DisconnectPeerResponse
+	11, // 21: api_pb.ControlService.BanPeer:output_type -> api_pb.BanPeerResponse
+	13, // 22: api_pb.ControlService.SendMessage:output_type -> api_pb.SendMessageResponse
+	15, // 23: api_pb.ControlService.ShareContent:output_type -> api_pb.ShareContentResponse
+	17, // 24: api_pb.ControlService.FetchContent:output_type -> api_pb.ContentChunk
+	19, // 25: api_pb.ControlService.SubscribeEvents:output_type -> api_pb.Event
+	17, // [17:26] is the sub-list for method output_type
+	8,  // [8:17] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_api_proto_control_proto_init() }
+func file_api_proto_control_proto_init() {
+	if File_api_proto_control_proto != nil {
+		return
+	}
+	file_api_proto_control_proto_msgTypes[18].OneofWrappers = []any{
+		(*Event_PeerConnected)(nil),
+		(*Event_PeerDisconnected)(nil),
+		(*Event_MessageReceived)(nil),
+		(*Event_ContentAnnounced)(nil),
+		(*Event_HolePunch)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_proto_control_proto_rawDesc), len(file_api_proto_control_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   24,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_proto_control_proto_goTypes,
+		DependencyIndexes: file_api_proto_control_proto_depIdxs,
+		EnumInfos:         file_api_proto_control_proto_enumTypes,
+		MessageInfos:      file_api_proto_control_proto_msgTypes,
+	}.Build()
+	File_api_proto_control_proto = out.File
+	file_api_proto_control_proto_goTypes = nil
+	file_api_proto_control_proto_depIdxs = nil
+}
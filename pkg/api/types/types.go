@@ -2,6 +2,7 @@ package types
 
 import (
 	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 
 	"github.com/google/uuid"
@@ -23,6 +24,51 @@ func ToPeerID(b []byte) (PeerID, error) {
 	return id, nil
 }
 
+func ToPeerPublicKey(b []byte) (PeerPublicKey, error) {
+	var pubKey PeerPublicKey
+	if len(b) != 32 {
+		return pubKey, fmt.Errorf("wrong length: %d", len(b))
+	}
+	copy(pubKey[:], b)
+	return pubKey, nil
+}
+
+func ToContentHash(b []byte) (ContentHash, error) {
+	var hash ContentHash
+	if len(b) != 32 {
+		return hash, fmt.Errorf("wrong length: %d", len(b))
+	}
+	copy(hash[:], b)
+	return hash, nil
+}
+
+// ParsePeerID decodes a hex encoded peer ID
+func ParsePeerID(s string) (PeerID, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return PeerID{}, fmt.Errorf("invalid peer id: %w", err)
+	}
+	return ToPeerID(b)
+}
+
+// ParsePeerPublicKey decodes a hex encoded Ed25519 public key
+func ParsePeerPublicKey(s string) (PeerPublicKey, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return PeerPublicKey{}, fmt.Errorf("invalid public key: %w", err)
+	}
+	return ToPeerPublicKey(b)
+}
+
+// ParseContentHash decodes a hex encoded content root hash
+func ParseContentHash(s string) (ContentHash, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return ContentHash{}, fmt.Errorf("invalid content hash: %w", err)
+	}
+	return ToContentHash(b)
+}
+
 func PeerPubKeyToID(pubKey PeerPublicKey) PeerID {
 	return sha256.Sum256(pubKey[:])
 }
@@ -0,0 +1,154 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const DefaultBufferSize = 256
+
+// DropPolicy decides what happens when a subscriber does not keep up.
+// Publish never blocks the network code: once a subscription buffer is full
+// an event is dropped for that subscriber only and counted in Dropped.
+type DropPolicy int
+
+const (
+	// DropNewest discards the event being published and keeps the buffered ones
+	DropNewest DropPolicy = iota
+	// DropOldest evicts the oldest buffered event to make room for the new one
+	DropOldest
+)
+
+// Filter reports whether a subscriber wants the event. A nil Filter accepts everything.
+type Filter func(Event) bool
+
+// OfType accepts only events of the given types
+func OfType(types ...Type) Filter {
+	return func(e Event) bool {
+		for _, t := range types {
+			if e.Type() == t {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[uint64]*Subscription
+	nextID uint64
+	closed bool
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subs: make(map[uint64]*Subscription),
+	}
+}
+
+// Subscribe registers a new subscriber. bufSize <= 0 uses DefaultBufferSize.
+func (b *Bus) Subscribe(bufSize int, policy DropPolicy, filter Filter) *Subscription {
+	if bufSize <= 0 {
+		bufSize = DefaultBufferSize
+	}
+
+	ch := make(chan Event, bufSize)
+	sub := &Subscription{
+		C:      ch,
+		ch:     ch,
+		bus:    b,
+		policy: policy,
+		filter: filter,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return sub
+	}
+	b.nextID++
+	sub.id = b.nextID
+	b.subs[sub.id] = sub
+
+	return sub
+}
+
+// Publish delivers e to every matching subscriber without blocking
+func (b *Bus) Publish(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, sub := range b.subs {
+		if sub.filter != nil && !sub.filter(e) {
+			continue
+		}
+		sub.deliver(e)
+	}
+}
+
+// Close ends all subscriptions. Publishing after Close is a no-op.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+
+	for id, sub := range b.subs {
+		close(sub.ch)
+		delete(b.subs, id)
+	}
+}
+
+func (b *Bus) unsubscribe(id uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if sub, ok := b.subs[id]; ok {
+		close(sub.ch)
+		delete(b.subs, id)
+	}
+}
+
+type Subscription struct {
+	// C receives the events. It is closed by Close or when the bus shuts down.
+	C <-chan Event
+
+	id      uint64
+	ch      chan Event
+	bus     *Bus
+	policy  DropPolicy
+	filter  Filter
+	dropped atomic.Uint64
+}
+
+// Dropped returns how many events were lost because the buffer was full
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+func (s *Subscription) Close() {
+	s.bus.unsubscribe(s.id)
+}
+
+func (s *Subscription) deliver(e Event) {
+	select {
+	case s.ch <- e:
+		return
+	default:
+	}
+
+	if s.policy == DropOldest {
+		select {
+		case <-s.ch:
+		default:
+		}
+		select {
+		case s.ch <- e:
+		default:
+		}
+	}
+	s.dropped.Add(1)
+}
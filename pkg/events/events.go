@@ -0,0 +1,94 @@
+package events
+
+import (
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+)
+
+type Type int
+
+const (
+	TypeUnknown Type = iota
+	TypePeerConnected
+	TypePeerDisconnected
+	TypeMessageReceived
+	// 4 was download progress, the values are mirrored by the control API
+	_
+	TypeContentAnnounced
+	TypeHolePunch
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypePeerConnected:
+		return "peer_connected"
+	case TypePeerDisconnected:
+		return "peer_disconnected"
+	case TypeMessageReceived:
+		return "message_received"
+	case TypeContentAnnounced:
+		return "content_announced"
+	case TypeHolePunch:
+		return "hole_punch"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is implemented by every event published on the Bus
+type Event interface {
+	Type() Type
+}
+
+type PeerConnected struct {
+	PeerID   types.PeerID
+	PubKey   types.PeerPublicKey
+	Addr     string
+	Outbound bool
+	Time     time.Time
+}
+
+func (PeerConnected) Type() Type { return TypePeerConnected }
+
+type PeerDisconnected struct {
+	PeerID types.PeerID
+	Reason string
+	Time   time.Time
+}
+
+func (PeerDisconnected) Type() Type { return TypePeerDisconnected }
+
+// MessageReceived carries a chat message that was addressed to this node and decrypted
+type MessageReceived struct {
+	MessageID types.MessageID
+	From      types.PeerPublicKey
+	ViaPeer   types.PeerID
+	Text      []byte
+	SentAt    time.Time
+}
+
+func (MessageReceived) Type() Type { return TypeMessageReceived }
+
+// ContentAnnounced is published when this node shares content and when a
+// content announcement of another peer arrives by gossip
+type ContentAnnounced struct {
+	Hash   types.ContentHash
+	PeerID types.PeerID
+	Size   uint64
+}
+
+func (ContentAnnounced) Type() Type { return TypeContentAnnounced }
+
+// HolePunch reports a hole punch this node started through the peer Via,
+// Addr is the address that answered
+type HolePunch struct {
+	PeerID   types.PeerID
+	Via      types.PeerID
+	Addr     string
+	Success  bool
+	Error    string
+	Duration time.Duration
+}
+
+func (HolePunch) Type() Type { return TypeHolePunch }
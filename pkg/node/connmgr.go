@@ -0,0 +1,37 @@
+package node
+
+import (
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/config"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/events"
+)
+
+// chatTag protects the connections to the peers we exchange messages with
+const chatTag = "chat"
+
+// applyConnLimits sets network.max_connections and the conn_manager
+// watermarks, both capped by the profile
+func (n *Node) applyConnLimits(cfg *config.AppConfig) {
+	maxConns := n.Profile.maxConnections(cfg.Network.MaxConnections)
+	high := min(cfg.ConnManager.HighWater, maxConns)
+	low := min(cfg.ConnManager.LowWater, high)
+	n.Swarm.SetMaxConnections(maxConns)
+	n.Swarm.ConnManager().SetLimits(low, high, time.Duration(cfg.ConnManager.GracePeriodSeconds)*time.Second)
+}
+
+// protectChatPartner keeps the connections to a peer we message with, the
+// protection is kept across reconnects
+func (n *Node) protectChatPartner(pubKey types.PeerPublicKey) {
+	n.Swarm.ConnManager().Protect(types.PeerPubKeyToID(pubKey), chatTag)
+}
+
+// watchChatPartners protects the senders of incoming messages until the bus closes
+func (n *Node) watchChatPartners() {
+	sub := n.Events.Subscribe(0, events.DropOldest, events.OfType(events.TypeMessageReceived))
+	defer sub.Close()
+	for e := range sub.C {
+		n.protectChatPartner(e.(events.MessageReceived).From)
+	}
+}
@@ -0,0 +1,41 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestChatProtectionSurvivesReconnect messages from a to b, both keep the
+// other protected after the connection dropped and came back
+func TestChatProtectionSurvivesReconnect(t *testing.T) {
+	a := startTestNode(t, nil)
+	b := startTestNode(t, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := a.Connect(ctx, b.ListenAddrs()[0]); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 5*time.Second, "the connection", func() bool { return b.Swarm.ThisIsActivePeer(a.ID) })
+	if err := a.SendMessage(ctx, b.PubKey, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 5*time.Second, "b to protect a", func() bool { return b.Swarm.ConnManager().IsProtected(a.ID) })
+
+	if err := a.Disconnect(b.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 5*time.Second, "the disconnect", func() bool { return !b.Swarm.ThisIsActivePeer(a.ID) })
+	if !a.Swarm.ConnManager().IsProtected(b.ID) || !b.Swarm.ConnManager().IsProtected(a.ID) {
+		t.Fatal("protection dropped on disconnect")
+	}
+
+	if _, err := a.Connect(ctx, b.ListenAddrs()[0]); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 5*time.Second, "the reconnect", func() bool { return b.Swarm.ThisIsActivePeer(a.ID) })
+	if !a.Swarm.ConnManager().IsProtected(b.ID) || !b.Swarm.ConnManager().IsProtected(a.ID) {
+		t.Fatal("protection dropped after the reconnect")
+	}
+}
@@ -0,0 +1,36 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/config"
+	"github.com/DmytroBuzhylov/echofog-core/internal/crypto"
+)
+
+// secretSource selects the password source configured in identity.password_source
+func secretSource(cfg *config.AppConfig, prompt crypto.PromptSecret) (crypto.SecretSource, error) {
+	id := cfg.Identity
+
+	switch id.PasswordSource {
+	case "", config.PasswordSourcePrompt:
+		return prompt, nil
+	case config.PasswordSourceEnv:
+		name := id.PasswordEnv
+		if name == "" {
+			name = config.DefaultPasswordEnv
+		}
+		return crypto.EnvSecret(name), nil
+	case config.PasswordSourceFile:
+		if id.PasswordFile == "" {
+			return nil, errors.New("identity.password_file is required for the file password source")
+		}
+		return crypto.FileSecret(id.PasswordFile), nil
+	case config.PasswordSourceFD:
+		return crypto.FDSecret(id.PasswordFD), nil
+	case config.PasswordSourceDev:
+		return crypto.DevSecret{}, nil
+	default:
+		return nil, fmt.Errorf("unknown identity.password_source %q", id.PasswordSource)
+	}
+}
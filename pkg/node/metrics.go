@@ -0,0 +1,124 @@
+package node
+
+import (
+	"github.com/DmytroBuzhylov/echofog-core/internal/metrics"
+	"github.com/DmytroBuzhylov/echofog-core/internal/network"
+)
+
+// registerMetrics exposes the counters of the running components, it is
+// called once by the first Start that creates them. The funcs read the
+// components on every scrape, so they follow a retried Start.
+func (n *Node) registerMetrics() {
+	m := n.Metrics
+
+	m.GaugeFunc("echofog_peers_active", "Peers currently connected.", func() float64 {
+		return float64(n.Swarm.PeerCount())
+	})
+	m.GaugeFunc("echofog_peers_max", "Configured network.max_connections.", func() float64 {
+		return float64(n.Swarm.MaxConnections())
+	})
+	m.CounterFunc("echofog_connections_trimmed_total", "Connections closed by the connection manager above conn_manager.high_water.", func() float64 {
+		return float64(n.Swarm.ConnManager().Trimmed())
+	})
+	m.CounterVecFunc("echofog_dials_total", "Outbound dials by result.", func() []metrics.Sample {
+		ok, failed := n.Transport.DialStats()
+		return []metrics.Sample{
+			{Labels: []metrics.Label{{Name: "result", Value: "success"}}, Value: float64(ok)},
+			{Labels: []metrics.Label{{Name: "result", Value: "failure"}}, Value: float64(failed)},
+		}
+	})
+	m.CounterFunc("echofog_protocol_violations_total", "Connections closed because the peer broke the framing.", func() float64 {
+		return float64(n.Swarm.Violations())
+	})
+	m.GaugeFunc("echofog_relay_reservations", "Peers holding a reservation on this relay.", func() float64 {
+		reservations, _, _ := n.Swarm.RelayStats()
+		return float64(reservations)
+	})
+	m.GaugeFunc("echofog_relay_circuits", "Circuits this node relays.", func() float64 {
+		_, circuits, _ := n.Swarm.RelayStats()
+		return float64(circuits)
+	})
+	m.CounterFunc("echofog_relay_bytes_total", "Bytes relayed through circuits.", func() float64 {
+		_, _, bytes := n.Swarm.RelayStats()
+		return float64(bytes)
+	})
+	m.CounterVecFunc("echofog_hole_punches_total", "Hole punches started by this node, by result.", func() []metrics.Sample {
+		ok, failed := n.Swarm.HolePunchStats()
+		return []metrics.Sample{
+			{Labels: []metrics.Label{{Name: "result", Value: "success"}}, Value: float64(ok)},
+			{Labels: []metrics.Label{{Name: "result", Value: "failure"}}, Value: float64(failed)},
+		}
+	})
+	m.GaugeVecFunc("echofog_nat_mapping", "NAT mapping behaviour found through STUN, 1 for the current one.", func() []metrics.Sample {
+		return []metrics.Sample{
+			{Labels: []metrics.Label{{Name: "mapping", Value: n.NAT().Mapping.String()}}, Value: 1},
+		}
+	})
+	m.GaugeFunc("echofog_public_addrs", "Addresses the node advertises to peers.", func() float64 {
+		return float64(len(n.PublicAddrs()))
+	})
+
+	m.GaugeFunc("echofog_dispatcher_queue_depth", "Packets waiting for a dispatcher worker.", func() float64 {
+		return float64(n.Dispatcher.QueueLen())
+	})
+	m.CounterFunc("echofog_dispatcher_dropped_total", "Packets dropped because the dispatcher queue was full.", func() float64 {
+		return float64(n.Dispatcher.Dropped())
+	})
+
+	m.GaugeFunc("echofog_gossip_seen_cache_size", "Message IDs in the gossip seen-cache.", func() float64 {
+		return float64(n.Gossip.SeenCacheSize())
+	})
+	m.CounterFunc("echofog_gossip_received_total", "Gossip messages received.", func() float64 {
+		return float64(n.Gossip.Received())
+	})
+	m.CounterFunc("echofog_gossip_duplicates_total", "Gossip messages received that were already seen.", func() float64 {
+		return float64(n.Gossip.Duplicates())
+	})
+
+	m.CounterVecFunc("echofog_network_bytes_total", "Framed bytes by message type and direction.", func() []metrics.Sample {
+		in, out := n.Transport.Traffic().Snapshot()
+		samples := make([]metrics.Sample, 0, len(in)+len(out))
+		samples = appendTraffic(samples, "in", in)
+		samples = appendTraffic(samples, "out", out)
+		return samples
+	})
+
+	m.GaugeVecFunc("echofog_dag_store", "Merkle DAG nodes stored locally and their size in bytes.", func() []metrics.Sample {
+		nodes, size, err := n.DHT.StoreSize()
+		if err != nil {
+			return nil
+		}
+		return []metrics.Sample{
+			{Labels: []metrics.Label{{Name: "unit", Value: "nodes"}}, Value: float64(nodes)},
+			{Labels: []metrics.Label{{Name: "unit", Value: "bytes"}}, Value: float64(size)},
+		}
+	})
+
+	m.GaugeFunc("echofog_transfer_sessions_active", "Content transfer sessions in progress.", func() float64 {
+		return float64(n.Swarm.GetSessionManager().Len())
+	})
+	m.CounterVecFunc("echofog_transfer_bytes_total", "Bytes moved by transfer sessions, rate() gives the throughput.", func() []metrics.Sample {
+		in, out := n.Swarm.GetSessionManager().Transferred()
+		return []metrics.Sample{
+			{Labels: []metrics.Label{{Name: "direction", Value: "in"}}, Value: float64(in)},
+			{Labels: []metrics.Label{{Name: "direction", Value: "out"}}, Value: float64(out)},
+		}
+	})
+}
+
+func appendTraffic(samples []metrics.Sample, direction string, counts map[network.MessageType]uint64) []metrics.Sample {
+	for msgType := network.TypeUnknown; msgType <= network.TypeRelayData; msgType++ {
+		bytes, ok := counts[msgType]
+		if !ok {
+			continue
+		}
+		samples = append(samples, metrics.Sample{
+			Labels: []metrics.Label{
+				{Name: "type", Value: msgType.String()},
+				{Name: "direction", Value: direction},
+			},
+			Value: float64(bytes),
+		})
+	}
+	return samples
+}
@@ -0,0 +1,48 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/config"
+	"github.com/DmytroBuzhylov/echofog-core/internal/network"
+)
+
+// failingListen is a transport whose Listen fails, Start fails after it
+// registered the metrics
+type failingListen struct {
+	network.Transport
+}
+
+func (failingListen) Listen(context.Context) error {
+	return errors.New("listen refused")
+}
+
+func TestStartRetryRegistersMetricsOnce(t *testing.T) {
+	cfg := config.DefaultConfigIn(t.TempDir())
+	cfg.Identity.PasswordSource = config.PasswordSourceDev
+	n, err := NewNode(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem, err := network.NewMemoryNetwork().NewTransport("", n.PrivKey, network.Protocol{}, n.Logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Transport = failingListen{mem}
+
+	for i := range 2 {
+		if err := n.Start(context.Background()); err == nil || !strings.Contains(err.Error(), "listen refused") {
+			t.Fatalf("start %d returned %v, want the listen error", i, err)
+		}
+	}
+	var out strings.Builder
+	if _, err := n.Metrics.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	if c := strings.Count(out.String(), "# TYPE echofog_peers_active "); c != 1 {
+		t.Fatalf("echofog_peers_active exposed %d times", c)
+	}
+}
@@ -1,24 +1,40 @@
 package node
 
 import (
+	"bytes"
 	"context"
+	"crypto/ed25519"
+	"crypto/tls"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/DmytroBuzhylov/echofog-core/internal/config"
 	"github.com/DmytroBuzhylov/echofog-core/internal/crypto"
 	"github.com/DmytroBuzhylov/echofog-core/internal/dispatcher"
 	"github.com/DmytroBuzhylov/echofog-core/internal/identity"
 	"github.com/DmytroBuzhylov/echofog-core/internal/logger"
+	"github.com/DmytroBuzhylov/echofog-core/internal/metrics"
+	"github.com/DmytroBuzhylov/echofog-core/internal/nat"
 	"github.com/DmytroBuzhylov/echofog-core/internal/network"
 	"github.com/DmytroBuzhylov/echofog-core/internal/p2p"
+	"github.com/DmytroBuzhylov/echofog-core/internal/p2p/dht"
 	"github.com/DmytroBuzhylov/echofog-core/internal/p2p/gossip"
+	internal_pb "github.com/DmytroBuzhylov/echofog-core/internal/proto"
 	"github.com/DmytroBuzhylov/echofog-core/internal/services"
+	"github.com/DmytroBuzhylov/echofog-core/internal/services/content"
 	"github.com/DmytroBuzhylov/echofog-core/internal/services/discovery"
 	"github.com/DmytroBuzhylov/echofog-core/internal/services/messenger"
 	"github.com/DmytroBuzhylov/echofog-core/internal/storage"
 	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/events"
 	"github.com/dgraph-io/badger/v4"
 )
 
@@ -27,35 +43,89 @@ type Node struct {
 	PrivKey types.PeerPrivateKey
 	PubKey  types.PeerPublicKey
 
-	Cfg        *config.AppConfig
-	Storage    storage.Storage
-	Transport  *network.QuicTransport
+	Cfg     *config.AppConfig
+	Profile Profile
+	Storage storage.Storage
+	// Transport is created by Start from the config unless it was set before
+	Transport  network.Transport
 	Dispatcher *dispatcher.Dispatcher
 	Swarm      *p2p.Swarm
 
+	DHT       *dht.DHT
+	Gossip    *gossip.Manager
 	Discovery *discovery.DiscoveryService
 	Messenger *messenger.MessageService
+	Content   *content.ContentService
 
 	Logger  *slog.Logger
 	LogChan chan logger.LogEntry
+	Logs    *logger.Hub
+	Events  *events.Bus
+	Metrics *metrics.Registry
+
+	// PasswordPrompt is used when identity.password_source is "prompt"
+	PasswordPrompt crypto.PromptSecret
+
+	logLevel *slog.LevelVar
+	// metricsOnce keeps a retried Start from registering the metrics twice
+	metricsOnce sync.Once
+	// cfgMu guards the Cfg fields that Reload changes at runtime
+	cfgMu sync.Mutex
+
+	natMu     sync.Mutex
+	natResult nat.Result
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopOnce sync.Once
 }
 
-func NewNode(cfg *config.AppConfig) *Node {
+// NewNode prepares a node without touching the disk or the network. Records
+// are written to log, if it is not nil, as well as to LogChan and Logs.
+// Nodes share no state, a process can run several with different configs.
+func NewNode(cfg *config.AppConfig, log *slog.Logger) (*Node, error) {
+	profile, err := ProfileFor(cfg.Node.Role)
+	if err != nil {
+		return nil, err
+	}
+
 	logChan := make(chan logger.LogEntry, 100)
+	logs := logger.NewHub()
+
+	logLevel := new(slog.LevelVar)
+	level, _ := config.ParseLogLevel(cfg.Log.Level)
+	logLevel.Set(level)
 
-	handler := logger.NewChannelHandler(logChan, &slog.HandlerOptions{
-		Level: slog.LevelDebug,
+	var handler slog.Handler = logger.NewChannelHandler(logChan, logs, &slog.HandlerOptions{
+		Level: logLevel,
 	})
+	if log != nil {
+		handler = logger.NewTeeHandler(handler, log.Handler())
+	}
 
 	return &Node{
-		Cfg:     cfg,
-		LogChan: logChan,
-		Logger:  slog.New(handler),
-	}
+		Cfg:      cfg,
+		Profile:  profile,
+		LogChan:  logChan,
+		Logs:     logs,
+		Logger:   slog.New(handler),
+		Events:   events.NewBus(),
+		Metrics:  metrics.NewRegistry(),
+		logLevel: logLevel,
+	}, nil
 }
 
-func (n *Node) Start(ctx context.Context, password string) error {
-	n.Logger.Info("EchoFog Core is initializing...", "version", config.CurrentProtocolVersion)
+// LoadIdentity opens the storage and unlocks the node identity without
+// touching the network. Start calls it implicitly when needed.
+func (n *Node) LoadIdentity() error {
+	if n.Storage != nil {
+		return nil
+	}
+
+	secret, err := secretSource(n.Cfg, n.PasswordPrompt)
+	if err != nil {
+		return err
+	}
 
 	opts := badger.DefaultOptions(n.Cfg.Storage.DatabasePath)
 	opts.Logger = nil
@@ -65,59 +135,111 @@ func (n *Node) Start(ctx context.Context, password string) error {
 		return fmt.Errorf("failed to open badger db: %w", err)
 	}
 
-	n.Storage = storage.NewBadgerStorage(db)
+	store := storage.NewBadgerStorage(db)
+	n.Storage = store
 	n.Logger.Info("Storage initialized", "path", n.Cfg.Storage.DatabasePath)
 
-	keyStore := crypto.NewSecureKeyStore([]byte(password), n.Storage)
+	if _, ok := secret.(crypto.DevSecret); ok {
+		n.Logger.Warn("Identity key is stored UNENCRYPTED (dev password source), do not use in production")
+	}
+
+	keyStore := crypto.NewSecureKeyStore(secret, n.Storage)
 	privKeyEd, err := keyStore.GetOrGenerateKeys()
 	if err != nil {
+		n.Storage = nil
+		store.Close()
 		return fmt.Errorf("auth failed: %w", err)
 	}
 
 	n.PrivKey = types.PeerPrivateKey(privKeyEd)
 	n.PubKey = types.PeerPrivateKeyToPublic(n.PrivKey)
 	n.ID = types.PeerPubKeyToID(n.PubKey)
+	n.DHT = dht.NewDHT(n.Storage, n.PubKey)
 
 	n.Logger.Info("Identity unlocked", "peer_id", hex.EncodeToString(n.ID[:]))
 
+	return nil
+}
+
+func (n *Node) Start(ctx context.Context) (err error) {
+	n.Logger.Info("EchoFog Core is initializing...", "version", config.CurrentProtocolVersion, "role", n.Cfg.Node.Role)
+
+	if err := n.LoadIdentity(); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			n.Stop(context.Background())
+		}
+	}()
+	privKeyEd := ed25519.PrivateKey(n.PrivKey[:])
+
+	ctx, n.cancel = context.WithCancel(ctx)
+	n.ctx = ctx
+
 	tlsConfig, err := crypto.GenerateTLSConfig(privKeyEd)
 	if err != nil {
 		return fmt.Errorf("tls config failed: %w", err)
 	}
 
-	n.Dispatcher = dispatcher.NewDispatcher(ctx)
+	if n.Transport == nil {
+		if n.Transport, err = n.newTransport(tlsConfig); err != nil {
+			return err
+		}
+	}
 
-	n.Transport = network.NewQUICTransport(
-		n.Cfg.Network.ListenAddr,
-		tlsConfig,
-		network.GetQuicConfig(),
-		n.PrivKey,
-		n.Cfg.Network.ProtocolVersion,
-	)
+	n.Dispatcher = dispatcher.NewDispatcher(ctx, n.Logger)
 
 	n.Swarm = p2p.NewSwarm(
 		n.ID,
 		n.PrivKey,
 		n.Dispatcher,
-		n.Transport.ConnChan(),
+		n.Transport,
 		n.Storage,
 		n.Cfg,
+		n.Events,
+		n.Logger,
 	)
+	n.applyConnLimits(n.Cfg)
+	if n.Profile.relays(n.Cfg) {
+		relayCfg := n.Cfg.Relay
+		n.Swarm.EnableRelay(p2p.RelayLimits{
+			MaxReservations:    relayCfg.MaxReservations,
+			MaxCircuits:        relayCfg.MaxCircuits,
+			MaxCircuitsPerPeer: relayCfg.MaxCircuitsPerPeer,
+			MaxCircuitBytes:    int64(relayCfg.MaxCircuitBytes),
+			MaxCircuitDuration: time.Duration(relayCfg.MaxCircuitSeconds) * time.Second,
+			MaxPeerBytes:       int64(relayCfg.MaxPeerBytes),
+		})
+	}
 
 	eng, err := crypto.NewEngine(privKeyEd)
 	if err != nil {
 		return fmt.Errorf("crypto engine init failed: %w", err)
 	}
 
-	gsp := gossip.NewManager(n.Swarm)
-
-	n.Discovery = discovery.NewDiscoveryService(n.Storage, gsp, n.Swarm, n.PubKey)
-
-	n.Messenger = messenger.NewMessageService(n.PrivKey, eng, n.Storage, gsp)
+	n.Gossip = gossip.NewManager(n.Swarm, n.Profile.ForwardGossip)
+	n.Dispatcher.SetFilter(func(msg *internal_pb.MessageData, from types.PeerID) bool {
+		if !gossip.Gossiped(msg) {
+			return true
+		}
+		toSelf := bytes.Equal(msg.GetTargetId(), n.PubKey[:])
+		return n.Gossip.HandleIncoming(gossip.TypeOf(msg), msg, from, toSelf)
+	})
 
-	svcList := []services.Service{
-		n.Discovery,
-		n.Messenger,
+	var svcList []services.Service
+	if n.Profile.Discovery {
+		n.Discovery = discovery.NewDiscoveryService(n.Storage, n.Gossip, n.Swarm, n.PubKey, n.PublicAddrs)
+		svcList = append(svcList, n.Discovery)
+	}
+	if n.Profile.Messaging {
+		n.Messenger = messenger.NewMessageService(n.PrivKey, eng, n.Storage, n.Gossip, n.Events, n.Logger)
+		svcList = append(svcList, n.Messenger)
+		go n.watchChatPartners()
+	}
+	if n.Profile.StoreContent {
+		n.Content = content.NewContentService(n.Storage, n.Swarm, n.DHT, n.Gossip, n.PubKey, n.Events, n.Logger)
+		svcList = append(svcList, n.Content)
 	}
 
 	for _, s := range svcList {
@@ -126,14 +248,22 @@ func (n *Node) Start(ctx context.Context, password string) error {
 		}
 	}
 
+	n.metricsOnce.Do(n.registerMetrics)
+
 	n.Logger.Info("Starting network stack...")
 
-	go n.Dispatcher.Start()
+	n.Dispatcher.Start()
 
-	if err := n.Transport.ListenEarly(ctx); err != nil {
+	if err := n.Transport.Listen(ctx); err != nil {
 		return fmt.Errorf("transport listen failed: %w", err)
 	}
 
+	n.cfgMu.Lock()
+	bootstrap := slices.Clone(n.Cfg.Network.BootstrapNodes)
+	n.cfgMu.Unlock()
+	go n.connectBootstrapNodes(ctx, bootstrap)
+	go n.detectNAT(ctx, n.Cfg.Network.StunServers)
+
 	localIP, _ := identity.GetLocalIP()
 	outboundIP, _ := identity.GetOutboundIP()
 	n.Logger.Info("Node started successfully",
@@ -145,14 +275,164 @@ func (n *Node) Start(ctx context.Context, password string) error {
 	return nil
 }
 
+// Reload applies the settings that are safe to change on a running node:
+// network.bootstrap_nodes (new entries are dialed), network.max_connections,
+// conn_manager and log.level. Other changed keys are logged and need a restart.
+func (n *Node) Reload(cfg *config.AppConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	level, _ := config.ParseLogLevel(cfg.Log.Level)
+
+	n.cfgMu.Lock()
+	old := *n.Cfg
+	n.Cfg.Network.BootstrapNodes = cfg.Network.BootstrapNodes
+	n.Cfg.Network.MaxConnections = cfg.Network.MaxConnections
+	n.Cfg.ConnManager = cfg.ConnManager
+	n.Cfg.Log.Level = cfg.Log.Level
+	pending := config.Changed(n.Cfg, cfg)
+	n.cfgMu.Unlock()
+
+	n.logLevel.Set(level)
+
+	var added []string
+	for _, addr := range cfg.Network.BootstrapNodes {
+		if !slices.Contains(old.Network.BootstrapNodes, addr) {
+			added = append(added, addr)
+		}
+	}
+	if len(added) > 0 && n.ctx != nil {
+		go n.connectBootstrapNodes(n.ctx, added)
+	}
+
+	if n.Swarm != nil {
+		n.applyConnLimits(cfg)
+	}
+
+	n.Logger.Info("Config reloaded", "changed", strings.Join(config.Changed(&old, n.Cfg), ","))
+	if len(pending) > 0 {
+		n.Logger.Warn("Config changes need a restart", "keys", strings.Join(pending, ","))
+	}
+	return nil
+}
+
 func (n *Node) GetLogChannel() <-chan logger.LogEntry {
 	return n.LogChan
 }
 
-func (n *Node) Stop() {
-	if n.Storage != nil {
-		// n.Storage.Close()
+// SubscribeLogs returns a copy of the log stream for an additional consumer,
+// call the returned func to unsubscribe
+func (n *Node) SubscribeLogs(bufSize int) (<-chan logger.LogEntry, func()) {
+	return n.Logs.Subscribe(bufSize)
+}
+
+// Subscribe returns a stream of node events matching filter (nil for all).
+// Events are dropped for this subscriber when its buffer of bufSize is full,
+// see events.DropPolicy.
+func (n *Node) Subscribe(bufSize int, policy events.DropPolicy, filter events.Filter) *events.Subscription {
+	return n.Events.Subscribe(bufSize, policy, filter)
+}
+
+// Stop shuts the node down in order: stop accepting connections, say goodbye
+// to peers, drain the dispatcher queue, close sessions and streams, close the
+// transport and flush the storage. Steps that do not finish before ctx
+// expires are reported in the returned error, the remaining steps still run.
+func (n *Node) Stop(ctx context.Context) error {
+	var errs []error
+
+	n.stopOnce.Do(func() {
+		if n.Transport != nil {
+			n.Transport.StopAccepting()
+		}
+		if n.Swarm != nil {
+			if err := n.Swarm.Close(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("swarm: %w", err))
+			}
+		}
+		if n.Dispatcher != nil {
+			if err := n.Dispatcher.Stop(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("dispatcher: %w", err))
+			}
+		}
+		if n.Transport != nil {
+			if err := n.Transport.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("transport: %w", err))
+			}
+		}
+		if n.cancel != nil {
+			n.cancel()
+		}
+		if n.Storage != nil {
+			if err := n.Storage.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("storage: %w", err))
+			}
+		}
+		n.Events.Close()
+
+		n.Logger.Info("Node stopped")
+	})
+
+	return errors.Join(errs...)
+}
+
+// newTransport creates QUIC, the TCP fallback if enabled and the WebSocket
+// transport. Transports created before a failure are closed.
+func (n *Node) newTransport(tlsConfig *tls.Config) (network.Transport, error) {
+	netCfg := n.Cfg.Network
+	protocol := n.Profile.protocol(n.Cfg)
+
+	quic, err := network.NewQUICTransport(
+		netCfg.ListenAddr,
+		tlsConfig,
+		network.GetQuicConfig(),
+		n.PrivKey,
+		protocol,
+		n.Logger,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("transport init failed: %w", err)
+	}
+	transports := []network.Transport{quic}
+	closeAll := func() {
+		for _, t := range transports {
+			t.Close()
+		}
+	}
+
+	if netCfg.EnableTCP {
+		tcp, err := network.NewTCPTransport(tcpListenAddr(netCfg.ListenAddr, quic.Addr()), n.PrivKey, protocol, n.Logger)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("tcp transport init failed: %w", err)
+		}
+		transports = append(transports, tcp)
+	}
+	// without websocket_addr the transport only dials ws:// peers
+	ws, err := network.NewWebSocketTransport(netCfg.WebSocketAddr, n.PrivKey, protocol, n.Logger)
+	if err != nil {
+		closeAll()
+		return nil, fmt.Errorf("websocket transport init failed: %w", err)
+	}
+	if addr := ws.Addr(); addr != nil {
+		n.Logger.Info("Accepting WebSocket peers", "addr", addr.String())
+	}
+	transports = append(transports, ws)
+	// relay:// addresses are dialed through circuits the swarm opens
+	transports = append(transports, network.NewRelayTransport(n.PrivKey, protocol, n.Logger))
+
+	return network.NewFallbackTransport(transports, n.Logger), nil
+}
+
+// tcpListenAddr is the host of listenAddr with the port QUIC got, so peers
+// reach both transports at the same address
+func tcpListenAddr(listenAddr string, quicAddr net.Addr) string {
+	host, _, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		host = ""
+	}
+	port := "0"
+	if udp, ok := quicAddr.(*net.UDPAddr); ok {
+		port = strconv.Itoa(udp.Port)
 	}
-	// n.Transport.Close()
-	n.Logger.Info("Node stopped")
+	return net.JoinHostPort(host, port)
 }
@@ -0,0 +1,248 @@
+package node
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/network"
+	"github.com/DmytroBuzhylov/echofog-core/internal/p2p/gossip"
+	"github.com/DmytroBuzhylov/echofog-core/internal/storage"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/api/types"
+	"github.com/DmytroBuzhylov/echofog-core/pkg/events"
+
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrNotStarted       = errors.New("node is not started")
+	ErrPeerNotConnected = errors.New("peer is not connected")
+	// ErrNoPeers means a message could not be handed to any peer
+	ErrNoPeers = gossip.ErrNoPeers
+)
+
+type PeerInfo struct {
+	ID       types.PeerID
+	PubKey   types.PeerPublicKey
+	Addr     string
+	Outbound bool
+	LastSeen time.Time
+	// Version and Caps were negotiated in the handshake, connected peers only
+	Version uint32
+	Caps    network.Capabilities
+	// Agent, Role and Callsign come from the identify exchange
+	Agent    string
+	Role     string
+	Callsign string
+}
+
+// Connect dials addr and waits for the authenticated peer to be registered in the swarm
+func (n *Node) Connect(ctx context.Context, addr string) (types.PeerID, error) {
+	if n.Transport == nil {
+		return types.PeerID{}, ErrNotStarted
+	}
+
+	peerID, err := n.Transport.Dial(ctx, addr)
+	if err != nil {
+		return types.PeerID{}, fmt.Errorf("dial %s: %w", addr, err)
+	}
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for !n.Swarm.ThisIsActivePeer(peerID) {
+		select {
+		case <-ctx.Done():
+			return peerID, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return peerID, nil
+}
+
+// Peers returns the currently connected peers
+func (n *Node) Peers() []PeerInfo {
+	if n.Swarm == nil {
+		return nil
+	}
+
+	peers := n.Swarm.GetAllPeers()
+	res := make([]PeerInfo, 0, len(peers))
+	for _, p := range peers {
+		info := PeerInfo{
+			ID:       p.ID(),
+			PubKey:   p.PubKey(),
+			Addr:     p.Addr(),
+			Outbound: p.IsOutbound(),
+			Version:  p.Version(),
+			Caps:     p.Capabilities(),
+		}
+		if meta, ok := n.Swarm.PeerMetadata(p.ID()); ok {
+			info.Agent, info.Role, info.Callsign = meta.Agent, meta.Role, meta.Callsign
+		}
+		res = append(res, info)
+	}
+	return res
+}
+
+// Disconnect closes the connection to the peer, it may reconnect later
+func (n *Node) Disconnect(peerID types.PeerID) error {
+	if n.Swarm == nil {
+		return ErrNotStarted
+	}
+	if !n.Swarm.ThisIsActivePeer(peerID) {
+		return ErrPeerNotConnected
+	}
+	n.Swarm.RemovePeer(peerID)
+	return nil
+}
+
+// Ban disconnects the peer and rejects its future connections
+func (n *Node) Ban(peerID types.PeerID) error {
+	if n.Swarm == nil {
+		return ErrNotStarted
+	}
+	n.Swarm.BanPeer(peerID)
+	n.Logger.Info("Peer banned", "peer_id", hex.EncodeToString(peerID[:]))
+	return nil
+}
+
+func (n *Node) Unban(peerID types.PeerID) error {
+	if n.Swarm == nil {
+		return ErrNotStarted
+	}
+	n.Swarm.UnBanPeer(peerID)
+	n.Logger.Info("Peer unbanned", "peer_id", hex.EncodeToString(peerID[:]))
+	return nil
+}
+
+// ListenAddrs returns the addresses the node accepts connections on
+func (n *Node) ListenAddrs() []string {
+	if n.Swarm != nil {
+		return n.Swarm.ListenAddrs()
+	}
+	if n.Transport == nil {
+		return nil
+	}
+	return network.ListenAddrs(n.Transport)
+}
+
+// KnownPeers returns peers saved from previous sessions. It only needs the storage to be open.
+func (n *Node) KnownPeers() ([]PeerInfo, error) {
+	if n.Storage == nil {
+		return nil, ErrNotStarted
+	}
+
+	values, err := n.Storage.FindValues([]byte("saved:peers:"))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]PeerInfo, 0, len(values))
+	for _, entry := range decodePeerEntries(values) {
+		pubKey, err := types.ToPeerPublicKey(entry.GetPubKey())
+		if err != nil {
+			continue
+		}
+		res = append(res, PeerInfo{
+			ID:       types.PeerPubKeyToID(pubKey),
+			PubKey:   pubKey,
+			Addr:     entry.GetLastKnownAddr(),
+			LastSeen: time.Unix(0, int64(entry.GetLastSeen())),
+		})
+	}
+	return res, nil
+}
+
+// SendMessage encrypts text for the recipient and gossips it to the network.
+// It returns once the message was written to the connections of the peers
+// it was passed to, or ErrNoPeers if there were none.
+func (n *Node) SendMessage(ctx context.Context, to types.PeerPublicKey, text []byte) error {
+	if !n.Profile.Messaging {
+		return fmt.Errorf("messaging: %w", ErrDisabledByRole)
+	}
+	if n.Messenger == nil {
+		return ErrNotStarted
+	}
+	n.protectChatPartner(to)
+	return n.Messenger.Send(ctx, to, text)
+}
+
+// Share stores the file in the local Merkle DAG, announces it to the
+// network and returns its root hash
+func (n *Node) Share(path string) (types.ContentHash, error) {
+	if !n.Profile.StoreContent {
+		return types.ContentHash{}, fmt.Errorf("content storage: %w", ErrDisabledByRole)
+	}
+	if n.DHT == nil {
+		return types.ContentHash{}, ErrNotStarted
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return types.ContentHash{}, err
+	}
+
+	key, err := n.DHT.SaveFile(data)
+	if err != nil {
+		return types.ContentHash{}, fmt.Errorf("save file: %w", err)
+	}
+
+	hash, err := types.ToContentHash(key)
+	if err != nil {
+		return types.ContentHash{}, err
+	}
+
+	n.Events.Publish(events.ContentAnnounced{
+		Hash:   hash,
+		PeerID: n.ID,
+		Size:   uint64(len(data)),
+	})
+	// before Start there are no peers to tell
+	if n.Content != nil {
+		n.Content.Announce(hash, uint64(len(data)))
+	}
+	return hash, nil
+}
+
+// Get reads content by its root hash from the local Merkle DAG. Content is
+// not fetched from peers, only what was shared on or copied to this node
+// can be read.
+func (n *Node) Get(hash types.ContentHash) ([]byte, error) {
+	if n.DHT == nil {
+		return nil, ErrNotStarted
+	}
+	return n.DHT.GetFileChunk(hash[:])
+}
+
+func (n *Node) connectBootstrapNodes(ctx context.Context, addrs []string) {
+	for _, addr := range addrs {
+		dialCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		_, err := n.Connect(dialCtx, addr)
+		cancel()
+		if err != nil {
+			n.Logger.Warn("Bootstrap node unreachable", "addr", addr, "err", err)
+			continue
+		}
+		n.Logger.Info("Connected to bootstrap node", "addr", addr)
+	}
+}
+
+func decodePeerEntries(values []interface{}) []*storage.PeerStoreEntry {
+	entries := make([]*storage.PeerStoreEntry, 0, len(values))
+	for _, v := range values {
+		raw, ok := v.([]byte)
+		if !ok {
+			continue
+		}
+		var entry storage.PeerStoreEntry
+		if err := proto.Unmarshal(raw, &entry); err != nil {
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+	return entries
+}
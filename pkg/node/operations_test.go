@@ -0,0 +1,52 @@
+package node
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/pkg/events"
+)
+
+// TestShareAnnouncesContent shares on a and expects the announcement on c,
+// which is only connected through b
+func TestShareAnnouncesContent(t *testing.T) {
+	a := startTestNode(t, nil)
+	b := startTestNode(t, nil)
+	c := startTestNode(t, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := a.Connect(ctx, b.ListenAddrs()[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Connect(ctx, c.ListenAddrs()[0]); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 5*time.Second, "both connections", func() bool { return b.Swarm.ThisIsActivePeer(a.ID) && c.Swarm.ThisIsActivePeer(b.ID) })
+
+	sub := c.Subscribe(1, events.DropNewest, events.OfType(events.TypeContentAnnounced))
+	defer sub.Close()
+
+	data := []byte("announced to the whole network")
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := a.Share(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-sub.C:
+		ev := e.(events.ContentAnnounced)
+		if ev.Hash != hash || ev.PeerID != a.ID || ev.Size != uint64(len(data)) {
+			t.Fatalf("announced %x by %x with %d bytes, want %x by %x with %d", ev.Hash[:4], ev.PeerID[:4], ev.Size, hash[:4], a.ID[:4], len(data))
+		}
+	case <-ctx.Done():
+		t.Fatal("no announcement arrived")
+	}
+}
@@ -0,0 +1,103 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/config"
+	"github.com/DmytroBuzhylov/echofog-core/internal/network"
+)
+
+var ErrDisabledByRole = errors.New("not available for this node role")
+
+// Profile decides which services a node registers with the dispatcher and
+// which limits apply, it is selected by node.role
+type Profile struct {
+	Role string
+	// Messaging runs the chat service, without it the node can neither send nor receive messages
+	Messaging bool
+	// Discovery answers peer exchange requests
+	Discovery bool
+	// ForwardGossip passes on gossip messages that are not addressed to this node
+	ForwardGossip bool
+	// StoreContent keeps shared content in the local Merkle DAG
+	StoreContent bool
+	// MaxConnections caps network.max_connections, 0 keeps the configured value
+	MaxConnections int
+	// Relay lets relay.enabled turn on relaying circuits for others, the
+	// relay role relays without it
+	Relay bool
+	// Capabilities are announced to peers in the handshake, CapRelay is
+	// added while the node relays
+	Capabilities network.Capabilities
+}
+
+var profiles = map[string]Profile{
+	config.RoleFull: {
+		Role:          config.RoleFull,
+		Messaging:     true,
+		Discovery:     true,
+		ForwardGossip: true,
+		StoreContent:  true,
+		Relay:         true,
+		Capabilities:  network.CapDatagrams,
+	},
+	// relay nodes carry traffic for others and keep nothing
+	config.RoleRelay: {
+		Role:          config.RoleRelay,
+		Discovery:     true,
+		ForwardGossip: true,
+		Relay:         true,
+		Capabilities:  network.CapDatagrams,
+	},
+	// seed nodes are well known entry points that only hand out peers
+	config.RoleSeed: {
+		Role:         config.RoleSeed,
+		Discovery:    true,
+		Capabilities: network.CapDatagrams,
+	},
+	// light nodes are leaves, for example on phones
+	config.RoleLight: {
+		Role:           config.RoleLight,
+		Messaging:      true,
+		Discovery:      true,
+		StoreContent:   true,
+		MaxConnections: 8,
+		Capabilities:   network.CapDatagrams,
+	},
+}
+
+func ProfileFor(role string) (Profile, error) {
+	p, ok := profiles[role]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown node role %q", role)
+	}
+	return p, nil
+}
+
+// relays reports whether the node carries circuits for peers that cannot
+// be dialed
+func (p Profile) relays(cfg *config.AppConfig) bool {
+	return p.Relay && (cfg.Relay.Enabled || p.Role == config.RoleRelay)
+}
+
+// protocol is what the node announces in the handshake
+func (p Profile) protocol(cfg *config.AppConfig) network.Protocol {
+	caps := p.Capabilities
+	if p.relays(cfg) {
+		caps |= network.CapRelay
+	}
+	return network.Protocol{
+		MinVersion:   config.MinProtocolVersion,
+		MaxVersion:   cfg.ProtocolVersion(),
+		Capabilities: caps,
+	}
+}
+
+// maxConnections applies the profile cap to the configured limit
+func (p Profile) maxConnections(configured int) int {
+	if p.MaxConnections > 0 && p.MaxConnections < configured {
+		return p.MaxConnections
+	}
+	return configured
+}
@@ -0,0 +1,105 @@
+package node
+
+import (
+	"context"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/nat"
+	"github.com/DmytroBuzhylov/echofog-core/internal/network"
+)
+
+const (
+	// natDetectInterval is how often the NAT is probed again, mappings
+	// change when the router restarts or the node moves
+	natDetectInterval = 30 * time.Minute
+	natDetectTimeout  = 15 * time.Second
+)
+
+// NAT returns what the last probe of network.stun_servers learned, the
+// mapping is unknown until one finished
+func (n *Node) NAT() nat.Result {
+	n.natMu.Lock()
+	defer n.natMu.Unlock()
+	return n.natResult
+}
+
+// PublicAddrs returns the addresses peers elsewhere can dial this node at:
+// the address STUN reported if the NAT keeps it for every destination, the
+// addresses peers confirmed they see us at, the listen addresses bound to a
+// specific IP and the relay addresses. Discovery advertises them.
+func (n *Node) PublicAddrs() []string {
+	var addrs []string
+	add := func(addr string) {
+		if !slices.Contains(addrs, addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	if res := n.NAT(); res.Mapping.Reachable() && res.Addr != nil {
+		add(res.Addr.String())
+	}
+	if n.Swarm != nil {
+		for _, addr := range n.Swarm.ObservedAddrs() {
+			add(addr)
+		}
+	}
+	for _, addr := range n.ListenAddrs() {
+		if dialable(addr) {
+			add(addr)
+		}
+	}
+	return addrs
+}
+
+// detectNAT probes the STUN servers through the QUIC socket, so the mapping
+// found is the one peers dial, until ctx is done
+func (n *Node) detectNAT(ctx context.Context, servers []string) {
+	quic, ok := network.AsQuicTransport(n.Transport)
+	if len(servers) == 0 || !ok {
+		return
+	}
+	conn := quic.PacketConn()
+
+	ticker := time.NewTicker(natDetectInterval)
+	defer ticker.Stop()
+	for {
+		probeCtx, cancel := context.WithTimeout(ctx, natDetectTimeout)
+		res, err := nat.Detect(probeCtx, conn, servers)
+		cancel()
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			n.Logger.Warn("NAT detection failed", "err", err)
+		default:
+			n.natMu.Lock()
+			changed := n.natResult.Mapping != res.Mapping || n.natResult.Addr.String() != res.Addr.String()
+			n.natResult = res
+			n.natMu.Unlock()
+			if changed {
+				n.Logger.Info("NAT detected", "mapping", res.Mapping.String(), "addr", res.Addr.String(), "servers", res.Servers)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// dialable reports whether addr names a host, wildcard listen addresses do not
+func dialable(addr string) bool {
+	if network.IsRelayAddr(addr) {
+		return true
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return host != "" && (ip == nil || !ip.IsUnspecified())
+}
@@ -0,0 +1,155 @@
+package node
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DmytroBuzhylov/echofog-core/internal/config"
+	"github.com/DmytroBuzhylov/echofog-core/internal/nat"
+)
+
+// startTestNode runs a node on a loopback QUIC socket without discovery,
+// APIs or STUN unless mod sets them
+func startTestNode(t *testing.T, mod func(*config.AppConfig)) *Node {
+	t.Helper()
+	cfg := config.DefaultConfigIn(t.TempDir())
+	cfg.Identity.PasswordSource = config.PasswordSourceDev
+	cfg.Network.ListenAddr = "127.0.0.1:0"
+	cfg.Network.BootstrapNodes = nil
+	cfg.Network.EnableMDNS = false
+	cfg.Network.EnableTCP = false
+	cfg.Network.StunServers = nil
+	cfg.API.ControlSocket = ""
+	cfg.API.HTTPAddr = ""
+	cfg.Metrics.ListenAddr = ""
+	if mod != nil {
+		mod(cfg)
+	}
+
+	n, err := NewNode(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		n.Stop(ctx)
+	})
+	return n
+}
+
+// waitFor polls cond until it holds or the timeout passes
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func shiftPort(t *testing.T, addr string, shift int) string {
+	t.Helper()
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := strconv.Atoi(port)
+	return net.JoinHostPort(host, strconv.Itoa((p+shift)&0xffff))
+}
+
+// TestNATDetectionThroughQUICSocket runs detection against two local
+// stand-in STUN servers that make the loopback look like a NAT keeping one
+// public port for every destination
+func TestNATDetectionThroughQUICSocket(t *testing.T) {
+	var servers []string
+	for range 2 {
+		s, err := nat.NewServer("127.0.0.1:0", 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		servers = append(servers, s.Addr().String())
+	}
+	n := startTestNode(t, func(cfg *config.AppConfig) { cfg.Network.StunServers = servers })
+
+	waitFor(t, 5*time.Second, "NAT detection", func() bool { return n.NAT().Mapping != nat.MappingUnknown })
+	res := n.NAT()
+	if res.Mapping != nat.MappingEndpointIndependent || res.Servers != 2 {
+		t.Fatalf("NAT = %v from %d servers, want %v from 2", res.Mapping, res.Servers, nat.MappingEndpointIndependent)
+	}
+	listen := n.ListenAddrs()[0]
+	if want := shiftPort(t, listen, 1000); res.Addr.String() != want {
+		t.Fatalf("mapped addr = %v, want %s", res.Addr, want)
+	}
+
+	// the mapped address comes first, then the listen address
+	if got, want := n.PublicAddrs(), []string{shiftPort(t, listen, 1000), listen}; !slices.Equal(got, want) {
+		t.Fatalf("PublicAddrs = %v, want %v", got, want)
+	}
+}
+
+// TestObservedAddrExchange checks that the address two peers report seeing
+// us at through identify is advertised, and one peer is not enough
+func TestObservedAddrExchange(t *testing.T) {
+	a := startTestNode(t, nil)
+	b := startTestNode(t, nil)
+	c := startTestNode(t, nil)
+	// a QUIC dial leaves from the listening socket, peers see the listen address
+	want := a.ListenAddrs()[0]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := a.Connect(ctx, b.ListenAddrs()[0]); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 5*time.Second, "identify with b", func() bool {
+		for _, p := range a.Peers() {
+			if p.ID == b.ID && p.Agent != "" {
+				return true
+			}
+		}
+		return false
+	})
+	if got := a.Swarm.ObservedAddrs(); len(got) != 0 {
+		t.Fatalf("advertised %v after a single report", got)
+	}
+
+	if _, err := a.Connect(ctx, c.ListenAddrs()[0]); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 5*time.Second, "confirmed observed address", func() bool {
+		return slices.Equal(a.Swarm.ObservedAddrs(), []string{want})
+	})
+	if got := a.PublicAddrs(); !slices.Equal(got, []string{want}) {
+		t.Fatalf("PublicAddrs = %v, want [%s]", got, want)
+	}
+}
+
+func TestDialable(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"203.0.113.7:4000", true},
+		{"[2001:db8::1]:4000", true},
+		{"example.com:4000", true},
+		{"0.0.0.0:4000", false},
+		{"[::]:4000", false},
+		{":4000", false},
+	}
+	for _, tt := range tests {
+		if got := dialable(tt.addr); got != tt.want {
+			t.Errorf("dialable(%q) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}